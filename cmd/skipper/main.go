@@ -11,6 +11,25 @@ documentation of the root skipper package.
 
 To see which built-in filters are available, see the skipper/filters
 package documentation.
+
+Custom filters, predicates and data clients can be loaded from Go plugins.
+Every .so file in the directory set with the -plugindir flag is loaded,
+and the following functions are looked up and called, when defined:
+
+    func InitFilter(args []string) (filters.Spec, error)
+    func InitPredicate(args []string) (routing.PredicateSpec, error)
+    func InitDataClient(args []string) (routing.DataClient, error)
+
+A plugin needs to define at least one of them. The filters and the
+predicates cannot use the names of the builtin ones. The arguments can be set
+with the -plugin flag, using the base name of the plugin file without the
+.so extension, and it can be used multiple times:
+
+    skipper -plugindir /usr/lib/skipper -plugin geoip,/var/lib/geoip.db -plugin ratelimit,100
+
+Plugins need to be built with the same version of Go and of the skipper
+packages as the skipper binary. When a plugin cannot be loaded, skipper
+fails to start.
 */
 package main
 
@@ -24,7 +43,10 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/zalando/skipper"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/oauth"
 	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/tracing"
 )
 
 const (
@@ -54,6 +76,8 @@ const (
 	oauthUrlUsage                  = "OAuth2 URL for Innkeeper authentication"
 	oauthCredentialsDirUsage       = "directory where oauth credentials are stored: client.json and user.json"
 	oauthScopeUsage                = "the whitespace separated list of oauth scopes"
+	oauthTokeninfoURLUsage         = "URL of the OAuth2 token introspection endpoint (RFC 7662), enables the oauthTokeninfo* filters"
+	oauthTokeninfoTimeoutUsage     = "timeout of the requests to the OAuth2 token introspection endpoint"
 	routesFileUsage                = "file containing static route definitions"
 	sourcePollTimeoutUsage         = "polling timeout of the routing data sources, in milliseconds"
 	insecureUsage                  = "flag indicating to ignore the verification of the TLS certificates of the backend services"
@@ -77,9 +101,17 @@ const (
 	debugEndpointUsage             = "when this address is set, skipper starts an additional listener returning the original and transformed requests"
 	certPathTLSUsage               = "the path on the local filesystem to the certificate file (including any intermediates)"
 	keyPathTLSUsage                = "the path on the local filesystem to the certificate's private key file"
+	clientCAPathTLSUsage           = "the path on the local filesystem to the CA bundle used to verify the client certificates, when the clients send one"
+	clientCertHostsTLSUsage        = "a host name requiring client certificates, and the path to the CA bundle used to verify them, in the format of <host>=<path>; can be used multiple times"
 	backendFlushIntervalUsage      = "flush interval for upgraded proxy connections"
 	experimentalUpgradeUsage       = "enable experimental feature to handle upgrade protocol requests"
+	otlpEndpointUsage              = "address of the OTLP/HTTP receiver, e.g. an OpenTelemetry collector or Jaeger, where the spans of the requests are sent, e.g. http://localhost:4318"
+	tracingServiceNameUsage        = "service name used in the exported spans"
+	tracingSampleRatioUsage        = "the ratio of the new traces that are sampled, between 0 and 1"
+	endpointQuarantineUsage        = "the period during which an endpoint of a backend with multiple endpoints is not selected after a failed connection"
 	versionUsage                   = "print Skipper version"
+	pluginDirUsage                 = "directory to load the filter, predicate and data client plugins (.so files) from"
+	pluginArgsUsage                = "arguments of a plugin, in the format of <name>,<arg1>,<arg2>; can be used multiple times"
 )
 
 var (
@@ -106,6 +138,8 @@ var (
 	oauthUrl                  string
 	oauthScope                string
 	oauthCredentialsDir       string
+	oauthTokeninfoURL         string
+	oauthTokeninfoTimeout     time.Duration
 	innkeeperAuthToken        string
 	innkeeperPreRouteFilters  string
 	innkeeperPostRouteFilters string
@@ -126,9 +160,17 @@ var (
 	debugListener             string
 	certPathTLS               string
 	keyPathTLS                string
+	clientCAPathTLS           string
+	clientCertHostsTLS        = make(hostCAs)
 	backendFlushInterval      time.Duration
 	experimentalUpgrade       bool
+	otlpEndpoint              string
+	tracingServiceName        string
+	tracingSampleRatio        float64
+	endpointQuarantine        time.Duration
 	printVersion              bool
+	pluginDir                 string
+	pluginArguments           = make(pluginArgs)
 )
 
 func init() {
@@ -150,6 +192,8 @@ func init() {
 	flag.StringVar(&oauthUrl, "oauth-url", "", oauthUrlUsage)
 	flag.StringVar(&oauthScope, "oauth-scope", "", oauthScopeUsage)
 	flag.StringVar(&oauthCredentialsDir, "oauth-credentials-dir", "", oauthCredentialsDirUsage)
+	flag.StringVar(&oauthTokeninfoURL, "oauth-tokeninfo-url", "", oauthTokeninfoURLUsage)
+	flag.DurationVar(&oauthTokeninfoTimeout, "oauth-tokeninfo-timeout", oauth.DefaultIntrospectionTimeout, oauthTokeninfoTimeoutUsage)
 	flag.StringVar(&innkeeperAuthToken, "innkeeper-auth-token", "", innkeeperAuthTokenUsage)
 	flag.StringVar(&innkeeperPreRouteFilters, "innkeeper-pre-route-filters", "", innkeeperPreRouteFiltersUsage)
 	flag.StringVar(&innkeeperPostRouteFilters, "innkeeper-post-route-filters", "", innkeeperPostRouteFiltersUsage)
@@ -170,10 +214,17 @@ func init() {
 	flag.StringVar(&debugListener, "debug-listener", "", debugEndpointUsage)
 	flag.StringVar(&certPathTLS, "tls-cert", "", certPathTLSUsage)
 	flag.StringVar(&keyPathTLS, "tls-key", "", keyPathTLSUsage)
+	flag.StringVar(&clientCAPathTLS, "tls-client-ca", "", clientCAPathTLSUsage)
+	flag.Var(clientCertHostsTLS, "tls-client-cert-host", clientCertHostsTLSUsage)
 	flag.DurationVar(&backendFlushInterval, "backend-flush-interval", defaultBackendFlushInterval, backendFlushIntervalUsage)
 	flag.BoolVar(&experimentalUpgrade, "experimental-upgrade", defaultExperimentalUpgrade, experimentalUpgradeUsage)
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", otlpEndpointUsage)
+	flag.StringVar(&tracingServiceName, "tracing-service-name", tracing.DefaultOTLPServiceName, tracingServiceNameUsage)
+	flag.Float64Var(&tracingSampleRatio, "tracing-sample-ratio", 1, tracingSampleRatioUsage)
+	flag.DurationVar(&endpointQuarantine, "endpoint-quarantine", loadbalancer.DefaultQuarantine, endpointQuarantineUsage)
 	flag.BoolVar(&printVersion, "version", false, versionUsage)
-	flag.Parse()
+	flag.StringVar(&pluginDir, "plugindir", "", pluginDirUsage)
+	flag.Var(pluginArguments, "plugin", pluginArgsUsage)
 }
 
 func parseDurationFlag(ds string) (time.Duration, error) {
@@ -191,6 +242,10 @@ func parseDurationFlag(ds string) (time.Duration, error) {
 }
 
 func main() {
+	// parsed here instead of init, so that the tests can use their own
+	// flags
+	flag.Parse()
+
 	if printVersion {
 		fmt.Printf(
 			"Skipper version %s (commit: %s)\n",
@@ -217,6 +272,11 @@ func main() {
 		os.Exit(2)
 	}
 
+	plugins, err := loadPlugins(pluginDir, pluginArguments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	options := skipper.Options{
 		Address:                   address,
 		EtcdUrls:                  eus,
@@ -235,6 +295,8 @@ func main() {
 		OAuthUrl:                  oauthUrl,
 		OAuthScope:                oauthScope,
 		OAuthCredentialsDir:       oauthCredentialsDir,
+		OAuthTokeninfoURL:         oauthTokeninfoURL,
+		OAuthTokeninfoTimeout:     oauthTokeninfoTimeout,
 		InnkeeperAuthToken:        innkeeperAuthToken,
 		InnkeeperPreRouteFilters:  innkeeperPreRouteFilters,
 		InnkeeperPostRouteFilters: innkeeperPostRouteFilters,
@@ -254,8 +316,17 @@ func main() {
 		DebugListener:             debugListener,
 		CertPathTLS:               certPathTLS,
 		KeyPathTLS:                keyPathTLS,
+		ClientCAPathTLS:           clientCAPathTLS,
+		ClientCertHostsTLS:        clientCertHostsTLS,
 		BackendFlushInterval:      backendFlushInterval,
 		ExperimentalUpgrade:       experimentalUpgrade,
+		OTLPEndpoint:              otlpEndpoint,
+		TracingServiceName:        tracingServiceName,
+		TracingSampleRatio:        tracingSampleRatio,
+		EndpointQuarantine:        endpointQuarantine,
+		CustomFilters:             plugins.filters,
+		CustomPredicates:          plugins.predicates,
+		CustomDataClients:         plugins.dataClients,
 	}
 
 	if insecure {
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	filterConstructor     = "InitFilter"
+	predicateConstructor  = "InitPredicate"
+	dataClientConstructor = "InitDataClient"
+)
+
+// the predicates handled by the eskip and the routing packages, that
+// cannot be overridden
+var reservedPredicates = []string{
+	routing.PathName,
+	routing.PathSubtreeName,
+	routing.WeightName,
+	"PathRegexp",
+	"Host",
+	"Method",
+	"Header",
+	"HeaderRegexp",
+	"Any",
+}
+
+// pluginArgs holds the arguments of the plugins, by the name of the
+// plugin. It implements flag.Value, and it can be set multiple times, in
+// the format of: <name>,<arg1>,<arg2>
+type pluginArgs map[string][]string
+
+type plugins struct {
+	filters     []filters.Spec
+	predicates  []routing.PredicateSpec
+	dataClients []routing.DataClient
+}
+
+func (a pluginArgs) String() string {
+	var s []string
+	for name, args := range a {
+		s = append(s, strings.Join(append([]string{name}, args...), ","))
+	}
+
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+func (a pluginArgs) Set(value string) error {
+	parts := strings.Split(value, ",")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return errors.New("missing plugin name")
+	}
+
+	if _, exists := a[name]; exists {
+		return fmt.Errorf("duplicate arguments for plugin: %s", name)
+	}
+
+	args := parts[1:]
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+
+	a[name] = args
+	return nil
+}
+
+// the name of a plugin is the base name of the file without the .so
+// extension
+func pluginName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".so")
+}
+
+// plugins cannot override the builtin filters, because the routes
+// relying on them would silently change their behavior
+func checkFilterName(name string) error {
+	if _, exists := builtin.MakeRegistry()[name]; exists {
+		return fmt.Errorf("filter %s overrides a builtin filter", name)
+	}
+
+	return nil
+}
+
+// plugins cannot override the builtin predicates, because they would be
+// ignored
+func checkPredicateName(name string) error {
+	for _, r := range reservedPredicates {
+		if name == r {
+			return fmt.Errorf("predicate %s overrides a builtin predicate", name)
+		}
+	}
+
+	for _, b := range skipper.BundledPredicates() {
+		if name == b.Name() {
+			return fmt.Errorf("predicate %s overrides a builtin predicate", name)
+		}
+	}
+
+	return nil
+}
+
+func (p *plugins) load(path string, args []string) error {
+	plug, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+
+	if sym, err := plug.Lookup(filterConstructor); err == nil {
+		create, ok := sym.(func([]string) (filters.Spec, error))
+		if !ok {
+			return fmt.Errorf("invalid type of %s: %T", filterConstructor, sym)
+		}
+
+		spec, err := create(args)
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", filterConstructor, err)
+		}
+
+		if err := checkFilterName(spec.Name()); err != nil {
+			return err
+		}
+
+		p.filters = append(p.filters, spec)
+		found = true
+	}
+
+	if sym, err := plug.Lookup(predicateConstructor); err == nil {
+		create, ok := sym.(func([]string) (routing.PredicateSpec, error))
+		if !ok {
+			return fmt.Errorf("invalid type of %s: %T", predicateConstructor, sym)
+		}
+
+		spec, err := create(args)
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", predicateConstructor, err)
+		}
+
+		if err := checkPredicateName(spec.Name()); err != nil {
+			return err
+		}
+
+		p.predicates = append(p.predicates, spec)
+		found = true
+	}
+
+	if sym, err := plug.Lookup(dataClientConstructor); err == nil {
+		create, ok := sym.(func([]string) (routing.DataClient, error))
+		if !ok {
+			return fmt.Errorf("invalid type of %s: %T", dataClientConstructor, sym)
+		}
+
+		dc, err := create(args)
+		if err != nil {
+			return fmt.Errorf("%s failed: %v", dataClientConstructor, err)
+		}
+
+		p.dataClients = append(p.dataClients, dc)
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf(
+			"none of %s, %s or %s is defined",
+			filterConstructor,
+			predicateConstructor,
+			dataClientConstructor,
+		)
+	}
+
+	return nil
+}
+
+// loads all the .so files from the plugin directory, and calls their
+// known constructors with the arguments set for the plugin
+func loadPlugins(dir string, args pluginArgs) (*plugins, error) {
+	p := &plugins{}
+	if dir == "" {
+		if len(args) > 0 {
+			return nil, errors.New("plugin arguments set without a plugin directory")
+		}
+
+		return p, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	loaded := make(map[string]bool)
+	for _, path := range paths {
+		name := pluginName(path)
+		if err := p.load(path, args[name]); err != nil {
+			return nil, fmt.Errorf("failed to load plugin %s from %s: %v", name, path, err)
+		}
+
+		log.Infof("plugin loaded: %s", name)
+		loaded[name] = true
+	}
+
+	for name := range args {
+		if !loaded[name] {
+			return nil, fmt.Errorf("arguments set for plugin %s, but it was not found in %s", name, dir)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPluginArgsSet(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		values   []string
+		expected pluginArgs
+		err      bool
+	}{{
+		"name only",
+		[]string{"geoip"},
+		pluginArgs{"geoip": {}},
+		false,
+	}, {
+		"name and args, trimmed",
+		[]string{" geoip , /var/lib/geoip.db ,foo"},
+		pluginArgs{"geoip": {"/var/lib/geoip.db", "foo"}},
+		false,
+	}, {
+		"multiple plugins",
+		[]string{"geoip,/var/lib/geoip.db", "ratelimit,100"},
+		pluginArgs{"geoip": {"/var/lib/geoip.db"}, "ratelimit": {"100"}},
+		false,
+	}, {
+		"missing name",
+		[]string{",100"},
+		nil,
+		true,
+	}, {
+		"duplicate plugin",
+		[]string{"ratelimit,100", "ratelimit,200"},
+		nil,
+		true,
+	}} {
+		a := make(pluginArgs)
+		var err error
+		for _, v := range ti.values {
+			if err = a.Set(v); err != nil {
+				break
+			}
+		}
+
+		if ti.err {
+			if err == nil {
+				t.Error(ti.msg, "failed to fail")
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(a, ti.expected) {
+			t.Error(ti.msg, "invalid args", a, ti.expected)
+		}
+	}
+}
+
+func TestPluginArgsString(t *testing.T) {
+	a := pluginArgs{"ratelimit": {"100"}, "geoip": {"/var/lib/geoip.db", "foo"}}
+	if s := a.String(); s != "geoip,/var/lib/geoip.db,foo ratelimit,100" {
+		t.Error("invalid string", s)
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skipper-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(dir+"/README", []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		msg  string
+		dir  string
+		args pluginArgs
+		err  bool
+	}{{
+		"no directory",
+		"",
+		pluginArgs{},
+		false,
+	}, {
+		"arguments without directory",
+		"",
+		pluginArgs{"geoip": {"/var/lib/geoip.db"}},
+		true,
+	}, {
+		"empty directory",
+		dir,
+		pluginArgs{},
+		false,
+	}, {
+		"arguments for a missing plugin",
+		dir,
+		pluginArgs{"geoip": {"/var/lib/geoip.db"}},
+		true,
+	}} {
+		p, err := loadPlugins(ti.dir, ti.args)
+		if ti.err {
+			if err == nil {
+				t.Error(ti.msg, "failed to fail")
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if len(p.filters) != 0 || len(p.predicates) != 0 || len(p.dataClients) != 0 {
+			t.Error(ti.msg, "unexpected plugins loaded")
+		}
+	}
+}
+
+func TestBuiltinNames(t *testing.T) {
+	for _, ti := range []struct {
+		msg   string
+		check func(string) error
+		name  string
+		err   bool
+	}{{
+		"custom filter",
+		checkFilterName,
+		"geoip",
+		false,
+	}, {
+		"builtin filter",
+		checkFilterName,
+		"setPath",
+		true,
+	}, {
+		"custom predicate",
+		checkPredicateName,
+		"GeoIP",
+		false,
+	}, {
+		"predicate handled by the routing",
+		checkPredicateName,
+		"Path",
+		true,
+	}, {
+		"predicate handled by the parser",
+		checkPredicateName,
+		"Host",
+		true,
+	}, {
+		"bundled predicate",
+		checkPredicateName,
+		"Cron",
+		true,
+	}} {
+		if err := ti.check(ti.name); (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
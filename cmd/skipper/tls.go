@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hostCAs holds the paths of the client CA bundles, by host name. It
+// implements flag.Value, and it can be set multiple times, in the format
+// of: <host>=<path>
+type hostCAs map[string]string
+
+func (h hostCAs) String() string {
+	var s []string
+	for host, path := range h {
+		s = append(s, host+"="+path)
+	}
+
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+func (h hostCAs) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return errors.New("missing client CA path")
+	}
+
+	host := strings.ToLower(strings.TrimSpace(parts[0]))
+	path := strings.TrimSpace(parts[1])
+	if host == "" || path == "" {
+		return errors.New("missing host or client CA path")
+	}
+
+	if _, exists := h[host]; exists {
+		return fmt.Errorf("duplicate client CA for host: %s", host)
+	}
+
+	h[host] = path
+	return nil
+}
@@ -358,6 +358,35 @@ exposes them on a separate listener endpoint for pulling snapshots.
 For details, see the 'logging' and 'metrics' packages documentation.
 
 
+Tracing
+
+Skipper can create spans for the processing of every request: for the
+route lookup, for each filter in the request and the response phase, and
+for the backend round trip. The spans can be sent to an OpenTelemetry
+collector, or to Jaeger, with the -otlp-endpoint option, or to any other
+system by setting a custom tracer in the options. The trace context is
+propagated to the backends in the W3C traceparent header. By default,
+the spans are not recorded.
+
+For details, see the 'tracing' package documentation.
+
+
+Load Balancing
+
+The backend address of a route can contain multiple endpoints separated
+by commas. By default, skipper forwards every request to a random
+endpoint. The stickyCookie filter makes the clients stick to an endpoint
+by setting a cookie with its opaque id, while the stickyHeader filter
+selects the endpoint by consistent hashing of a request header value.
+When the connection to an endpoint fails, it is not selected during a
+quarantine period, set with the -endpoint-quarantine option, and the
+sticky clients are moved to another endpoint.
+
+    * -> stickyCookie("backend", 86400) -> "http://10.2.0.1:8080,http://10.2.0.2:8080"
+
+For details, see the 'loadbalancer' package documentation.
+
+
 Performance Considerations
 
 The router's performance depends on the environment and on the used
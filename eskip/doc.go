@@ -121,7 +121,7 @@ Filters are used to augment the incoming requests and the outgoing
 responses, or do other useful or fun stuff. Filters can have different
 numbers of arguments depending on the implementation of the particular
 filter. The arguments can be of type string ("a string"), number
-(3.1415) or regular expression (/[.]html$/ or "[.]html$").
+(3.1415 or -42) or regular expression (/[.]html$/ or "[.]html$").
 
 A filter example:
 
@@ -188,6 +188,11 @@ An endpoint address backend is surrounded by '"'. It contains the scheme
 and the hostname of the endpoint, and optionally the port number that is
 inferred from the scheme if not specified.
 
+A backend can consist of multiple endpoints, listed in the address
+separated by commas. The proxy selects one of them for every request:
+
+    "http://10.2.0.1:8080,http://10.2.0.2:8080"
+
 A shunt backend:
 
     <shunt>
@@ -269,6 +269,16 @@ func TestParseFilters(t *testing.T) {
 		`filter1(3.14) -> filter2("key", 42)`,
 		[]*Filter{{Name: "filter1", Args: []interface{}{3.14}}, {Name: "filter2", Args: []interface{}{"key", float64(42)}}},
 		false,
+	}, {
+		"negative numbers",
+		`filter1(-3.14)->filter2(-42, -.5)`,
+		[]*Filter{{Name: "filter1", Args: []interface{}{-3.14}}, {Name: "filter2", Args: []interface{}{float64(-42), -.5}}},
+		false,
+	}, {
+		"incomplete negative number",
+		`filter1(-)`,
+		nil,
+		true,
 	}} {
 		fs, err := ParseFilters(ti.expression)
 		if err == nil && ti.err || err != nil && !ti.err {
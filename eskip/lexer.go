@@ -50,6 +50,7 @@ type fixedScanner string
 const (
 	escapeChar  = '\\'
 	decimalChar = '.'
+	minusChar   = '-'
 	newlineChar = '\n'
 	underscore  = '_'
 )
@@ -201,6 +202,11 @@ func scanDoubleQuote(code string) (token, string, error) { return scanStringLite
 func scanBacktick(code string) (token, string, error)    { return scanStringLiteral('`', code) }
 
 func scanNumber(code string) (t token, rest string, err error) {
+	var sign string
+	if code[0] == minusChar {
+		sign, code = code[:1], code[1:]
+	}
+
 	decimal := false
 	b, rest := scanWhile(code, func(c byte) bool {
 		if isDecimalChar(c) {
@@ -221,7 +227,7 @@ func scanNumber(code string) (t token, rest string, err error) {
 	}
 
 	t.id = number
-	t.val = string(b)
+	t.val = sign + string(b)
 	return
 }
 
@@ -253,7 +259,7 @@ func selectVaryingScanner(code string) scanner {
 		sf = scanBacktick
 	}
 
-	if isNumberChar(code[0]) {
+	if isNumberChar(code[0]) || code[0] == minusChar && len(code) > 1 && isNumberChar(code[1]) {
 		sf = scanNumber
 	}
 
@@ -5,19 +5,77 @@
 package eskip
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
+
+	"github.com/zalando/skipper/net"
+)
+
+const (
+	requestHeaderPrefix = "request.header."
+	requestQueryPrefix  = "request.query."
+	requestCookiePrefix = "request.cookie."
+	requestSource       = "request.source"
+	requestPath         = "request.path"
+	stateBagPrefix      = "state."
+	requestPrefix       = "request."
+)
+
+// TemplateEscaping defines how the values resolved from the request context
+// are escaped when they are inserted into the template.
+type TemplateEscaping int
+
+const (
+
+	// NoEscaping inserts the values as they are, e.g. into header values.
+	NoEscaping TemplateEscaping = iota
+
+	// PathEscaping escapes the values of the request placeholders as path
+	// segments, so that they cannot introduce new path segments or a
+	// query. The value of ${request.path} is inserted as the escaped path
+	// of the request. The path parameters and the state bag values are
+	// escaped as a path, so they can contain multiple segments, e.g. the
+	// value of a freeform wildcard.
+	PathEscaping
+
+	// URLEscaping escapes the values the same way as PathEscaping, when
+	// the placeholder is before the query of the template, and it escapes
+	// the values of the request placeholders as query components, when
+	// the placeholder is in the query.
+	URLEscaping
 )
 
-var parameterRegexp = regexp.MustCompile("\\$\\{(\\w+)\\}")
+var parameterRegexp = regexp.MustCompile("\\$\\{([\\w.\\-]+)\\}")
 
 // TemplateGetter functions return the value for a template parameter name.
 type TemplateGetter func(string) string
 
+// TemplateContext provides the request specific values used to resolve the
+// placeholders of a template. It is implemented by filters.FilterContext.
+type TemplateContext interface {
+
+	// Provides the wildcard parameter values from the request path by their
+	// name as the key.
+	PathParam(string) string
+
+	// The incoming request object.
+	Request() *http.Request
+
+	// The state bag of the request.
+	StateBag() map[string]interface{}
+}
+
 // Template represents a string template with named placeholders.
 type Template struct {
 	template     string
 	placeholders []string
+
+	// the positions of the placeholders in the template, as returned by
+	// regexp.FindAllStringSubmatchIndex
+	positions [][]int
 }
 
 // New parses a template string and returns a reusable *Template object.
@@ -33,7 +91,10 @@ func NewTemplate(template string) *Template {
 		placeholders[index] = placeholder[1]
 	}
 
-	return &Template{template: template, placeholders: placeholders}
+	return &Template{
+		template:     template,
+		placeholders: placeholders,
+		positions:    parameterRegexp.FindAllStringSubmatchIndex(template, -1)}
 }
 
 // Apply evaluates the template using a TemplateGetter function to resolve the
@@ -51,3 +112,137 @@ func (t *Template) Apply(get TemplateGetter) string {
 
 	return result
 }
+
+// ApplyContext evaluates the template using the values of the request
+// context to resolve the placeholders. The following placeholders are
+// supported:
+//
+// 	${name}: the path parameter with the given name
+// 	${request.header.<name>}: the request header with the given name
+// 	${request.query.<name>}: the query parameter with the given name
+// 	${request.cookie.<name>}: the value of the cookie with the given name
+// 	${request.source}: the IP address of the client, see net.RemoteHost
+// 	${request.path}: the path of the request
+// 	${state.<key>}: the value from the state bag with the given key
+//
+// Other placeholders starting with "request." are not resolved. Missing
+// values are replaced with an empty string. The second return value is
+// false if any of the placeholders could not be resolved, so that the
+// callers can decide whether to skip the operation.
+//
+// The values are inserted without escaping. Use ApplyContextEscaped when
+// the result is used as a URL or as a part of it.
+func (t *Template) ApplyContext(ctx TemplateContext) (string, bool) {
+	return t.ApplyContextEscaped(ctx, NoEscaping)
+}
+
+// ApplyContextEscaped evaluates the template the same way as ApplyContext,
+// but escapes the resolved values for the target context of the result.
+// Only the values are escaped, the rest of the template is used as it is.
+func (t *Template) ApplyContextEscaped(ctx TemplateContext, e TemplateEscaping) (string, bool) {
+	query := -1
+	if e == URLEscaping {
+		query = strings.Index(t.template, "?")
+	}
+
+	var (
+		result   []string
+		last     int
+		resolved = true
+	)
+
+	for _, p := range t.positions {
+		key := t.template[p[2]:p[3]]
+		v, ok := getContextValue(ctx, key)
+		if !ok {
+			resolved = false
+		}
+
+		inQuery := query >= 0 && p[0] > query
+		result = append(result, t.template[last:p[0]], escapeValue(ctx, key, v, e, inQuery))
+		last = p[1]
+	}
+
+	result = append(result, t.template[last:])
+	return strings.Join(result, ""), resolved
+}
+
+func getContextValue(ctx TemplateContext, key string) (string, bool) {
+	r := ctx.Request()
+	switch {
+	case strings.HasPrefix(key, requestHeaderPrefix):
+		h := r.Header[http.CanonicalHeaderKey(key[len(requestHeaderPrefix):])]
+		if len(h) == 0 {
+			return "", false
+		}
+
+		return h[0], true
+	case strings.HasPrefix(key, requestQueryPrefix):
+		q := r.URL.Query()[key[len(requestQueryPrefix):]]
+		if len(q) == 0 {
+			return "", false
+		}
+
+		return q[0], true
+	case strings.HasPrefix(key, requestCookiePrefix):
+		c, err := r.Cookie(key[len(requestCookiePrefix):])
+		if err != nil {
+			return "", false
+		}
+
+		return c.Value, true
+	case key == requestSource:
+		ip := net.RemoteHost(r)
+		if ip == nil {
+			return "", false
+		}
+
+		return ip.String(), true
+	case key == requestPath:
+		return r.URL.Path, true
+	case strings.HasPrefix(key, stateBagPrefix):
+		v, ok := ctx.StateBag()[key[len(stateBagPrefix):]]
+		if !ok {
+			return "", false
+		}
+
+		if s, ok := v.(string); ok {
+			return s, true
+		}
+
+		return fmt.Sprint(v), true
+	case strings.HasPrefix(key, requestPrefix):
+		return "", false
+	default:
+		v := ctx.PathParam(key)
+		return v, v != ""
+	}
+}
+
+func escapeValue(ctx TemplateContext, key, v string, e TemplateEscaping, inQuery bool) string {
+	if e == NoEscaping {
+		return v
+	}
+
+	// the path parameters and the state bag values are set by the
+	// routes, and they are allowed to contain multiple path segments
+	if !strings.HasPrefix(key, requestPrefix) {
+		if inQuery {
+			return v
+		}
+
+		return (&url.URL{Path: v}).EscapedPath()
+	}
+
+	if inQuery {
+		// unlike in the query, the '+' would mean a literal plus sign
+		// in the path, so the spaces are escaped as %20
+		return strings.Replace(url.QueryEscape(v), "+", "%20", -1)
+	}
+
+	if key == requestPath {
+		return ctx.Request().URL.EscapedPath()
+	}
+
+	return url.PathEscape(v)
+}
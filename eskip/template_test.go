@@ -1,6 +1,9 @@
 package eskip
 
-import "testing"
+import (
+	"net/http"
+	"testing"
+)
 
 type createTestItem struct {
 	template string
@@ -57,3 +60,199 @@ func TestTemplateGetter(t *testing.T) {
 		nil,
 	}})
 }
+
+type testTemplateContext struct {
+	params   map[string]string
+	request  *http.Request
+	stateBag map[string]interface{}
+}
+
+func (c *testTemplateContext) PathParam(key string) string      { return c.params[key] }
+func (c *testTemplateContext) Request() *http.Request           { return c.request }
+func (c *testTemplateContext) StateBag() map[string]interface{} { return c.stateBag }
+
+func TestTemplateApplyContext(t *testing.T) {
+	r, err := http.NewRequest("GET", "https://www.example.org/foo/bar?baz=qux", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RemoteAddr = "192.168.0.1:9090"
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Custom-Header", "custom")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	ctx := &testTemplateContext{
+		params:   map[string]string{"id": "42"},
+		request:  r,
+		stateBag: map[string]interface{}{"user": "jdoe", "count": 3}}
+
+	for _, ti := range []struct {
+		msg      string
+		template string
+		expected string
+		resolved bool
+	}{{
+		"no placeholders",
+		"template",
+		"template",
+		true,
+	}, {
+		"path param",
+		"/items/${id}",
+		"/items/42",
+		true,
+	}, {
+		"missing path param",
+		"/items/${missing}",
+		"/items/",
+		false,
+	}, {
+		"request header",
+		"${request.header.Authorization}",
+		"Bearer token",
+		true,
+	}, {
+		"request header, non-canonical, with hyphen",
+		"${request.header.x-custom-header}",
+		"custom",
+		true,
+	}, {
+		"missing request header",
+		"${request.header.X-Missing}",
+		"",
+		false,
+	}, {
+		"query parameter",
+		"${request.query.baz}",
+		"qux",
+		true,
+	}, {
+		"missing query parameter",
+		"${request.query.missing}",
+		"",
+		false,
+	}, {
+		"cookie",
+		"${request.cookie.session}",
+		"abc",
+		true,
+	}, {
+		"missing cookie",
+		"${request.cookie.missing}",
+		"",
+		false,
+	}, {
+		"source",
+		"${request.source}",
+		"192.168.0.1",
+		true,
+	}, {
+		"path",
+		"${request.path}",
+		"/foo/bar",
+		true,
+	}, {
+		"state bag string",
+		"${state.user}",
+		"jdoe",
+		true,
+	}, {
+		"state bag non-string",
+		"${state.count}",
+		"3",
+		true,
+	}, {
+		"missing state",
+		"${state.missing}",
+		"",
+		false,
+	}, {
+		"unknown request key",
+		"${request.foo}",
+		"",
+		false,
+	}, {
+		"combined, one missing",
+		"${id}-${request.query.baz}-${state.missing}",
+		"42-qux-",
+		false,
+	}} {
+		result, resolved := NewTemplate(ti.template).ApplyContext(ctx)
+		if result != ti.expected {
+			t.Error(ti.msg, "invalid result", result, ti.expected)
+		}
+
+		if resolved != ti.resolved {
+			t.Error(ti.msg, "invalid resolved flag", resolved, ti.resolved)
+		}
+	}
+}
+
+func TestTemplateApplyContextEscaped(t *testing.T) {
+	r, err := http.NewRequest("GET", "https://www.example.org/foo%2Fbar/baz?q=a%2Fb%3Fc%26d%3De+f", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &testTemplateContext{
+		params:  map[string]string{"id": "42", "rest": "/a b/c"},
+		request: r}
+
+	for _, ti := range []struct {
+		msg      string
+		template string
+		escaping TemplateEscaping
+		expected string
+	}{{
+		"no escaping",
+		"/items/${request.query.q}",
+		NoEscaping,
+		"/items/a/b?c&d=e f",
+	}, {
+		"path escaping",
+		"/items/${request.query.q}",
+		PathEscaping,
+		"/items/a%2Fb%3Fc&d=e%20f",
+	}, {
+		"path escaping, path param",
+		"/items/${id}",
+		PathEscaping,
+		"/items/42",
+	}, {
+		"path escaping, freeform path param keeps the segments",
+		"/api${rest}",
+		PathEscaping,
+		"/api/a%20b/c",
+	}, {
+		"path escaping, request path",
+		"/api${request.path}",
+		PathEscaping,
+		"/api/foo%2Fbar/baz",
+	}, {
+		"url escaping, in the path",
+		"https://www.example.org/items/${request.query.q}${rest}",
+		URLEscaping,
+		"https://www.example.org/items/a%2Fb%3Fc&d=e%20f/a%20b/c",
+	}, {
+		"url escaping, in the host",
+		"https://www.example.org${request.path}",
+		URLEscaping,
+		"https://www.example.org/foo%2Fbar/baz",
+	}, {
+		"url escaping, in the query",
+		"https://www.example.org/?q=${request.query.q}",
+		URLEscaping,
+		"https://www.example.org/?q=a%2Fb%3Fc%26d%3De%20f",
+	}, {
+		"url escaping, request path in the query",
+		"https://www.example.org/?return=${request.path}",
+		URLEscaping,
+		"https://www.example.org/?return=%2Ffoo%2Fbar%2Fbaz",
+	}} {
+		result, _ := NewTemplate(ti.template).ApplyContextEscaped(ctx, ti.escaping)
+		if result != ti.expected {
+			t.Error(ti.msg, "invalid result", result, ti.expected)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package auth
 
 import (
-	auth "github.com/abbot/go-http-auth"
-	"github.com/zalando/skipper/filters"
 	"net/http"
+	"sync"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
 )
 
 const (
@@ -13,15 +15,19 @@ const (
 	DefaultRealmName          = "Basic Realm"
 )
 
-type basicSpec struct{}
+type basicSpec struct {
+	mx    sync.Mutex
+	files map[string]*htpasswdFile
+}
 
 type basic struct {
-	authenticator   *auth.BasicAuth
+	htpasswd        *htpasswdFile
 	realmDefinition string
+	userHeader      string
 }
 
 func NewBasicAuth() *basicSpec {
-	return &basicSpec{}
+	return &basicSpec{files: make(map[string]*htpasswdFile)}
 }
 
 //We do not touch response at all
@@ -29,9 +35,13 @@ func (a *basic) Response(filters.FilterContext) {}
 
 // check basic auth
 func (a *basic) Request(ctx filters.FilterContext) {
-	username := a.authenticator.CheckAuth(ctx.Request())
+	req := ctx.Request()
+	if a.userHeader != "" {
+		req.Header.Del(a.userHeader)
+	}
 
-	if username == "" {
+	username, password, ok := req.BasicAuth()
+	if !ok || !a.htpasswd.check(username, password) {
 		header := http.Header{}
 		header.Set(ForceBasicAuthHeaderName, a.realmDefinition)
 
@@ -39,14 +49,42 @@ func (a *basic) Request(ctx filters.FilterContext) {
 			StatusCode: http.StatusUnauthorized,
 			Header:     header,
 		})
+
+		return
+	}
+
+	logging.SetAuthUser(req, username)
+	if a.userHeader != "" {
+		req.Header.Set(a.userHeader, username)
 	}
 }
 
+// the htpasswd files are shared between the routes, and reloaded when
+// they change
+func (spec *basicSpec) htpasswd(path string) (*htpasswdFile, error) {
+	spec.mx.Lock()
+	defer spec.mx.Unlock()
+
+	if h, ok := spec.files[path]; ok {
+		return h, nil
+	}
+
+	h, err := loadHtpasswd(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.files[path] = h
+	return h, nil
+}
+
 // Creates out basicAuth Filter
 // The first params specifies the used htpasswd file
 // The second is optional and defines the realm name
+// The third is optional and defines the request header, in which the
+// name of the authenticated user is passed to the backend
 func (spec *basicSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
-	if len(config) == 0 {
+	if len(config) == 0 || len(config) > 3 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
@@ -57,18 +95,28 @@ func (spec *basicSpec) CreateFilter(config []interface{}) (filters.Filter, error
 
 	realmName := DefaultRealmName
 
-	if len(config) == 2 {
+	if len(config) >= 2 {
 		if definedName, ok := config[1].(string); ok {
 			realmName = definedName
 		}
 	}
 
-	htpasswd := auth.HtpasswdFileProvider(configFile)
-	authenticator := auth.NewBasicAuthenticator(realmName, htpasswd)
+	var userHeader string
+	if len(config) == 3 {
+		if userHeader, ok = config[2].(string); !ok || userHeader == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	htpasswd, err := spec.htpasswd(configFile)
+	if err != nil {
+		return nil, err
+	}
 
 	return &basic{
-		authenticator:   authenticator,
+		htpasswd:        htpasswd,
 		realmDefinition: ForceBasicAuthHeaderValue + `"` + realmName + `"`,
+		userHeader:      userHeader,
 	}, nil
 }
 
@@ -1,9 +1,14 @@
 package auth
 
 import (
-	"github.com/zalando/skipper/filters/filtertest"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type createTestItem struct {
@@ -74,3 +79,219 @@ func TestWithSuccessfulAuth(t *testing.T) {
 		t.Error("Authentication not successful")
 	}
 }
+
+func TestVerifyPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcryptPassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		msg      string
+		hash     string
+		password string
+	}{{
+		"apr1",
+		"$apr1$abcdefgh$YJmXdaMB.I3tpuKOVl6rj0",
+		"apr1Password",
+	}, {
+		"md5 crypt",
+		"$1$abcdefgh$WxQ5gM8DheER70Xmmbhwx0",
+		"md5Password",
+	}, {
+		"sha1",
+		"{SHA}Rq8NZAkCDkQUvVhugQd0HJrX4TY=",
+		"shaPassword",
+	}, {
+		"sha256 crypt",
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"Hello world!",
+	}, {
+		"sha256 crypt with rounds",
+		"$5$rounds=2000$someSalt$yTPG0z5RwnCpoZPKVIbPrXy1Jgi9XC6Hp8IDmi0ivyC",
+		"sha256Password",
+	}, {
+		"sha512 crypt",
+		"$6$someSalt$XQowdx1Z3CA7ODGsgkHKNhX8jOIPgcyFm.nTHpuMFtE/Y4RDreSiD2Ubwcl12KsNVHq7rsg1x85m.6LFazj/0/",
+		"sha512Password",
+	}, {
+		"sha512 crypt with rounds and long salt",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+		"Hello world!",
+	}, {
+		"bcrypt",
+		string(bcryptHash),
+		"bcryptPassword",
+	}} {
+		if err := verifyPassword(ti.hash, ti.password); err != nil {
+			t.Error(ti.msg, "failed to verify the password", err)
+		}
+
+		if err := verifyPassword(ti.hash, ti.password+"x"); err == nil {
+			t.Error(ti.msg, "failed to reject the wrong password")
+		}
+	}
+
+	if err := verifyPassword("plainPassword", "plainPassword"); err != errUnsupportedHash {
+		t.Error("failed to reject the unsupported hash", err)
+	}
+}
+
+func writeHtpasswd(t *testing.T, f string, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(f, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(f, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkBasicAuth(t *testing.T, spec *basicSpec, args []interface{}, user, password string) *filtertest.Context {
+	f, err := spec.CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetBasicAuth(user, password)
+	req.Header.Set("X-Auth-User", "admin")
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	return ctx
+}
+
+func TestBasicAuthArgs(t *testing.T) {
+	for _, ti := range []createTestItem{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"missing file",
+		[]interface{}{"testdata/missing"},
+		true,
+	}, {
+		"invalid user header",
+		[]interface{}{"testdata/htpasswd", "My Website", 42},
+		true,
+	}, {
+		"too many args",
+		[]interface{}{"testdata/htpasswd", "My Website", "X-Auth-User", "foo"},
+		true,
+	}, {
+		"user header",
+		[]interface{}{"testdata/htpasswd", "My Website", "X-Auth-User"},
+		false,
+	}} {
+		_, err := NewBasicAuth().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestBasicAuthUserHeader(t *testing.T) {
+	spec := NewBasicAuth()
+	args := []interface{}{"testdata/htpasswd", "My Website", "X-Auth-User"}
+
+	ctx := checkBasicAuth(t, spec, args, "myName", "myPassword")
+	if ctx.FServed {
+		t.Fatal("failed to authenticate")
+	}
+
+	if u := ctx.FRequest.Header.Get("X-Auth-User"); u != "myName" {
+		t.Error("failed to set the user header", u)
+	}
+
+	ctx = checkBasicAuth(t, spec, args, "myName", "wrongPassword")
+	if !ctx.FServed {
+		t.Fatal("failed to reject the request")
+	}
+
+	if u := ctx.FRequest.Header.Get("X-Auth-User"); u != "" {
+		t.Error("failed to remove the user header", u)
+	}
+}
+
+func TestBasicAuthUnknownUser(t *testing.T) {
+	// the password of an existing user is not accepted for unknown users,
+	// even though it is used for the dummy comparison
+	ctx := checkBasicAuth(t, NewBasicAuth(), []interface{}{"testdata/htpasswd"}, "unknown", "myPassword")
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+		t.Error("failed to reject the unknown user")
+	}
+}
+
+func TestBasicAuthReload(t *testing.T) {
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	defer os.Remove(f.Name())
+
+	modTime := time.Now().Add(-time.Hour)
+	writeHtpasswd(t, f.Name(), "myName:$apr1$abcdefgh$YJmXdaMB.I3tpuKOVl6rj0\n", modTime)
+
+	spec := NewBasicAuth()
+	args := []interface{}{f.Name()}
+	if ctx := checkBasicAuth(t, spec, args, "myName", "apr1Password"); ctx.FServed {
+		t.Fatal("failed to authenticate")
+	}
+
+	if ctx := checkBasicAuth(t, spec, args, "newName", "sha512Password"); !ctx.FServed {
+		t.Fatal("failed to reject the unknown user")
+	}
+
+	writeHtpasswd(t, f.Name(), "myName:$apr1$abcdefgh$YJmXdaMB.I3tpuKOVl6rj0\n"+
+		"newName:$6$someSalt$XQowdx1Z3CA7ODGsgkHKNhX8jOIPgcyFm.nTHpuMFtE/Y4RDreSiD2Ubwcl12KsNVHq7rsg1x85m.6LFazj/0/\n",
+		modTime.Add(time.Minute))
+
+	// skip waiting for the check interval
+	h, err := spec.htpasswd(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.mx.Lock()
+	h.checked = time.Time{}
+	h.mx.Unlock()
+
+	if ctx := checkBasicAuth(t, spec, args, "newName", "sha512Password"); ctx.FServed {
+		t.Error("failed to reload the file")
+	}
+
+	// invalid files are ignored, and the previous users are kept
+	writeHtpasswd(t, f.Name(), "invalid\n", modTime.Add(2*time.Minute))
+	h.mx.Lock()
+	h.checked = time.Time{}
+	h.mx.Unlock()
+
+	if ctx := checkBasicAuth(t, spec, args, "newName", "sha512Password"); ctx.FServed {
+		t.Error("failed to keep the previous users")
+	}
+}
+
+func TestBasicAuthShared(t *testing.T) {
+	spec := NewBasicAuth()
+	f1, err := spec.CreateFilter([]interface{}{"testdata/htpasswd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := spec.CreateFilter([]interface{}{"testdata/htpasswd", "My Website"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f1.(*basic).htpasswd != f2.(*basic).htpasswd {
+		t.Error("failed to share the htpasswd file")
+	}
+}
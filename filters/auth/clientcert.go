@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	RequireClientCertName = "requireClientCert"
+	ForwardClientCertName = "forwardClientCert"
+
+	// The default headers used by the forwardClientCert filter.
+	ClientCertSubjectHeader     = "X-Client-Cert-Subject"
+	ClientCertSANHeader         = "X-Client-Cert-San"
+	ClientCertFingerprintHeader = "X-Client-Cert-Fingerprint"
+	ClientCertPEMHeader         = "X-Client-Cert"
+)
+
+type clientCertField int
+
+const (
+	clientCertSubject clientCertField = iota
+	clientCertSAN
+	clientCertFingerprint
+	clientCertPEM
+)
+
+var clientCertFields = map[string]struct {
+	field  clientCertField
+	header string
+}{
+	"subject":     {clientCertSubject, ClientCertSubjectHeader},
+	"san":         {clientCertSAN, ClientCertSANHeader},
+	"fingerprint": {clientCertFingerprint, ClientCertFingerprintHeader},
+	"pem":         {clientCertPEM, ClientCertPEMHeader},
+}
+
+type requireClientCertSpec struct{}
+
+type requireClientCert struct {
+	rx *regexp.Regexp
+}
+
+type forwardClientCertSpec struct{}
+
+type forwardedClientCertField struct {
+	field  clientCertField
+	header string
+}
+
+type forwardClientCert struct {
+	fields []forwardedClientCertField
+}
+
+// Returns a filter specification whose instances reject the requests
+// without a verified TLS client certificate with 401, and, when a regular
+// expression is passed in as the argument, the requests whose certificate
+// subject or subject alternative names don't match it with 403.
+//
+// 	* -> requireClientCert("^CN=[^,]+,OU=Engineering,O=Example,C=DE$") -> "https://internal.example.org"
+//
+// Name: "requireClientCert".
+func NewRequireClientCert() filters.Spec { return &requireClientCertSpec{} }
+
+// "requireClientCert"
+func (s *requireClientCertSpec) Name() string { return RequireClientCertName }
+
+func (s *requireClientCertSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) > 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &requireClientCert{}
+	if len(config) == 1 {
+		expr, ok := config[0].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.rx = rx
+	}
+
+	return f, nil
+}
+
+func (f *requireClientCert) Request(ctx filters.FilterContext) {
+	c := snet.ClientCertificate(ctx.Request())
+	if c == nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusUnauthorized})
+		return
+	}
+
+	if f.rx != nil && !snet.CertificateMatches(c, f.rx) {
+		ctx.Serve(&http.Response{StatusCode: http.StatusForbidden})
+		return
+	}
+
+	logging.SetAuthUser(ctx.Request(), c.Subject.CommonName)
+}
+
+func (f *requireClientCert) Response(filters.FilterContext) {}
+
+// Returns a filter specification whose instances pass the properties of
+// the verified TLS client certificate to the backend in request headers.
+// The arguments select the properties: subject, san, fingerprint (SHA-256)
+// or pem, optionally followed by the name of the header, e.g.
+// "subject=X-Subject". Without arguments, the subject and the fingerprint
+// are forwarded. The headers are always removed from the incoming
+// requests.
+//
+// 	* -> forwardClientCert("subject", "pem=X-Ssl-Client-Cert") -> "https://internal.example.org"
+//
+// Name: "forwardClientCert".
+func NewForwardClientCert() filters.Spec { return &forwardClientCertSpec{} }
+
+// "forwardClientCert"
+func (s *forwardClientCertSpec) Name() string { return ForwardClientCertName }
+
+func (s *forwardClientCertSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		config = []interface{}{"subject", "fingerprint"}
+	}
+
+	f := &forwardClientCert{}
+	for _, c := range config {
+		a, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		kv := strings.SplitN(a, "=", 2)
+		d, ok := clientCertFields[kv[0]]
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		header := d.header
+		if len(kv) == 2 {
+			if kv[1] == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			header = kv[1]
+		}
+
+		f.fields = append(f.fields, forwardedClientCertField{field: d.field, header: header})
+	}
+
+	return f, nil
+}
+
+func (f *forwardClientCert) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	for _, fi := range f.fields {
+		r.Header.Del(fi.header)
+	}
+
+	c := snet.ClientCertificate(r)
+	if c == nil {
+		return
+	}
+
+	for _, fi := range f.fields {
+		var v string
+		switch fi.field {
+		case clientCertSubject:
+			v = c.Subject.String()
+		case clientCertSAN:
+			v = strings.Join(snet.SubjectAlternativeNames(c), ",")
+		case clientCertFingerprint:
+			sum := sha256.Sum256(c.Raw)
+			v = hex.EncodeToString(sum[:])
+		case clientCertPEM:
+			// the PEM encoding contains new lines, that are not allowed
+			// in header values
+			v = url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})))
+		}
+
+		if v != "" {
+			r.Header.Set(fi.header, v)
+		}
+	}
+}
+
+func (f *forwardClientCert) Response(filters.FilterContext) {}
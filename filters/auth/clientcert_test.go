@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+var testClientCert = &x509.Certificate{
+	Raw:      []byte("raw certificate"),
+	Subject:  pkix.Name{CommonName: "client", OrganizationalUnit: []string{"Engineering"}},
+	DNSNames: []string{"client.example.org", "client.services.example.org"},
+}
+
+func clientCertRequest(t *testing.T, cert *x509.Certificate) *http.Request {
+	req, err := http.NewRequest("GET", "https://internal.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert != nil {
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	return req
+}
+
+func TestRequireClientCertArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{
+		{"no args", nil, false},
+		{"regexp", []interface{}{"OU=Engineering"}, false},
+		{"not a string", []interface{}{42}, true},
+		{"invalid regexp", []interface{}{"OU=(Engineering"}, true},
+		{"too many args", []interface{}{"OU=Engineering", "CN=client"}, true},
+	} {
+		_, err := NewRequireClientCert().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		args   []interface{}
+		cert   *x509.Certificate
+		status int
+	}{{
+		"no certificate",
+		nil,
+		nil,
+		http.StatusUnauthorized,
+	}, {
+		"any certificate",
+		nil,
+		testClientCert,
+		http.StatusOK,
+	}, {
+		"matching subject",
+		[]interface{}{"OU=Engineering"},
+		testClientCert,
+		http.StatusOK,
+	}, {
+		"matching alternative name",
+		[]interface{}{"^client[.]services[.]"},
+		testClientCert,
+		http.StatusOK,
+	}, {
+		"not matching",
+		[]interface{}{"OU=Marketing"},
+		testClientCert,
+		http.StatusForbidden,
+	}} {
+		f, err := NewRequireClientCert().CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		ctx := &filtertest.Context{FRequest: clientCertRequest(t, ti.cert)}
+		f.Request(ctx)
+
+		if ti.status == http.StatusOK {
+			if ctx.FServed {
+				t.Error(ti.msg, "unexpectedly rejected", ctx.FResponse.StatusCode)
+			}
+
+			continue
+		}
+
+		if !ctx.FServed || ctx.FResponse.StatusCode != ti.status {
+			t.Error(ti.msg, "failed to reject the request with the right status")
+		}
+	}
+}
+
+func TestForwardClientCertArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{
+		{"no args", nil, false},
+		{"all fields", []interface{}{"subject", "san", "fingerprint", "pem"}, false},
+		{"custom header", []interface{}{"pem=X-Ssl-Client-Cert"}, false},
+		{"unknown field", []interface{}{"issuer"}, true},
+		{"empty header", []interface{}{"subject="}, true},
+		{"not a string", []interface{}{42}, true},
+	} {
+		_, err := NewForwardClientCert().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestForwardClientCert(t *testing.T) {
+	f, err := NewForwardClientCert().CreateFilter([]interface{}{"subject", "san", "fingerprint", "pem=X-Ssl-Client-Cert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := clientCertRequest(t, testClientCert)
+	req.Header.Set(ClientCertSubjectHeader, "CN=admin")
+	f.Request(&filtertest.Context{FRequest: req})
+
+	if s := req.Header.Get(ClientCertSubjectHeader); s != "CN=client,OU=Engineering" {
+		t.Error("invalid subject", s)
+	}
+
+	if s := req.Header.Get(ClientCertSANHeader); s != "client.example.org,client.services.example.org" {
+		t.Error("invalid alternative names", s)
+	}
+
+	sum := sha256.Sum256(testClientCert.Raw)
+	if s := req.Header.Get(ClientCertFingerprintHeader); s != hex.EncodeToString(sum[:]) {
+		t.Error("invalid fingerprint", s)
+	}
+
+	pem, err := url.QueryUnescape(req.Header.Get("X-Ssl-Client-Cert"))
+	if err != nil || !strings.HasPrefix(pem, "-----BEGIN CERTIFICATE-----\n") {
+		t.Error("invalid certificate", pem, err)
+	}
+
+	if req.Header.Get(ClientCertPEMHeader) != "" {
+		t.Error("unexpected default certificate header")
+	}
+}
+
+func TestForwardClientCertStripsHeaders(t *testing.T) {
+	f, err := NewForwardClientCert().CreateFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := clientCertRequest(t, nil)
+	req.Header.Set(ClientCertSubjectHeader, "CN=admin")
+	req.Header.Set(ClientCertFingerprintHeader, "00")
+	f.Request(&filtertest.Context{FRequest: req})
+
+	if req.Header.Get(ClientCertSubjectHeader) != "" || req.Header.Get(ClientCertFingerprintHeader) != "" {
+		t.Error("failed to remove the client certificate headers")
+	}
+}
@@ -1,18 +1,215 @@
 /*
-Package auth implements the basic auth for headers based on "https://github.com/abbot/go-http-auth".
+Package auth implements the basic auth for headers, and other authentication filters.
 
 How It Works
 
-The filter accepts two parameters, the first mandatory one is the path to the htpasswd file usually used with Apache or nginx. The second one is the optional realm name that will be displayed in the browser.
-Each incoming request will be validated against the password file, for more information which formats are currently
-supported check "https://github.com/abbot/go-http-auth".
-Assuming you are going to use the MD5 version new entries can be generated like
+The filter accepts three parameters, the first mandatory one is the path to the htpasswd file usually used with Apache or nginx. The second one is the optional realm name that will be displayed in the browser.
+The third one is the optional name of a request header, in which the name of the authenticated user is passed to the
+backend. This header is always removed from the incoming requests.
+Each incoming request will be validated against the password file. The supported password formats are MD5 (apr1), SHA1,
+bcrypt, and the SHA-256 and SHA-512 based crypt. New entries can be generated like
 
-	htpasswd -nbm myName myPassword
+	htpasswd -nbB myName myPassword
+	openssl passwd -6 myPassword
+
+The password file is shared by the routes using the same path, and it is reloaded when it changes, without updating
+the routes. When the reload fails, e.g. the file contains invalid entries, the previously loaded users are used.
+The requests with unknown user names take the same time to validate as the ones with wrong passwords, so the response
+times don't reveal the existing user names.
 
 Usage
 
 	basicAuth("/path/to/htpasswd")
 	basicAuth("/path/to/htpasswd", "My Website")
+	basicAuth("/path/to/htpasswd", "My Website", "X-Auth-User")
+
+JWT Validation
+
+The jwtValidation filter validates the bearer token of the requests as a
+JSON Web Token. The signature of the token is verified with the keys of a
+JSON Web Key Set, loaded from a local file, or from a URL. The supported
+algorithms are RS256, ES256 and HS256, where the algorithm of the token
+needs to match the type of the key: RSA, EC with the P-256 curve, or oct
+for the HMAC secrets.
+
+The first argument is the path of the JWKS file or the JWKS URL. Files are
+loaded when the filter is created, and reloaded only when the routes are
+created again after the file has changed, while the keys from URLs are
+refreshed periodically, by default every 5 minutes. The following options
+can be set as additional string arguments:
+
+	issuer=<iss>: the expected value of the iss claim
+	audience=<aud>: the value expected in the aud claim
+	refresh=<duration>: the refresh period of the keys loaded from a URL, e.g. 1m
+	leeway=<duration>: the allowed clock skew when checking the exp and nbf claims
+	realm=<realm>: the realm in the WWW-Authenticate header of the rejected requests
+
+The exp and the nbf claims are always checked when present. Requests
+without a valid token are rejected with 401 Unauthorized, and a
+WWW-Authenticate header as defined in RFC 6750. The claims of the
+validated tokens are stored in the state bag with the "jwtClaims" key, and
+when the sub claim is set, it is logged as the user in the access log.
+
+	jwtValidation("https://auth.example.org/.well-known/jwks.json", "issuer=https://auth.example.org", "audience=my-api")
+	jwtValidation("/etc/skipper/jwks.json", "leeway=30s", "realm=my-api")
+
+OAuth2 Token Introspection
+
+The oauthTokeninfo* filters validate the bearer token of the requests by
+calling an OAuth2 token introspection endpoint, as defined in RFC 7662, or
+a compatible tokeninfo service. The filters are available only when
+skipper is started with the URL of the endpoint, set with the
+-oauth-tokeninfo-url flag. The results of the introspection are cached
+until the tokens expire.
+
+Requests without an active token are rejected with 401 Unauthorized,
+while the requests whose token doesn't have the required scopes or
+key-value pairs are rejected with 403 Forbidden. When the introspection
+endpoint cannot be reached, the requests are rejected with 503 Service
+Unavailable, and when it fails or its response is invalid, with 502 Bad
+Gateway. A 401 or 404 response of the endpoint means an inactive token
+only for the tokeninfo services, when no client credentials are set:
+
+	oauthTokeninfoAnyScope("read", "write"): at least one of the scopes
+	oauthTokeninfoAllScope("read", "write"): all the scopes
+	oauthTokeninfoAnyKV("realm", "/employees", "realm", "/services"): at least one of the key-value pairs
+	oauthTokeninfoAllKV("realm", "/employees", "team", "teapot"): all the key-value pairs
+
+The key-value pairs are checked against the top level fields of the token
+information. The token information of the accepted requests is stored in
+the state bag with the "tokeninfo" key, and the sub, uid or username
+field is logged as the user in the access log.
+
+OpenID Connect
+
+The oauthOidc filter requires the users to log in with an OpenID Connect
+provider, using the authorization code flow. The arguments are the issuer
+URL of the provider, the client ID, the client secret and the callback
+URL registered with the provider. The endpoints and the keys of the
+provider are discovered from the issuer URL on the first request.
+
+Users without a valid session are redirected to the provider. When the
+provider redirects them back to the callback URL, the filter exchanges
+the authorization code for an ID token, validates it, and redirects the
+users to the originally requested URL, with a session cookie. The path of
+the callback URL needs to be handled by the same route. The cookies follow
+the conventions of the cookie filters, and their values are encrypted with
+a key derived from the client secret.
+
+The following options can be set as additional string arguments:
+
+	scopes=<scopes>: space separated list of scopes requested in addition to openid
+	header=<name>:<claim>: the request header to set from a claim of the ID token, can be repeated
+	cookie=<name>: the name of the session cookie, defaults to skipper-oidc
+	session=<duration>: the lifetime of the session, defaults to 1h
+
+The headers set from the claims are removed from the incoming requests,
+and the session cookie is not forwarded to the backend. The claims of the
+ID token are stored in the state bag with the "oidcClaims" key, and the
+sub claim is logged as the user in the access log.
+
+	* -> oauthOidc("https://accounts.example.org", "my-app", "my-secret", "https://app.example.org/auth/callback", "scopes=email", "header=X-Auth-Email:email") -> "https://app.internal"
+
+Forward Authentication
+
+The forwardAuth filter delegates the authorization of the requests to an
+external auth service. For every request, it sends a request to the URL
+of the auth service, with the method of the original request, the
+Authorization and the Cookie headers, and the X-Forwarded-Method,
+X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Uri headers describing
+the original request.
+
+When the auth service responds with 2xx, the request is forwarded to the
+backend, with the headers whose names are passed in as the additional
+arguments copied from the response of the auth service. These headers are
+always removed from the incoming requests. Otherwise, the response of the
+auth service, e.g. 401 or a redirect to a login page, is returned to the
+client. When the auth service cannot be reached, the filter responds with
+503 Service Unavailable.
+
+The following options can be set as additional string arguments:
+
+	request-header=<name>: additional request header to send to the auth service, can be repeated
+	cache=<duration>: cache the responses of the auth service for the given time
+	timeout=<duration>: the timeout of the requests to the auth service, defaults to 2s
+
+When caching is enabled, the responses are cached per credential and
+requested resource, while the requests without credentials are always
+sent to the auth service.
+
+	* -> forwardAuth("https://auth.example.org/check", "X-Auth-User", "cache=10s") -> "https://api.internal"
+
+HMAC Signatures
+
+The verifyHmac filter verifies the HMAC signature of the requests, e.g.
+of webhook calls. The arguments are the path of the file containing the
+secrets, the name of the header containing the signature, the algorithm
+(sha1, sha256 or sha512), and the canonical format of the signed message.
+
+The secrets file contains one secret per line. Empty lines and lines
+starting with # are ignored. The signature is accepted when it matches
+any of the secrets, so that the secrets can be rotated. The file is
+reloaded when it changes. The signature can be hex or base64 encoded,
+optionally prefixed with the algorithm, e.g. sha256=<hex>.
+
+The canonical format is a template, where the following placeholders are
+replaced with the parts of the request:
+
+	{method}: the HTTP method
+	{path}: the path
+	{uri}: the path and the query
+	{host}: the host
+	{header:<name>}: the value of a header
+	{timestamp}: the value of the timestamp header
+	{body}: the request body
+
+The following options can be set as additional string arguments:
+
+	timestamp=<header>: the header containing the timestamp of the request, as Unix seconds, RFC 3339 or HTTP date
+	max-age=<duration>: the maximum difference between the timestamp and the current time, defaults to 5m
+	max-body=<bytes>: the maximum size of the buffered request body, defaults to 1MB
+
+The timestamp option requires the {timestamp} placeholder in the
+canonical format, and vice versa. When the timestamp header is set, the
+requests with a missing or stale timestamp are rejected. The body is
+buffered only when the format contains the {body} placeholder. The requests without a valid signature are
+rejected with 401 Unauthorized, while the ones with a body larger than
+the maximum size with 413 Request Entity Too Large.
+
+	* -> verifyHmac("/etc/skipper/webhook-secrets", "X-Signature", "sha256", "{body}") -> "https://hooks.internal"
+	* -> verifyHmac("/etc/skipper/webhook-secrets", "X-Signature", "sha256", "{timestamp}.{body}", "timestamp=X-Timestamp") -> "https://hooks.internal"
+
+Client Certificates
+
+When skipper terminates TLS, it can verify the certificates of the
+clients with the CA bundle set with the -tls-client-ca option, or, for
+specific host names, require them with the -tls-client-cert-host option.
+
+The requireClientCert filter rejects the requests without a verified
+client certificate with 401 Unauthorized. When a regular expression is
+passed in as the argument, the requests whose certificate subject, in
+the format of RFC 2253, e.g. CN=client,OU=Engineering,O=Example, or
+subject alternative names don't match it, are rejected with 403
+Forbidden. The expression should be anchored, because the subject
+contains all the attributes: an expression like OU=Engineering would
+match a certificate whose common name contains the same text, too.
+
+	* -> requireClientCert() -> "https://internal.example.org"
+	* -> requireClientCert("^CN=[^,]+,OU=Engineering,O=Example,C=DE$") -> "https://internal.example.org"
+
+The forwardClientCert filter passes the properties of the verified client
+certificate to the backend in request headers. The arguments select the
+properties, optionally with a custom header name:
+
+	subject: the subject, in X-Client-Cert-Subject
+	san: the comma separated alternative names, in X-Client-Cert-San
+	fingerprint: the hex encoded SHA-256 fingerprint, in X-Client-Cert-Fingerprint
+	pem: the URL encoded PEM certificate, in X-Client-Cert
+
+Without arguments, the subject and the fingerprint are forwarded. The
+headers are always removed from the incoming requests, so that the
+clients cannot set them.
+
+	* -> requireClientCert() -> forwardClientCert("subject", "pem=X-Ssl-Client-Cert") -> "https://internal.example.org"
 */
 package auth
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	ForwardAuthName = "forwardAuth"
+
+	// The default timeout of the requests to the auth service.
+	DefaultForwardAuthTimeout = 2 * time.Second
+
+	forwardAuthRequestHeaderOption = "request-header="
+	forwardAuthCacheOption         = "cache="
+	forwardAuthTimeoutOption       = "timeout="
+
+	// the maximum number of cached responses, and the maximum size of
+	// the response body of the auth service returned to the clients
+	forwardAuthCacheSize = 1 << 14
+	forwardAuthMaxBody   = 1 << 16
+)
+
+// the request headers always sent to the auth service
+var forwardAuthCredentialHeaders = []string{"Authorization", "Cookie"}
+
+// the response of the auth service, as returned to the clients, or used
+// to set the upstream request headers
+type forwardAuthResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type forwardAuthSpec struct{}
+
+type forwardAuth struct {
+	url            *url.URL
+	requestHeaders []string
+	copyHeaders    []string
+	cacheTTL       time.Duration
+	client         *http.Client
+	mx             sync.Mutex
+	cache          map[string]*forwardAuthResponse
+}
+
+// Returns a filter specification whose instances delegate the
+// authorization of the requests to an external auth service. See the
+// package documentation for the details.
+//
+// Name: "forwardAuth".
+func NewForwardAuth() filters.Spec { return &forwardAuthSpec{} }
+
+// "forwardAuth"
+func (s *forwardAuthSpec) Name() string { return ForwardAuthName }
+
+func (s *forwardAuthSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	su, ok := config[0].(string)
+	if !ok || !isURL(su) {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	u, err := url.Parse(su)
+	if err != nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &forwardAuth{
+		url:            u,
+		requestHeaders: append([]string(nil), forwardAuthCredentialHeaders...),
+		cache:          make(map[string]*forwardAuthResponse)}
+	timeout := DefaultForwardAuthTimeout
+	for _, c := range config[1:] {
+		o, ok := c.(string)
+		if !ok || o == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		switch {
+		case strings.HasPrefix(o, forwardAuthRequestHeaderOption):
+			h := o[len(forwardAuthRequestHeaderOption):]
+			if h == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.requestHeaders = append(f.requestHeaders, http.CanonicalHeaderKey(h))
+		case strings.HasPrefix(o, forwardAuthCacheOption):
+			f.cacheTTL, err = time.ParseDuration(o[len(forwardAuthCacheOption):])
+			if err != nil || f.cacheTTL <= 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.HasPrefix(o, forwardAuthTimeoutOption):
+			timeout, err = time.ParseDuration(o[len(forwardAuthTimeoutOption):])
+			if err != nil || timeout <= 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.Contains(o, "="):
+			return nil, filters.ErrInvalidFilterParameters
+		default:
+			f.copyHeaders = append(f.copyHeaders, http.CanonicalHeaderKey(o))
+		}
+	}
+
+	// the redirects of the auth service are returned to the clients
+	f.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+
+	return f, nil
+}
+
+// the responses are cached per credential and requested resource. Without
+// credentials, the responses are not cached.
+func (f *forwardAuth) cacheKey(r *http.Request) (string, bool) {
+	var credentials bool
+	key := []string{r.Method, r.URL.RequestURI()}
+	for _, h := range f.requestHeaders {
+		v := r.Header[h]
+		credentials = credentials || len(v) > 0
+		key = append(key, h+":"+strings.Join(v, ","))
+	}
+
+	return strings.Join(key, "\n"), credentials
+}
+
+func (f *forwardAuth) fromCache(key string, now time.Time) (*forwardAuthResponse, bool) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	rsp, ok := f.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !now.Before(rsp.expires) {
+		delete(f.cache, key)
+		return nil, false
+	}
+
+	return rsp, true
+}
+
+func (f *forwardAuth) store(key string, rsp *forwardAuthResponse, now time.Time) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if len(f.cache) >= forwardAuthCacheSize {
+		for k, c := range f.cache {
+			if !now.Before(c.expires) {
+				delete(f.cache, k)
+			}
+		}
+	}
+
+	// when the cache is still full, an arbitrary entry is dropped
+	if len(f.cache) >= forwardAuthCacheSize {
+		for k := range f.cache {
+			delete(f.cache, k)
+			break
+		}
+	}
+
+	f.cache[key] = rsp
+}
+
+func (f *forwardAuth) authRequest(r *http.Request) (*forwardAuthResponse, error) {
+	req, err := http.NewRequest(r.Method, f.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range f.requestHeaders {
+		for _, v := range r.Header[h] {
+			req.Header.Add(h, v)
+		}
+	}
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	req.Header.Set("X-Forwarded-Method", r.Method)
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+
+	rsp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, forwardAuthMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	return &forwardAuthResponse{status: rsp.StatusCode, header: rsp.Header, body: body}, nil
+}
+
+func (f *forwardAuth) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+
+	// the headers set from the response of the auth service are never
+	// accepted from the clients
+	for _, h := range f.copyHeaders {
+		r.Header.Del(h)
+	}
+
+	key, cacheable := f.cacheKey(r)
+	cacheable = cacheable && f.cacheTTL > 0
+
+	var (
+		rsp    *forwardAuthResponse
+		cached bool
+	)
+
+	now := time.Now()
+	if cacheable {
+		rsp, cached = f.fromCache(key, now)
+	}
+
+	if !cached {
+		var err error
+		rsp, err = f.authRequest(r)
+		if err != nil {
+			log.Errorf("forwardAuth: %s: %v", f.url, err)
+			ctx.Serve(&http.Response{StatusCode: http.StatusServiceUnavailable})
+			return
+		}
+
+		if cacheable && rsp.status < http.StatusInternalServerError {
+			rsp.expires = now.Add(f.cacheTTL)
+			f.store(key, rsp, now)
+		}
+	}
+
+	if rsp.status >= 200 && rsp.status < 300 {
+		for _, h := range f.copyHeaders {
+			for _, v := range rsp.header[h] {
+				r.Header.Add(h, v)
+			}
+		}
+
+		return
+	}
+
+	header := http.Header{}
+	for k, v := range rsp.header {
+		if k != "Content-Length" && k != "Transfer-Encoding" && k != "Connection" {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode:    rsp.status,
+		Header:        header,
+		ContentLength: int64(len(rsp.body)),
+		Body:          ioutil.NopCloser(bytes.NewReader(rsp.body))})
+}
+
+func (f *forwardAuth) Response(filters.FilterContext) {}
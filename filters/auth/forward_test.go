@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+// accepts the requests with the token "valid-token", and redirects the
+// requests without credentials to a login page
+type testAuthService struct {
+	mx       sync.Mutex
+	requests []*http.Request
+}
+
+func (s *testAuthService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	s.requests = append(s.requests, r)
+	s.mx.Unlock()
+
+	switch r.Header.Get("Authorization") {
+	case "Bearer valid-token":
+		w.Header().Set("X-Auth-User", "jdoe")
+		w.Header().Add("X-Auth-Groups", "admins")
+		w.Header().Add("X-Auth-Groups", "users")
+		w.Header().Set("X-Internal", "foo")
+	case "":
+		http.Redirect(w, r, "https://login.example.org", http.StatusFound)
+	default:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("invalid token"))
+	}
+}
+
+func (s *testAuthService) count() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.requests)
+}
+
+func (s *testAuthService) last() *http.Request {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func forwardAuthRequest(t *testing.T, f filters.Filter, method, auth string) *filtertest.Context {
+	req, err := http.NewRequest(method, "https://www.example.org/api/orders?limit=10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	req.Header.Set("X-Auth-User", "admin")
+	req.Header.Set("X-Other", "bar")
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	return ctx
+}
+
+func TestForwardAuthArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"invalid url",
+		[]interface{}{"auth.example.org"},
+		true,
+	}, {
+		"not a string",
+		[]interface{}{"https://auth.example.org", 42},
+		true,
+	}, {
+		"invalid cache ttl",
+		[]interface{}{"https://auth.example.org", "cache=foo"},
+		true,
+	}, {
+		"invalid timeout",
+		[]interface{}{"https://auth.example.org", "timeout=0s"},
+		true,
+	}, {
+		"unknown option",
+		[]interface{}{"https://auth.example.org", "foo=bar"},
+		true,
+	}, {
+		"url only",
+		[]interface{}{"https://auth.example.org"},
+		false,
+	}, {
+		"headers and options",
+		[]interface{}{"https://auth.example.org", "X-Auth-User", "request-header=X-Api-Key", "cache=10s", "timeout=1s"},
+		false,
+	}} {
+		_, err := NewForwardAuth().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestForwardAuth(t *testing.T) {
+	s := &testAuthService{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	f, err := NewForwardAuth().CreateFilter([]interface{}{ts.URL + "/check", "X-Auth-User", "X-Auth-Groups"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		ctx := forwardAuthRequest(t, f, "POST", "Bearer valid-token")
+		if ctx.FServed {
+			t.Fatal("failed to accept the request", ctx.FResponse.StatusCode)
+		}
+
+		ar := s.last()
+		if ar.Method != "POST" || ar.URL.Path != "/check" {
+			t.Error("invalid auth request", ar.Method, ar.URL.Path)
+		}
+
+		if ar.Header.Get("X-Forwarded-Uri") != "/api/orders?limit=10" ||
+			ar.Header.Get("X-Forwarded-Method") != "POST" ||
+			ar.Header.Get("X-Forwarded-Host") != "www.example.org" {
+			t.Error("failed to forward the original request", ar.Header)
+		}
+
+		if ar.Header.Get("X-Other") != "" {
+			t.Error("unexpectedly forwarded header")
+		}
+
+		h := ctx.FRequest.Header
+		if h.Get("X-Auth-User") != "jdoe" {
+			t.Error("failed to copy the header", h.Get("X-Auth-User"))
+		}
+
+		if g := h["X-Auth-Groups"]; len(g) != 2 || g[0] != "admins" || g[1] != "users" {
+			t.Error("failed to copy the header", g)
+		}
+
+		if h.Get("X-Internal") != "" {
+			t.Error("unexpectedly copied header")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		ctx := forwardAuthRequest(t, f, "GET", "Bearer invalid-token")
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Fatal("failed to reject the request")
+		}
+
+		if ctx.FResponse.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Error("failed to return the auth service headers")
+		}
+
+		b, err := ioutil.ReadAll(ctx.FResponse.Body)
+		if err != nil || string(b) != "invalid token" {
+			t.Error("failed to return the auth service body", string(b), err)
+		}
+
+		if ctx.FRequest.Header.Get("X-Auth-User") != "" {
+			t.Error("failed to remove the header set by the client")
+		}
+	})
+
+	t.Run("redirected", func(t *testing.T) {
+		ctx := forwardAuthRequest(t, f, "GET", "")
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+			t.Fatal("failed to redirect the request")
+		}
+
+		if l := ctx.FResponse.Header.Get("Location"); l != "https://login.example.org" {
+			t.Error("failed to return the redirect", l)
+		}
+	})
+}
+
+func TestForwardAuthUnavailable(t *testing.T) {
+	ts := httptest.NewServer(&testAuthService{})
+	ts.Close()
+
+	f, err := NewForwardAuth().CreateFilter([]interface{}{ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := forwardAuthRequest(t, f, "GET", "Bearer valid-token")
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusServiceUnavailable {
+		t.Error("failed to reject the request")
+	}
+}
+
+func TestForwardAuthCache(t *testing.T) {
+	s := &testAuthService{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	f, err := NewForwardAuth().CreateFilter([]interface{}{ts.URL, "X-Auth-User", "cache=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx := forwardAuthRequest(t, f, "GET", "Bearer valid-token")
+		if ctx.FServed || ctx.FRequest.Header.Get("X-Auth-User") != "jdoe" {
+			t.Error("failed to accept the request")
+		}
+
+		ctx = forwardAuthRequest(t, f, "GET", "Bearer invalid-token")
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the request")
+		}
+
+		if b, _ := ioutil.ReadAll(ctx.FResponse.Body); string(b) != "invalid token" {
+			t.Error("failed to return the cached body", string(b))
+		}
+	}
+
+	if s.count() != 2 {
+		t.Error("failed to cache the responses", s.count())
+	}
+
+	// requests without credentials are not cached
+	forwardAuthRequest(t, f, "GET", "")
+	forwardAuthRequest(t, f, "GET", "")
+	if s.count() != 4 {
+		t.Error("unexpectedly cached the response", s.count())
+	}
+
+	// the method is part of the cache key
+	forwardAuthRequest(t, f, "DELETE", "Bearer valid-token")
+	if s.count() != 5 {
+		t.Error("unexpectedly used the cached response", s.count())
+	}
+}
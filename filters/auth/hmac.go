@@ -0,0 +1,458 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	VerifyHmacName = "verifyHmac"
+
+	// The default maximum difference between the timestamp of the signed
+	// requests and the current time.
+	DefaultHmacMaxAge = 5 * time.Minute
+
+	// The default maximum size of the buffered request body.
+	DefaultHmacMaxBody = 1 << 20
+
+	hmacTimestampOption = "timestamp="
+	hmacMaxAgeOption    = "max-age="
+	hmacMaxBodyOption   = "max-body="
+
+	hmacHeaderPlaceholder = "header:"
+)
+
+var (
+	errEmptySecrets           = errors.New("no secrets in the file")
+	errInvalidSignatureFormat = errors.New("invalid signature format")
+)
+
+type hmacPartType int
+
+const (
+	hmacLiteral hmacPartType = iota
+	hmacMethod
+	hmacPath
+	hmacURI
+	hmacHost
+	hmacBody
+	hmacTimestamp
+	hmacHeader
+)
+
+var hmacPlaceholders = map[string]hmacPartType{
+	"method":    hmacMethod,
+	"path":      hmacPath,
+	"uri":       hmacURI,
+	"host":      hmacHost,
+	"body":      hmacBody,
+	"timestamp": hmacTimestamp,
+}
+
+// a part of the canonical format, either a literal string or a
+// placeholder
+type hmacPart struct {
+	typ   hmacPartType
+	value string
+}
+
+// secretsFile holds the secrets loaded from a file, one per line. The file
+// is reloaded when it changes, checked by the requests after the check
+// interval has passed.
+type secretsFile struct {
+	path    string
+	mx      sync.Mutex
+	secrets [][]byte
+	modTime time.Time
+	checked time.Time
+}
+
+type hmacSpec struct {
+	mx    sync.Mutex
+	files map[string]*secretsFile
+}
+
+type hmacFilter struct {
+	secrets         *secretsFile
+	header          string
+	newHash         func() hash.Hash
+	format          []hmacPart
+	timestampHeader string
+	maxAge          time.Duration
+	maxBody         int64
+	signsBody       bool
+}
+
+// Returns a filter specification whose instances verify the HMAC signature
+// of the requests. See the package documentation for the details.
+//
+// Name: "verifyHmac".
+func NewVerifyHmac() filters.Spec {
+	return &hmacSpec{files: make(map[string]*secretsFile)}
+}
+
+// "verifyHmac"
+func (s *hmacSpec) Name() string { return VerifyHmacName }
+
+func parseSecrets(b []byte) ([][]byte, error) {
+	var secrets [][]byte
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		secrets = append(secrets, []byte(line))
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(secrets) == 0 {
+		return nil, errEmptySecrets
+	}
+
+	return secrets, nil
+}
+
+func (f *secretsFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := parseSecrets(b)
+	if err != nil {
+		return err
+	}
+
+	f.secrets, f.modTime = secrets, info.ModTime()
+	return nil
+}
+
+// returns the current secrets, reloading the file when it has changed
+func (f *secretsFile) current() [][]byte {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.checked) >= fileCheckInterval {
+		f.checked = now
+		if info, err := os.Stat(f.path); err != nil {
+			log.Errorf("verifyHmac: failed to check %s: %v", f.path, err)
+		} else if !info.ModTime().Equal(f.modTime) {
+			if err := f.load(); err != nil {
+				log.Errorf("verifyHmac: failed to reload %s: %v", f.path, err)
+			}
+		}
+	}
+
+	return f.secrets
+}
+
+// the secret files are shared between the routes
+func (s *hmacSpec) secretsFile(path string) (*secretsFile, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if f, ok := s.files[path]; ok {
+		return f, nil
+	}
+
+	f := &secretsFile{path: path, checked: time.Now()}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	s.files[path] = f
+	return f, nil
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, bool) {
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return sha1.New, true
+	case "sha256":
+		return sha256.New, true
+	case "sha512":
+		return sha512.New, true
+	default:
+		return nil, false
+	}
+}
+
+// parses the canonical format, e.g. "{method}\n{path}\n{body}"
+func parseHmacFormat(format string) ([]hmacPart, error) {
+	var parts []hmacPart
+	for format != "" {
+		i := strings.IndexByte(format, '{')
+		if i < 0 {
+			parts = append(parts, hmacPart{typ: hmacLiteral, value: format})
+			break
+		}
+
+		if i > 0 {
+			parts = append(parts, hmacPart{typ: hmacLiteral, value: format[:i]})
+		}
+
+		format = format[i+1:]
+		j := strings.IndexByte(format, '}')
+		if j < 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		name := format[:j]
+		format = format[j+1:]
+		if strings.HasPrefix(name, hmacHeaderPlaceholder) && len(name) > len(hmacHeaderPlaceholder) {
+			parts = append(parts, hmacPart{typ: hmacHeader, value: name[len(hmacHeaderPlaceholder):]})
+			continue
+		}
+
+		typ, ok := hmacPlaceholders[name]
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		parts = append(parts, hmacPart{typ: typ})
+	}
+
+	if len(parts) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return parts, nil
+}
+
+func (s *hmacSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) < 4 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	args := make([]string, len(config))
+	for i, c := range config {
+		a, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		args[i] = a
+	}
+
+	if args[1] == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	newHash, ok := hashFunc(args[2])
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	format, err := parseHmacFormat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &hmacFilter{
+		header:  args[1],
+		newHash: newHash,
+		format:  format,
+		maxAge:  DefaultHmacMaxAge,
+		maxBody: DefaultHmacMaxBody}
+
+	for _, o := range args[4:] {
+		switch {
+		case strings.HasPrefix(o, hmacTimestampOption):
+			f.timestampHeader = o[len(hmacTimestampOption):]
+		case strings.HasPrefix(o, hmacMaxAgeOption):
+			f.maxAge, err = time.ParseDuration(o[len(hmacMaxAgeOption):])
+			if err != nil || f.maxAge <= 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.HasPrefix(o, hmacMaxBodyOption):
+			f.maxBody, err = strconv.ParseInt(o[len(hmacMaxBodyOption):], 10, 64)
+			if err != nil || f.maxBody <= 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	// the timestamp needs to be signed, otherwise it would not protect
+	// against replaying the requests
+	var signsTimestamp bool
+	for _, p := range f.format {
+		switch p.typ {
+		case hmacTimestamp:
+			signsTimestamp = true
+		case hmacBody:
+			f.signsBody = true
+		}
+	}
+
+	if signsTimestamp != (f.timestampHeader != "") {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f.secrets, err = s.secretsFile(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// decodes the signature, accepting hex and base64 encoding, with an
+// optional algorithm prefix, e.g. sha256=<hex>
+func decodeSignature(s string, size int) ([]byte, error) {
+	if i := strings.IndexByte(s, '='); i > 0 && i < len(s)-1 {
+		if _, ok := hashFunc(s[:i]); ok {
+			s = s[i+1:]
+		}
+	}
+
+	if len(s) == 2*size {
+		if b, err := hex.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(s); err == nil && len(b) == size {
+			return b, nil
+		}
+	}
+
+	return nil, errInvalidSignatureFormat
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return http.ParseTime(s)
+}
+
+func (f *hmacFilter) canonical(r *http.Request, body []byte) []byte {
+	var b bytes.Buffer
+	for _, p := range f.format {
+		switch p.typ {
+		case hmacLiteral:
+			b.WriteString(p.value)
+		case hmacMethod:
+			b.WriteString(r.Method)
+		case hmacPath:
+			b.WriteString(r.URL.EscapedPath())
+		case hmacURI:
+			b.WriteString(r.URL.RequestURI())
+		case hmacHost:
+			b.WriteString(r.Host)
+		case hmacBody:
+			b.Write(body)
+		case hmacTimestamp:
+			b.WriteString(r.Header.Get(f.timestampHeader))
+		case hmacHeader:
+			b.WriteString(r.Header.Get(p.value))
+		}
+	}
+
+	return b.Bytes()
+}
+
+func (f *hmacFilter) verify(message, signature []byte) bool {
+	for _, secret := range f.secrets.current() {
+		mac := hmac.New(f.newHash, secret)
+		mac.Write(message)
+		if hmac.Equal(mac.Sum(nil), signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *hmacFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	unauthorized := func() { ctx.Serve(&http.Response{StatusCode: http.StatusUnauthorized}) }
+
+	signature, err := decodeSignature(r.Header.Get(f.header), f.newHash().Size())
+	if err != nil {
+		unauthorized()
+		return
+	}
+
+	if f.timestampHeader != "" {
+		t, err := parseTimestamp(r.Header.Get(f.timestampHeader))
+		if err != nil {
+			unauthorized()
+			return
+		}
+
+		if d := time.Since(t); d > f.maxAge || d < -f.maxAge {
+			unauthorized()
+			return
+		}
+	}
+
+	// the body is buffered, when it is signed, and passed on to the
+	// backend
+	var body []byte
+	if f.signsBody && r.Body != nil {
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, f.maxBody+1))
+		r.Body.Close()
+		if err != nil {
+			log.Errorf("verifyHmac: failed to read the body: %v", err)
+			ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+			return
+		}
+
+		if int64(len(body)) > f.maxBody {
+			ctx.Serve(&http.Response{StatusCode: http.StatusRequestEntityTooLarge})
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if !f.verify(f.canonical(r, body), signature) {
+		unauthorized()
+	}
+}
+
+func (f *hmacFilter) Response(filters.FilterContext) {}
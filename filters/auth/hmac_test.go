@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func sign(newHash func() hash.Hash, secret, message string) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func writeSecrets(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func createHmacFilter(t *testing.T, args ...interface{}) filters.Filter {
+	f, err := NewVerifyHmac().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func hmacRequest(t *testing.T, f filters.Filter, body string, header http.Header) *filtertest.Context {
+	req, err := http.NewRequest("POST", "https://hooks.example.org/events?source=partner", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	return ctx
+}
+
+func TestVerifyHmacArgs(t *testing.T) {
+	secrets := writeSecrets(t, "secret\n")
+	defer os.Remove(secrets)
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"missing args",
+		[]interface{}{secrets, "X-Signature", "sha256"},
+		true,
+	}, {
+		"missing file",
+		[]interface{}{"/no/such/file", "X-Signature", "sha256", "{body}"},
+		true,
+	}, {
+		"unsupported algorithm",
+		[]interface{}{secrets, "X-Signature", "md5", "{body}"},
+		true,
+	}, {
+		"unknown placeholder",
+		[]interface{}{secrets, "X-Signature", "sha256", "{foo}"},
+		true,
+	}, {
+		"unterminated placeholder",
+		[]interface{}{secrets, "X-Signature", "sha256", "{body"},
+		true,
+	}, {
+		"timestamp without header",
+		[]interface{}{secrets, "X-Signature", "sha256", "{timestamp}.{body}"},
+		true,
+	}, {
+		"timestamp header without the placeholder",
+		[]interface{}{secrets, "X-Signature", "sha256", "{body}", "timestamp=X-Timestamp"},
+		true,
+	}, {
+		"invalid max age",
+		[]interface{}{secrets, "X-Signature", "sha256", "{body}", "max-age=foo"},
+		true,
+	}, {
+		"body",
+		[]interface{}{secrets, "X-Signature", "sha256", "{body}"},
+		false,
+	}, {
+		"all parts and options",
+		[]interface{}{
+			secrets, "X-Signature", "sha512",
+			"{method}\n{path}\n{uri}\n{host}\n{header:X-Request-Id}\n{timestamp}\n{body}",
+			"timestamp=X-Timestamp", "max-age=1m", "max-body=1024",
+		},
+		false,
+	}} {
+		_, err := NewVerifyHmac().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestVerifyHmac(t *testing.T) {
+	secrets := writeSecrets(t, "# rotated secrets\nnew-secret\nold-secret\n")
+	defer os.Remove(secrets)
+
+	const body = `{"event": "order.created"}`
+	f := createHmacFilter(t, secrets, "X-Signature", "sha256", "{body}")
+
+	for _, ti := range []struct {
+		msg       string
+		signature string
+		valid     bool
+	}{{
+		"missing signature",
+		"",
+		false,
+	}, {
+		"hex",
+		hex.EncodeToString(sign(sha256.New, "new-secret", body)),
+		true,
+	}, {
+		"hex with prefix",
+		"sha256=" + hex.EncodeToString(sign(sha256.New, "new-secret", body)),
+		true,
+	}, {
+		"base64",
+		base64.StdEncoding.EncodeToString(sign(sha256.New, "new-secret", body)),
+		true,
+	}, {
+		"old secret",
+		hex.EncodeToString(sign(sha256.New, "old-secret", body)),
+		true,
+	}, {
+		"unknown secret",
+		hex.EncodeToString(sign(sha256.New, "other-secret", body)),
+		false,
+	}, {
+		"other algorithm",
+		hex.EncodeToString(sign(sha1.New, "new-secret", body)),
+		false,
+	}, {
+		"other body",
+		hex.EncodeToString(sign(sha256.New, "new-secret", body+" ")),
+		false,
+	}} {
+		ctx := hmacRequest(t, f, body, http.Header{"X-Signature": []string{ti.signature}})
+		if ti.valid && ctx.FServed {
+			t.Error(ti.msg, "failed to accept the request", ctx.FResponse.StatusCode)
+			continue
+		}
+
+		if !ti.valid {
+			if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+				t.Error(ti.msg, "failed to reject the request")
+			}
+
+			continue
+		}
+
+		b, err := ioutil.ReadAll(ctx.FRequest.Body)
+		if err != nil || string(b) != body {
+			t.Error(ti.msg, "failed to pass on the body", string(b), err)
+		}
+	}
+}
+
+func TestVerifyHmacCanonicalFormat(t *testing.T) {
+	secrets := writeSecrets(t, "secret\n")
+	defer os.Remove(secrets)
+
+	const body = "payload"
+	f := createHmacFilter(t,
+		secrets, "X-Signature", "sha256",
+		"{method}\n{uri}\n{host}\n{header:X-Request-Id}\n{timestamp}\n{body}",
+		"timestamp=X-Timestamp", "max-age=1m")
+
+	signed := func(ts time.Time, requestID string) http.Header {
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		message := "POST\n/events?source=partner\nhooks.example.org\nabc123\n" + timestamp + "\n" + body
+		return http.Header{
+			"X-Signature":  []string{hex.EncodeToString(sign(sha256.New, "secret", message))},
+			"X-Timestamp":  []string{timestamp},
+			"X-Request-Id": []string{requestID},
+		}
+	}
+
+	if ctx := hmacRequest(t, f, body, signed(time.Now(), "abc123")); ctx.FServed {
+		t.Error("failed to accept the request", ctx.FResponse.StatusCode)
+	}
+
+	if ctx := hmacRequest(t, f, body, signed(time.Now(), "def456")); !ctx.FServed {
+		t.Error("failed to reject the request with a modified header")
+	}
+
+	if ctx := hmacRequest(t, f, body, signed(time.Now().Add(-2*time.Minute), "abc123")); !ctx.FServed ||
+		ctx.FResponse.StatusCode != http.StatusUnauthorized {
+		t.Error("failed to reject the stale request")
+	}
+
+	if ctx := hmacRequest(t, f, body, signed(time.Now().Add(2*time.Minute), "abc123")); !ctx.FServed {
+		t.Error("failed to reject the request from the future")
+	}
+}
+
+func TestVerifyHmacMaxBody(t *testing.T) {
+	secrets := writeSecrets(t, "secret\n")
+	defer os.Remove(secrets)
+
+	const body = "0123456789"
+	f := createHmacFilter(t, secrets, "X-Signature", "sha256", "{body}", "max-body=8")
+	header := http.Header{"X-Signature": []string{hex.EncodeToString(sign(sha256.New, "secret", body))}}
+	if ctx := hmacRequest(t, f, body, header); !ctx.FServed || ctx.FResponse.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Error("failed to reject the large body")
+	}
+}
+
+func TestVerifyHmacBodyNotSigned(t *testing.T) {
+	secrets := writeSecrets(t, "secret\n")
+	defer os.Remove(secrets)
+
+	const body = "0123456789"
+	f := createHmacFilter(t, secrets, "X-Signature", "sha256", "{method} {path}", "max-body=8")
+	header := http.Header{"X-Signature": []string{hex.EncodeToString(sign(sha256.New, "secret", "POST /events"))}}
+	ctx := hmacRequest(t, f, body, header)
+	if ctx.FServed {
+		t.Fatal("failed to accept the request", ctx.FResponse.StatusCode)
+	}
+
+	if b, err := ioutil.ReadAll(ctx.FRequest.Body); err != nil || string(b) != body {
+		t.Error("failed to pass on the body", string(b), err)
+	}
+}
+
+func TestVerifyHmacReload(t *testing.T) {
+	secrets := writeSecrets(t, "old-secret\n")
+	defer os.Remove(secrets)
+
+	const body = "payload"
+	spec := NewVerifyHmac().(*hmacSpec)
+	f, err := spec.CreateFilter([]interface{}{secrets, "X-Signature", "sha256", "{body}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"X-Signature": []string{hex.EncodeToString(sign(sha256.New, "new-secret", body))}}
+	if ctx := hmacRequest(t, f, body, header); !ctx.FServed {
+		t.Fatal("unexpectedly accepted the request")
+	}
+
+	if err := ioutil.WriteFile(secrets, []byte("new-secret\nold-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(secrets, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	// skip waiting for the check interval
+	sf := spec.files[secrets]
+	sf.mx.Lock()
+	sf.checked = time.Time{}
+	sf.mx.Unlock()
+
+	if ctx := hmacRequest(t, f, body, header); ctx.FServed {
+		t.Error("failed to reload the secrets")
+	}
+}
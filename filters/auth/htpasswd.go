@@ -0,0 +1,345 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	httpauth "github.com/abbot/go-http-auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// the files are checked for changes at most once in this period
+	fileCheckInterval = time.Second
+
+	shaCryptRoundsPrefix  = "rounds="
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSalt       = 16
+	cryptAlphabet         = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+var (
+	errEmptyHtpasswd    = errors.New("no users in the htpasswd file")
+	errInvalidHtpasswd  = errors.New("invalid htpasswd entry")
+	errUnsupportedHash  = errors.New("unsupported password hash")
+	errPasswordMismatch = errors.New("password mismatch")
+)
+
+// the byte order of the encoded SHA-256 and SHA-512 crypt digests, in
+// groups of three bytes
+var (
+	sha256CryptOrder = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+
+	sha512CryptOrder = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+		{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+		{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+		{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+		{62, 20, 41},
+	}
+)
+
+// htpasswdFile holds the users loaded from an htpasswd file. The file is
+// reloaded when it changes, checked by the requests after the check
+// interval has passed. When the reload fails, the previous users are
+// used.
+type htpasswdFile struct {
+	path    string
+	mx      sync.Mutex
+	users   map[string]string
+	dummy   string
+	modTime time.Time
+	checked time.Time
+}
+
+func parseHtpasswd(b []byte) (map[string]string, string, error) {
+	var dummy string
+	users := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := strings.SplitN(line, ":", 2)
+		if len(entry) != 2 || entry[0] == "" || entry[1] == "" {
+			return nil, "", errInvalidHtpasswd
+		}
+
+		users[entry[0]] = entry[1]
+		if dummy == "" {
+			dummy = entry[1]
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(users) == 0 {
+		return nil, "", errEmptyHtpasswd
+	}
+
+	return users, dummy, nil
+}
+
+func loadHtpasswd(path string) (*htpasswdFile, error) {
+	h := &htpasswdFile{path: path}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *htpasswdFile) load() error {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(f); err != nil {
+		return err
+	}
+
+	users, dummy, err := parseHtpasswd(b.Bytes())
+	if err != nil {
+		return err
+	}
+
+	h.users, h.dummy, h.modTime = users, dummy, info.ModTime()
+	return nil
+}
+
+// returns the hash of a user, reloading the file when it has changed. When
+// the user doesn't exist, it returns the hash of another user, to be used
+// for a dummy comparison.
+func (h *htpasswdFile) lookup(user string) (string, bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.checked) >= fileCheckInterval {
+		h.checked = now
+		if info, err := os.Stat(h.path); err != nil {
+			log.Errorf("basicAuth: failed to check %s: %v", h.path, err)
+		} else if !info.ModTime().Equal(h.modTime) {
+			if err := h.load(); err != nil {
+				log.Errorf("basicAuth: failed to reload %s: %v", h.path, err)
+			}
+		}
+	}
+
+	secret, ok := h.users[user]
+	if !ok {
+		return h.dummy, false
+	}
+
+	return secret, true
+}
+
+// the user lookup takes the same time whether the user exists or not,
+// because the password is always compared with a hash
+func (h *htpasswdFile) check(user, password string) bool {
+	secret, exists := h.lookup(user)
+	err := verifyPassword(secret, password)
+	return exists && err == nil
+}
+
+func constantTimeEqual(a, b string) error {
+	if subtle.ConstantTimeCompare([]byte(a), []byte(b)) != 1 {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func verifyPassword(secret, password string) error {
+	switch {
+	case strings.HasPrefix(secret, "$2a$"),
+		strings.HasPrefix(secret, "$2b$"),
+		strings.HasPrefix(secret, "$2x$"),
+		strings.HasPrefix(secret, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(secret), []byte(password))
+	case strings.HasPrefix(secret, "$5$"):
+		return constantTimeEqual(secret, shaCrypt(sha256.New, "$5$", sha256CryptOrder, password, secret))
+	case strings.HasPrefix(secret, "$6$"):
+		return constantTimeEqual(secret, shaCrypt(sha512.New, "$6$", sha512CryptOrder, password, secret))
+	case strings.HasPrefix(secret, "$apr1$"), strings.HasPrefix(secret, "$1$"):
+		parts := strings.SplitN(secret, "$", 4)
+		if len(parts) != 4 {
+			return errUnsupportedHash
+		}
+
+		crypted := httpauth.MD5Crypt([]byte(password), []byte(parts[2]), []byte("$"+parts[1]+"$"))
+		return constantTimeEqual(secret, string(crypted))
+	case strings.HasPrefix(secret, "{SHA}"):
+		d := sha1.Sum([]byte(password))
+		return constantTimeEqual(secret[len("{SHA}"):], base64.StdEncoding.EncodeToString(d[:]))
+	default:
+		return errUnsupportedHash
+	}
+}
+
+func cryptEncode(b []byte, n int) []byte {
+	v := uint(0)
+	for i := range b {
+		v |= uint(b[i]) << (8 * uint(len(b)-1-i))
+	}
+
+	out := make([]byte, n)
+	for i := 0; i < n; i++ {
+		out[i] = cryptAlphabet[v&0x3f]
+		v >>= 6
+	}
+
+	return out
+}
+
+func repeatDigest(d []byte, n int) []byte {
+	b := make([]byte, 0, n)
+	for len(b) < n {
+		rest := n - len(b)
+		if rest > len(d) {
+			rest = len(d)
+		}
+
+		b = append(b, d[:rest]...)
+	}
+
+	return b
+}
+
+// shaCrypt implements the SHA-256 and SHA-512 based crypt as specified in
+// https://www.akkadia.org/drepper/SHA-crypt.txt, taking the salt and the
+// rounds from an existing hash
+func shaCrypt(newHash func() hash.Hash, magic string, order [][3]int, password, setting string) string {
+	setting = strings.TrimPrefix(setting, magic)
+	rounds, customRounds := shaCryptDefaultRounds, false
+	if strings.HasPrefix(setting, shaCryptRoundsPrefix) {
+		parts := strings.SplitN(setting[len(shaCryptRoundsPrefix):], "$", 2)
+		if r, err := strconv.Atoi(parts[0]); err == nil && len(parts) == 2 {
+			rounds, customRounds, setting = r, true, parts[1]
+			if rounds < shaCryptMinRounds {
+				rounds = shaCryptMinRounds
+			} else if rounds > shaCryptMaxRounds {
+				rounds = shaCryptMaxRounds
+			}
+		}
+	}
+
+	salt := setting
+	if i := strings.IndexByte(salt, '$'); i >= 0 {
+		salt = salt[:i]
+	}
+
+	if len(salt) > shaCryptMaxSalt {
+		salt = salt[:shaCryptMaxSalt]
+	}
+
+	p, s := []byte(password), []byte(salt)
+
+	h := newHash()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+
+	h = newHash()
+	h.Write(p)
+	h.Write(s)
+	h.Write(repeatDigest(b, len(p)))
+	for l := len(p); l > 0; l >>= 1 {
+		if l&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+
+	a := h.Sum(nil)
+
+	h = newHash()
+	for range p {
+		h.Write(p)
+	}
+
+	pp := repeatDigest(h.Sum(nil), len(p))
+
+	h = newHash()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+
+	ss := repeatDigest(h.Sum(nil), len(s))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h = newHash()
+		if i&1 != 0 {
+			h.Write(pp)
+		} else {
+			h.Write(c)
+		}
+
+		if i%3 != 0 {
+			h.Write(ss)
+		}
+
+		if i%7 != 0 {
+			h.Write(pp)
+		}
+
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pp)
+		}
+
+		c = h.Sum(nil)
+	}
+
+	out := []byte(magic)
+	if customRounds {
+		out = append(out, shaCryptRoundsPrefix+strconv.Itoa(rounds)+"$"...)
+	}
+
+	out = append(out, salt+"$"...)
+	for _, g := range order {
+		out = append(out, cryptEncode([]byte{c[g[0]], c[g[1]], c[g[2]]}, 4)...)
+	}
+
+	if len(c) == sha512.Size {
+		out = append(out, cryptEncode([]byte{c[63]}, 2)...)
+	} else {
+		out = append(out, cryptEncode([]byte{c[31], c[30]}, 3)...)
+	}
+
+	return string(out)
+}
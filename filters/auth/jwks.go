@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	algRS256 = "RS256"
+	algES256 = "ES256"
+	algHS256 = "HS256"
+
+	jwksTimeout = 10 * time.Second
+
+	// when no keys could be loaded from a URL, retry sooner than the
+	// refresh period
+	jwksRetry = time.Second
+)
+
+var errNoKeys = errors.New("no usable keys in the key set")
+
+// a single key of a JSON Web Key Set, as defined in RFC 7517 and RFC 7518
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// a parsed key, that can be used to verify the signatures with a single
+// algorithm
+type verificationKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// keySet holds the keys loaded from a JWKS file or URL. When loaded from a
+// URL, the keys are refreshed in the background, triggered by the
+// requests after the refresh period has passed.
+type keySet struct {
+	source     string
+	refresh    time.Duration
+	client     *http.Client
+	mx         sync.Mutex
+	keys       []*verificationKey
+	loaded     time.Time
+	refreshing bool
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) parse() (*verificationKey, error) {
+	var (
+		alg string
+		key interface{}
+	)
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		alg, key = algRS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid EC key")
+		}
+
+		alg, key = algES256, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "oct":
+		secret, err := decodeSegment(k.K)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(secret) == 0 {
+			return nil, errors.New("empty secret")
+		}
+
+		alg, key = algHS256, secret
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+
+	if k.Alg != "" && k.Alg != alg {
+		return nil, fmt.Errorf("unsupported algorithm: %s", k.Alg)
+	}
+
+	return &verificationKey{kid: k.Kid, alg: alg, key: key}, nil
+}
+
+// parses a JWKS document, skipping the keys that are not meant for
+// signature verification, or cannot be used
+func parseJWKS(b []byte) ([]*verificationKey, error) {
+	var doc jwks
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	var keys []*verificationKey
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		vk, err := k.parse()
+		if err != nil {
+			log.Warnf("jwks: skipping key %s: %v", k.Kid, err)
+			continue
+		}
+
+		keys = append(keys, vk)
+	}
+
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+
+	return keys, nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+func newKeySet(source string, refresh time.Duration) *keySet {
+	return &keySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: jwksTimeout}}
+}
+
+func (ks *keySet) fetch() ([]byte, error) {
+	if !isURL(ks.source) {
+		return ioutil.ReadFile(ks.source)
+	}
+
+	rsp, err := ks.client.Get(ks.source)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch key set, status: %d", rsp.StatusCode)
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+func (ks *keySet) load() error {
+	var keys []*verificationKey
+	b, err := ks.fetch()
+	if err == nil {
+		keys, err = parseJWKS(b)
+	}
+
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+
+	if err == nil {
+		ks.keys = keys
+	}
+
+	ks.loaded = time.Now()
+	ks.refreshing = false
+	return err
+}
+
+func (ks *keySet) update() {
+	if err := ks.load(); err != nil {
+		log.Errorf("jwks: failed to refresh keys from %s: %v", ks.source, err)
+	}
+}
+
+// returns the current keys, and starts refreshing them in the background
+// when the refresh period has passed. Until the refresh succeeds, the
+// previous keys are used.
+func (ks *keySet) current() []*verificationKey {
+	ks.mx.Lock()
+	defer ks.mx.Unlock()
+
+	age := time.Since(ks.loaded)
+	expired := age > ks.refresh || len(ks.keys) == 0 && age > jwksRetry
+	if ks.refresh > 0 && !ks.refreshing && expired {
+		ks.refreshing = true
+		go ks.update()
+	}
+
+	return ks.keys
+}
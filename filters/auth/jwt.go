@@ -0,0 +1,399 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
+)
+
+const (
+	JwtValidationName = "jwtValidation"
+
+	// The key in the state bag, where the jwtValidation filter stores
+	// the claims of the validated token, as map[string]interface{}.
+	JwtClaimsKey = "jwtClaims"
+
+	// The default refresh period of the keys loaded from a JWKS URL.
+	DefaultJwksRefresh = 5 * time.Minute
+
+	jwtIssuerOption   = "issuer="
+	jwtAudienceOption = "audience="
+	jwtRefreshOption  = "refresh="
+	jwtLeewayOption   = "leeway="
+	jwtRealmOption    = "realm="
+
+	bearerPrefix = "Bearer "
+)
+
+var (
+	errMissingToken     = errors.New("missing token")
+	errMalformedToken   = errors.New("malformed token")
+	errUnsupportedAlg   = errors.New("unsupported algorithm")
+	errInvalidSignature = errors.New("invalid signature")
+	errExpired          = errors.New("token expired")
+	errNotYetValid      = errors.New("token not yet valid")
+	errInvalidIssuer    = errors.New("invalid issuer")
+	errInvalidAudience  = errors.New("invalid audience")
+)
+
+type jwtSpec struct {
+	mx      sync.Mutex
+	keySets map[string]*jwksEntry
+}
+
+// a shared key set. The keys are loaded without holding the lock of the
+// spec, and the concurrent callers wait until the loading is done. The
+// key sets loaded from files are reloaded when the modification time or
+// the size of the file changes.
+type jwksEntry struct {
+	keys    *keySet
+	err     error
+	modTime time.Time
+	size    int64
+	loaded  chan struct{}
+}
+
+type jwtValidation struct {
+	keys     *keySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	realm    string
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Returns a filter specification whose instances validate the bearer
+// tokens of the requests as JSON Web Tokens. See the package documentation
+// for the details.
+//
+// Name: "jwtValidation".
+func NewJwtValidation() filters.Spec {
+	return &jwtSpec{keySets: make(map[string]*jwksEntry)}
+}
+
+// "jwtValidation"
+func (s *jwtSpec) Name() string { return JwtValidationName }
+
+// the key sets are shared between the filter instances using the same
+// source with the same refresh period, so that the routes don't fetch
+// the same keys multiple times
+func (s *jwtSpec) keySet(source string, refresh time.Duration) (*keySet, error) {
+	var (
+		modTime time.Time
+		size    int64
+	)
+
+	if !isURL(source) {
+		fi, err := os.Stat(source)
+		if err != nil {
+			return nil, err
+		}
+
+		modTime, size = fi.ModTime(), fi.Size()
+	}
+
+	id := fmt.Sprintf("%s|%v", source, refresh)
+
+	s.mx.Lock()
+	e, ok := s.keySets[id]
+	if ok && e.modTime.Equal(modTime) && e.size == size {
+		s.mx.Unlock()
+		<-e.loaded
+		return e.keys, e.err
+	}
+
+	e = &jwksEntry{modTime: modTime, size: size, loaded: make(chan struct{})}
+	s.keySets[id] = e
+	s.mx.Unlock()
+
+	defer close(e.loaded)
+	ks := newKeySet(source, refresh)
+	if err := ks.load(); err != nil {
+		if !isURL(source) {
+			e.err = err
+			return nil, err
+		}
+
+		// the key server may be temporarily unavailable, the keys are
+		// fetched again with the next refresh
+		log.Errorf("jwks: failed to load keys from %s: %v", source, err)
+	}
+
+	e.keys = ks
+	return ks, nil
+}
+
+func (s *jwtSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	source, ok := config[0].(string)
+	if !ok || source == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &jwtValidation{}
+	refresh := DefaultJwksRefresh
+	for _, c := range config[1:] {
+		o, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(o, jwtIssuerOption):
+			f.issuer = o[len(jwtIssuerOption):]
+		case strings.HasPrefix(o, jwtAudienceOption):
+			f.audience = o[len(jwtAudienceOption):]
+		case strings.HasPrefix(o, jwtRefreshOption):
+			refresh, err = time.ParseDuration(o[len(jwtRefreshOption):])
+		case strings.HasPrefix(o, jwtLeewayOption):
+			f.leeway, err = time.ParseDuration(o[len(jwtLeewayOption):])
+		case strings.HasPrefix(o, jwtRealmOption):
+			f.realm = o[len(jwtRealmOption):]
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if err != nil || refresh <= 0 || f.leeway < 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	// keys from files are not refreshed, only reloaded when the file
+	// changes and the routes are created again
+	if !isURL(source) {
+		refresh = 0
+	}
+
+	ks, err := s.keySet(source, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	f.keys = ks
+	return f, nil
+}
+
+func verifySignature(k *verificationKey, input, sig []byte) bool {
+	h := sha256.Sum256(input)
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig) == nil
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, h[:], r, s)
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), sig)
+	default:
+		return false
+	}
+}
+
+func (f *jwtValidation) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+
+	hb, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	var h jwtHeader
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, errMalformedToken
+	}
+
+	switch h.Alg {
+	case algRS256, algES256, algHS256:
+	default:
+		return nil, errUnsupportedAlg
+	}
+
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	// the algorithm of the token needs to match the type of the key, to
+	// prevent using a public key as an HMAC secret
+	input := []byte(parts[0] + "." + parts[1])
+	var verified bool
+	for _, k := range f.keys.current() {
+		if k.alg != h.Alg || h.Kid != "" && k.kid != h.Kid {
+			continue
+		}
+
+		if verifySignature(k, input, sig) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, errInvalidSignature
+	}
+
+	cb, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(cb, &claims); err != nil {
+		return nil, errMalformedToken
+	}
+
+	return claims, f.validateClaims(claims)
+}
+
+func numericDate(claims map[string]interface{}, key string) (time.Time, bool, error) {
+	v, ok := claims[key]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	n, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false, errMalformedToken
+	}
+
+	return time.Unix(int64(n), 0), true, nil
+}
+
+func (f *jwtValidation) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	exp, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+
+	if ok && !now.Before(exp.Add(f.leeway)) {
+		return errExpired
+	}
+
+	nbf, ok, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+
+	if ok && now.Add(f.leeway).Before(nbf) {
+		return errNotYetValid
+	}
+
+	if f.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != f.issuer {
+			return errInvalidIssuer
+		}
+	}
+
+	if f.audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud != f.audience {
+				return errInvalidAudience
+			}
+		case []interface{}:
+			var found bool
+			for _, a := range aud {
+				if a == f.audience {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return errInvalidAudience
+			}
+		default:
+			return errInvalidAudience
+		}
+	}
+
+	return nil
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(bearerPrefix) || !strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+		return "", errMissingToken
+	}
+
+	return strings.TrimSpace(h[len(bearerPrefix):]), nil
+}
+
+// responds with 401, and the WWW-Authenticate header as defined in
+// RFC 6750
+func (f *jwtValidation) unauthorized(ctx filters.FilterContext, err error) {
+	var params []string
+	if f.realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", f.realm))
+	}
+
+	if err != errMissingToken {
+		params = append(params, `error="invalid_token"`, fmt.Sprintf("error_description=%q", err.Error()))
+	}
+
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+
+	header := http.Header{}
+	header.Set(ForceBasicAuthHeaderName, challenge)
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header:     header})
+}
+
+func (f *jwtValidation) Request(ctx filters.FilterContext) {
+	token, err := bearerToken(ctx.Request())
+	if err != nil {
+		f.unauthorized(ctx, err)
+		return
+	}
+
+	claims, err := f.verify(token)
+	if err != nil {
+		f.unauthorized(ctx, err)
+		return
+	}
+
+	ctx.StateBag()[JwtClaimsKey] = claims
+	if sub, ok := claims["sub"].(string); ok {
+		logging.SetAuthUser(ctx.Request(), sub)
+	}
+}
+
+func (f *jwtValidation) Response(filters.FilterContext) {}
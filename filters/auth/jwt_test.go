@@ -0,0 +1,519 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{rsa: rk, ec: ek, secret: []byte("test-secret-with-enough-length!!")}
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func padded(i *big.Int, size int) []byte {
+	b := i.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func (k *testKeys) jwks(kidPrefix string) []byte {
+	doc := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kidPrefix + "rsa",
+		"use": "sig",
+		"n":   b64(k.rsa.N.Bytes()),
+		"e":   b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+	}, {
+		"kty": "EC",
+		"kid": kidPrefix + "ec",
+		"crv": "P-256",
+		"x":   b64(padded(k.ec.X, 32)),
+		"y":   b64(padded(k.ec.Y, 32)),
+	}, {
+		"kty": "oct",
+		"kid": kidPrefix + "hmac",
+		"alg": "HS256",
+		"k":   b64(k.secret),
+	}, {
+		"kty": "RSA",
+		"kid": kidPrefix + "enc",
+		"use": "enc",
+		"n":   b64(k.rsa.N.Bytes()),
+		"e":   b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+	}}}
+
+	b, _ := json.Marshal(doc)
+	return b
+}
+
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	h := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		h["kid"] = kid
+	}
+
+	hb, _ := json.Marshal(h)
+	cb, _ := json.Marshal(claims)
+	input := b64(hb) + "." + b64(cb)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case algRS256:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case algES256:
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig = append(padded(r, 32), padded(s, 32)...)
+	case algHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "none":
+	}
+
+	return input + "." + b64(sig)
+}
+
+func writeJWKSFile(t *testing.T, b []byte) string {
+	f, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestJwtValidationArgs(t *testing.T) {
+	keys := newTestKeys(t)
+	file := writeJWKSFile(t, keys.jwks(""))
+	defer os.Remove(file)
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"invalid source",
+		[]interface{}{42.0},
+		true,
+	}, {
+		"missing file",
+		[]interface{}{"/no/such/jwks.json"},
+		true,
+	}, {
+		"invalid option",
+		[]interface{}{file, "foo=bar"},
+		true,
+	}, {
+		"invalid refresh",
+		[]interface{}{file, "refresh=soon"},
+		true,
+	}, {
+		"negative leeway",
+		[]interface{}{file, "leeway=-1s"},
+		true,
+	}, {
+		"unreachable URL is retried later",
+		[]interface{}{"http://127.0.0.1:1/jwks.json"},
+		false,
+	}, {
+		"valid",
+		[]interface{}{file, "issuer=https://issuer.example.org", "audience=api", "leeway=30s", "realm=api"},
+		false,
+	}} {
+		_, err := NewJwtValidation().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestJwtValidation(t *testing.T) {
+	keys := newTestKeys(t)
+	otherKeys := newTestKeys(t)
+	file := writeJWKSFile(t, keys.jwks(""))
+	defer os.Remove(file)
+
+	now := time.Now().Unix()
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "jdoe",
+			"iss": "https://issuer.example.org",
+			"aud": "api",
+			"exp": now + 3600,
+			"nbf": now - 60,
+		}
+	}
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+
+		return c
+	}
+
+	options := []interface{}{"issuer=https://issuer.example.org", "audience=api", "realm=api"}
+
+	for _, ti := range []struct {
+		msg           string
+		options       []interface{}
+		authorization string
+		expectValid   bool
+		expectError   string
+	}{{
+		msg:         "missing token",
+		expectError: "",
+	}, {
+		msg:           "not bearer",
+		authorization: "Basic Zm9vOmJhcg==",
+		expectError:   "",
+	}, {
+		msg:           "malformed",
+		authorization: "Bearer foo.bar",
+		expectError:   "malformed token",
+	}, {
+		msg:           "RS256",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", validClaims()),
+		expectValid:   true,
+	}, {
+		msg:           "ES256",
+		authorization: "Bearer " + keys.sign(t, algES256, "ec", validClaims()),
+		expectValid:   true,
+	}, {
+		msg:           "HS256",
+		authorization: "Bearer " + keys.sign(t, algHS256, "hmac", validClaims()),
+		expectValid:   true,
+	}, {
+		msg:           "no kid",
+		authorization: "Bearer " + keys.sign(t, algES256, "", validClaims()),
+		expectValid:   true,
+	}, {
+		msg:           "unknown kid",
+		authorization: "Bearer " + keys.sign(t, algRS256, "foo", validClaims()),
+		expectError:   "invalid signature",
+	}, {
+		msg:           "wrong key",
+		authorization: "Bearer " + otherKeys.sign(t, algRS256, "rsa", validClaims()),
+		expectError:   "invalid signature",
+	}, {
+		msg:           "encryption key not used",
+		authorization: "Bearer " + keys.sign(t, algRS256, "enc", validClaims()),
+		expectError:   "invalid signature",
+	}, {
+		msg:           "algorithm does not match the key",
+		authorization: "Bearer " + keys.sign(t, algHS256, "rsa", validClaims()),
+		expectError:   "invalid signature",
+	}, {
+		msg:           "alg none",
+		authorization: "Bearer " + keys.sign(t, "none", "", validClaims()),
+		expectError:   "unsupported algorithm",
+	}, {
+		msg:           "expired",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("exp", now-10)),
+		expectError:   "token expired",
+	}, {
+		msg:           "expired, within leeway",
+		options:       []interface{}{"leeway=1m"},
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("exp", now-10)),
+		expectValid:   true,
+	}, {
+		msg:           "not yet valid",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("nbf", now+600)),
+		expectError:   "token not yet valid",
+	}, {
+		msg:           "invalid issuer",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("iss", "https://evil.example.org")),
+		expectError:   "invalid issuer",
+	}, {
+		msg:           "missing audience",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("aud", nil)),
+		expectError:   "invalid audience",
+	}, {
+		msg:           "audience list",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("aud", []string{"other", "api"})),
+		expectValid:   true,
+	}, {
+		msg:           "audience list without the audience",
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("aud", []string{"other"})),
+		expectError:   "invalid audience",
+	}, {
+		msg:           "no issuer and audience configured",
+		options:       []interface{}{},
+		authorization: "Bearer " + keys.sign(t, algRS256, "rsa", with("iss", "https://other.example.org")),
+		expectValid:   true,
+	}} {
+		o := ti.options
+		if o == nil {
+			o = options
+		} else if len(o) > 0 {
+			o = append(append([]interface{}{}, options...), o...)
+		}
+
+		f, err := NewJwtValidation().CreateFilter(append([]interface{}{file}, o...))
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		req := &http.Request{Header: http.Header{}}
+		if ti.authorization != "" {
+			req.Header.Set("Authorization", ti.authorization)
+		}
+
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		if ti.expectValid {
+			if ctx.FServed {
+				t.Error(ti.msg, "unexpected rejection", ctx.FResponse.Header.Get("WWW-Authenticate"))
+				continue
+			}
+
+			claims, ok := ctx.FStateBag[JwtClaimsKey].(map[string]interface{})
+			if !ok || claims["sub"] != "jdoe" {
+				t.Error(ti.msg, "claims not stored in the state bag")
+			}
+
+			continue
+		}
+
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error(ti.msg, "failed to reject the request")
+			continue
+		}
+
+		challenge := ctx.FResponse.Header.Get("WWW-Authenticate")
+		expected := `Bearer realm="api"`
+		if ti.expectError != "" {
+			expected += `, error="invalid_token", error_description="` + ti.expectError + `"`
+		}
+
+		if challenge != expected {
+			t.Error(ti.msg, "invalid challenge", challenge)
+		}
+
+		if _, ok := ctx.FStateBag[JwtClaimsKey]; ok {
+			t.Error(ti.msg, "unexpected claims in the state bag")
+		}
+	}
+}
+
+func TestJwksRefresh(t *testing.T) {
+	keys := newTestKeys(t)
+	rotated := newTestKeys(t)
+
+	var (
+		mx      sync.Mutex
+		current = keys.jwks("v1-")
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+		w.Write(current)
+	}))
+	defer s.Close()
+
+	f, err := NewJwtValidation().CreateFilter([]interface{}{s.URL, "refresh=30ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func(token string) bool {
+		req := &http.Request{Header: http.Header{"Authorization": []string{"Bearer " + token}}}
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		return !ctx.FServed
+	}
+
+	claims := map[string]interface{}{"sub": "jdoe"}
+	if !valid(keys.sign(t, algRS256, "v1-rsa", claims)) {
+		t.Fatal("failed to validate token with the initial keys")
+	}
+
+	rotatedToken := rotated.sign(t, algES256, "v2-ec", claims)
+	if valid(rotatedToken) {
+		t.Fatal("unexpected validation with unknown key")
+	}
+
+	mx.Lock()
+	current = rotated.jwks("v2-")
+	mx.Unlock()
+
+	timeout := time.After(3 * time.Second)
+	for !valid(rotatedToken) {
+		select {
+		case <-timeout:
+			t.Fatal("failed to refresh the keys")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if valid(keys.sign(t, algRS256, "v1-rsa", claims)) {
+		t.Error("old key still valid after rotation")
+	}
+}
+
+func TestJwtKeySetsShared(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		requests int
+	)
+
+	keys := newTestKeys(t)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		requests++
+		mx.Unlock()
+		w.Write(keys.jwks(""))
+	}))
+	defer s.Close()
+
+	spec := NewJwtValidation()
+	for i := 0; i < 3; i++ {
+		if _, err := spec.CreateFilter([]interface{}{s.URL, "audience=api" + strings.Repeat("x", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if requests != 1 {
+		t.Error("failed to share the key set", requests)
+	}
+}
+
+func TestJwksFileReloaded(t *testing.T) {
+	keys := newTestKeys(t)
+	rotated := newTestKeys(t)
+	file := writeJWKSFile(t, keys.jwks("v1-"))
+	defer os.Remove(file)
+
+	spec := NewJwtValidation()
+	valid := func(token string) bool {
+		f, err := spec.CreateFilter([]interface{}{file})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := &http.Request{Header: http.Header{"Authorization": []string{"Bearer " + token}}}
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+		return !ctx.FServed
+	}
+
+	claims := map[string]interface{}{"sub": "jdoe"}
+	if !valid(keys.sign(t, algRS256, "v1-rsa", claims)) {
+		t.Fatal("failed to validate token with the initial keys")
+	}
+
+	if err := ioutil.WriteFile(file, rotated.jwks("v2-"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the modification time may have a low resolution
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if !valid(rotated.sign(t, algRS256, "v2-rsa", claims)) {
+		t.Error("failed to reload the changed key file")
+	}
+
+	if valid(keys.sign(t, algRS256, "v1-rsa", claims)) {
+		t.Error("old key still valid after the file changed")
+	}
+}
+
+func TestJwksSlowURLDoesNotBlockOtherSources(t *testing.T) {
+	keys := newTestKeys(t)
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write(keys.jwks(""))
+	}))
+	defer s.Close()
+	defer close(release)
+
+	file := writeJWKSFile(t, keys.jwks(""))
+	defer os.Remove(file)
+
+	spec := NewJwtValidation()
+	go spec.CreateFilter([]interface{}{s.URL})
+
+	// waiting until the slow fetch has started
+	time.Sleep(30 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := spec.CreateFilter([]interface{}{file})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("creating the filter was blocked by the slow key server")
+	}
+}
@@ -0,0 +1,584 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/cookie"
+	"github.com/zalando/skipper/logging"
+)
+
+const (
+	OAuthOidcName = "oauthOidc"
+
+	// The key in the state bag, where the oauthOidc filter stores the
+	// claims of the ID token of the session, as map[string]interface{}.
+	OidcClaimsKey = "oidcClaims"
+
+	// The default name of the session cookie.
+	DefaultOidcCookieName = "skipper-oidc"
+
+	// The default lifetime of the sessions.
+	DefaultOidcSession = time.Hour
+
+	oidcScopesOption  = "scopes="
+	oidcHeaderOption  = "header="
+	oidcCookieOption  = "cookie="
+	oidcSessionOption = "session="
+
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	oidcStateSuffix   = "-state"
+	oidcStateTTL      = 10 * time.Minute
+	oidcTimeout       = 10 * time.Second
+	oidcRetryDelay    = 10 * time.Second
+)
+
+var (
+	errInvalidCookie = errors.New("invalid cookie")
+	errInvalidNonce  = errors.New("invalid nonce")
+)
+
+// the subset of the provider metadata used by the filter, as defined in
+// OpenID Connect Discovery 1.0
+type oidcConfig struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider discovers the endpoints and the keys of an OpenID provider
+// on the first use, so that the routes can be created while the provider
+// is not available. Failed discoveries are retried only after a delay.
+type oidcProvider struct {
+	issuer   string
+	client   *http.Client
+	mx       sync.Mutex
+	config   *oidcConfig
+	keys     *keySet
+	err      error
+	retry    time.Time
+	fetching chan struct{}
+}
+
+type oidcSpec struct {
+	mx        sync.Mutex
+	providers map[string]*oidcProvider
+}
+
+type oidcHeader struct {
+	name  string
+	claim string
+}
+
+type oidcFilter struct {
+	provider     *oidcProvider
+	clientID     string
+	clientSecret string
+	callback     *url.URL
+	scopes       []string
+	headers      []oidcHeader
+	cookieName   string
+	session      time.Duration
+	aead         cipher.AEAD
+}
+
+// the content of the cookie set while the user is redirected to the
+// provider
+type oidcState struct {
+	State   string `json:"state"`
+	Nonce   string `json:"nonce"`
+	URL     string `json:"url"`
+	Expires int64  `json:"exp"`
+}
+
+// the content of the session cookie
+type oidcSession struct {
+	Claims  map[string]interface{} `json:"claims"`
+	Expires int64                  `json:"exp"`
+}
+
+type oidcTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// Returns a filter specification whose instances require the users to
+// log in with an OpenID Connect provider. See the package documentation
+// for the details.
+//
+// Name: "oauthOidc".
+func NewOAuthOidc() filters.Spec {
+	return &oidcSpec{providers: make(map[string]*oidcProvider)}
+}
+
+// "oauthOidc"
+func (s *oidcSpec) Name() string { return OAuthOidcName }
+
+// the providers are shared between the filter instances using the same
+// issuer
+func (s *oidcSpec) provider(issuer string) *oidcProvider {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if p, ok := s.providers[issuer]; ok {
+		return p
+	}
+
+	p := &oidcProvider{issuer: issuer, client: &http.Client{Timeout: oidcTimeout}}
+	s.providers[issuer] = p
+	return p
+}
+
+func (s *oidcSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) < 4 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	args := make([]string, len(config))
+	for i, c := range config {
+		a, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		args[i] = a
+	}
+
+	issuer, clientID, clientSecret := args[0], args[1], args[2]
+	if !isURL(issuer) || clientID == "" || clientSecret == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	callback, err := url.Parse(args[3])
+	if err != nil || !callback.IsAbs() || callback.Host == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &oidcFilter{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		callback:     callback,
+		scopes:       []string{"openid"},
+		cookieName:   DefaultOidcCookieName,
+		session:      DefaultOidcSession}
+
+	for _, o := range args[4:] {
+		switch {
+		case strings.HasPrefix(o, oidcScopesOption):
+			for _, scope := range strings.Fields(o[len(oidcScopesOption):]) {
+				if scope != "openid" {
+					f.scopes = append(f.scopes, scope)
+				}
+			}
+		case strings.HasPrefix(o, oidcHeaderOption):
+			h := strings.SplitN(o[len(oidcHeaderOption):], ":", 2)
+			if len(h) != 2 || h[0] == "" || h[1] == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.headers = append(f.headers, oidcHeader{name: h[0], claim: h[1]})
+		case strings.HasPrefix(o, oidcCookieOption):
+			f.cookieName = o[len(oidcCookieOption):]
+			if f.cookieName == "" {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.HasPrefix(o, oidcSessionOption):
+			f.session, err = time.ParseDuration(o[len(oidcSessionOption):])
+			if err != nil || f.session <= 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	// the cookies are encrypted with a key derived from the client
+	// secret, so that every instance sharing the same route configuration
+	// can read them
+	key := sha256.Sum256([]byte(OAuthOidcName + ":" + clientID + ":" + clientSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	f.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	f.provider = s.provider(issuer)
+	return f, nil
+}
+
+func (p *oidcProvider) fetch() (*oidcConfig, *keySet, error) {
+	rsp, err := p.client.Get(strings.TrimSuffix(p.issuer, "/") + oidcDiscoveryPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("discovery failed, status: %d", rsp.StatusCode)
+	}
+
+	var c oidcConfig
+	if err := json.NewDecoder(rsp.Body).Decode(&c); err != nil {
+		return nil, nil, err
+	}
+
+	if c.Issuer != p.issuer {
+		return nil, nil, fmt.Errorf("discovery failed, issuer mismatch: %s", c.Issuer)
+	}
+
+	if c.AuthorizationEndpoint == "" || c.TokenEndpoint == "" || !isURL(c.JwksURI) {
+		return nil, nil, errors.New("discovery failed, missing endpoints")
+	}
+
+	ks := newKeySet(c.JwksURI, DefaultJwksRefresh)
+	if err := ks.load(); err != nil {
+		return nil, nil, err
+	}
+
+	return &c, ks, nil
+}
+
+// the discovery is made without holding the lock. The concurrent callers
+// wait for the ongoing discovery, and after a failure, the error is
+// returned until the retry delay passes.
+func (p *oidcProvider) discover() (*oidcConfig, *keySet, error) {
+	p.mx.Lock()
+	for p.fetching != nil {
+		wait := p.fetching
+		p.mx.Unlock()
+		<-wait
+		p.mx.Lock()
+	}
+
+	if p.config != nil || time.Now().Before(p.retry) {
+		defer p.mx.Unlock()
+		return p.config, p.keys, p.err
+	}
+
+	done := make(chan struct{})
+	p.fetching = done
+	p.mx.Unlock()
+
+	config, keys, err := p.fetch()
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.config, p.keys, p.err = config, keys, err
+	if err != nil {
+		p.retry = time.Now().Add(oidcRetryDelay)
+	}
+
+	p.fetching = nil
+	close(done)
+	return config, keys, err
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// the name of the cookie is used as additional data, so that the value of
+// one cookie cannot be used as the value of the other
+func (f *oidcFilter) encrypt(name string, v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(f.aead.Seal(nonce, nonce, b, []byte(name))), nil
+}
+
+func (f *oidcFilter) decrypt(r *http.Request, name string, v interface{}) error {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(b) < f.aead.NonceSize() {
+		return errInvalidCookie
+	}
+
+	n := f.aead.NonceSize()
+	b, err = f.aead.Open(nil, b[:n], b[n:], []byte(name))
+	if err != nil {
+		return errInvalidCookie
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// the cookies are set for the domain of the callback URL, following the
+// conventions of the cookie filters
+func (f *oidcFilter) cookie(name, value string, maxAge int) string {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		HttpOnly: true,
+		Secure:   f.callback.Scheme == "https",
+		Domain:   cookie.ExtractDomainFromHost(f.callback.Host),
+		Path:     "/",
+		MaxAge:   maxAge}
+	return c.String()
+}
+
+func serveOidc(ctx filters.FilterContext, status int, location string, cookies ...string) {
+	header := http.Header{}
+	if location != "" {
+		header.Set("Location", location)
+	}
+
+	for _, c := range cookies {
+		header.Add(cookie.SetCookieHttpHeader, c)
+	}
+
+	ctx.Serve(&http.Response{StatusCode: status, Header: header})
+}
+
+// only the path and the query are used as the redirect target after the
+// login. A path starting with // or /\ would be interpreted by the
+// browsers as a reference to another host, so these are replaced by /.
+func localRedirect(u string) string {
+	if !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") || strings.HasPrefix(u, "/\\") {
+		return "/"
+	}
+
+	return u
+}
+
+// redirects the user to the authorization endpoint of the provider, and
+// stores the state and the original URL in a cookie
+func (f *oidcFilter) login(ctx filters.FilterContext) {
+	config, _, err := f.provider.discover()
+	if err != nil {
+		log.Errorf("oidc: %s: %v", f.provider.issuer, err)
+		serveOidc(ctx, http.StatusServiceUnavailable, "")
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		log.Errorf("oidc: %v", err)
+		serveOidc(ctx, http.StatusInternalServerError, "")
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		log.Errorf("oidc: %v", err)
+		serveOidc(ctx, http.StatusInternalServerError, "")
+		return
+	}
+
+	value, err := f.encrypt(f.cookieName+oidcStateSuffix, &oidcState{
+		State:   state,
+		Nonce:   nonce,
+		URL:     localRedirect(ctx.Request().URL.RequestURI()),
+		Expires: time.Now().Add(oidcStateTTL).Unix()})
+	if err != nil {
+		log.Errorf("oidc: %v", err)
+		serveOidc(ctx, http.StatusInternalServerError, "")
+		return
+	}
+
+	u, err := url.Parse(config.AuthorizationEndpoint)
+	if err != nil {
+		log.Errorf("oidc: %s: %v", f.provider.issuer, err)
+		serveOidc(ctx, http.StatusServiceUnavailable, "")
+		return
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", f.clientID)
+	q.Set("redirect_uri", f.callback.String())
+	q.Set("scope", strings.Join(f.scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+
+	stateCookie := f.cookie(f.cookieName+oidcStateSuffix, value, int(oidcStateTTL.Seconds()))
+	serveOidc(ctx, http.StatusFound, u.String(), stateCookie)
+}
+
+// exchanges the authorization code for the ID token at the token endpoint
+func (f *oidcFilter) exchange(config *oidcConfig, code string) (string, error) {
+	body := url.Values{
+		"grant_type":   []string{"authorization_code"},
+		"code":         []string{code},
+		"redirect_uri": []string{f.callback.String()}}
+	req, err := http.NewRequest("POST", config.TokenEndpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(f.clientID), url.QueryEscape(f.clientSecret))
+
+	rsp, err := f.provider.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed, status: %d", rsp.StatusCode)
+	}
+
+	var tr oidcTokenResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+
+	if tr.IDToken == "" {
+		return "", errMissingToken
+	}
+
+	return tr.IDToken, nil
+}
+
+// handles the redirect from the provider, and when the login succeeded,
+// starts the session and redirects the user to the original URL
+func (f *oidcFilter) callbackRequest(ctx filters.FilterContext) {
+	r := ctx.Request()
+	clearState := f.cookie(f.cookieName+oidcStateSuffix, "", -1)
+
+	var st oidcState
+	if err := f.decrypt(r, f.cookieName+oidcStateSuffix, &st); err != nil ||
+		st.State == "" || st.State != r.URL.Query().Get("state") ||
+		time.Now().Unix() > st.Expires {
+		serveOidc(ctx, http.StatusUnauthorized, "", clearState)
+		return
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		log.Infof("oidc: login failed: %s", e)
+		serveOidc(ctx, http.StatusUnauthorized, "", clearState)
+		return
+	}
+
+	config, keys, err := f.provider.discover()
+	if err != nil {
+		log.Errorf("oidc: %s: %v", f.provider.issuer, err)
+		serveOidc(ctx, http.StatusServiceUnavailable, "")
+		return
+	}
+
+	idToken, err := f.exchange(config, r.URL.Query().Get("code"))
+	if err != nil {
+		log.Errorf("oidc: %s: %v", f.provider.issuer, err)
+		serveOidc(ctx, http.StatusUnauthorized, "", clearState)
+		return
+	}
+
+	v := &jwtValidation{keys: keys, issuer: config.Issuer, audience: f.clientID}
+	claims, err := v.verify(idToken)
+	if err == nil && claims["nonce"] != st.Nonce {
+		err = errInvalidNonce
+	}
+
+	if err != nil {
+		log.Errorf("oidc: invalid ID token: %v", err)
+		serveOidc(ctx, http.StatusUnauthorized, "", clearState)
+		return
+	}
+
+	value, err := f.encrypt(f.cookieName, &oidcSession{
+		Claims:  claims,
+		Expires: time.Now().Add(f.session).Unix()})
+	if err != nil {
+		log.Errorf("oidc: %v", err)
+		serveOidc(ctx, http.StatusInternalServerError, "", clearState)
+		return
+	}
+
+	session := f.cookie(f.cookieName, value, int(f.session.Seconds()))
+	serveOidc(ctx, http.StatusFound, localRedirect(st.URL), session, clearState)
+}
+
+func claimValue(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case float64, bool:
+		return fmt.Sprint(vv)
+	default:
+		b, _ := json.Marshal(vv)
+		return string(b)
+	}
+}
+
+// removes the cookies of the filter from the request, so that they are
+// not sent to the backend
+func (f *oidcFilter) removeCookies(r *http.Request) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != f.cookieName && c.Name != f.cookieName+oidcStateSuffix {
+			r.AddCookie(c)
+		}
+	}
+}
+
+func (f *oidcFilter) Request(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if r.URL.Path == f.callback.Path {
+		f.callbackRequest(ctx)
+		return
+	}
+
+	// the headers set from the claims are never accepted from the
+	// clients
+	for _, h := range f.headers {
+		r.Header.Del(h.name)
+	}
+
+	var s oidcSession
+	if err := f.decrypt(r, f.cookieName, &s); err != nil || time.Now().Unix() > s.Expires {
+		f.login(ctx)
+		return
+	}
+
+	f.removeCookies(r)
+	for _, h := range f.headers {
+		if v, ok := s.Claims[h.claim]; ok {
+			r.Header.Set(h.name, claimValue(v))
+		}
+	}
+
+	ctx.StateBag()[OidcClaimsKey] = s.Claims
+	if sub, ok := s.Claims["sub"].(string); ok {
+		logging.SetAuthUser(r, sub)
+	}
+}
+
+func (f *oidcFilter) Response(filters.FilterContext) {}
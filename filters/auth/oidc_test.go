@@ -0,0 +1,422 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+const (
+	testOidcClientID     = "test-client"
+	testOidcClientSecret = "test-secret"
+	testOidcCallback     = "https://app.example.org/auth/callback"
+)
+
+// mock identity provider, accepting every login
+type testOidcProvider struct {
+	t      *testing.T
+	keys   *testKeys
+	server *httptest.Server
+	mx     sync.Mutex
+	codes  map[string]string
+	nonce  string
+}
+
+func newTestOidcProvider(t *testing.T) *testOidcProvider {
+	p := &testOidcProvider{t: t, keys: newTestKeys(t), codes: make(map[string]string)}
+	p.server = httptest.NewServer(p)
+	return p
+}
+
+func (p *testOidcProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case oidcDiscoveryPath:
+		w.Write([]byte(`{
+			"issuer": "` + p.server.URL + `",
+			"authorization_endpoint": "` + p.server.URL + `/authorize?prompt=login",
+			"token_endpoint": "` + p.server.URL + `/token",
+			"jwks_uri": "` + p.server.URL + `/jwks"
+		}`))
+	case "/jwks":
+		w.Write(p.keys.jwks(""))
+	case "/authorize":
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("client_id") != testOidcClientID ||
+			q.Get("redirect_uri") != testOidcCallback || q.Get("prompt") != "login" ||
+			!strings.HasPrefix(q.Get("scope"), "openid") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		code, _ := randomString()
+		p.mx.Lock()
+		p.codes[code] = q.Get("nonce")
+		p.mx.Unlock()
+
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{
+			"code":  []string{code},
+			"state": []string{q.Get("state")}}.Encode(), http.StatusFound)
+	case "/token":
+		id, secret, _ := r.BasicAuth()
+		if id != testOidcClientID || secret != testOidcClientSecret ||
+			r.FormValue("grant_type") != "authorization_code" ||
+			r.FormValue("redirect_uri") != testOidcCallback {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		p.mx.Lock()
+		nonce, ok := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		if p.nonce != "" {
+			nonce = p.nonce
+		}
+		p.mx.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := p.keys.sign(p.t, algRS256, "rsa", map[string]interface{}{
+			"iss":   p.server.URL,
+			"aud":   testOidcClientID,
+			"sub":   "jdoe",
+			"email": "jdoe@example.org",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": nonce})
+		w.Write([]byte(`{"access_token": "some-token", "token_type": "Bearer", "id_token": "` + token + `"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func createOidcFilter(t *testing.T, issuer string, options ...interface{}) filters.Filter {
+	args := append([]interface{}{issuer, testOidcClientID, testOidcClientSecret, testOidcCallback}, options...)
+	f, err := NewOAuthOidc().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func oidcRequest(t *testing.T, f filters.Filter, u string, cookies ...*http.Cookie) *filtertest.Context {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	return ctx
+}
+
+func responseCookie(rsp *http.Response, name string) *http.Cookie {
+	for _, c := range rsp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// starts the login, and follows the redirect to the provider, returning
+// the callback URL and the state cookie
+func startOidcLogin(t *testing.T, f filters.Filter, u string) (string, *http.Cookie) {
+	ctx := oidcRequest(t, f, u)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+		t.Fatal("failed to redirect to the provider")
+	}
+
+	state := responseCookie(ctx.FResponse, DefaultOidcCookieName+oidcStateSuffix)
+	if state == nil || !state.HttpOnly || !state.Secure || state.Domain != "example.org" {
+		t.Fatal("failed to set the state cookie", state)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	rsp, err := client.Get(ctx.FResponse.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusFound {
+		t.Fatal("failed to log in", rsp.StatusCode)
+	}
+
+	return rsp.Header.Get("Location"), state
+}
+
+func TestOidcArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"missing args",
+		[]interface{}{"https://auth.example.org", "client", "secret"},
+		true,
+	}, {
+		"not a string",
+		[]interface{}{"https://auth.example.org", "client", "secret", testOidcCallback, 42},
+		true,
+	}, {
+		"invalid issuer",
+		[]interface{}{"auth.example.org", "client", "secret", testOidcCallback},
+		true,
+	}, {
+		"missing secret",
+		[]interface{}{"https://auth.example.org", "client", "", testOidcCallback},
+		true,
+	}, {
+		"relative callback",
+		[]interface{}{"https://auth.example.org", "client", "secret", "/auth/callback"},
+		true,
+	}, {
+		"invalid header",
+		[]interface{}{"https://auth.example.org", "client", "secret", testOidcCallback, "header=X-Auth-Email"},
+		true,
+	}, {
+		"invalid session",
+		[]interface{}{"https://auth.example.org", "client", "secret", testOidcCallback, "session=-1h"},
+		true,
+	}, {
+		"unknown option",
+		[]interface{}{"https://auth.example.org", "client", "secret", testOidcCallback, "foo=bar"},
+		true,
+	}, {
+		"options",
+		[]interface{}{
+			"https://auth.example.org", "client", "secret", testOidcCallback,
+			"scopes=openid email profile",
+			"header=X-Auth-Email:email",
+			"cookie=test-session",
+			"session=8h",
+		},
+		false,
+	}} {
+		_, err := NewOAuthOidc().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestOidcLogin(t *testing.T) {
+	p := newTestOidcProvider(t)
+	defer p.server.Close()
+
+	f := createOidcFilter(t, p.server.URL, "scopes=email", "header=X-Auth-Email:email")
+	callback, state := startOidcLogin(t, f, "https://app.example.org/page?foo=bar")
+
+	ctx := oidcRequest(t, f, callback, state)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+		t.Fatal("failed to handle the callback", ctx.FResponse.StatusCode)
+	}
+
+	if l := ctx.FResponse.Header.Get("Location"); l != "/page?foo=bar" {
+		t.Error("failed to redirect to the original URL", l)
+	}
+
+	session := responseCookie(ctx.FResponse, DefaultOidcCookieName)
+	if session == nil || session.MaxAge != int(DefaultOidcSession.Seconds()) {
+		t.Fatal("failed to set the session cookie", session)
+	}
+
+	if c := responseCookie(ctx.FResponse, DefaultOidcCookieName+oidcStateSuffix); c == nil || c.MaxAge >= 0 {
+		t.Error("failed to clear the state cookie", c)
+	}
+
+	req, _ := http.NewRequest("GET", "https://app.example.org/page", nil)
+	req.Header.Set("X-Auth-Email", "admin@example.org")
+	req.AddCookie(session)
+	req.AddCookie(&http.Cookie{Name: "other", Value: "foo"})
+	ctx = &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+
+	if ctx.FServed {
+		t.Fatal("failed to accept the session", ctx.FResponse.StatusCode)
+	}
+
+	if h := req.Header.Get("X-Auth-Email"); h != "jdoe@example.org" {
+		t.Error("failed to forward the claim", h)
+	}
+
+	if _, err := req.Cookie(DefaultOidcCookieName); err == nil {
+		t.Error("failed to remove the session cookie")
+	}
+
+	if c, err := req.Cookie("other"); err != nil || c.Value != "foo" {
+		t.Error("failed to keep the other cookies")
+	}
+
+	claims, ok := ctx.FStateBag[OidcClaimsKey].(map[string]interface{})
+	if !ok || claims["sub"] != "jdoe" {
+		t.Error("failed to store the claims", claims)
+	}
+}
+
+func TestOidcRejected(t *testing.T) {
+	p := newTestOidcProvider(t)
+	defer p.server.Close()
+
+	f := createOidcFilter(t, p.server.URL)
+
+	t.Run("invalid session", func(t *testing.T) {
+		ctx := oidcRequest(t, f, "https://app.example.org/page", &http.Cookie{Name: DefaultOidcCookieName, Value: "foo"})
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+			t.Error("failed to redirect to the provider")
+		}
+	})
+
+	t.Run("state cookie as session", func(t *testing.T) {
+		_, state := startOidcLogin(t, f, "https://app.example.org/page")
+		ctx := oidcRequest(t, f, "https://app.example.org/page", &http.Cookie{Name: DefaultOidcCookieName, Value: state.Value})
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+			t.Error("failed to reject the state cookie")
+		}
+	})
+
+	t.Run("missing state", func(t *testing.T) {
+		callback, _ := startOidcLogin(t, f, "https://app.example.org/page")
+		ctx := oidcRequest(t, f, callback)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the callback")
+		}
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		callback, _ := startOidcLogin(t, f, "https://app.example.org/page")
+		_, state := startOidcLogin(t, f, "https://app.example.org/page")
+		ctx := oidcRequest(t, f, callback, state)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the callback")
+		}
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		_, state := startOidcLogin(t, f, "https://app.example.org/page")
+		ctx := oidcRequest(t, f, testOidcCallback+"?error=access_denied&state=foo", state)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the callback")
+		}
+	})
+
+	t.Run("invalid nonce", func(t *testing.T) {
+		p.mx.Lock()
+		p.nonce = "invalid-nonce"
+		p.mx.Unlock()
+		defer func() {
+			p.mx.Lock()
+			p.nonce = ""
+			p.mx.Unlock()
+		}()
+
+		callback, state := startOidcLogin(t, f, "https://app.example.org/page")
+		ctx := oidcRequest(t, f, callback, state)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the ID token")
+		}
+	})
+
+	t.Run("other client", func(t *testing.T) {
+		callback, state := startOidcLogin(t, f, "https://app.example.org/page")
+		other, err := NewOAuthOidc().CreateFilter([]interface{}{p.server.URL, "other-client", "other-secret", testOidcCallback})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := oidcRequest(t, other, callback, state)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error("failed to reject the state of another client")
+		}
+	})
+}
+
+func TestOidcProviderUnavailable(t *testing.T) {
+	p := newTestOidcProvider(t)
+	p.server.Close()
+
+	f := createOidcFilter(t, p.server.URL)
+	ctx := oidcRequest(t, f, "https://app.example.org/page")
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusServiceUnavailable {
+		t.Error("failed to report the unavailable provider")
+	}
+}
+
+func TestOidcRedirectToOtherHost(t *testing.T) {
+	p := newTestOidcProvider(t)
+	defer p.server.Close()
+
+	f := createOidcFilter(t, p.server.URL)
+	callback, state := startOidcLogin(t, f, "https://app.example.org//evil.example.org/page")
+	ctx := oidcRequest(t, f, callback, state)
+	if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusFound {
+		t.Fatal("failed to handle the callback", ctx.FResponse.StatusCode)
+	}
+
+	if l := ctx.FResponse.Header.Get("Location"); l != "/" {
+		t.Error("failed to prevent the redirect to another host", l)
+	}
+}
+
+func TestOidcLocalRedirect(t *testing.T) {
+	for _, ti := range []struct {
+		url      string
+		expected string
+	}{
+		{"/page?foo=bar", "/page?foo=bar"},
+		{"//evil.example.org/page", "/"},
+		{"/\\evil.example.org/page", "/"},
+		{"https://evil.example.org", "/"},
+		{"", "/"},
+	} {
+		if r := localRedirect(ti.url); r != ti.expected {
+			t.Error("unexpected redirect target", ti.url, r)
+		}
+	}
+}
+
+func TestOidcDiscoveryFailureRetry(t *testing.T) {
+	var mx sync.Mutex
+	var count int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		count++
+		mx.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	f := createOidcFilter(t, s.URL)
+	for i := 0; i < 3; i++ {
+		ctx := oidcRequest(t, f, "https://app.example.org/page")
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusServiceUnavailable {
+			t.Error("failed to report the unavailable provider")
+		}
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if count != 1 {
+		t.Error("failed to remember the discovery failure", count)
+	}
+}
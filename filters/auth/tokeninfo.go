@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
+	"github.com/zalando/skipper/oauth"
+)
+
+const (
+	OAuthTokeninfoAnyScopeName = "oauthTokeninfoAnyScope"
+	OAuthTokeninfoAllScopeName = "oauthTokeninfoAllScope"
+	OAuthTokeninfoAnyKVName    = "oauthTokeninfoAnyKV"
+	OAuthTokeninfoAllKVName    = "oauthTokeninfoAllKV"
+
+	// The key in the state bag, where the tokeninfo filters store the
+	// result of the introspection, as oauth.TokenInfo.
+	TokeninfoKey = "tokeninfo"
+)
+
+type tokeninfoCheck int
+
+const (
+	checkAnyScope tokeninfoCheck = iota
+	checkAllScope
+	checkAnyKV
+	checkAllKV
+)
+
+// TokeninfoClient returns the information about an access token.
+// Implemented by oauth.IntrospectionClient.
+type TokeninfoClient interface {
+	Introspect(token string) (oauth.TokenInfo, error)
+}
+
+type tokeninfoSpec struct {
+	check  tokeninfoCheck
+	client TokeninfoClient
+}
+
+type tokeninfoFilter struct {
+	check  tokeninfoCheck
+	client TokeninfoClient
+	scopes []string
+	kv     map[string][]string
+}
+
+// Returns a filter specification whose instances accept the requests with
+// an active bearer token having at least one of the scopes passed in as
+// the arguments.
+//
+// 	* -> oauthTokeninfoAnyScope("read", "write") -> "https://api.example.org"
+//
+// Name: "oauthTokeninfoAnyScope".
+func NewOAuthTokeninfoAnyScope(c TokeninfoClient) filters.Spec {
+	return &tokeninfoSpec{check: checkAnyScope, client: c}
+}
+
+// Returns a filter specification whose instances accept the requests with
+// an active bearer token having all the scopes passed in as the
+// arguments.
+//
+// 	* -> oauthTokeninfoAllScope("read", "write") -> "https://api.example.org"
+//
+// Name: "oauthTokeninfoAllScope".
+func NewOAuthTokeninfoAllScope(c TokeninfoClient) filters.Spec {
+	return &tokeninfoSpec{check: checkAllScope, client: c}
+}
+
+// Returns a filter specification whose instances accept the requests with
+// an active bearer token having at least one of the key-value pairs
+// passed in as the arguments, among the top level fields of the token
+// information.
+//
+// 	* -> oauthTokeninfoAnyKV("realm", "/employees", "realm", "/services") -> "https://api.example.org"
+//
+// Name: "oauthTokeninfoAnyKV".
+func NewOAuthTokeninfoAnyKV(c TokeninfoClient) filters.Spec {
+	return &tokeninfoSpec{check: checkAnyKV, client: c}
+}
+
+// Returns a filter specification whose instances accept the requests with
+// an active bearer token having all the key-value pairs passed in as the
+// arguments, among the top level fields of the token information.
+//
+// 	* -> oauthTokeninfoAllKV("realm", "/employees", "team", "teapot") -> "https://api.example.org"
+//
+// Name: "oauthTokeninfoAllKV".
+func NewOAuthTokeninfoAllKV(c TokeninfoClient) filters.Spec {
+	return &tokeninfoSpec{check: checkAllKV, client: c}
+}
+
+func (s *tokeninfoSpec) Name() string {
+	switch s.check {
+	case checkAnyScope:
+		return OAuthTokeninfoAnyScopeName
+	case checkAllScope:
+		return OAuthTokeninfoAllScopeName
+	case checkAnyKV:
+		return OAuthTokeninfoAnyKVName
+	case checkAllKV:
+		return OAuthTokeninfoAllKVName
+	default:
+		panic("invalid tokeninfo check")
+	}
+}
+
+func (s *tokeninfoSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	args := make([]string, len(config))
+	for i, c := range config {
+		a, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		args[i] = a
+	}
+
+	f := &tokeninfoFilter{check: s.check, client: s.client}
+	switch s.check {
+	case checkAnyScope, checkAllScope:
+		f.scopes = args
+	default:
+		if len(args)%2 != 0 {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.kv = make(map[string][]string)
+		for i := 0; i < len(args); i += 2 {
+			f.kv[args[i]] = append(f.kv[args[i]], args[i+1])
+		}
+	}
+
+	return f, nil
+}
+
+func contains(l []string, s string) bool {
+	for _, li := range l {
+		if li == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *tokeninfoFilter) validScopes(info oauth.TokenInfo) bool {
+	scopes := info.Scopes()
+	for _, s := range f.scopes {
+		has := contains(scopes, s)
+		if has && f.check == checkAnyScope {
+			return true
+		}
+
+		if !has && f.check == checkAllScope {
+			return false
+		}
+	}
+
+	return f.check == checkAllScope
+}
+
+func (f *tokeninfoFilter) validKV(info oauth.TokenInfo) bool {
+	for k, values := range f.kv {
+		v, ok := info[k]
+		has := ok && contains(values, fmt.Sprint(v))
+		if has && f.check == checkAnyKV {
+			return true
+		}
+
+		if !has && f.check == checkAllKV {
+			return false
+		}
+	}
+
+	return f.check == checkAllKV
+}
+
+// when the token cannot be validated, the failure is not the fault of the
+// client
+func introspectionFailureStatus(err error) int {
+	if _, ok := err.(*oauth.IntrospectionUnavailableError); ok {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadGateway
+}
+
+func rejectTokeninfo(ctx filters.FilterContext, status int) {
+	header := http.Header{}
+	if status == http.StatusUnauthorized {
+		header.Set(ForceBasicAuthHeaderName, "Bearer")
+	}
+
+	ctx.Serve(&http.Response{StatusCode: status, Header: header})
+}
+
+func (f *tokeninfoFilter) Request(ctx filters.FilterContext) {
+	token, err := bearerToken(ctx.Request())
+	if err != nil {
+		rejectTokeninfo(ctx, http.StatusUnauthorized)
+		return
+	}
+
+	info, err := f.client.Introspect(token)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if err != oauth.ErrInactiveToken {
+			log.Errorf("tokeninfo: %v", err)
+			status = introspectionFailureStatus(err)
+		}
+
+		rejectTokeninfo(ctx, status)
+		return
+	}
+
+	var valid bool
+	switch f.check {
+	case checkAnyScope, checkAllScope:
+		valid = f.validScopes(info)
+	default:
+		valid = f.validKV(info)
+	}
+
+	if !valid {
+		rejectTokeninfo(ctx, http.StatusForbidden)
+		return
+	}
+
+	ctx.StateBag()[TokeninfoKey] = info
+	for _, key := range []string{"sub", "uid", "username"} {
+		if user, ok := info[key].(string); ok && user != "" {
+			logging.SetAuthUser(ctx.Request(), user)
+			break
+		}
+	}
+}
+
+func (f *tokeninfoFilter) Response(filters.FilterContext) {}
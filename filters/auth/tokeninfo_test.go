@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/oauth"
+)
+
+type testTokeninfoClient map[string]oauth.TokenInfo
+
+func (c testTokeninfoClient) Introspect(token string) (oauth.TokenInfo, error) {
+	switch token {
+	case "failing-token":
+		return nil, errors.New("introspection failed")
+	case "unavailable-token":
+		return nil, &oauth.IntrospectionUnavailableError{Err: errors.New("connection refused")}
+	}
+
+	info, ok := c[token]
+	if !ok {
+		return nil, oauth.ErrInactiveToken
+	}
+
+	return info, nil
+}
+
+var testTokeninfo = testTokeninfoClient{
+	"user-token": {
+		"active": true,
+		"sub":    "jdoe",
+		"scope":  "read write",
+		"realm":  "/employees",
+		"team":   "teapot",
+	},
+	"service-token": {
+		"uid":   "stups_service",
+		"scope": []interface{}{"uid", "read"},
+		"realm": "/services",
+	},
+}
+
+func TestTokeninfoArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		spec filters.Spec
+		args []interface{}
+		err  bool
+	}{{
+		"no scopes",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		nil,
+		true,
+	}, {
+		"not a string",
+		NewOAuthTokeninfoAllScope(testTokeninfo),
+		[]interface{}{"read", 42},
+		true,
+	}, {
+		"scopes",
+		NewOAuthTokeninfoAllScope(testTokeninfo),
+		[]interface{}{"read", "write"},
+		false,
+	}, {
+		"no key-value pairs",
+		NewOAuthTokeninfoAnyKV(testTokeninfo),
+		nil,
+		true,
+	}, {
+		"missing value",
+		NewOAuthTokeninfoAllKV(testTokeninfo),
+		[]interface{}{"realm", "/employees", "team"},
+		true,
+	}, {
+		"key-value pairs",
+		NewOAuthTokeninfoAnyKV(testTokeninfo),
+		[]interface{}{"realm", "/employees", "realm", "/services"},
+		false,
+	}} {
+		_, err := ti.spec.CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestTokeninfo(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		spec   filters.Spec
+		args   []interface{}
+		auth   string
+		status int
+		user   string
+	}{{
+		"missing token",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"read"},
+		"",
+		http.StatusUnauthorized,
+		"",
+	}, {
+		"basic auth instead of a token",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"read"},
+		"Basic dXNlcjpwYXNz",
+		http.StatusUnauthorized,
+		"",
+	}, {
+		"inactive token",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"read"},
+		"Bearer unknown-token",
+		http.StatusUnauthorized,
+		"",
+	}, {
+		"introspection failure",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"read"},
+		"Bearer failing-token",
+		http.StatusBadGateway,
+		"",
+	}, {
+		"introspection unavailable",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"read"},
+		"Bearer unavailable-token",
+		http.StatusServiceUnavailable,
+		"",
+	}, {
+		"any scope",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"admin", "write"},
+		"Bearer user-token",
+		http.StatusOK,
+		"jdoe",
+	}, {
+		"none of the scopes",
+		NewOAuthTokeninfoAnyScope(testTokeninfo),
+		[]interface{}{"admin", "delete"},
+		"Bearer user-token",
+		http.StatusForbidden,
+		"",
+	}, {
+		"all scopes",
+		NewOAuthTokeninfoAllScope(testTokeninfo),
+		[]interface{}{"uid", "read"},
+		"Bearer service-token",
+		http.StatusOK,
+		"stups_service",
+	}, {
+		"not all scopes",
+		NewOAuthTokeninfoAllScope(testTokeninfo),
+		[]interface{}{"read", "write"},
+		"Bearer service-token",
+		http.StatusForbidden,
+		"",
+	}, {
+		"any key-value",
+		NewOAuthTokeninfoAnyKV(testTokeninfo),
+		[]interface{}{"realm", "/employees", "realm", "/services"},
+		"Bearer service-token",
+		http.StatusOK,
+		"stups_service",
+	}, {
+		"none of the key-values",
+		NewOAuthTokeninfoAnyKV(testTokeninfo),
+		[]interface{}{"realm", "/customers", "team", "teapot"},
+		"Bearer service-token",
+		http.StatusForbidden,
+		"",
+	}, {
+		"all key-values",
+		NewOAuthTokeninfoAllKV(testTokeninfo),
+		[]interface{}{"realm", "/employees", "team", "teapot"},
+		"Bearer user-token",
+		http.StatusOK,
+		"jdoe",
+	}, {
+		"not all key-values",
+		NewOAuthTokeninfoAllKV(testTokeninfo),
+		[]interface{}{"realm", "/employees", "team", "coffeepot"},
+		"Bearer user-token",
+		http.StatusForbidden,
+		"",
+	}} {
+		f, err := ti.spec.CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		req, err := http.NewRequest("GET", "https://www.example.org", nil)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if ti.auth != "" {
+			req.Header.Set("Authorization", ti.auth)
+		}
+
+		ctx := &filtertest.Context{FRequest: req, FStateBag: make(map[string]interface{})}
+		f.Request(ctx)
+
+		if ti.status == http.StatusOK {
+			if ctx.FServed {
+				t.Error(ti.msg, "unexpectedly rejected", ctx.FResponse.StatusCode)
+				continue
+			}
+
+			info, ok := ctx.FStateBag[TokeninfoKey].(oauth.TokenInfo)
+			if !ok {
+				t.Error(ti.msg, "failed to store the token info")
+				continue
+			}
+
+			if info["sub"] != ti.user && info["uid"] != ti.user {
+				t.Error(ti.msg, "invalid token info stored", info)
+			}
+
+			continue
+		}
+
+		if !ctx.FServed {
+			t.Error(ti.msg, "failed to reject the request")
+			continue
+		}
+
+		if ctx.FResponse.StatusCode != ti.status {
+			t.Error(ti.msg, "invalid status code", ctx.FResponse.StatusCode, ti.status)
+		}
+
+		challenge := ctx.FResponse.Header.Get(ForceBasicAuthHeaderName)
+		if ti.status == http.StatusUnauthorized && challenge != "Bearer" {
+			t.Error(ti.msg, "invalid challenge", challenge)
+		}
+
+		if _, ok := ctx.FStateBag[TokeninfoKey]; ok {
+			t.Error(ti.msg, "unexpectedly stored the token info")
+		}
+	}
+}
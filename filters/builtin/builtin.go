@@ -9,6 +9,9 @@ import (
 	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/filters/diag"
 	"github.com/zalando/skipper/filters/flowid"
+	"github.com/zalando/skipper/filters/jsonbody"
+	"github.com/zalando/skipper/filters/scripts"
+	"github.com/zalando/skipper/filters/source"
 	"github.com/zalando/skipper/filters/tee"
 )
 
@@ -28,18 +31,24 @@ const (
 	AppendResponseHeaderName = "appendResponseHeader"
 	DropRequestHeaderName    = "dropRequestHeader"
 	DropResponseHeaderName   = "dropResponseHeader"
+	ModRequestHeaderName     = "modRequestHeader"
+	ModResponseHeaderName    = "modResponseHeader"
 
-	HealthCheckName  = "healthcheck"
-	ModPathName      = "modPath"
-	SetPathName      = "setPath"
-	RedirectToName   = "redirectTo"
-	StaticName       = "static"
-	StripQueryName   = "stripQuery"
-	PreserveHostName = "preserveHost"
-	StatusName       = "status"
-	CompressName     = "compress"
-	SetQueryName     = "setQuery"
-	DropQueryName    = "dropQuery"
+	HealthCheckName       = "healthcheck"
+	ModPathName           = "modPath"
+	SetPathName           = "setPath"
+	RedirectToName        = "redirectTo"
+	StaticName            = "static"
+	StripQueryName        = "stripQuery"
+	PreserveHostName      = "preserveHost"
+	StatusName            = "status"
+	CompressName          = "compress"
+	DecompressRequestName = "decompressRequest"
+	SetQueryName          = "setQuery"
+	DropQueryName         = "dropQuery"
+	ProxyPassReverseName  = "proxyPassReverse"
+	CorsName              = "cors"
+	StickyHeaderName      = "stickyHeader"
 )
 
 // Returns a Registry object initialized with the default set of filter
@@ -56,6 +65,10 @@ func MakeRegistry() filters.Registry {
 		NewSetResponseHeader(),
 		NewAppendResponseHeader(),
 		NewDropResponseHeader(),
+		NewModRequestHeader(),
+		NewModResponseHeader(),
+		NewProxyPassReverse(),
+		NewCors(),
 		NewModPath(),
 		NewSetPath(),
 		NewDropQuery(),
@@ -65,10 +78,12 @@ func MakeRegistry() filters.Registry {
 		NewRedirect(),
 		NewRedirectTo(),
 		NewStripQuery(),
+		NewStickyHeader(),
 		flowid.New(),
 		PreserveHost(),
 		NewStatus(),
 		NewCompress(),
+		NewDecompressRequest(),
 		diag.NewRandom(),
 		diag.NewLatency(),
 		diag.NewBandwidth(),
@@ -80,9 +95,30 @@ func MakeRegistry() filters.Registry {
 		tee.NewTeeDeprecated(),
 		tee.NewTeeNoFollow(),
 		auth.NewBasicAuth(),
+		auth.NewJwtValidation(),
+		auth.NewOAuthOidc(),
+		auth.NewForwardAuth(),
+		auth.NewVerifyHmac(),
+		auth.NewRequireClientCert(),
+		auth.NewForwardClientCert(),
 		cookie.NewRequestCookie(),
 		cookie.NewResponseCookie(),
 		cookie.NewJSCookie(),
+		cookie.NewVerifyCookie(),
+		cookie.NewStickyCookie(),
+		jsonbody.NewSetRequestJSONField(),
+		jsonbody.NewSetResponseJSONField(),
+		jsonbody.NewDropRequestJSONField(),
+		jsonbody.NewDropResponseJSONField(),
+		jsonbody.NewRenameRequestJSONField(),
+		jsonbody.NewRenameResponseJSONField(),
+		jsonbody.NewWrapRequestJSON(),
+		jsonbody.NewWrapResponseJSON(),
+		jsonbody.NewUnwrapRequestJSON(),
+		jsonbody.NewUnwrapResponseJSON(),
+		scripts.NewLua(),
+		source.NewAllowSource(),
+		source.NewDenySource(),
 	} {
 		r.Register(s)
 	}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/andybalholm/brotli"
 	"github.com/zalando/skipper/filters"
 )
 
@@ -37,7 +38,7 @@ type encoder interface {
 }
 
 var (
-	supportedEncodings  = []string{"gzip", "deflate"}
+	supportedEncodings  = []string{"gzip", "deflate", "br"}
 	unsupportedEncoding = errors.New("unsupported encoding")
 )
 
@@ -56,6 +57,7 @@ var defaultCompressMIME = []string{
 var (
 	gzipPool    = &sync.Pool{}
 	deflatePool = &sync.Pool{}
+	brotliPool  = &sync.Pool{}
 )
 
 func init() {
@@ -76,6 +78,13 @@ func init() {
 		}
 
 		deflatePool.Put(fe)
+
+		be, err := newEncoder("br", flate.BestSpeed)
+		if err != nil {
+			panic(err)
+		}
+
+		brotliPool.Put(be)
 	}
 }
 
@@ -121,9 +130,16 @@ func (e encodings) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
 //
 // The filter also checks the incoming request, if it accepts the supported
 // encodings, explicitly stated in the Accept-Encoding header. The filter currently
-// supports gzip and deflate. It does not assume that the client accepts any
-// encoding if the Accept-Encoding header is not set. It ignores * in the
-// Accept-Encoding header.
+// supports gzip, deflate and br (brotli). It does not assume that the client
+// accepts any encoding if the Accept-Encoding header is not set. It ignores * in
+// the Accept-Encoding header.
+//
+// The q-values in the Accept-Encoding header are honoured: the encoding with the
+// highest weight is selected, and encodings with q=0 are considered as not
+// acceptable. When the weights are equal, the order in the header decides.
+//
+// Responses that are already encoded by the backend, i.e. having a
+// Content-Encoding other than identity, are passed through untouched.
 //
 // When compressing the response, it updates the response header. It deletes the
 // the Content-Length value triggering the proxy to always return the response
@@ -221,7 +237,10 @@ func canEncodeEntity(r *http.Response, mime []string) bool {
 	return true
 }
 
-func acceptedEncoding(r *http.Request) string {
+// returns the encodings from the Accept-Encoding header of a request that are
+// found in the supported list, in the order of the client preference, leaving
+// out the ones with q=0
+func acceptedEncodings(r *http.Request, supported []string) []string {
 	var encs encodings
 	for _, s := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
 		sp := strings.Split(s, ";")
@@ -230,7 +249,7 @@ func acceptedEncoding(r *http.Request) string {
 		}
 
 		name := strings.ToLower(strings.TrimSpace(sp[0]))
-		if !stringsContain(supportedEncodings, name) {
+		if !stringsContain(supported, name) {
 			continue
 		}
 
@@ -253,12 +272,28 @@ func acceptedEncoding(r *http.Request) string {
 		}
 	}
 
+	// stable, to keep the client order for equal weights
+	sort.Stable(encs)
+
+	var names []string
+	for _, e := range encs {
+		if e.q <= 0 {
+			break
+		}
+
+		names = append(names, e.name)
+	}
+
+	return names
+}
+
+func acceptedEncoding(r *http.Request) string {
+	encs := acceptedEncodings(r, supportedEncodings)
 	if len(encs) == 0 {
 		return ""
 	}
 
-	sort.Sort(encs)
-	return encs[0].name
+	return encs[0]
 }
 
 func responseHeader(r *http.Response, enc string) {
@@ -283,6 +318,8 @@ func newEncoder(enc string, level int) (encoder, error) {
 		return gzip.NewWriterLevel(nil, level)
 	case "deflate":
 		return flate.NewWriter(nil, level)
+	case "br":
+		return brotli.NewWriterLevel(nil, level), nil
 	default:
 		unsupported()
 		return nil, nil
@@ -295,6 +332,8 @@ func encoderPool(enc string) *sync.Pool {
 		return gzipPool
 	case "deflate":
 		return deflatePool
+	case "br":
+		return brotliPool
 	default:
 		unsupported()
 		return nil
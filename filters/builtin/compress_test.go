@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
@@ -77,6 +78,8 @@ func decoder(enc string, r io.Reader) io.Reader {
 		return rr
 	case "deflate":
 		return flate.NewReader(r)
+	case "br":
+		return brotli.NewReader(r)
 	default:
 		panic(unsupportedEncoding)
 	}
@@ -391,6 +394,58 @@ func TestCompress(t *testing.T) {
 		http.Header{
 			"Content-Encoding": []string{"deflate"},
 			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"brotli",
+		http.Header{},
+		3 * 8192,
+		nil,
+		"x-custom,br",
+		http.Header{
+			"Content-Encoding": []string{"br"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"brotli, best compression",
+		http.Header{},
+		3 * 8192,
+		[]interface{}{float64(flate.BestCompression)},
+		"x-custom,br",
+		http.Header{
+			"Content-Encoding": []string{"br"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"brotli weighted",
+		http.Header{},
+		3 * 8192,
+		nil,
+		"gzip; q=0.4, br; q=0.8, deflate; q=0.6",
+		http.Header{
+			"Content-Encoding": []string{"br"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"equal weights, header order",
+		http.Header{},
+		3 * 8192,
+		nil,
+		"deflate, gzip",
+		http.Header{
+			"Content-Encoding": []string{"deflate"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"zero weight not acceptable",
+		http.Header{},
+		3 * 8192,
+		nil,
+		"gzip; q=0, deflate; q=0.1",
+		http.Header{
+			"Content-Encoding": []string{"deflate"},
+			"Vary":             []string{"Accept-Encoding"}},
+	}, {
+		"all zero weights",
+		http.Header{},
+		3 * 8192,
+		nil,
+		"gzip; q=0, deflate; q=0",
+		http.Header{},
 	}, {
 		"drops content length",
 		http.Header{"Content-Length": []string{strconv.Itoa(3 * 8192)}},
@@ -0,0 +1,254 @@
+package builtin
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	corsOriginOption       = "origin="
+	corsOriginRegexpOption = "origin-regexp="
+	corsMethodsOption      = "methods="
+	corsHeadersOption      = "headers="
+	corsExposeOption       = "expose="
+	corsMaxAgeOption       = "max-age="
+	corsCredentialsOption  = "credentials"
+
+	corsAnyOrigin = "*"
+)
+
+var corsDefaultMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+type cors struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	originRx    []*regexp.Regexp
+	methods     []string
+	headers     string
+	expose      string
+	maxAge      string
+	credentials bool
+}
+
+// Returns a filter specification whose instances handle the Cross-Origin
+// Resource Sharing for the route. The instances answer the preflight
+// requests, the OPTIONS requests with the Access-Control-Request-Method
+// header, without calling the backend, and set the CORS headers on the
+// responses of the actual requests.
+//
+// The filter accepts the following string arguments:
+//
+// "origin=<origin>": an allowed origin, matched exactly, e.g.
+// "origin=https://www.example.org". "origin=*" allows any origin. Can be
+// repeated.
+//
+// "origin-regexp=<expression>": allows the origins matching the regular
+// expression, e.g. "origin-regexp=^https://[a-z]+\\.example\\.org$". Can
+// be repeated.
+//
+// "methods=<list>": the comma separated list of the allowed methods. The
+// default is GET, HEAD, POST, PUT, PATCH and DELETE.
+//
+// "headers=<list>": the comma separated list of the allowed request
+// headers. When not set, the headers requested by the preflight request
+// are allowed.
+//
+// "expose=<list>": the comma separated list of the response headers
+// exposed to the client.
+//
+// "max-age=<seconds>": how long the result of a preflight request can be
+// cached by the client.
+//
+// "credentials": allows requests with credentials. It cannot be combined
+// with "origin=*", because reflecting any origin with credentials would
+// allow every site to make authenticated requests.
+//
+// At least one origin option is required. The filter never responds with
+// the `*` wildcard, only the allowed origins are reflected in the
+// Access-Control-Allow-Origin header, and the Vary: Origin header is
+// set. When the origin of a request is not allowed, no CORS headers are
+// set, and the browser blocks the request.
+//
+// Example:
+//
+// 	* -> cors("origin=https://www.example.org", "methods=GET,POST", "max-age=600", "credentials") -> "https://api.example.org"
+//
+// Name: "cors".
+func NewCors() filters.Spec { return &cors{} }
+
+// "cors"
+func (spec *cors) Name() string { return CorsName }
+
+func splitList(l string) []string {
+	var s []string
+	for _, li := range strings.Split(l, ",") {
+		if li = strings.TrimSpace(li); li != "" {
+			s = append(s, li)
+		}
+	}
+
+	return s
+}
+
+func (spec *cors) CreateFilter(config []interface{}) (filters.Filter, error) {
+	f := &cors{origins: make(map[string]bool), methods: corsDefaultMethods}
+	for _, c := range config {
+		o, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		switch {
+		case strings.HasPrefix(o, corsOriginOption):
+			origin := o[len(corsOriginOption):]
+			if origin == corsAnyOrigin {
+				f.anyOrigin = true
+			} else if origin != "" {
+				f.origins[origin] = true
+			} else {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.HasPrefix(o, corsOriginRegexpOption):
+			rx, err := regexp.Compile(o[len(corsOriginRegexpOption):])
+			if err != nil {
+				return nil, err
+			}
+
+			f.originRx = append(f.originRx, rx)
+		case strings.HasPrefix(o, corsMethodsOption):
+			f.methods = splitList(strings.ToUpper(o[len(corsMethodsOption):]))
+			if len(f.methods) == 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+		case strings.HasPrefix(o, corsHeadersOption):
+			f.headers = strings.Join(splitList(o[len(corsHeadersOption):]), ", ")
+		case strings.HasPrefix(o, corsExposeOption):
+			f.expose = strings.Join(splitList(o[len(corsExposeOption):]), ", ")
+		case strings.HasPrefix(o, corsMaxAgeOption):
+			maxAge := o[len(corsMaxAgeOption):]
+			if s, err := strconv.Atoi(maxAge); err != nil || s < 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.maxAge = maxAge
+		case o == corsCredentialsOption:
+			f.credentials = true
+		default:
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if !f.anyOrigin && len(f.origins) == 0 && len(f.originRx) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if f.anyOrigin && f.credentials {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return f, nil
+}
+
+func (f *cors) allowedOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	if f.anyOrigin || f.origins[origin] {
+		return true
+	}
+
+	for _, rx := range f.originRx {
+		if rx.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *cors) allowedMethod(m string) bool {
+	for _, mi := range f.methods {
+		if mi == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func addVary(h http.Header, names ...string) {
+	for _, n := range names {
+		if !stringsContain(h["Vary"], n, http.CanonicalHeaderKey) {
+			h.Add("Vary", n)
+		}
+	}
+}
+
+func (f *cors) setOrigin(h http.Header, origin string) {
+	h.Set("Access-Control-Allow-Origin", origin)
+	if f.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (f *cors) preflight(r *http.Request) *http.Response {
+	rsp := &http.Response{StatusCode: http.StatusNoContent, Header: make(http.Header)}
+	addVary(rsp.Header, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !f.allowedOrigin(origin) || !f.allowedMethod(method) {
+		return rsp
+	}
+
+	f.setOrigin(rsp.Header, origin)
+	rsp.Header.Set("Access-Control-Allow-Methods", strings.Join(f.methods, ", "))
+
+	if f.headers != "" {
+		rsp.Header.Set("Access-Control-Allow-Headers", f.headers)
+	} else if rh := r.Header.Get("Access-Control-Request-Headers"); rh != "" {
+		rsp.Header.Set("Access-Control-Allow-Headers", rh)
+	}
+
+	if f.maxAge != "" {
+		rsp.Header.Set("Access-Control-Max-Age", f.maxAge)
+	}
+
+	return rsp
+}
+
+func (f *cors) Request(ctx filters.FilterContext) {
+	if r := ctx.Request(); isPreflight(r) {
+		ctx.Serve(f.preflight(r))
+	}
+}
+
+func (f *cors) Response(ctx filters.FilterContext) {
+	r := ctx.Request()
+	if isPreflight(r) {
+		return
+	}
+
+	h := ctx.Response().Header
+	addVary(h, "Origin")
+
+	origin := r.Header.Get("Origin")
+	if !f.allowedOrigin(origin) {
+		return
+	}
+
+	f.setOrigin(h, origin)
+	if f.expose != "" {
+		h.Set("Access-Control-Expose-Headers", f.expose)
+	}
+}
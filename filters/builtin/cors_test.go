@@ -0,0 +1,273 @@
+package builtin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestCorsArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"no origin",
+		[]interface{}{"methods=GET"},
+		true,
+	}, {
+		"not string",
+		[]interface{}{"origin=https://www.example.org", 3.14},
+		true,
+	}, {
+		"empty origin",
+		[]interface{}{"origin="},
+		true,
+	}, {
+		"invalid regexp",
+		[]interface{}{"origin-regexp=["},
+		true,
+	}, {
+		"empty methods",
+		[]interface{}{"origin=*", "methods= , "},
+		true,
+	}, {
+		"invalid max age",
+		[]interface{}{"origin=*", "max-age=forever"},
+		true,
+	}, {
+		"unknown option",
+		[]interface{}{"origin=*", "foo"},
+		true,
+	}, {
+		"any origin with credentials",
+		[]interface{}{"origin=*", "credentials"},
+		true,
+	}, {
+		"any origin with credentials and other origins",
+		[]interface{}{"origin=https://www.example.org", "origin=*", "credentials"},
+		true,
+	}, {
+		"valid",
+		[]interface{}{
+			"origin=https://www.example.org",
+			"origin-regexp=^https://[a-z]+\\.example\\.org$",
+			"methods=GET, POST",
+			"headers=Content-Type, Authorization",
+			"expose=X-Request-Id",
+			"max-age=600",
+			"credentials",
+		},
+		false,
+	}} {
+		_, err := NewCors().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestCors(t *testing.T) {
+	args := []interface{}{
+		"origin=https://www.example.org",
+		"origin-regexp=^https://[a-z]+\\.example\\.com$",
+		"methods=GET,POST",
+		"expose=X-Request-Id",
+		"max-age=600",
+		"credentials",
+	}
+
+	for _, ti := range []struct {
+		msg            string
+		args           []interface{}
+		method         string
+		requestHeader  http.Header
+		expectServed   bool
+		expectedStatus int
+		expectedHeader http.Header
+	}{{
+		msg:            "no origin",
+		method:         "GET",
+		expectedHeader: http.Header{"Vary": []string{"Origin"}},
+	}, {
+		msg:           "allowed origin",
+		method:        "GET",
+		requestHeader: http.Header{"Origin": []string{"https://www.example.org"}},
+		expectedHeader: http.Header{
+			"Vary":                             []string{"Origin"},
+			"Access-Control-Allow-Origin":      []string{"https://www.example.org"},
+			"Access-Control-Allow-Credentials": []string{"true"},
+			"Access-Control-Expose-Headers":    []string{"X-Request-Id"},
+		},
+	}, {
+		msg:           "allowed origin by regexp",
+		method:        "POST",
+		requestHeader: http.Header{"Origin": []string{"https://app.example.com"}},
+		expectedHeader: http.Header{
+			"Vary":                             []string{"Origin"},
+			"Access-Control-Allow-Origin":      []string{"https://app.example.com"},
+			"Access-Control-Allow-Credentials": []string{"true"},
+			"Access-Control-Expose-Headers":    []string{"X-Request-Id"},
+		},
+	}, {
+		msg:            "not allowed origin",
+		method:         "GET",
+		requestHeader:  http.Header{"Origin": []string{"https://evil.example.net"}},
+		expectedHeader: http.Header{"Vary": []string{"Origin"}},
+	}, {
+		msg:    "preflight",
+		method: "OPTIONS",
+		requestHeader: http.Header{
+			"Origin":                         []string{"https://www.example.org"},
+			"Access-Control-Request-Method":  []string{"POST"},
+			"Access-Control-Request-Headers": []string{"Content-Type"},
+		},
+		expectServed:   true,
+		expectedStatus: http.StatusNoContent,
+		expectedHeader: http.Header{
+			"Vary":                             []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+			"Access-Control-Allow-Origin":      []string{"https://www.example.org"},
+			"Access-Control-Allow-Credentials": []string{"true"},
+			"Access-Control-Allow-Methods":     []string{"GET, POST"},
+			"Access-Control-Allow-Headers":     []string{"Content-Type"},
+			"Access-Control-Max-Age":           []string{"600"},
+		},
+	}, {
+		msg:    "preflight with configured headers and default methods",
+		args:   []interface{}{"origin=*", "headers=Content-Type,Authorization"},
+		method: "OPTIONS",
+		requestHeader: http.Header{
+			"Origin":                         []string{"https://www.example.org"},
+			"Access-Control-Request-Method":  []string{"delete"},
+			"Access-Control-Request-Headers": []string{"X-Foo"},
+		},
+		expectServed:   true,
+		expectedStatus: http.StatusNoContent,
+		expectedHeader: http.Header{
+			"Vary":                         []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+			"Access-Control-Allow-Origin":  []string{"https://www.example.org"},
+			"Access-Control-Allow-Methods": []string{"GET, HEAD, POST, PUT, PATCH, DELETE"},
+			"Access-Control-Allow-Headers": []string{"Content-Type, Authorization"},
+		},
+	}, {
+		msg:    "preflight, not allowed method",
+		method: "OPTIONS",
+		requestHeader: http.Header{
+			"Origin":                        []string{"https://www.example.org"},
+			"Access-Control-Request-Method": []string{"DELETE"},
+		},
+		expectServed:   true,
+		expectedStatus: http.StatusNoContent,
+		expectedHeader: http.Header{
+			"Vary": []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+	}, {
+		msg:    "preflight, not allowed origin",
+		method: "OPTIONS",
+		requestHeader: http.Header{
+			"Origin":                        []string{"https://evil.example.net"},
+			"Access-Control-Request-Method": []string{"GET"},
+		},
+		expectServed:   true,
+		expectedStatus: http.StatusNoContent,
+		expectedHeader: http.Header{
+			"Vary": []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+	}, {
+		msg:           "options without preflight headers is forwarded",
+		method:        "OPTIONS",
+		requestHeader: http.Header{"Origin": []string{"https://www.example.org"}},
+		expectedHeader: http.Header{
+			"Vary":                             []string{"Origin"},
+			"Access-Control-Allow-Origin":      []string{"https://www.example.org"},
+			"Access-Control-Allow-Credentials": []string{"true"},
+			"Access-Control-Expose-Headers":    []string{"X-Request-Id"},
+		},
+	}} {
+		a := ti.args
+		if a == nil {
+			a = args
+		}
+
+		f, err := NewCors().CreateFilter(a)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		req := &http.Request{Method: ti.method, Header: ti.requestHeader}
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+
+		ctx := &filtertest.Context{FRequest: req}
+		f.Request(ctx)
+		if ctx.FServed != ti.expectServed {
+			t.Error(ti.msg, "unexpected served state", ti.expectServed, ctx.FServed)
+			continue
+		}
+
+		if !ti.expectServed {
+			ctx.FResponse = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+		}
+
+		f.Response(ctx)
+		if ti.expectServed && ctx.FResponse.StatusCode != ti.expectedStatus {
+			t.Error(ti.msg, "invalid status", ti.expectedStatus, ctx.FResponse.StatusCode)
+		}
+
+		if !compareHeaders(ti.expectedHeader, ctx.FResponse.Header) {
+			t.Error(ti.msg, "invalid headers")
+			printHeader(t, ti.expectedHeader, ti.msg, "expected")
+			printHeader(t, ctx.FResponse.Header, ti.msg, "got")
+		}
+	}
+}
+
+func TestCorsPreflightNotForwarded(t *testing.T) {
+	var backendCalled bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendCalled = true
+	}))
+	defer backend.Close()
+
+	p := proxytest.New(MakeRegistry(), &eskip.Route{
+		Filters: []*eskip.Filter{{Name: CorsName, Args: []interface{}{"origin=https://www.example.org"}}},
+		Backend: backend.URL})
+	defer p.Close()
+
+	req, err := http.NewRequest("OPTIONS", p.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Origin", "https://www.example.org")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if backendCalled {
+		t.Error("preflight request forwarded to the backend")
+	}
+
+	if rsp.StatusCode != http.StatusNoContent {
+		t.Error("invalid status", rsp.StatusCode)
+	}
+
+	if rsp.Header.Get("Access-Control-Allow-Origin") != "https://www.example.org" {
+		t.Error("invalid allowed origin", rsp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
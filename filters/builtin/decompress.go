@@ -0,0 +1,148 @@
+package builtin
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// The default limit of the decompressed request body size: 64MB.
+const DefaultMaxDecompressedSize = 1 << 26
+
+var errDecompressedTooLarge = errors.New("decompressed request body too large")
+
+type decompress struct {
+	maxSize int64
+}
+
+type limitedReadCloser struct {
+	reader    io.Reader
+	closers   []io.Closer
+	remaining int64
+}
+
+// Returns a filter specification that is used to decompress the request
+// content, for backends that cannot handle encoded request bodies.
+//
+// Example:
+//
+// 	* -> decompressRequest() -> "https://www.example.org"
+//
+// The filter, when executed on the request path, checks the Content-Encoding
+// header of the request. When it is set to gzip or deflate, it decodes the
+// request body in a streaming way, deletes the Content-Encoding and the
+// Content-Length headers, and forwards the request with chunked transfer
+// encoding. Requests with other encodings are forwarded untouched.
+//
+// To protect the backends from compression bombs, the size of the
+// decompressed content is limited. When the limit is exceeded, reading the
+// request body fails, and the forwarding of the request is aborted. The
+// default limit is 64MB, and it can be set in bytes as the only filter
+// argument:
+//
+// 	* -> decompressRequest(1048576) -> "https://www.example.org"
+//
+// When the request body is not a valid gzip stream, the filter responds
+// with 400 Bad Request, and the request is not forwarded.
+//
+func NewDecompressRequest() filters.Spec { return &decompress{} }
+
+func (d *decompress) Name() string { return DecompressRequestName }
+
+func (d *decompress) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &decompress{maxSize: DefaultMaxDecompressedSize}
+
+	switch len(args) {
+	case 0:
+		return f, nil
+	case 1:
+		s, ok := args[0].(float64)
+		if !ok || s <= 0 || math.Trunc(s) != s {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.maxSize = int64(s)
+		return f, nil
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (r *limitedReadCloser) Read(b []byte) (int, error) {
+	if r.remaining <= 0 {
+		// checking if there is anything left over the limit:
+		var probe [1]byte
+		n, err := r.reader.Read(probe[:])
+		if n > 0 {
+			return 0, errDecompressedTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(b)) > r.remaining {
+		b = b[:r.remaining]
+	}
+
+	n, err := r.reader.Read(b)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (r *limitedReadCloser) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+func newDecoder(enc string, body io.ReadCloser) (io.ReadCloser, error) {
+	switch enc {
+	case "gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return flate.NewReader(body), nil
+	default:
+		unsupported()
+		return nil, nil
+	}
+}
+
+func (d *decompress) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	if req.Body == nil || req.ContentLength == 0 {
+		return
+	}
+
+	enc := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if enc != "gzip" && enc != "deflate" {
+		return
+	}
+
+	dec, err := newDecoder(enc, req.Body)
+	if err != nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	req.Body = &limitedReadCloser{
+		reader:    dec,
+		closers:   []io.Closer{dec, req.Body},
+		remaining: d.maxSize}
+
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+}
+
+func (d *decompress) Response(filters.FilterContext) {}
@@ -0,0 +1,241 @@
+package builtin
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func encodeTestContent(t *testing.T, enc string, content []byte) []byte {
+	var (
+		b bytes.Buffer
+		w io.WriteCloser
+	)
+
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		var err error
+		w, err = flate.NewWriter(&b, flate.BestSpeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		return content
+	}
+
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func TestDecompressRequestArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg             string
+		args            []interface{}
+		err             error
+		expectedMaxSize int64
+	}{{
+		"default limit",
+		nil,
+		nil,
+		DefaultMaxDecompressedSize,
+	}, {
+		"custom limit",
+		[]interface{}{float64(1024)},
+		nil,
+		1024,
+	}, {
+		"invalid limit",
+		[]interface{}{"1024"},
+		filters.ErrInvalidFilterParameters,
+		0,
+	}, {
+		"non integer limit",
+		[]interface{}{3.14},
+		filters.ErrInvalidFilterParameters,
+		0,
+	}, {
+		"zero limit",
+		[]interface{}{float64(0)},
+		filters.ErrInvalidFilterParameters,
+		0,
+	}, {
+		"too many args",
+		[]interface{}{float64(1024), float64(2048)},
+		filters.ErrInvalidFilterParameters,
+		0,
+	}} {
+		f, err := NewDecompressRequest().CreateFilter(ti.args)
+		if err != ti.err {
+			t.Error(ti.msg, "unexpected error value", ti.err, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if d := f.(*decompress); d.maxSize != ti.expectedMaxSize {
+			t.Error(ti.msg, "invalid max size", ti.expectedMaxSize, d.maxSize)
+		}
+	}
+}
+
+func TestDecompressRequest(t *testing.T) {
+	for _, ti := range []struct {
+		msg              string
+		args             []interface{}
+		contentEncoding  string
+		contentLength    int
+		rawBody          []byte
+		expectedStatus   int
+		expectedEncoding string
+		expectBackend    bool
+	}{{
+		msg:            "not encoded",
+		contentLength:  3 * 8192,
+		expectedStatus: http.StatusOK,
+		expectBackend:  true,
+	}, {
+		msg:             "gzip",
+		contentEncoding: "gzip",
+		contentLength:   3 * 8192,
+		expectedStatus:  http.StatusOK,
+		expectBackend:   true,
+	}, {
+		msg:             "deflate",
+		contentEncoding: "deflate",
+		contentLength:   3 * 8192,
+		expectedStatus:  http.StatusOK,
+		expectBackend:   true,
+	}, {
+		msg:             "large body",
+		contentEncoding: "gzip",
+		contentLength:   maxTestContent,
+		expectedStatus:  http.StatusOK,
+		expectBackend:   true,
+	}, {
+		msg:             "exactly at the limit",
+		args:            []interface{}{float64(3 * 8192)},
+		contentEncoding: "gzip",
+		contentLength:   3 * 8192,
+		expectedStatus:  http.StatusOK,
+		expectBackend:   true,
+	}, {
+		msg:             "over the limit",
+		args:            []interface{}{float64(8192)},
+		contentEncoding: "gzip",
+		contentLength:   3 * 8192,
+		expectedStatus:  http.StatusInternalServerError,
+	}, {
+		msg:              "unsupported encoding passed through",
+		contentEncoding:  "x-custom",
+		contentLength:    3 * 8192,
+		expectedStatus:   http.StatusOK,
+		expectedEncoding: "x-custom",
+		expectBackend:    true,
+	}, {
+		msg:             "invalid gzip",
+		contentEncoding: "gzip",
+		rawBody:         []byte("not gzip"),
+		expectedStatus:  http.StatusBadRequest,
+	}} {
+		var (
+			mx            sync.Mutex
+			backendCalled bool
+			backendBody   []byte
+			backendHeader http.Header
+		)
+
+		// when the proxy fails to read the request body, the backend
+		// handler may still run after the response was received
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+
+			mx.Lock()
+			defer mx.Unlock()
+			backendCalled = true
+			backendHeader = r.Header
+			if err == nil {
+				backendBody = b
+			}
+		}))
+		defer s.Close()
+
+		p := proxytest.New(MakeRegistry(), &eskip.Route{
+			Filters: []*eskip.Filter{{Name: DecompressRequestName, Args: ti.args}},
+			Backend: s.URL})
+		defer p.Close()
+
+		body := ti.rawBody
+		if body == nil {
+			body = encodeTestContent(t, ti.contentEncoding, testContent[:ti.contentLength])
+		}
+
+		req, err := http.NewRequest("POST", p.URL, bytes.NewBuffer(body))
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if ti.contentEncoding != "" {
+			req.Header.Set("Content-Encoding", ti.contentEncoding)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		rsp.Body.Close()
+
+		mx.Lock()
+		called, received, header := backendCalled, backendBody, backendHeader
+		mx.Unlock()
+
+		if rsp.StatusCode != ti.expectedStatus {
+			t.Error(ti.msg, "invalid status code", ti.expectedStatus, rsp.StatusCode)
+			continue
+		}
+
+		if !ti.expectBackend {
+			if called && received != nil {
+				t.Error(ti.msg, "unexpected backend request")
+			}
+
+			continue
+		}
+
+		if !called {
+			t.Error(ti.msg, "backend not called")
+			continue
+		}
+
+		if enc := header.Get("Content-Encoding"); enc != ti.expectedEncoding {
+			t.Error(ti.msg, "invalid content encoding", ti.expectedEncoding, enc)
+		}
+
+		if ti.expectedEncoding == "" && !bytes.Equal(received, testContent[:ti.contentLength]) {
+			t.Error(ti.msg, "invalid content")
+		}
+	}
+}
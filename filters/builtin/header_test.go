@@ -174,6 +174,47 @@ func TestHeader(t *testing.T) {
 		args:           []interface{}{"X-Test-Name"},
 		valid:          true,
 		responseHeader: http.Header{"X-Test-Name": []string{"value0", "value1"}},
+	}, {
+		msg:           "set request header from template",
+		filterName:    "setRequestHeader",
+		args:          []interface{}{"X-Test-Name", "user: ${request.header.X-Test-Source}"},
+		valid:         true,
+		requestHeader: http.Header{"X-Test-Source": []string{"foo"}},
+		expectedHeader: http.Header{
+			"X-Test-Request-Source": []string{"foo"},
+			"X-Test-Request-Name":   []string{"user: foo"}},
+	}, {
+		msg:            "set request header from template, missing value",
+		filterName:     "setRequestHeader",
+		args:           []interface{}{"X-Test-Name", "user: ${request.header.X-Test-Source}"},
+		valid:          true,
+		requestHeader:  http.Header{"X-Test-Name": []string{"value0"}},
+		expectedHeader: http.Header{"X-Test-Request-Name": []string{"value0"}},
+	}, {
+		msg:           "append request header from template",
+		filterName:    "appendRequestHeader",
+		args:          []interface{}{"X-Test-Name", "${request.header.X-Test-Source}"},
+		valid:         true,
+		requestHeader: http.Header{"X-Test-Source": []string{"foo"}, "X-Test-Name": []string{"value0"}},
+		expectedHeader: http.Header{
+			"X-Test-Request-Source": []string{"foo"},
+			"X-Test-Request-Name":   []string{"value0", "foo"}},
+	}, {
+		msg:           "set response header from template",
+		filterName:    "setResponseHeader",
+		args:          []interface{}{"X-Test-Name", "${request.header.X-Test-Source}"},
+		valid:         true,
+		requestHeader: http.Header{"X-Test-Source": []string{"foo"}},
+		expectedHeader: http.Header{
+			"X-Test-Request-Source": []string{"foo"},
+			"X-Test-Name":           []string{"foo"}},
+	}, {
+		msg:            "append response header from template, missing value",
+		filterName:     "appendResponseHeader",
+		args:           []interface{}{"X-Test-Name", "${request.header.X-Test-Source}"},
+		valid:          true,
+		responseHeader: http.Header{"X-Test-Name": []string{"value0"}},
+		expectedHeader: http.Header{"X-Test-Name": []string{"value0"}},
 	}, {
 		msg:        "set outgoing host on set",
 		filterName: "setRequestHeader",
@@ -15,6 +15,7 @@
 package builtin
 
 import (
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"strings"
 )
@@ -38,6 +39,7 @@ const (
 type headerFilter struct {
 	typ              headerType
 	name, key, value string
+	template         *eskip.Template
 }
 
 // verifies that the filter config has two string parameters
@@ -83,6 +85,11 @@ func NewResponseHeader() filters.Spec {
 // Instances expect two parameters: the header name and the header value.
 // Name: "setRequestHeader".
 //
+// The header value can be a template, e.g.
+// setRequestHeader("X-User", "${request.header.Authorization}"). See
+// eskip.Template.ApplyContext for the supported placeholders. When any
+// of the placeholders cannot be resolved, the header is not set.
+//
 // If the header name is 'Host', the filter uses the `SetOutgoingHost()`
 // method to set the header in addition to the standard `Request.Header`
 // map.
@@ -94,6 +101,8 @@ func NewSetRequestHeader() filters.Spec {
 // Instances expect two parameters: the header name and the header value.
 // Name: "appendRequestHeader".
 //
+// The header value can be a template, the same way as for setRequestHeader.
+//
 // If the header name is 'Host', the filter uses the `SetOutgoingHost()`
 // method to set the header in addition to the standard `Request.Header`
 // map.
@@ -111,6 +120,9 @@ func NewDropRequestHeader() filters.Spec {
 // Returns a filter specification that is used to set headers for responses.
 // Instances expect two parameters: the header name and the header value.
 // Name: "setResponseHeader".
+//
+// The header value can be a template, the same way as for setRequestHeader.
+// The placeholders are resolved from the request.
 func NewSetResponseHeader() filters.Spec {
 	return &headerFilter{typ: setResponseHeader}
 }
@@ -118,6 +130,9 @@ func NewSetResponseHeader() filters.Spec {
 // Returns a filter specification that is used to append headers for responses.
 // Instances expect two parameters: the header name and the header value.
 // Name: "appendResponseHeader".
+//
+// The header value can be a template, the same way as for setRequestHeader.
+// The placeholders are resolved from the request.
 func NewAppendResponseHeader() filters.Spec {
 	return &headerFilter{typ: appendResponseHeader}
 }
@@ -154,20 +169,30 @@ func (spec *headerFilter) Name() string {
 
 func (spec *headerFilter) CreateFilter(config []interface{}) (filters.Filter, error) {
 	key, value, err := headerFilterConfig(spec.typ, config)
-	return &headerFilter{typ: spec.typ, key: key, value: value}, err
+	return &headerFilter{typ: spec.typ, key: key, value: value, template: eskip.NewTemplate(value)}, err
 }
 
 func (f *headerFilter) Request(ctx filters.FilterContext) {
 	switch f.typ {
 	case setRequestHeader:
-		ctx.Request().Header.Set(f.key, f.value)
+		value, ok := f.template.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		ctx.Request().Header.Set(f.key, value)
 		if strings.ToLower(f.key) == "host" {
-			ctx.SetOutgoingHost(f.value)
+			ctx.SetOutgoingHost(value)
 		}
 	case appendRequestHeader, depRequestHeader:
-		ctx.Request().Header.Add(f.key, f.value)
+		value, ok := f.template.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		ctx.Request().Header.Add(f.key, value)
 		if strings.ToLower(f.key) == "host" {
-			ctx.SetOutgoingHost(f.value)
+			ctx.SetOutgoingHost(value)
 		}
 	case dropRequestHeader:
 		ctx.Request().Header.Del(f.key)
@@ -177,9 +202,13 @@ func (f *headerFilter) Request(ctx filters.FilterContext) {
 func (f *headerFilter) Response(ctx filters.FilterContext) {
 	switch f.typ {
 	case setResponseHeader:
-		ctx.Response().Header.Set(f.key, f.value)
+		if value, ok := f.template.ApplyContext(ctx); ok {
+			ctx.Response().Header.Set(f.key, value)
+		}
 	case appendResponseHeader, depResponseHeader:
-		ctx.Response().Header.Add(f.key, f.value)
+		if value, ok := f.template.ApplyContext(ctx); ok {
+			ctx.Response().Header.Add(f.key, value)
+		}
 	case dropResponseHeader:
 		ctx.Response().Header.Del(f.key)
 	}
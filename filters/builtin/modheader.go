@@ -0,0 +1,120 @@
+package builtin
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type modHeaderDirection int
+
+const (
+	modRequest modHeaderDirection = iota
+	modResponse
+)
+
+type modHeader struct {
+	direction   modHeaderDirection
+	name        string
+	rx          *regexp.Regexp
+	replacement string
+}
+
+// Returns a filter specification whose instances execute
+// regexp.ReplaceAllString on the values of a request header. Instances
+// expect three parameters: the header name, the expression to match and
+// the replacement string. When the header is not set, the filter has no
+// effect.
+//
+// Example:
+//
+// 	* -> modRequestHeader("Accept", "application/vnd\\.legacy\\+json", "application/json") -> "https://www.example.org"
+//
+// If the header name is 'Host', the filter modifies the outgoing host,
+// using the `SetOutgoingHost()` method.
+//
+// Name: "modRequestHeader".
+func NewModRequestHeader() filters.Spec { return &modHeader{direction: modRequest} }
+
+// Returns a filter specification whose instances execute
+// regexp.ReplaceAllString on the values of a response header. Instances
+// expect three parameters: the header name, the expression to match and
+// the replacement string. When the header is not set, the filter has no
+// effect.
+//
+// Name: "modResponseHeader".
+func NewModResponseHeader() filters.Spec { return &modHeader{direction: modResponse} }
+
+// "modRequestHeader" or "modResponseHeader"
+func (spec *modHeader) Name() string {
+	switch spec.direction {
+	case modRequest:
+		return ModRequestHeaderName
+	case modResponse:
+		return ModResponseHeaderName
+	default:
+		panic("invalid direction")
+	}
+}
+
+func (spec *modHeader) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) != 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := config[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	expr, ok := config[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	replacement, ok := config[2].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &modHeader{
+		direction:   spec.direction,
+		name:        http.CanonicalHeaderKey(name),
+		rx:          rx,
+		replacement: replacement}, nil
+}
+
+func (f *modHeader) modify(h http.Header) {
+	values := h[f.name]
+	for i, v := range values {
+		values[i] = f.rx.ReplaceAllString(v, f.replacement)
+	}
+}
+
+func (f *modHeader) Request(ctx filters.FilterContext) {
+	if f.direction != modRequest {
+		return
+	}
+
+	if strings.ToLower(f.name) == "host" {
+		ctx.SetOutgoingHost(f.rx.ReplaceAllString(ctx.OutgoingHost(), f.replacement))
+		return
+	}
+
+	f.modify(ctx.Request().Header)
+}
+
+func (f *modHeader) Response(ctx filters.FilterContext) {
+	if f.direction != modResponse {
+		return
+	}
+
+	f.modify(ctx.Response().Header)
+}
@@ -0,0 +1,141 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestModHeaderArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"too few args",
+		[]interface{}{"X-Test", "foo"},
+		true,
+	}, {
+		"too many args",
+		[]interface{}{"X-Test", "foo", "bar", "baz"},
+		true,
+	}, {
+		"name not string",
+		[]interface{}{3.14, "foo", "bar"},
+		true,
+	}, {
+		"empty name",
+		[]interface{}{"", "foo", "bar"},
+		true,
+	}, {
+		"expression not string",
+		[]interface{}{"X-Test", 3.14, "bar"},
+		true,
+	}, {
+		"replacement not string",
+		[]interface{}{"X-Test", "foo", 3.14},
+		true,
+	}, {
+		"invalid expression",
+		[]interface{}{"X-Test", "(foo", "bar"},
+		true,
+	}, {
+		"valid",
+		[]interface{}{"X-Test", "foo", "bar"},
+		false,
+	}} {
+		for _, s := range []filters.Spec{NewModRequestHeader(), NewModResponseHeader()} {
+			_, err := s.CreateFilter(ti.args)
+			if ti.err && err == nil {
+				t.Error(ti.msg, s.Name(), "failed to fail")
+			} else if !ti.err && err != nil {
+				t.Error(ti.msg, s.Name(), err)
+			}
+		}
+	}
+}
+
+func TestModHeader(t *testing.T) {
+	for _, ti := range []struct {
+		msg          string
+		spec         filters.Spec
+		args         []interface{}
+		header       http.Header
+		host         string
+		expected     http.Header
+		expectedHost string
+	}{{
+		msg:      "request header missing",
+		spec:     NewModRequestHeader(),
+		args:     []interface{}{"X-Test", "foo", "bar"},
+		header:   http.Header{},
+		expected: http.Header{},
+	}, {
+		msg:      "request header not matching",
+		spec:     NewModRequestHeader(),
+		args:     []interface{}{"X-Test", "foo", "bar"},
+		header:   http.Header{"X-Test": []string{"baz"}},
+		expected: http.Header{"X-Test": []string{"baz"}},
+	}, {
+		msg:      "request header, all values",
+		spec:     NewModRequestHeader(),
+		args:     []interface{}{"x-test", "foo", "bar"},
+		header:   http.Header{"X-Test": []string{"foo-1", "2-foo-foo"}},
+		expected: http.Header{"X-Test": []string{"bar-1", "2-bar-bar"}},
+	}, {
+		msg:      "request header, with groups",
+		spec:     NewModRequestHeader(),
+		args:     []interface{}{"Accept", "^application/vnd\\.(\\w+)\\+json$", "application/json; v=$1"},
+		header:   http.Header{"Accept": []string{"application/vnd.legacy+json"}},
+		expected: http.Header{"Accept": []string{"application/json; v=legacy"}},
+	}, {
+		msg:          "request host",
+		spec:         NewModRequestHeader(),
+		args:         []interface{}{"Host", "^www\\.", "api."},
+		header:       http.Header{},
+		host:         "www.example.org",
+		expected:     http.Header{},
+		expectedHost: "api.example.org",
+	}, {
+		msg:      "response header",
+		spec:     NewModResponseHeader(),
+		args:     []interface{}{"Link", "http://internal", "https://external"},
+		header:   http.Header{"Link": []string{"<http://internal/next>; rel=\"next\""}},
+		expected: http.Header{"Link": []string{"<https://external/next>; rel=\"next\""}},
+	}} {
+		f, err := ti.spec.CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:      &http.Request{Header: http.Header{}},
+			FResponse:     &http.Response{Header: http.Header{}},
+			FOutgoingHost: ti.host}
+
+		if ti.spec.Name() == ModRequestHeaderName {
+			ctx.FRequest.Header = ti.header
+			f.Request(ctx)
+			if !compareHeaders(ctx.FRequest.Header, ti.expected) {
+				t.Error(ti.msg, "invalid request header", ctx.FRequest.Header)
+			}
+		} else {
+			ctx.FResponse.Header = ti.header
+			f.Response(ctx)
+			if !compareHeaders(ctx.FResponse.Header, ti.expected) {
+				t.Error(ti.msg, "invalid response header", ctx.FResponse.Header)
+			}
+		}
+
+		if ti.expectedHost != "" && ctx.FOutgoingHost != ti.expectedHost {
+			t.Error(ti.msg, "invalid outgoing host", ctx.FOutgoingHost, ti.expectedHost)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"net/url"
 	"regexp"
 
 	"github.com/zalando/skipper/eskip"
@@ -34,7 +35,13 @@ func NewModPath() filters.Spec { return &modPath{behavior: regexpReplace} }
 // to apply template operations. The current solution supports templates
 // with placeholders of the format: ${param1}, and the placeholders will
 // be replaced with the values of the same name from the wildcards in the
-// Path() predicate.
+// Path() predicate. Request headers, query parameters, cookies, the client
+// IP and the state bag can be used in the placeholders, too, see
+// eskip.Template.ApplyContext. The values of the request placeholders are
+// escaped as path segments, so a slash in them doesn't introduce a new
+// path segment, while the path parameters, e.g. of freeform wildcards, can
+// contain multiple segments. Missing values are replaced with an empty
+// string.
 //
 // See: https://godoc.org/github.com/zalando/skipper/routing#hdr-Wildcards
 //
@@ -116,7 +123,12 @@ func (f *modPath) Request(ctx filters.FilterContext) {
 	case regexpReplace:
 		req.URL.Path = f.rx.ReplaceAllString(req.URL.Path, f.replacement)
 	case fullReplace:
-		req.URL.Path = f.template.Apply(ctx.PathParam)
+		p, _ := f.template.ApplyContextEscaped(ctx, eskip.PathEscaping)
+		if up, err := url.PathUnescape(p); err == nil {
+			req.URL.Path, req.URL.RawPath = up, p
+		} else {
+			req.URL.Path, req.URL.RawPath = p, ""
+		}
 	default:
 		panic("unspecified behavior")
 	}
@@ -81,6 +81,32 @@ func TestSetPathWithTemplate(t *testing.T) {
 	}
 }
 
+func TestSetPathWithTemplateEscapesValues(t *testing.T) {
+	spec := NewSetPath()
+	f, err := spec.CreateFilter([]interface{}{"/path${rest}/${request.query.id}"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/foo?id=..%2Fadmin%3Ffoo%3Dbar", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FParams: map[string]string{
+		"rest": "/a/b",
+	}}
+
+	f.Request(ctx)
+	if req.URL.Path != "/path/a/b/../admin?foo=bar" {
+		t.Error("failed to transform path", req.URL.Path)
+	}
+
+	if req.URL.EscapedPath() != "/path/a/b/..%2Fadmin%3Ffoo=bar" {
+		t.Error("failed to escape the request value", req.URL.EscapedPath())
+	}
+}
+
 func testCreate(t *testing.T, spec func() filters.Spec, items []createTestItem) {
 	for _, ti := range items {
 		func() {
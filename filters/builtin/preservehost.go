@@ -17,6 +17,8 @@ package builtin
 import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/routing"
 	"net/url"
 )
 
@@ -53,15 +55,24 @@ func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
 func (preserve filter) Response(_ filters.FilterContext) {}
 
 func (preserve filter) Request(ctx filters.FilterContext) {
-	u, err := url.Parse(ctx.BackendUrl())
-	if err != nil {
-		log.Error("failed to parse backend host in preserveHost filter", err)
-		return
+	var backendHost string
+	if endpoints, ok := ctx.StateBag()[loadbalancer.EndpointsKey].([]routing.LBEndpoint); ok && len(endpoints) > 0 {
+		// in case of multiple endpoints, the proxy replaces the host of
+		// the first one with the host of the selected endpoint
+		backendHost = endpoints[0].Host
+	} else {
+		u, err := url.Parse(ctx.BackendUrl())
+		if err != nil {
+			log.Error("failed to parse backend host in preserveHost filter", err)
+			return
+		}
+
+		backendHost = u.Host
 	}
 
-	if preserve && ctx.OutgoingHost() == u.Host {
+	if preserve && ctx.OutgoingHost() == backendHost {
 		ctx.SetOutgoingHost(ctx.Request().Host)
 	} else if !preserve && ctx.OutgoingHost() == ctx.Request().Host {
-		ctx.SetOutgoingHost(u.Host)
+		ctx.SetOutgoingHost(backendHost)
 	}
 }
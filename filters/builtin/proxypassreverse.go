@@ -0,0 +1,254 @@
+package builtin
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/loadbalancer"
+	"github.com/zalando/skipper/routing"
+)
+
+type proxyPassReverse struct {
+	internal, external *url.URL
+}
+
+// the internal and the external addresses used for a single response,
+// when the internal is not set, only the hosts of the locations are
+// rewritten
+type reverseMapping struct {
+	internalHosts []string
+	internal      *url.URL
+	external      *url.URL
+}
+
+// Returns a filter specification whose instances rewrite the Location and
+// Content-Location headers and the domain and path of the Set-Cookie
+// headers in the responses, so that backends emitting their internal
+// addresses work behind the proxy. It works similar to the ProxyPassReverse
+// and ProxyPassReverseCookieDomain directives of Apache.
+//
+// Without arguments, the internal address is taken from the route backend
+// and from the outgoing Host header, and the external address from the
+// incoming request:
+//
+// 	* -> proxyPassReverse() -> "http://10.0.0.1:8080"
+//
+// With this, a `Location: http://10.0.0.1:8080/login` response header is
+// rewritten to `Location: https://www.example.org/login`, when the
+// incoming request was https://www.example.org/. The scheme of the
+// external address is https when the incoming request was received over
+// TLS, or when its X-Forwarded-Proto header is https.
+//
+// Optionally, the internal and external base URLs can be set explicitly,
+// including their path prefixes:
+//
+// 	* -> proxyPassReverse("http://app.internal:8080/app/", "https://www.example.org/") -> "http://app.internal:8080"
+//
+// In this case, every location starting with the internal URL has its
+// prefix replaced with the external URL, and the path of the cookies
+// starting with the internal path are rewritten to start with the
+// external path.
+//
+// Cookie domains matching the internal host are replaced with the
+// external host.
+//
+// Name: "proxyPassReverse".
+func NewProxyPassReverse() filters.Spec { return &proxyPassReverse{} }
+
+// "proxyPassReverse"
+func (spec *proxyPassReverse) Name() string { return ProxyPassReverseName }
+
+func (spec *proxyPassReverse) CreateFilter(config []interface{}) (filters.Filter, error) {
+	switch len(config) {
+	case 0:
+		return &proxyPassReverse{}, nil
+	case 2:
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var u [2]*url.URL
+	for i, c := range config {
+		s, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		ui, err := url.Parse(s)
+		if err != nil || ui.Host == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		u[i] = ui
+	}
+
+	return &proxyPassReverse{internal: u[0], external: u[1]}, nil
+}
+
+func (f *proxyPassReverse) Request(filters.FilterContext) {}
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || strings.ToLower(r.Header.Get("X-Forwarded-Proto")) == "https" {
+		return "https"
+	}
+
+	return "http"
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+
+	return host
+}
+
+func (f *proxyPassReverse) mapping(ctx filters.FilterContext) *reverseMapping {
+	if f.internal != nil {
+		return &reverseMapping{
+			internalHosts: []string{f.internal.Host},
+			internal:      f.internal,
+			external:      f.external}
+	}
+
+	var req = ctx.Request()
+	if ctx.OriginalRequest() != nil {
+		req = ctx.OriginalRequest()
+	}
+
+	m := &reverseMapping{external: &url.URL{Scheme: requestScheme(req), Host: req.Host}}
+	if e, ok := ctx.StateBag()[loadbalancer.EndpointKey].(routing.LBEndpoint); ok {
+		m.internalHosts = append(m.internalHosts, e.Host)
+	} else if b, err := url.Parse(ctx.BackendUrl()); err == nil && b.Host != "" {
+		m.internalHosts = append(m.internalHosts, b.Host)
+	}
+
+	if h := ctx.OutgoingHost(); h != "" {
+		m.internalHosts = append(m.internalHosts, h)
+	}
+
+	return m
+}
+
+func (m *reverseMapping) isInternalHost(host string) bool {
+	for _, h := range m.internalHosts {
+		if strings.EqualFold(host, h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *reverseMapping) rewriteLocation(location string) string {
+	if m.internal != nil {
+		internal := strings.TrimSuffix(m.internal.String(), "/")
+		if !strings.HasPrefix(location, internal) {
+			return location
+		}
+
+		// the prefix needs to end at a path segment or at the port
+		rest := location[len(internal):]
+		if rest != "" && !strings.ContainsRune("/?#", rune(rest[0])) {
+			return location
+		}
+
+		// an empty path is replaced by the root path, the same way as in
+		// rewritePath
+		if strings.TrimSuffix(m.external.Path, "/") == "" && !strings.HasPrefix(rest, "/") {
+			rest = "/" + rest
+		}
+
+		return strings.TrimSuffix(m.external.String(), "/") + rest
+	}
+
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" || !m.isInternalHost(u.Host) {
+		return location
+	}
+
+	u.Scheme = m.external.Scheme
+	u.Host = m.external.Host
+	return u.String()
+}
+
+func (m *reverseMapping) rewritePath(p string) string {
+	if m.internal == nil {
+		return p
+	}
+
+	internal := strings.TrimSuffix(m.internal.Path, "/")
+	if !strings.HasPrefix(p, internal) {
+		return p
+	}
+
+	rest := p[len(internal):]
+	if rest != "" && rest[0] != '/' {
+		return p
+	}
+
+	p = strings.TrimSuffix(m.external.Path, "/") + rest
+	if p == "" {
+		p = "/"
+	}
+
+	return p
+}
+
+// rewrites the domain and the path attributes of a raw Set-Cookie header
+// value, leaving the rest untouched
+func (m *reverseMapping) rewriteCookie(c string) string {
+	parts := strings.Split(c, ";")
+	for i, p := range parts {
+		// the first part is the name and the value of the cookie
+		if i == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+		switch key {
+		case "domain":
+			domain := strings.TrimPrefix(value, ".")
+			for _, h := range m.internalHosts {
+				if strings.EqualFold(domain, hostname(h)) {
+					parts[i] = " Domain=" + hostname(m.external.Host)
+					break
+				}
+			}
+		case "path":
+			if rp := m.rewritePath(value); rp != value {
+				parts[i] = " Path=" + rp
+			}
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (f *proxyPassReverse) Response(ctx filters.FilterContext) {
+	h := ctx.Response().Header
+	m := f.mapping(ctx)
+	if m.external.Host == "" {
+		return
+	}
+
+	for _, name := range []string{"Location", "Content-Location"} {
+		if l := h.Get(name); l != "" {
+			h.Set(name, m.rewriteLocation(l))
+		}
+	}
+
+	cookies := h["Set-Cookie"]
+	for i, c := range cookies {
+		cookies[i] = m.rewriteCookie(c)
+	}
+}
@@ -0,0 +1,192 @@
+package builtin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestProxyPassReverseArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		false,
+	}, {
+		"one arg",
+		[]interface{}{"http://internal"},
+		true,
+	}, {
+		"not string",
+		[]interface{}{"http://internal", 3.14},
+		true,
+	}, {
+		"not absolute",
+		[]interface{}{"/internal", "https://www.example.org"},
+		true,
+	}, {
+		"valid",
+		[]interface{}{"http://internal:8080/app/", "https://www.example.org/"},
+		false,
+	}} {
+		_, err := NewProxyPassReverse().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestProxyPassReverse(t *testing.T) {
+	for _, ti := range []struct {
+		msg            string
+		args           []interface{}
+		backend        string
+		outgoingHost   string
+		host           string
+		tls            bool
+		requestHeader  http.Header
+		responseHeader http.Header
+		expectedHeader http.Header
+	}{{
+		msg:            "no location",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{},
+		expectedHeader: http.Header{},
+	}, {
+		msg:            "relative location untouched",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"/login"}},
+		expectedHeader: http.Header{"Location": []string{"/login"}},
+	}, {
+		msg:            "foreign location untouched",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"https://auth.example.org/login"}},
+		expectedHeader: http.Header{"Location": []string{"https://auth.example.org/login"}},
+	}, {
+		msg:            "backend location",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://10.0.0.1:8080/login?foo=bar"}},
+		expectedHeader: http.Header{"Location": []string{"http://www.example.org/login?foo=bar"}},
+	}, {
+		msg:            "backend location, tls",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		tls:            true,
+		responseHeader: http.Header{"Location": []string{"http://10.0.0.1:8080/login"}},
+		expectedHeader: http.Header{"Location": []string{"https://www.example.org/login"}},
+	}, {
+		msg:            "backend location, forwarded proto",
+		backend:        "http://10.0.0.1:8080",
+		host:           "www.example.org",
+		requestHeader:  http.Header{"X-Forwarded-Proto": []string{"https"}},
+		responseHeader: http.Header{"Content-Location": []string{"http://10.0.0.1:8080/doc"}},
+		expectedHeader: http.Header{"Content-Location": []string{"https://www.example.org/doc"}},
+	}, {
+		msg:            "outgoing host location",
+		backend:        "http://10.0.0.1:8080",
+		outgoingHost:   "app.internal",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal/login"}},
+		expectedHeader: http.Header{"Location": []string{"http://www.example.org/login"}},
+	}, {
+		msg:     "cookie domain",
+		backend: "http://app.internal:8080",
+		host:    "www.example.org",
+		responseHeader: http.Header{"Set-Cookie": []string{
+			"session=abc; Domain=app.internal; Path=/; HttpOnly",
+			"other=def; domain=.app.internal",
+			"foreign=ghi; Domain=example.org"}},
+		expectedHeader: http.Header{"Set-Cookie": []string{
+			"session=abc; Domain=www.example.org; Path=/; HttpOnly",
+			"other=def; Domain=www.example.org",
+			"foreign=ghi; Domain=example.org"}},
+	}, {
+		msg:            "explicit mapping, location with prefix",
+		args:           []interface{}{"http://app.internal:8080/app/", "https://www.example.org/"},
+		backend:        "http://app.internal:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal:8080/app/login"}},
+		expectedHeader: http.Header{"Location": []string{"https://www.example.org/login"}},
+	}, {
+		msg:            "explicit mapping, location without prefix",
+		args:           []interface{}{"http://app.internal:8080/app/", "https://www.example.org/"},
+		backend:        "http://app.internal:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal:8080/other/login"}},
+		expectedHeader: http.Header{"Location": []string{"http://app.internal:8080/other/login"}},
+	}, {
+		msg:            "explicit mapping, location with the prefix not at a path segment boundary",
+		args:           []interface{}{"http://app.internal:8080/app/", "https://www.example.org/"},
+		backend:        "http://app.internal:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal:8080/apple"}},
+		expectedHeader: http.Header{"Location": []string{"http://app.internal:8080/apple"}},
+	}, {
+		msg:            "explicit mapping, location with the exact prefix and a query",
+		args:           []interface{}{"http://app.internal:8080/app/", "https://www.example.org/"},
+		backend:        "http://app.internal:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal:8080/app?foo=bar"}},
+		expectedHeader: http.Header{"Location": []string{"https://www.example.org/?foo=bar"}},
+	}, {
+		msg:            "explicit mapping, location with a different port",
+		args:           []interface{}{"http://app.internal:8080", "https://www.example.org"},
+		backend:        "http://app.internal:8080",
+		host:           "www.example.org",
+		responseHeader: http.Header{"Location": []string{"http://app.internal:80801/login"}},
+		expectedHeader: http.Header{"Location": []string{"http://app.internal:80801/login"}},
+	}, {
+		msg:     "explicit mapping, cookie path",
+		args:    []interface{}{"http://app.internal:8080/app/", "https://www.example.org/shop/"},
+		backend: "http://app.internal:8080",
+		host:    "www.example.org",
+		responseHeader: http.Header{"Set-Cookie": []string{
+			"session=abc; Domain=app.internal; Path=/app/cart",
+			"root=def; Path=/app",
+			"other=ghi; Path=/application"}},
+		expectedHeader: http.Header{"Set-Cookie": []string{
+			"session=abc; Domain=www.example.org; Path=/shop/cart",
+			"root=def; Path=/shop",
+			"other=ghi; Path=/application"}},
+	}} {
+		f, err := NewProxyPassReverse().CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		req := &http.Request{Host: ti.host, Header: ti.requestHeader}
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+
+		if ti.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:      req,
+			FResponse:     &http.Response{Header: ti.responseHeader},
+			FBackendUrl:   ti.backend,
+			FOutgoingHost: ti.outgoingHost}
+
+		f.Response(ctx)
+
+		if !compareHeaders(ctx.FResponse.Header, ti.expectedHeader) {
+			printHeader(t, ti.expectedHeader, ti.msg, "invalid header", "expected")
+			printHeader(t, ctx.FResponse.Header, ti.msg, "invalid header", "got")
+			t.Error(ti.msg, "invalid header")
+		}
+	}
+}
@@ -25,7 +25,9 @@ type modQuery struct {
 // to apply template operations. The current solution supports templates
 // with placeholders of the format: ${param1}, and the placeholders will
 // be replaced with the values of the same name from the wildcards in the
-// Path() predicate.
+// Path() predicate, or with the request values supported by
+// eskip.Template.ApplyContext. When a placeholder cannot be resolved, the
+// query is not changed.
 // The templating feature will stay in Skipper, but the syntax of the
 // templating may change.
 //
@@ -41,7 +43,10 @@ func NewDropQuery() filters.Spec { return &modQuery{behavior: drop} }
 // to apply template operations. The current solution supports templates
 // with placeholders of the format: ${param1}, and the placeholders will
 // be replaced with the values of the same name from the wildcards in the
-// Path() predicate.
+// Path() predicate. Request headers, query parameters, cookies, the client
+// IP and the state bag can be used in the placeholders, too, see
+// eskip.Template.ApplyContext. When a placeholder cannot be resolved, the
+// query parameter is not set.
 //
 // See: https://godoc.org/github.com/zalando/skipper/routing#hdr-Wildcards
 //
@@ -116,11 +121,28 @@ func (f *modQuery) Request(ctx filters.FilterContext) {
 	req := ctx.Request()
 	params := req.URL.Query()
 
+	// when a placeholder cannot be resolved, the query is left unchanged,
+	// the same way as the headers by the header filters
 	switch f.behavior {
 	case drop:
-		params.Del(f.name.Apply(ctx.PathParam))
+		name, ok := f.name.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		params.Del(name)
 	case set:
-		params.Set(f.name.Apply(ctx.PathParam), f.value.Apply(ctx.PathParam))
+		name, ok := f.name.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		value, ok := f.value.ApplyContext(ctx)
+		if !ok {
+			return
+		}
+
+		params.Set(name, value)
 	default:
 		panic("unspecified behavior")
 	}
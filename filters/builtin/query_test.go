@@ -88,3 +88,41 @@ func TestSetQueryWithTemplate(t *testing.T) {
 		t.Error("failed to transform path")
 	}
 }
+
+func TestSetQueryWithUnresolvedTemplate(t *testing.T) {
+	spec := NewSetQuery()
+	f, err := spec.CreateFilter([]interface{}{"user", "${request.header.X-User}"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/path?foo=1", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	if req.URL.String() != "https://www.example.org/path?foo=1" {
+		t.Error("unexpectedly changed the query", req.URL.String())
+	}
+}
+
+func TestDropQueryWithUnresolvedTemplate(t *testing.T) {
+	spec := NewDropQuery()
+	f, err := spec.CreateFilter([]interface{}{"${missing}"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/path?foo=1&bar=2", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	if req.URL.String() != "https://www.example.org/path?foo=1&bar=2" {
+		t.Error("unexpectedly changed the query", req.URL.String())
+	}
+}
@@ -15,6 +15,7 @@
 package builtin
 
 import (
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"net/http"
 	"net/url"
@@ -24,7 +25,7 @@ import (
 type redirect struct {
 	deprecated bool
 	code       int
-	location   *url.URL
+	template   *eskip.Template
 }
 
 // Returns a new filter Spec, whose instances create an HTTP redirect
@@ -41,6 +42,15 @@ func NewRedirect() filters.Spec { return &redirect{deprecated: true} }
 // backend. Instances expect two parameters: the redirect status code and
 // the redirect location.
 // Name: "redirectTo".
+//
+// The location can be a template, e.g.
+// redirectTo(302, "https://login.example.org/?return=${request.path}"). See
+// eskip.Template.ApplyContext for the supported placeholders. The values
+// of the request placeholders are escaped as path segments in the path,
+// and as query components in the query, so they cannot add path segments
+// or query parameters, while the path parameters can contain multiple
+// path segments, see eskip.URLEscaping. Missing values are replaced with
+// an empty string.
 func NewRedirectTo() filters.Spec { return &redirect{deprecated: false} }
 
 // "redirect" or "redirectTo"
@@ -72,12 +82,19 @@ func (spec *redirect) CreateFilter(config []interface{}) (filters.Filter, error)
 		return invalidArgs()
 	}
 
-	u, err := url.Parse(location)
-	if err != nil {
-		return invalidArgs()
+	// a templated location is validated only after the template is
+	// applied, because the placeholders can be in any part of the URL
+	t := eskip.NewTemplate(location)
+	if t.Apply(func(string) string { return "" }) == location {
+		if _, err := url.Parse(location); err != nil {
+			return invalidArgs()
+		}
 	}
 
-	return &redirect{spec.deprecated, int(code), u}, nil
+	return &redirect{
+		deprecated: spec.deprecated,
+		code:       int(code),
+		template:   t}, nil
 }
 
 func getRequestHost(r *http.Request) string {
@@ -133,12 +150,23 @@ func Redirect(ctx filters.FilterContext, code int, location *url.URL) {
 		Header:     http.Header{"Location": []string{u}}})
 }
 
+func (f *redirect) resolveLocation(ctx filters.FilterContext) (*url.URL, error) {
+	location, _ := f.template.ApplyContextEscaped(ctx, eskip.URLEscaping)
+	return url.Parse(location)
+}
+
 func (f *redirect) Request(ctx filters.FilterContext) {
 	if f.deprecated {
 		return
 	}
 
-	Redirect(ctx, f.code, f.location)
+	location, err := f.resolveLocation(ctx)
+	if err != nil {
+		ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		return
+	}
+
+	Redirect(ctx, f.code, location)
 }
 
 // Sets the status code and the location header of the response. Marks the
@@ -148,7 +176,14 @@ func (f *redirect) Response(ctx filters.FilterContext) {
 		return
 	}
 
-	u := getLocation(ctx, f.location)
+	location, err := f.resolveLocation(ctx)
+	if err != nil {
+		ctx.ResponseWriter().WriteHeader(http.StatusInternalServerError)
+		ctx.MarkServed()
+		return
+	}
+
+	u := getLocation(ctx, location)
 	w := ctx.ResponseWriter()
 	w.Header().Set("Location", u)
 	w.WriteHeader(f.code)
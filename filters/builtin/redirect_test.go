@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/filtertest"
 	"github.com/zalando/skipper/logging/loggingtest"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/routing"
@@ -85,6 +86,31 @@ func TestRedirect(t *testing.T) {
 		http.StatusFound,
 		"http://?newquery=3",
 		"http://incoming.example.org/some/path?newquery=3",
+	}, {
+		"templated path",
+		http.StatusFound,
+		"/other/${request.query.foo}",
+		"https://incoming.example.org/other/1?foo=1&bar=2",
+	}, {
+		"templated path, request path",
+		http.StatusFound,
+		"/other${request.path}",
+		"https://incoming.example.org/other/some/path?foo=1&bar=2",
+	}, {
+		"templated host",
+		http.StatusFound,
+		"https://redirect.example.org${request.path}",
+		"https://redirect.example.org/some/path?foo=1&bar=2",
+	}, {
+		"templated query, escaped value",
+		http.StatusFound,
+		"/other?return=${request.path}",
+		"https://incoming.example.org/other?return=%2Fsome%2Fpath",
+	}, {
+		"templated, missing value",
+		http.StatusFound,
+		"/other/${request.query.missing}",
+		"https://incoming.example.org/other/?foo=1&bar=2",
 	}, {
 		"different code",
 		http.StatusMovedPermanently,
@@ -144,3 +170,25 @@ func TestRedirect(t *testing.T) {
 		}
 	}
 }
+
+func TestRedirectTemplatedPathParam(t *testing.T) {
+	f, err := NewRedirectTo().CreateFilter([]interface{}{float64(http.StatusFound), "/new${rest}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://www.example.org/v1/a/b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FRequest: req, FParams: map[string]string{"rest": "/a/b"}}
+	f.Request(ctx)
+	if !ctx.FServed || ctx.FResponse == nil {
+		t.Fatal("failed to redirect")
+	}
+
+	if l := ctx.FResponse.Header.Get("Location"); l != "https://www.example.org/new/a/b" {
+		t.Error("invalid location", l)
+	}
+}
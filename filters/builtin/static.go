@@ -2,15 +2,52 @@ package builtin
 
 import (
 	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/serve"
-	"net/http"
 )
 
+const (
+	staticIndexOption         = "index="
+	staticPrecompressedOption = "precompressed"
+	staticNoListingOption     = "nolisting"
+	staticMemoryOption        = "memory"
+	staticCacheOption         = "cache="
+)
+
+// the supported encodings of the precompressed files and the
+// extensions of their file names
+var precompressedEncodings = []string{"br", "gzip"}
+
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz"}
+
+type cacheRule struct {
+	pattern      string
+	cacheControl string
+}
+
 type static struct {
 	handler http.Handler
 }
 
+type staticHandler struct {
+	fs            http.FileSystem
+	fileServer    http.Handler
+	index         string
+	precompressed bool
+	noListing     bool
+	cache         []cacheRule
+}
+
 // Returns a filter Spec to serve static content from a file system
 // location. Behaves similarly to net/http.FileServer. It shunts the route.
 //
@@ -18,7 +55,36 @@ type static struct {
 // request path prefix and a local directory path. When processing a
 // request, it clips the prefix from the request path, and appends the
 // rest of the path to the directory path. Then, it uses the resulting
-// path to serve static content from the file system.
+// path to serve static content from the file system. Range requests
+// and conditional GET requests are supported.
+//
+// Further optional string arguments can be used to control the
+// behavior of the filter:
+//
+// "index=<file>": single page application mode. When the requested file
+// is not found, the filter serves the index file from the root
+// directory instead, e.g. "index=index.html".
+//
+// "precompressed": when the client accepts the br or gzip encoding, and
+// a precompressed sibling of the requested file exists with the .br or
+// .gz extension, the filter serves the precompressed file with the
+// right Content-Encoding.
+//
+// "cache=<pattern>:<value>": sets the Cache-Control header to value for
+// the files matching pattern. Patterns are matched with path.Match,
+// against the file name, or, when the pattern contains a slash, against
+// the full path. The argument can be repeated, the first matching
+// pattern wins, e.g. "cache=*.js:max-age=31536000".
+//
+// "nolisting": disables directory listings.
+//
+// "memory": loads the complete directory into memory at the time when
+// the route is created, and serves the files from there. Changes to
+// the directory after the route was created are not reflected.
+//
+// Example:
+//
+// 	* -> static("/app", "/var/www/app", "index=index.html", "precompressed", "nolisting") -> <shunt>
 //
 // Name: "static".
 func NewStatic() filters.Spec { return &static{} }
@@ -27,10 +93,10 @@ func NewStatic() filters.Spec { return &static{} }
 func (spec *static) Name() string { return StaticName }
 
 // Creates instances of the static filter. Expects two parameters: request path
-// prefix and file system root.
+// prefix and file system root, followed by the optional settings.
 func (spec *static) CreateFilter(config []interface{}) (filters.Filter, error) {
-	if len(config) != 2 {
-		return nil, fmt.Errorf("invalid number of args: %d, expected 1", len(config))
+	if len(config) < 2 {
+		return nil, fmt.Errorf("invalid number of args: %d, expected at least 2", len(config))
 	}
 
 	webRoot, ok := config[0].(string)
@@ -43,7 +109,66 @@ func (spec *static) CreateFilter(config []interface{}) (filters.Filter, error) {
 		return nil, fmt.Errorf("invalid parameter type, expected string for path to root dir")
 	}
 
-	return &static{http.StripPrefix(webRoot, http.FileServer(http.Dir(root)))}, nil
+	if len(config) == 2 {
+		return &static{http.StripPrefix(webRoot, http.FileServer(http.Dir(root)))}, nil
+	}
+
+	h := &staticHandler{}
+	var memory bool
+	for _, c := range config[2:] {
+		o, ok := c.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter type, expected string for option")
+		}
+
+		switch {
+		case strings.HasPrefix(o, staticIndexOption):
+			h.index = path.Clean("/" + strings.TrimPrefix(o, staticIndexOption))
+		case o == staticPrecompressedOption:
+			h.precompressed = true
+		case o == staticNoListingOption:
+			h.noListing = true
+		case o == staticMemoryOption:
+			memory = true
+		case strings.HasPrefix(o, staticCacheOption):
+			r, err := parseCacheRule(strings.TrimPrefix(o, staticCacheOption))
+			if err != nil {
+				return nil, err
+			}
+
+			h.cache = append(h.cache, r)
+		default:
+			return nil, fmt.Errorf("invalid option for the static filter: %s", o)
+		}
+	}
+
+	if memory {
+		fs, err := loadMemFS(root)
+		if err != nil {
+			return nil, err
+		}
+
+		h.fs = fs
+	} else {
+		h.fs = http.Dir(root)
+	}
+
+	h.fileServer = http.FileServer(h.fs)
+	return &static{http.StripPrefix(webRoot, h)}, nil
+}
+
+func parseCacheRule(s string) (cacheRule, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 || i == len(s)-1 {
+		return cacheRule{}, fmt.Errorf("invalid cache option, expected <pattern>:<value>: %s", s)
+	}
+
+	r := cacheRule{pattern: s[:i], cacheControl: s[i+1:]}
+	if _, err := path.Match(r.pattern, ""); err != nil {
+		return cacheRule{}, fmt.Errorf("invalid cache option pattern: %s", r.pattern)
+	}
+
+	return r, nil
 }
 
 // Serves content from the file system and marks the request served.
@@ -53,3 +178,173 @@ func (f *static) Request(ctx filters.FilterContext) {
 
 // Noop.
 func (f *static) Response(filters.FilterContext) {}
+
+func (h *staticHandler) stat(name string) (os.FileInfo, error) {
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return f.Stat()
+}
+
+func (h *staticHandler) setCacheControl(w http.ResponseWriter, name string) {
+	for _, r := range h.cache {
+		target := path.Base(name)
+		if strings.Contains(r.pattern, "/") {
+			target = name
+		}
+
+		if m, _ := path.Match(r.pattern, target); m {
+			w.Header().Set("Cache-Control", r.cacheControl)
+			return
+		}
+	}
+}
+
+// serves a file, or its precompressed sibling when it exists and the
+// client accepts its encoding
+func (h *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	h.setCacheControl(w, name)
+
+	if h.precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		for _, enc := range acceptedEncodings(r, precompressedEncodings) {
+			if h.servePrecompressed(w, r, name, enc) {
+				return
+			}
+		}
+	}
+
+	f, err := h.fs.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+func (h *staticHandler) servePrecompressed(w http.ResponseWriter, r *http.Request, name, enc string) bool {
+	f, err := h.fs.Open(name + precompressedExtensions[enc])
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
+	}
+
+	// the content type is detected from the original name, not
+	// from the compressed content:
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Content-Encoding", enc)
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+	return true
+}
+
+func (h *staticHandler) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	if h.index == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.serveFile(w, r, h.index)
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	name = path.Clean(name)
+
+	fi, err := h.stat(name)
+	switch {
+	case err != nil:
+		h.serveNotFound(w, r)
+	case fi.IsDir():
+		if !h.noListing {
+			h.fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// without listing, only directories with an index.html
+		// are served, the same way as by the file server
+		if ifi, err := h.stat(path.Join(name, "index.html")); err == nil && !ifi.IsDir() {
+			h.fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		h.serveNotFound(w, r)
+	default:
+		// keep the redirect behavior of the file server for
+		// the paths ending with /index.html
+		if strings.HasSuffix(r.URL.Path, "/index.html") {
+			h.fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		h.serveFile(w, r, name)
+	}
+}
+
+func loadMemFS(root string) (*memFS, error) {
+	if fi, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+
+	fs := newMemFS()
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + filepath.ToSlash(rel))
+		if fi.IsDir() {
+			fs.addDir(name, fi)
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		fs.addFile(name, fi, data)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
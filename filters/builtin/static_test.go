@@ -15,6 +15,9 @@
 package builtin
 
 import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/proxy/proxytest"
@@ -24,7 +27,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestStatic(t *testing.T) {
@@ -228,3 +234,338 @@ func TestMultipleRanges(t *testing.T) {
 		t.Error("failed to receive all ranges")
 	}
 }
+
+func createStaticTestDir(t *testing.T) string {
+	d, err := ioutil.TempDir("", "static-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(d, "assets", "sub"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		"index.html":          "index",
+		"assets/app.js":       "app",
+		"assets/app.js.gz":    encodeGzip(t, "app"),
+		"assets/app.js.br":    encodeBrotli(t, "app"),
+		"assets/style.css":    "style",
+		"assets/style.css.gz": encodeGzip(t, "style"),
+		"assets/sub/data.txt": "data",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(d, name), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return d
+}
+
+func encodeGzip(t *testing.T, s string) string {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+func encodeBrotli(t *testing.T, s string) string {
+	var b bytes.Buffer
+	w := brotli.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+func TestStaticOptions(t *testing.T) {
+	d := createStaticTestDir(t)
+	defer os.RemoveAll(d)
+
+	for _, ti := range []struct {
+		msg              string
+		options          []interface{}
+		path             string
+		requestHeader    http.Header
+		expectedStatus   int
+		expectedContent  string
+		expectListing    bool
+		expectedEncoding string
+		expectedCache    string
+		expectedType     string
+	}{{
+		msg:            "invalid option",
+		options:        []interface{}{"foo"},
+		path:           "/static/index.html",
+		expectedStatus: http.StatusNotFound,
+	}, {
+		msg:            "invalid option type",
+		options:        []interface{}{3.14},
+		path:           "/static/index.html",
+		expectedStatus: http.StatusNotFound,
+	}, {
+		msg:            "invalid cache option",
+		options:        []interface{}{"cache=*.js"},
+		path:           "/static/assets/app.js",
+		expectedStatus: http.StatusNotFound,
+	}, {
+		msg:             "found with options",
+		options:         []interface{}{"nolisting"},
+		path:            "/static/assets/app.js",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "app",
+	}, {
+		msg:            "not found without index",
+		options:        []interface{}{"nolisting"},
+		path:           "/static/some/route",
+		expectedStatus: http.StatusNotFound,
+	}, {
+		msg:             "index fallback",
+		options:         []interface{}{"index=index.html"},
+		path:            "/static/some/route",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "index",
+		expectedType:    "text/html; charset=utf-8",
+	}, {
+		msg:             "listing enabled",
+		options:         []interface{}{"index=index.html"},
+		path:            "/static/assets/",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "<a href=\"app.js\">app.js</a>\n<a href=\"app.js.br\">app.js.br</a>\n<a href=\"app.js.gz\">app.js.gz</a>\n<a href=\"style.css\">style.css</a>\n<a href=\"style.css.gz\">style.css.gz</a>\n<a href=\"sub/\">sub/</a>\n",
+		expectListing:   true,
+	}, {
+		msg:            "listing disabled",
+		options:        []interface{}{"nolisting"},
+		path:           "/static/assets/",
+		expectedStatus: http.StatusNotFound,
+	}, {
+		msg:             "listing disabled, index fallback",
+		options:         []interface{}{"nolisting", "index=index.html"},
+		path:            "/static/assets/",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "index",
+	}, {
+		msg:             "listing disabled, directory with index",
+		options:         []interface{}{"nolisting"},
+		path:            "/static/",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "index",
+	}, {
+		msg:             "precompressed, not accepted",
+		options:         []interface{}{"precompressed"},
+		path:            "/static/assets/app.js",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "app",
+	}, {
+		msg:              "precompressed, gzip",
+		options:          []interface{}{"precompressed"},
+		path:             "/static/assets/app.js",
+		requestHeader:    http.Header{"Accept-Encoding": []string{"gzip"}},
+		expectedStatus:   http.StatusOK,
+		expectedContent:  encodeGzip(t, "app"),
+		expectedEncoding: "gzip",
+	}, {
+		msg:              "precompressed, brotli preferred",
+		options:          []interface{}{"precompressed"},
+		path:             "/static/assets/app.js",
+		requestHeader:    http.Header{"Accept-Encoding": []string{"gzip;q=0.5, br"}},
+		expectedStatus:   http.StatusOK,
+		expectedContent:  encodeBrotli(t, "app"),
+		expectedEncoding: "br",
+	}, {
+		msg:              "precompressed, brotli missing",
+		options:          []interface{}{"precompressed"},
+		path:             "/static/assets/style.css",
+		requestHeader:    http.Header{"Accept-Encoding": []string{"br, gzip"}},
+		expectedStatus:   http.StatusOK,
+		expectedContent:  encodeGzip(t, "style"),
+		expectedEncoding: "gzip",
+		expectedType:     "text/css; charset=utf-8",
+	}, {
+		msg:             "precompressed, none available",
+		options:         []interface{}{"precompressed"},
+		path:            "/static/assets/sub/data.txt",
+		requestHeader:   http.Header{"Accept-Encoding": []string{"br, gzip"}},
+		expectedStatus:  http.StatusOK,
+		expectedContent: "data",
+	}, {
+		msg:             "cache by name",
+		options:         []interface{}{"cache=*.js:max-age=31536000", "cache=*:no-cache"},
+		path:            "/static/assets/app.js",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "app",
+		expectedCache:   "max-age=31536000",
+	}, {
+		msg:             "cache, first match wins",
+		options:         []interface{}{"cache=*.js:max-age=31536000", "cache=*:no-cache"},
+		path:            "/static/assets/style.css",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "style",
+		expectedCache:   "no-cache",
+	}, {
+		msg:             "cache by path",
+		options:         []interface{}{"cache=/assets/sub/*:max-age=60"},
+		path:            "/static/assets/sub/data.txt",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "data",
+		expectedCache:   "max-age=60",
+	}, {
+		msg:             "cache, no match",
+		options:         []interface{}{"cache=/assets/sub/*:max-age=60"},
+		path:            "/static/assets/app.js",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "app",
+	}, {
+		msg:             "memory",
+		options:         []interface{}{"memory"},
+		path:            "/static/assets/sub/data.txt",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "data",
+	}, {
+		msg:             "memory, listing",
+		options:         []interface{}{"memory"},
+		path:            "/static/assets/sub/",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "<a href=\"data.txt\">data.txt</a>\n",
+		expectListing:   true,
+	}, {
+		msg:             "memory, index fallback",
+		options:         []interface{}{"memory", "index=index.html"},
+		path:            "/static/some/route",
+		expectedStatus:  http.StatusOK,
+		expectedContent: "index",
+	}, {
+		msg:              "memory, precompressed",
+		options:          []interface{}{"memory", "precompressed"},
+		path:             "/static/assets/app.js",
+		requestHeader:    http.Header{"Accept-Encoding": []string{"gzip"}},
+		expectedStatus:   http.StatusOK,
+		expectedContent:  encodeGzip(t, "app"),
+		expectedEncoding: "gzip",
+	}} {
+		fr := make(filters.Registry)
+		fr.Register(NewStatic())
+		pr := proxytest.New(fr, &eskip.Route{
+			Filters: []*eskip.Filter{{Name: StaticName, Args: append([]interface{}{"/static", d}, ti.options...)}},
+			Shunt:   true})
+		defer pr.Close()
+
+		req, err := http.NewRequest("GET", pr.URL+ti.path, nil)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		for k, v := range ti.requestHeader {
+			req.Header[k] = v
+		}
+
+		// prevent transparent decompression:
+		if req.Header.Get("Accept-Encoding") == "" {
+			req.Header.Set("Accept-Encoding", "identity")
+		}
+
+		rsp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != ti.expectedStatus {
+			t.Error(ti.msg, "status code doesn't match", rsp.StatusCode, ti.expectedStatus)
+			continue
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if ti.expectListing && !strings.Contains(string(content), ti.expectedContent) ||
+			!ti.expectListing && string(content) != ti.expectedContent {
+			t.Error(ti.msg, "content doesn't match", string(content), ti.expectedContent)
+		}
+
+		if enc := rsp.Header.Get("Content-Encoding"); enc != ti.expectedEncoding {
+			t.Error(ti.msg, "content encoding doesn't match", enc, ti.expectedEncoding)
+		}
+
+		if cc := rsp.Header.Get("Cache-Control"); cc != ti.expectedCache {
+			t.Error(ti.msg, "cache control doesn't match", cc, ti.expectedCache)
+		}
+
+		if ti.expectedType != "" && rsp.Header.Get("Content-Type") != ti.expectedType {
+			t.Error(ti.msg, "content type doesn't match", rsp.Header.Get("Content-Type"), ti.expectedType)
+		}
+	}
+}
+
+func TestStaticRangeAndConditional(t *testing.T) {
+	d := createStaticTestDir(t)
+	defer os.RemoveAll(d)
+
+	for _, options := range [][]interface{}{nil, {"memory"}, {"precompressed", "nolisting"}} {
+		fr := make(filters.Registry)
+		fr.Register(NewStatic())
+		pr := proxytest.New(fr, &eskip.Route{
+			Filters: []*eskip.Filter{{Name: StaticName, Args: append([]interface{}{"/static", d}, options...)}},
+			Shunt:   true})
+		defer pr.Close()
+
+		req, err := http.NewRequest("GET", pr.URL+"/static/assets/style.css", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Range", "bytes=1-3")
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != http.StatusPartialContent || string(content) != "tyl" {
+			t.Error(options, "failed to serve range", rsp.StatusCode, string(content))
+		}
+
+		req, err = http.NewRequest("GET", pr.URL+"/static/assets/style.css", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		rsp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusNotModified {
+			t.Error(options, "failed to serve conditional request", rsp.StatusCode)
+		}
+	}
+}
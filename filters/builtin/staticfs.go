@@ -0,0 +1,125 @@
+package builtin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// in-memory implementation of http.FileSystem, used by the static
+// filter in memory mode
+type memFS struct {
+	entries map[string]*memEntry
+}
+
+type memEntry struct {
+	info     os.FileInfo
+	data     []byte
+	children []os.FileInfo
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+type memFile struct {
+	*bytes.Reader
+	entry   *memEntry
+	dirRead int
+}
+
+type fileInfos []os.FileInfo
+
+func (fi fileInfos) Len() int           { return len(fi) }
+func (fi fileInfos) Less(i, j int) bool { return fi[i].Name() < fi[j].Name() }
+func (fi fileInfos) Swap(i, j int)      { fi[i], fi[j] = fi[j], fi[i] }
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+func newMemFS() *memFS {
+	return &memFS{entries: make(map[string]*memEntry)}
+}
+
+func copyFileInfo(name string, fi os.FileInfo, size int64) os.FileInfo {
+	return &memFileInfo{
+		name:    name,
+		size:    size,
+		mode:    fi.Mode(),
+		modTime: fi.ModTime()}
+}
+
+func (fs *memFS) addChild(name string, fi os.FileInfo) {
+	if name == "/" {
+		return
+	}
+
+	if parent, ok := fs.entries[path.Dir(name)]; ok {
+		parent.children = append(parent.children, fi)
+		sort.Sort(fileInfos(parent.children))
+	}
+}
+
+// expects that the parent directory was already added
+func (fs *memFS) addDir(name string, fi os.FileInfo) {
+	e := &memEntry{info: copyFileInfo(path.Base(name), fi, 0)}
+	fs.entries[name] = e
+	fs.addChild(name, e.info)
+}
+
+// expects that the parent directory was already added
+func (fs *memFS) addFile(name string, fi os.FileInfo, data []byte) {
+	e := &memEntry{
+		info: copyFileInfo(path.Base(name), fi, int64(len(data))),
+		data: data}
+	fs.entries[name] = e
+	fs.addChild(name, e.info)
+}
+
+// implements http.FileSystem
+func (fs *memFS) Open(name string) (http.File, error) {
+	e, ok := fs.entries[path.Clean("/"+name)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return &memFile{Reader: bytes.NewReader(e.data), entry: e}, nil
+}
+
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Stat() (os.FileInfo, error) { return f.entry.info, nil }
+
+// implements http.File.Readdir, with the same semantics as os.File.Readdir
+func (f *memFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.entry.info.IsDir() {
+		return nil, os.ErrInvalid
+	}
+
+	rest := f.entry.children[f.dirRead:]
+	if count <= 0 {
+		f.dirRead += len(rest)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(rest) {
+		count = len(rest)
+	}
+
+	f.dirRead += count
+	return rest[:count], nil
+}
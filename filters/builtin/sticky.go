@@ -0,0 +1,43 @@
+package builtin
+
+import (
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/loadbalancer"
+)
+
+type stickyHeader string
+
+// Returns a filter specification whose instances select the endpoint
+// of routes with multiple backend endpoints by consistent hashing of
+// the value of the request header passed in as the argument, e.g. a
+// session id. Requests with the same header value are forwarded to the
+// same endpoint as long as it is healthy. Requests without the header
+// are forwarded to a random endpoint.
+//
+// 	* -> stickyHeader("X-Session-Id") -> "http://10.2.0.1:8080,http://10.2.0.2:8080"
+//
+// Name: "stickyHeader".
+func NewStickyHeader() filters.Spec { return stickyHeader("") }
+
+func (s stickyHeader) Name() string { return StickyHeaderName }
+
+func (s stickyHeader) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return stickyHeader(name), nil
+}
+
+func (s stickyHeader) Request(ctx filters.FilterContext) {
+	if v := ctx.Request().Header.Get(string(s)); v != "" {
+		ctx.StateBag()[loadbalancer.HashKey] = v
+	}
+}
+
+func (s stickyHeader) Response(filters.FilterContext) {}
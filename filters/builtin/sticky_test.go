@@ -0,0 +1,36 @@
+package builtin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/loadbalancer"
+)
+
+func TestStickyHeader(t *testing.T) {
+	if _, err := NewStickyHeader().CreateFilter(nil); err == nil {
+		t.Error("failed to fail on missing header name")
+	}
+
+	f, err := NewStickyHeader().CreateFilter([]interface{}{"X-Session-Id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{
+		FRequest:  &http.Request{Header: http.Header{"X-Session-Id": []string{"abc"}}},
+		FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if ctx.FStateBag[loadbalancer.HashKey] != "abc" {
+		t.Error("failed to set the hash key")
+	}
+
+	ctx = &filtertest.Context{
+		FRequest:  &http.Request{Header: make(http.Header)},
+		FStateBag: make(map[string]interface{})}
+	f.Request(ctx)
+	if _, ok := ctx.FStateBag[loadbalancer.HashKey]; ok {
+		t.Error("unexpected hash key")
+	}
+}
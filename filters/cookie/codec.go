@@ -0,0 +1,293 @@
+package cookie
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	SignArg    = "sign"
+	EncryptArg = "encrypt"
+
+	keyFileCheckInterval = time.Second
+)
+
+var (
+	ErrInvalidCookie = errors.New("invalid cookie value")
+	errEmptyKeyFile  = errors.New("no keys found")
+	errInvalidCodec  = errors.New("invalid cookie codec")
+)
+
+// keyFile holds the keys loaded from a file, one per line. The file is
+// reloaded when it changes, checked by the requests after the check
+// interval has passed.
+type keyFile struct {
+	path    string
+	mx      sync.Mutex
+	keys    [][]byte
+	modTime time.Time
+	checked time.Time
+}
+
+type codecMode int
+
+const (
+	signCookie codecMode = iota
+	encryptCookie
+)
+
+// Codec signs or encrypts the cookie values, using the keys loaded from a
+// file. The file contains one key per line, empty lines and lines starting
+// with # are ignored. The first key is used to sign or encrypt the values,
+// while all the keys are accepted when verifying or decrypting them, so
+// that the keys can be rotated. The file is reloaded when it changes.
+//
+// The signed values have the format of <value>.<signature>, where both
+// parts are base64 URL encoded, and the signature is the HMAC-SHA256 of
+// the cookie name and the value. The encrypted values are encrypted with
+// AES-256-GCM, with the SHA-256 hash of the key, and the cookie name as
+// additional data.
+type Codec struct {
+	mode codecMode
+	keys *keyFile
+}
+
+// the key files are shared between the filters and the predicates
+var (
+	keyFilesMx sync.Mutex
+	keyFiles   = make(map[string]*keyFile)
+)
+
+func parseKeys(b []byte) ([][]byte, error) {
+	var keys [][]byte
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keys = append(keys, []byte(line))
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errEmptyKeyFile
+	}
+
+	return keys, nil
+}
+
+func (f *keyFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseKeys(b)
+	if err != nil {
+		return err
+	}
+
+	f.keys, f.modTime = keys, info.ModTime()
+	return nil
+}
+
+// returns the current keys, reloading the file when it has changed
+func (f *keyFile) current() [][]byte {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.checked) >= keyFileCheckInterval {
+		f.checked = now
+		if info, err := os.Stat(f.path); err != nil {
+			log.Errorf("cookie: failed to check %s: %v", f.path, err)
+		} else if !info.ModTime().Equal(f.modTime) {
+			if err := f.load(); err != nil {
+				log.Errorf("cookie: failed to reload %s: %v", f.path, err)
+			}
+		}
+	}
+
+	return f.keys
+}
+
+func loadKeyFile(path string) (*keyFile, error) {
+	keyFilesMx.Lock()
+	defer keyFilesMx.Unlock()
+
+	if f, ok := keyFiles[path]; ok {
+		return f, nil
+	}
+
+	f := &keyFile{path: path, checked: time.Now()}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	keyFiles[path] = f
+	return f, nil
+}
+
+// NewSigningCodec creates a codec that signs the cookie values with the
+// keys from a file.
+func NewSigningCodec(path string) (*Codec, error) {
+	keys, err := loadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Codec{mode: signCookie, keys: keys}, nil
+}
+
+// NewEncryptingCodec creates a codec that encrypts the cookie values with
+// the keys from a file.
+func NewEncryptingCodec(path string) (*Codec, error) {
+	keys, err := loadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Codec{mode: encryptCookie, keys: keys}, nil
+}
+
+// ParseCodec creates a codec from a filter or predicate argument in the
+// format of sign=<key file> or encrypt=<key file>.
+func ParseCodec(arg string) (*Codec, error) {
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, errInvalidCodec
+	}
+
+	switch kv[0] {
+	case SignArg:
+		return NewSigningCodec(kv[1])
+	case EncryptArg:
+		return NewEncryptingCodec(kv[1])
+	default:
+		return nil, errInvalidCodec
+	}
+}
+
+func signature(key []byte, name, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'='})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	k := sha256.Sum256(key)
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Encode signs or encrypts a cookie value.
+func (c *Codec) Encode(name, value string) (string, error) {
+	key := c.keys.current()[0]
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(value))
+	if c.mode == signCookie {
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(signature(key, name, encoded)), nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), []byte(name))), nil
+}
+
+// Decode verifies or decrypts a cookie value. It returns ErrInvalidCookie
+// when the value was not signed or encrypted with any of the keys, or it
+// was tampered with.
+func (c *Codec) Decode(name, value string) (string, error) {
+	if c.mode == signCookie {
+		return c.verify(name, value)
+	}
+
+	return c.decrypt(name, value)
+}
+
+func (c *Codec) verify(name, value string) (string, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return "", ErrInvalidCookie
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range c.keys.current() {
+		if hmac.Equal(sig, signature(key, name, parts[0])) {
+			decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+			if err != nil {
+				return "", ErrInvalidCookie
+			}
+
+			return string(decoded), nil
+		}
+	}
+
+	return "", ErrInvalidCookie
+}
+
+func (c *Codec) decrypt(name, value string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range c.keys.current() {
+		gcm, err := newGCM(key)
+		if err != nil {
+			return "", err
+		}
+
+		if len(b) < gcm.NonceSize() {
+			return "", ErrInvalidCookie
+		}
+
+		plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], []byte(name))
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+
+	return "", ErrInvalidCookie
+}
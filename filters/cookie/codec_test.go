@@ -0,0 +1,153 @@
+package cookie
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "cookie-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestParseCodec(t *testing.T) {
+	keys := writeKeys(t, "key\n")
+	defer os.Remove(keys)
+
+	empty := writeKeys(t, "# no keys\n")
+	defer os.Remove(empty)
+
+	for _, ti := range []struct {
+		arg string
+		err bool
+	}{
+		{"sign=" + keys, false},
+		{"encrypt=" + keys, false},
+		{"sign=", true},
+		{"sign", true},
+		{"hash=" + keys, true},
+		{"sign=/no/such/file", true},
+		{"encrypt=" + empty, true},
+	} {
+		if _, err := ParseCodec(ti.arg); ti.err && err == nil {
+			t.Error(ti.arg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.arg, err)
+		}
+	}
+}
+
+func TestCodec(t *testing.T) {
+	keys := writeKeys(t, "# rotated keys\nnew-key\nold-key\n")
+	defer os.Remove(keys)
+
+	oldKeys := writeKeys(t, "old-key\n")
+	defer os.Remove(oldKeys)
+
+	otherKeys := writeKeys(t, "other-key\n")
+	defer os.Remove(otherKeys)
+
+	for _, mode := range []string{SignArg, EncryptArg} {
+		codec, err := ParseCodec(mode + "=" + keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const value = "user=jdoe; role=admin"
+		encoded, err := codec.Encode("session", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.ContainsAny(encoded, " ;,\\\"") {
+			t.Error(mode, "invalid cookie value", encoded)
+		}
+
+		if mode == EncryptArg && strings.Contains(encoded, "jdoe") {
+			t.Error(mode, "value not encrypted", encoded)
+		}
+
+		if decoded, err := codec.Decode("session", encoded); err != nil || decoded != value {
+			t.Error(mode, "failed to decode", decoded, err)
+		}
+
+		if _, err := codec.Decode("other-session", encoded); err != ErrInvalidCookie {
+			t.Error(mode, "accepted the value of another cookie")
+		}
+
+		tampered := []byte(encoded)
+		tampered[len(tampered)/2] ^= 1
+		if _, err := codec.Decode("session", string(tampered)); err != ErrInvalidCookie {
+			t.Error(mode, "accepted a tampered value")
+		}
+
+		oldCodec, err := ParseCodec(mode + "=" + oldKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		old, err := oldCodec.Encode("session", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded, err := codec.Decode("session", old); err != nil || decoded != value {
+			t.Error(mode, "failed to decode with the old key", decoded, err)
+		}
+
+		otherCodec, err := ParseCodec(mode + "=" + otherKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := otherCodec.Decode("session", encoded); err != ErrInvalidCookie {
+			t.Error(mode, "accepted a value with an unknown key")
+		}
+	}
+}
+
+func TestKeyFileReload(t *testing.T) {
+	keys := writeKeys(t, "old-key\n")
+	defer os.Remove(keys)
+
+	codec, err := NewSigningCodec(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(keys, []byte("new-key\nold-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(keys, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	// skip waiting for the check interval
+	codec.keys.mx.Lock()
+	codec.keys.checked = time.Time{}
+	codec.keys.mx.Unlock()
+
+	encoded, err := codec.Encode("session", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCodec := &Codec{mode: signCookie, keys: &keyFile{keys: [][]byte{[]byte("new-key")}, checked: time.Now()}}
+	if _, err := newCodec.Decode("session", encoded); err != nil {
+		t.Error("failed to sign with the new key after reload")
+	}
+}
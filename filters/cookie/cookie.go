@@ -3,7 +3,8 @@ Package cookie implements filters to append to requests or responses.
 
 It implements two filters, one for appending cookies to requests in
 the "Cookie" header, and one for appending cookies to responses in the
-"Set-Cookie" header.
+"Set-Cookie" header, and a filter to verify the signed or encrypted
+request cookies.
 
 Both the request and response cookies expect a name and a value argument.
 
@@ -21,6 +22,43 @@ The JS cookie behaves exactly as the response cookie, but it doesn't
 set the HttpOnly directive, so these cookies will be
 accessible from JS code running in web browsers.
 
+The response and JS cookies accept further string arguments to control
+the attributes of the cookie:
+
+    samesite=lax|strict|none: sets the SameSite attribute, not set by default
+    secure=true|false: sets the Secure attribute, true by default
+    httponly=true|false: sets the HttpOnly attribute, true by default for the response cookie
+    domain=<domain>: sets the Domain attribute, when empty, the cookie is set for the host only
+
+By default, the domain is the host of the request without the first
+label, when the host has at least three labels.
+
+All three filters accept an argument to sign or encrypt the cookie value
+with the keys from a file: sign=<key file> or encrypt=<key file>. The
+file contains one key per line. The first key is used to sign or encrypt
+the values, while all the keys are accepted when verifying or decrypting
+them, so that the keys can be rotated by adding a new key as the first
+line. The file is reloaded when it changes. The values are signed with
+HMAC-SHA256, or encrypted with AES-256-GCM, and the cookie name is part
+of the signed or authenticated data, so the values of one cookie cannot
+be used for another one.
+
+The verifyCookie filter verifies or decrypts a signed or encrypted
+request cookie, and stores the value in the state bag, with the key
+CookieValue:<name>, for the subsequent filters. The requests with a
+tampered value are rejected with 401 Unauthorized. When the third
+argument is "required", the requests without the cookie are rejected,
+too. The VerifiedCookie predicate can be used to match the requests
+with a valid cookie.
+
+The stickyCookie filter implements sticky sessions for routes whose
+backend consists of multiple endpoints. It expects the cookie name, an
+optional max-age, and the same attributes and signing options as the
+response cookie. When the request contains the cookie, the endpoint
+stored in it is used, as long as it is one of the endpoints of the route
+and healthy. The cookie is set to the opaque id of the selected endpoint
+when it is missing, or when the selected endpoint changed.
+
 Examples:
 
     requestCookie("test-session", "abc")
@@ -31,6 +69,16 @@ Examples:
 
     // response cookie without HttpOnly:
     jsCookie("test-session-info", "abc-debug", 31536000, "change-only")
+
+    // strict, host only cookie:
+    responseCookie("test-session", "abc", 31536000, "samesite=strict", "domain=")
+
+    // encrypted cookie, and its verification:
+    responseCookie("test-session", "abc", 31536000, "encrypt=/etc/skipper/cookie-keys")
+    verifyCookie("test-session", "encrypt=/etc/skipper/cookie-keys", "required")
+
+    // sticky sessions:
+    stickyCookie("backend", 86400, "samesite=lax")
 */
 package cookie
 
@@ -40,6 +88,7 @@ import (
 	"strings"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
 )
 
@@ -47,8 +96,20 @@ const (
 	RequestCookieFilterName    = "requestCookie"
 	ResponseCookieFilterName   = "responseCookie"
 	ResponseJSCookieFilterName = "jsCookie"
+	VerifyCookieFilterName     = "verifyCookie"
 	ChangeOnlyArg              = "change-only"
+	RequiredArg                = "required"
 	SetCookieHttpHeader        = "Set-Cookie"
+
+	SameSiteArg = "samesite"
+	SecureArg   = "secure"
+	HttpOnlyArg = "httponly"
+	DomainArg   = "domain"
+
+	// The prefix of the state bag keys, where the verifyCookie filter
+	// stores the verified or decrypted cookie values, followed by the
+	// cookie name.
+	CookieValueStateBagPrefix = "CookieValue:"
 )
 
 type direction int
@@ -65,11 +126,25 @@ type spec struct {
 }
 
 type filter struct {
-	typ        direction
-	name       string
-	value      string
-	ttl        time.Duration
-	changeOnly bool
+	typ         direction
+	name        string
+	value       string
+	ttl         time.Duration
+	changeOnly  bool
+	codec       *Codec
+	sameSite    string
+	secure      bool
+	httpOnly    bool
+	domain      string
+	fixedDomain bool
+}
+
+type verifySpec struct{}
+
+type verifyFilter struct {
+	name     string
+	codec    *Codec
+	required bool
 }
 
 // Creates a filter spec for appending cookies to requests.
@@ -91,14 +166,81 @@ func NewJSCookie() filters.Spec {
 	return &spec{responseJS, ResponseJSCookieFilterName}
 }
 
+// Creates a filter spec for verifying or decrypting the request cookies
+// signed or encrypted by the cookie filters.
+// Name: verifyCookie
+func NewVerifyCookie() filters.Spec {
+	return &verifySpec{}
+}
+
 func (s *spec) Name() string { return s.filterName }
 
+func parseBool(s string) (bool, bool) {
+	switch s {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parses an option in the format of key=value, returns false when it is
+// not a known option, or its value is invalid
+func (f *filter) setOption(arg string) (bool, error) {
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 {
+		return false, nil
+	}
+
+	var ok bool
+	switch kv[0] {
+	case SignArg, EncryptArg:
+		if f.codec != nil {
+			return false, nil
+		}
+
+		codec, err := ParseCodec(arg)
+		if err != nil {
+			return false, err
+		}
+
+		f.codec, ok = codec, true
+	case SameSiteArg, SecureArg, HttpOnlyArg, DomainArg:
+		// the cookie attributes apply only to the response cookies
+		if f.typ == request {
+			return false, nil
+		}
+
+		switch kv[0] {
+		case SameSiteArg:
+			switch strings.ToLower(kv[1]) {
+			case "lax":
+				f.sameSite, ok = "Lax", true
+			case "strict":
+				f.sameSite, ok = "Strict", true
+			case "none":
+				f.sameSite, ok = "None", true
+			}
+		case SecureArg:
+			f.secure, ok = parseBool(kv[1])
+		case HttpOnlyArg:
+			f.httpOnly, ok = parseBool(kv[1])
+		case DomainArg:
+			f.domain, f.fixedDomain, ok = kv[1], true, true
+		}
+	}
+
+	return ok, nil
+}
+
 func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
-	if len(args) < 2 || (len(args) > 2 && s.typ == request) || len(args) > 4 {
+	if len(args) < 2 {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
-	f := &filter{typ: s.typ}
+	f := &filter{typ: s.typ, secure: true, httpOnly: s.typ == response}
 
 	if name, ok := args[0].(string); ok && name != "" {
 		f.name = name
@@ -112,21 +254,52 @@ func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
 		return nil, filters.ErrInvalidFilterParameters
 	}
 
-	if len(args) >= 3 {
-		if ttl, ok := args[2].(float64); ok {
+	var hasTTL bool
+	for i, a := range args[2:] {
+		if ttl, ok := a.(float64); ok && i == 0 && s.typ != request {
 			f.ttl = time.Duration(ttl) * time.Second
-		} else {
+			hasTTL = true
+			continue
+		}
+
+		arg, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if arg == ChangeOnlyArg && s.typ != request {
+			f.changeOnly = true
+			continue
+		}
+
+		known, err := f.setOption(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		// for backwards compatibility, any other value is accepted in
+		// the place of the change-only argument
+		if !known && !(i == 1 && hasTTL) {
 			return nil, filters.ErrInvalidFilterParameters
 		}
 	}
 
-	if len(args) == 4 {
-		f.changeOnly = args[3] == ChangeOnlyArg
+	// browsers reject the SameSite=None cookies without Secure
+	if f.sameSite == "None" && !f.secure {
+		return nil, filters.ErrInvalidFilterParameters
 	}
 
 	return f, nil
 }
 
+func (f *filter) encodedValue() (string, error) {
+	if f.codec == nil {
+		return f.value, nil
+	}
+
+	return f.codec.Encode(f.name, f.value)
+}
+
 func (f *filter) Request(ctx filters.FilterContext) {
 	if f.typ != request {
 		return
@@ -134,26 +307,24 @@ func (f *filter) Request(ctx filters.FilterContext) {
 
 	ctx.StateBag()["CookieSet:"+f.name] = f.value
 
-	ctx.Request().AddCookie(&http.Cookie{Name: f.name, Value: f.value})
+	value, err := f.encodedValue()
+	if err != nil {
+		log.Errorf("cookie: failed to encode %s: %v", f.name, err)
+		return
+	}
+
+	ctx.Request().AddCookie(&http.Cookie{Name: f.name, Value: value})
 }
 
 func (f *filter) Response(ctx filters.FilterContext) {
-	var set func(filters.FilterContext, string, string, time.Duration)
-	switch f.typ {
-	case request:
+	if f.typ == request {
 		return
-	case response:
-		set = configSetCookie(false)
-	case responseJS:
-		set = configSetCookie(true)
-	default:
-		panic("invalid cookie filter type")
 	}
 
 	ctx.StateBag()["CookieSet:"+f.name] = f.value
 
 	if !f.changeOnly {
-		set(ctx, f.name, f.value, f.ttl)
+		f.setCookie(ctx)
 		return
 	}
 
@@ -163,45 +334,123 @@ func (f *filter) Response(ctx filters.FilterContext) {
 	}
 
 	requestCookie, err := req.Cookie(f.name)
-	if err == nil && requestCookie.Value == f.value {
-		return
+	if err == nil {
+		value := requestCookie.Value
+		if f.codec != nil {
+			value, err = f.codec.Decode(f.name, value)
+		}
+
+		if err == nil && value == f.value {
+			return
+		}
 	}
 
-	set(ctx, f.name, f.value, f.ttl)
+	f.setCookie(ctx)
 }
 
-func setCookie(ctx filters.FilterContext, name, value string, ttl time.Duration, jsEnabled bool) {
-	var req = ctx.Request()
-	if ctx.OriginalRequest() != nil {
-		req = ctx.OriginalRequest()
+func (f *filter) setCookie(ctx filters.FilterContext) {
+	value, err := f.encodedValue()
+	if err != nil {
+		log.Errorf("cookie: failed to encode %s: %v", f.name, err)
+		return
+	}
+
+	d := f.domain
+	if !f.fixedDomain {
+		var req = ctx.Request()
+		if ctx.OriginalRequest() != nil {
+			req = ctx.OriginalRequest()
+		}
+
+		d = ExtractDomainFromHost(req.Host)
 	}
-	d := extractDomainFromHost(req.Host)
+
 	c := &http.Cookie{
-		Name:     name,
+		Name:     f.name,
 		Value:    value,
-		HttpOnly: !jsEnabled,
-		Secure:   true,
+		HttpOnly: f.httpOnly,
+		Secure:   f.secure,
 		Domain:   d,
 		Path:     "/",
-		MaxAge:   int(ttl.Seconds())}
+		MaxAge:   int(f.ttl.Seconds())}
+
+	// the SameSite attribute is not supported by net/http
+	sc := c.String()
+	if f.sameSite != "" {
+		sc += "; SameSite=" + f.sameSite
+	}
+
+	ctx.Response().Header.Add(SetCookieHttpHeader, sc)
+}
+
+func (s *verifySpec) Name() string { return VerifyCookieFilterName }
+
+func (s *verifySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	arg, ok := args[1].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	codec, err := ParseCodec(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &verifyFilter{name: name, codec: codec}
+	if len(args) == 3 {
+		if args[2] != RequiredArg {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.required = true
+	}
 
-	ctx.Response().Header.Add(SetCookieHttpHeader, c.String())
+	return f, nil
 }
 
-func configSetCookie(jscookie bool) func(filters.FilterContext, string, string, time.Duration) {
-	return func(ctx filters.FilterContext, name, value string, ttl time.Duration) {
-		setCookie(ctx, name, value, ttl, jscookie)
+func (f *verifyFilter) Request(ctx filters.FilterContext) {
+	c, err := ctx.Request().Cookie(f.name)
+	if err != nil {
+		if f.required {
+			ctx.Serve(&http.Response{StatusCode: http.StatusUnauthorized})
+		}
+
+		return
 	}
+
+	value, err := f.codec.Decode(f.name, c.Value)
+	if err != nil {
+		log.Debugf("cookie: invalid value of %s: %v", f.name, err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusUnauthorized})
+		return
+	}
+
+	ctx.StateBag()[CookieValueStateBagPrefix+f.name] = value
 }
 
-func extractDomainFromHost(host string) string {
+func (f *verifyFilter) Response(filters.FilterContext) {}
+
+// ExtractDomainFromHost returns the domain set in the cookies by the
+// cookie filters: the host without the port, and without the first
+// label when the host has at least three labels. IP addresses are
+// returned unchanged.
+func ExtractDomainFromHost(host string) string {
 	h, _, err := net.SplitHostPort(host)
 
 	if err != nil {
 		h = host
 	}
 
-	if strings.Count(h, ".") < 2 {
+	if strings.Count(h, ".") < 2 || net.ParseIP(h) != nil {
 		return h
 	}
 
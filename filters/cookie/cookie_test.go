@@ -4,6 +4,7 @@ import (
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 )
@@ -382,3 +383,175 @@ func TestSetCookie(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractDomainFromHost(t *testing.T) {
+	for host, domain := range map[string]string{
+		"example.org":         "example.org",
+		"www.example.org":     "example.org",
+		"www.example.org:80":  "example.org",
+		"api.www.example.org": "www.example.org",
+		"localhost:9090":      "localhost",
+		"127.0.0.1:9090":      "127.0.0.1",
+		"[::1]:9090":          "::1",
+	} {
+		if d := ExtractDomainFromHost(host); d != domain {
+			t.Error("invalid domain", host, d, domain)
+		}
+	}
+}
+
+func TestCookieOptions(t *testing.T) {
+	keys := writeKeys(t, "key\n")
+	defer os.Remove(keys)
+
+	for _, ti := range []struct {
+		msg  string
+		spec filters.Spec
+		args []interface{}
+		err  bool
+	}{
+		{"unknown option", NewResponseCookie(), []interface{}{"test-cookie", "A", "foo=bar"}, true},
+		{"invalid samesite", NewResponseCookie(), []interface{}{"test-cookie", "A", "samesite=loose"}, true},
+		{"samesite none, not secure", NewResponseCookie(), []interface{}{"test-cookie", "A", "samesite=none", "secure=false"}, true},
+		{"invalid secure", NewResponseCookie(), []interface{}{"test-cookie", "A", "secure=yes"}, true},
+		{"attributes for request cookie", NewRequestCookie(), []interface{}{"test-cookie", "A", "samesite=lax"}, true},
+		{"sign and encrypt", NewResponseCookie(), []interface{}{"test-cookie", "A", "sign=" + keys, "encrypt=" + keys}, true},
+		{"missing key file", NewRequestCookie(), []interface{}{"test-cookie", "A", "sign=/no/such/file"}, true},
+		{"all options", NewResponseCookie(), []interface{}{"test-cookie", "A", 42.0, ChangeOnlyArg, "samesite=none", "httponly=false", "domain=", "encrypt=" + keys}, false},
+		{"options without ttl", NewJSCookie(), []interface{}{"test-cookie", "A", "samesite=strict", "domain=example.org"}, false},
+		{"signed request cookie", NewRequestCookie(), []interface{}{"test-cookie", "A", "sign=" + keys}, false},
+	} {
+		_, err := ti.spec.CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestCookieAttributes(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		spec     filters.Spec
+		args     []interface{}
+		expected string
+	}{{
+		"defaults",
+		NewResponseCookie(),
+		[]interface{}{"test-cookie", "A"},
+		"test-cookie=A; Path=/; Domain=example.org; HttpOnly; Secure",
+	}, {
+		"samesite, host only, not secure",
+		NewResponseCookie(),
+		[]interface{}{"test-cookie", "A", "samesite=lax", "domain=", "secure=false"},
+		"test-cookie=A; Path=/; HttpOnly; SameSite=Lax",
+	}, {
+		"js cookie with fixed domain and http only",
+		NewJSCookie(),
+		[]interface{}{"test-cookie", "A", 42.0, "domain=example.com", "httponly=true", "samesite=none"},
+		"test-cookie=A; Path=/; Domain=example.com; Max-Age=42; HttpOnly; Secure; SameSite=None",
+	}} {
+		f, err := ti.spec.CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{Header: http.Header{}, Host: "www.example.org"},
+			FStateBag: map[string]interface{}{},
+			FResponse: &http.Response{Header: http.Header{}}}
+		f.Response(ctx)
+
+		if sc := ctx.Response().Header.Get(SetCookieHttpHeader); sc != ti.expected {
+			t.Error(ti.msg, "invalid cookie", sc, ti.expected)
+		}
+	}
+}
+
+func TestSignedCookies(t *testing.T) {
+	keys := writeKeys(t, "key\n")
+	defer os.Remove(keys)
+
+	for _, mode := range []string{SignArg, EncryptArg} {
+		codecArg := mode + "=" + keys
+		set, err := NewResponseCookie().CreateFilter([]interface{}{"session", "jdoe", 42.0, ChangeOnlyArg, codecArg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{Header: http.Header{}, Host: "www.example.org"},
+			FStateBag: map[string]interface{}{},
+			FResponse: &http.Response{Header: http.Header{}}}
+		set.Response(ctx)
+
+		cookies := ctx.Response().Cookies()
+		if len(cookies) != 1 || cookies[0].Value == "jdoe" {
+			t.Fatal(mode, "failed to set the encoded cookie", cookies)
+		}
+
+		verify, err := NewVerifyCookie().CreateFilter([]interface{}{"session", codecArg, RequiredArg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := &http.Request{Header: http.Header{}}
+		req.AddCookie(cookies[0])
+		ctx = &filtertest.Context{FRequest: req, FStateBag: map[string]interface{}{}}
+		verify.Request(ctx)
+		if ctx.FServed || ctx.FStateBag[CookieValueStateBagPrefix+"session"] != "jdoe" {
+			t.Error(mode, "failed to verify the cookie", ctx.FStateBag)
+		}
+
+		// change-only compares the decoded values
+		ctx = &filtertest.Context{
+			FRequest:  req,
+			FStateBag: map[string]interface{}{},
+			FResponse: &http.Response{Header: http.Header{}}}
+		set.Response(ctx)
+		if len(ctx.Response().Cookies()) != 0 {
+			t.Error(mode, "unexpectedly set the unchanged cookie")
+		}
+
+		req = &http.Request{Header: http.Header{}}
+		req.AddCookie(&http.Cookie{Name: "session", Value: "x" + cookies[0].Value})
+		ctx = &filtertest.Context{FRequest: req, FStateBag: map[string]interface{}{}}
+		verify.Request(ctx)
+		if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized {
+			t.Error(mode, "failed to reject the tampered cookie")
+		}
+
+		ctx = &filtertest.Context{FRequest: &http.Request{Header: http.Header{}}, FStateBag: map[string]interface{}{}}
+		verify.Request(ctx)
+		if !ctx.FServed {
+			t.Error(mode, "failed to reject the request without the required cookie")
+		}
+	}
+}
+
+func TestVerifyCookieArgs(t *testing.T) {
+	keys := writeKeys(t, "key\n")
+	defer os.Remove(keys)
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{
+		{"missing codec", []interface{}{"session"}, true},
+		{"empty name", []interface{}{"", "sign=" + keys}, true},
+		{"invalid codec", []interface{}{"session", "hash=" + keys}, true},
+		{"invalid option", []interface{}{"session", "sign=" + keys, "optional"}, true},
+		{"optional", []interface{}{"session", "sign=" + keys}, false},
+		{"required", []interface{}{"session", "encrypt=" + keys, RequiredArg}, false},
+	} {
+		_, err := NewVerifyCookie().CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cookie
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/loadbalancer"
+)
+
+const StickyCookieFilterName = "stickyCookie"
+
+type stickySpec struct{}
+
+type stickyFilter struct {
+	cookie *filter
+}
+
+// Creates a filter spec for sticky sessions on routes with multiple
+// backend endpoints. The filter instances request the endpoint stored
+// in the cookie, and set the cookie to the opaque id of the selected
+// endpoint, when it is missing or the endpoint changed.
+// Name: stickyCookie
+func NewStickyCookie() filters.Spec {
+	return &stickySpec{}
+}
+
+func (s *stickySpec) Name() string { return StickyCookieFilterName }
+
+func (s *stickySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{typ: response, secure: true, httpOnly: true}
+	if name, ok := args[0].(string); ok && name != "" {
+		f.name = name
+	} else {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	for i, a := range args[1:] {
+		if ttl, ok := a.(float64); ok && i == 0 {
+			f.ttl = time.Duration(ttl) * time.Second
+			continue
+		}
+
+		arg, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		known, err := f.setOption(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !known {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	if f.sameSite == "None" && !f.secure {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &stickyFilter{cookie: f}, nil
+}
+
+func (f *stickyFilter) requestedEndpoint(r *http.Request) string {
+	c, err := r.Cookie(f.cookie.name)
+	if err != nil {
+		return ""
+	}
+
+	if f.cookie.codec == nil {
+		return c.Value
+	}
+
+	id, err := f.cookie.codec.Decode(f.cookie.name, c.Value)
+	if err != nil {
+		log.Debugf("cookie: invalid value of %s: %v", f.cookie.name, err)
+		return ""
+	}
+
+	return id
+}
+
+func (f *stickyFilter) Request(ctx filters.FilterContext) {
+	if id := f.requestedEndpoint(ctx.Request()); id != "" {
+		ctx.StateBag()[loadbalancer.StickyEndpointKey] = id
+	}
+}
+
+func (f *stickyFilter) Response(ctx filters.FilterContext) {
+	// not set when the route has a single endpoint, or the request was
+	// not forwarded to the backend
+	selected, ok := ctx.StateBag()[loadbalancer.SelectedEndpointKey].(string)
+	if !ok {
+		return
+	}
+
+	if requested, _ := ctx.StateBag()[loadbalancer.StickyEndpointKey].(string); requested == selected {
+		return
+	}
+
+	c := *f.cookie
+	c.value = selected
+	c.setCookie(ctx)
+}
@@ -0,0 +1,115 @@
+package cookie
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/loadbalancer"
+)
+
+func TestStickyCookieArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"name only",
+		[]interface{}{"sticky"},
+		false,
+	}, {
+		"ttl and options",
+		[]interface{}{"sticky", 3600.0, "samesite=lax", "domain="},
+		false,
+	}, {
+		"invalid option",
+		[]interface{}{"sticky", "foo=bar"},
+		true,
+	}, {
+		"SameSite=None without Secure",
+		[]interface{}{"sticky", "samesite=none", "secure=false"},
+		true,
+	}} {
+		_, err := NewStickyCookie().CreateFilter(ti.args)
+		if (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestStickyCookie(t *testing.T) {
+	for _, ti := range []struct {
+		msg       string
+		cookie    string
+		selected  string
+		requested string
+		setCookie string
+	}{{
+		"no cookie",
+		"",
+		"abc",
+		"",
+		"sticky=abc",
+	}, {
+		"same endpoint",
+		"abc",
+		"abc",
+		"abc",
+		"",
+	}, {
+		"changed endpoint",
+		"abc",
+		"def",
+		"abc",
+		"sticky=def",
+	}, {
+		"single endpoint",
+		"",
+		"",
+		"",
+		"",
+	}} {
+		f, err := NewStickyCookie().CreateFilter([]interface{}{"sticky"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("GET", "http://www.example.org", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ti.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "sticky", Value: ti.cookie})
+		}
+
+		ctx := &filtertest.Context{
+			FRequest:  req,
+			FResponse: &http.Response{Header: make(http.Header)},
+			FStateBag: make(map[string]interface{})}
+
+		f.Request(ctx)
+		if requested, _ := ctx.FStateBag[loadbalancer.StickyEndpointKey].(string); requested != ti.requested {
+			t.Error(ti.msg, "invalid requested endpoint", requested)
+		}
+
+		if ti.selected != "" {
+			ctx.FStateBag[loadbalancer.SelectedEndpointKey] = ti.selected
+		}
+
+		f.Response(ctx)
+		setCookie := ctx.FResponse.Header.Get(SetCookieHttpHeader)
+		if ti.setCookie == "" && setCookie != "" || !strings.HasPrefix(setCookie, ti.setCookie) {
+			t.Error(ti.msg, "invalid cookie", setCookie)
+		}
+
+		if setCookie != "" && (!strings.Contains(setCookie, "HttpOnly") || !strings.Contains(setCookie, "Secure")) {
+			t.Error(ti.msg, "invalid cookie attributes", setCookie)
+		}
+	}
+}
@@ -43,6 +43,13 @@ Each filter in a route is called twice, once for the request in the order of
 their position in the route definition, and once for the response in reverse
 order.
 
+When tracing is enabled, the proxy creates a span for every filter call.
+Filters can access it with tracing.SpanFromContext, and create their own
+spans as its children:
+
+	span := tracing.SpanFromContext(ctx).StartChild("fetch_token")
+	defer span.Finish()
+
 
 Handling Requests with Filters
 
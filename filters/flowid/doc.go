@@ -72,6 +72,27 @@ Reuse existing flow id
 With a single string parameter with the value "reuse", the filter will accept an existing X-Flow-Id header, if
 it's present in the request. If it's invalid, a new one is generated and the header is overwritten.
 
+W3C Trace Context
+
+	flowId("reuse", "tracecontext")
+
+With the "tracecontext" parameter, the filter also sets the traceparent header defined by the W3C Trace Context
+specification (https://www.w3.org/TR/trace-context/). When "reuse" is set, and the request contains a valid
+traceparent header, the trace is continued: the trace id and the flags are kept, and the parent id is replaced with a
+new span id, identifying the span of skipper. The tracestate header is propagated unchanged. In any other case, a new
+trace is started with a random trace id, and the tracestate header is removed.
+
+	flowId("reuse", "flowid=traceid")
+
+With the "flowid=traceid" parameter, the trace context is enabled, and the trace id is used as the flow id, so that
+the services using either of the headers can be correlated. When the request contains no valid traceparent header,
+but an X-Flow-Id header that is a valid trace id, it is used as the trace id of the new trace. When the reused
+X-Flow-Id header is valid, but it is not a trace id, it is kept, and the new trace gets a random trace id.
+
+Access Log
+
+The flow id of the request is appended to the access log entry of the request.
+
 Some Benchmarks
 
 Built-In Flow ID Generator
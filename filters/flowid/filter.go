@@ -3,6 +3,7 @@ package flowid
 import (
 	"fmt"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/logging"
 	"log"
 	"strings"
 )
@@ -29,6 +30,8 @@ type flowIdSpec struct {
 
 type flowId struct {
 	reuseExisting bool
+	traceContext  bool
+	traceIDFlowID bool
 	generator     Generator
 }
 
@@ -61,24 +64,34 @@ func NewWithGenerator(g Generator) *flowIdSpec {
 
 // Request will inspect the current Request for the presence of an X-Flow-Id header which will be kept in case the
 // "reuse" flag has been set. In any other case it will set the same header with the value returned from the
-// defined Flow ID Generator
+// defined Flow ID Generator. When the trace context is enabled, it also sets the W3C traceparent header. The flow
+// id is passed on to the access log.
 func (f *flowId) Request(fc filters.FilterContext) {
 	r := fc.Request()
 	var flowId string
 
 	if f.reuseExisting {
 		flowId = r.Header.Get(HeaderName)
-		if f.generator.IsValid(flowId) {
-			return
+		if !f.generator.IsValid(flowId) {
+			flowId = ""
 		}
 	}
 
-	flowId, err := f.generator.Generate()
-	if err == nil {
-		r.Header.Set(HeaderName, flowId)
-	} else {
-		log.Println(err)
+	if f.traceContext {
+		flowId = f.setTraceContext(r, flowId)
+	}
+
+	if flowId == "" {
+		var err error
+		flowId, err = f.generator.Generate()
+		if err != nil {
+			log.Println(err)
+			return
+		}
 	}
+
+	r.Header.Set(HeaderName, flowId)
+	logging.SetFlowId(r, flowId)
 }
 
 // Response is No-Op in this filter
@@ -86,21 +99,41 @@ func (_ *flowId) Response(filters.FilterContext) {}
 
 // CreateFilter will return a new flowId filter from the spec
 // If at least 1 argument is present and it contains the value "reuse", the filter instance is configured to accept
-// keep the value of the X-Flow-Id header, if it's already set
+// keep the value of the X-Flow-Id header, if it's already set. The "tracecontext" argument enables the W3C Trace
+// Context headers, while "flowid=traceid" additionally uses the trace id as the flow id.
 func (spec *flowIdSpec) CreateFilter(fc []interface{}) (filters.Filter, error) {
-	var reuseExisting bool
-	if len(fc) > 0 {
-		if r, ok := fc[0].(string); ok {
-			reuseExisting = strings.ToLower(r) == ReuseParameterValue
-		} else {
-			return nil, filters.ErrInvalidFilterParameters
+	f := &flowId{generator: spec.generator}
+	var deprecated bool
+	for i, a := range fc {
+		s, ok := a.(string)
+		if !ok {
+			if i == 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			deprecated = true
+			continue
 		}
-		if len(fc) > 1 {
-			log.Println("flow id filter warning: this syntaxt is deprecated and will be removed soon. " +
-				"please check updated docs")
+
+		switch strings.ToLower(s) {
+		case ReuseParameterValue:
+			f.reuseExisting = true
+		case TraceContextParameterValue:
+			f.traceContext = true
+		case TraceIDFlowIDParameterValue:
+			f.traceContext = true
+			f.traceIDFlowID = true
+		default:
+			deprecated = deprecated || i > 0
 		}
 	}
-	return &flowId{reuseExisting: reuseExisting, generator: spec.generator}, nil
+
+	if deprecated {
+		log.Println("flow id filter warning: this syntaxt is deprecated and will be removed soon. " +
+			"please check updated docs")
+	}
+
+	return f, nil
 }
 
 // Name returns the canonical filter name
@@ -0,0 +1,56 @@
+package flowid
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/tracing"
+)
+
+const (
+	TraceParentHeaderName = tracing.TraceParentHeaderName
+	TraceStateHeaderName  = tracing.TraceStateHeaderName
+
+	// TraceContextParameterValue enables the W3C Trace Context headers.
+	TraceContextParameterValue = "tracecontext"
+
+	// TraceIDFlowIDParameterValue enables the W3C Trace Context headers,
+	// and uses the trace id as the flow id.
+	TraceIDFlowIDParameterValue = "flowid=traceid"
+)
+
+// setTraceContext sets the traceparent header of the request, continuing
+// the incoming trace when it's accepted, or starting a new one, with a new
+// span id as the parent id. The tracestate header is only propagated
+// together with the incoming trace. It returns the flow id of the
+// request, that is the trace id, when it is mapped, except when a reused
+// flow id is not a valid trace id, and there is no incoming trace to
+// continue. In this case, the reused flow id is kept.
+func (f *flowId) setTraceContext(r *http.Request, flowId string) string {
+	var (
+		p     tracing.TraceParent
+		valid bool
+	)
+
+	if f.reuseExisting {
+		p, valid = tracing.ParseTraceParent(r.Header.Get(TraceParentHeaderName))
+	}
+
+	if !valid {
+		r.Header.Del(TraceStateHeaderName)
+		p.Flags = 0
+		if f.traceIDFlowID && tracing.IsTraceID(flowId) {
+			p.TraceID = flowId
+		} else {
+			p.TraceID = tracing.NewTraceID()
+		}
+	}
+
+	p.ParentID = tracing.NewSpanID()
+	r.Header.Set(TraceParentHeaderName, p.String())
+
+	if f.traceIDFlowID && (valid || flowId == "") {
+		return p.TraceID
+	}
+
+	return flowId
+}
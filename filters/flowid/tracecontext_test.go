@@ -0,0 +1,125 @@
+package flowid
+
+import (
+	"testing"
+
+	"github.com/zalando/skipper/tracing"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceParent = "00-" + testTraceID + "-" + testParentID + "-01"
+	testTraceState  = "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"
+)
+
+func createTraceFilter(t *testing.T, args ...interface{}) *flowId {
+	f, err := testFlowIdSpec.CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f.(*flowId)
+}
+
+func TestTraceContextContinuesTrace(t *testing.T) {
+	f := createTraceFilter(t, ReuseParameterValue, TraceContextParameterValue)
+	fc := buildfilterContext(TraceParentHeaderName, testTraceParent, TraceStateHeaderName, testTraceState, HeaderName, testFlowId)
+	f.Request(fc)
+
+	h := fc.Request().Header
+	p, ok := tracing.ParseTraceParent(h.Get(TraceParentHeaderName))
+	if !ok {
+		t.Fatal("invalid traceparent", h.Get(TraceParentHeaderName))
+	}
+
+	if p.TraceID != testTraceID || p.Flags != tracing.FlagSampled {
+		t.Error("failed to continue the trace", p)
+	}
+
+	if p.ParentID == testParentID {
+		t.Error("failed to set a new parent id")
+	}
+
+	if h.Get(TraceStateHeaderName) != testTraceState {
+		t.Error("failed to propagate the tracestate", h.Get(TraceStateHeaderName))
+	}
+
+	if h.Get(HeaderName) != testFlowId {
+		t.Error("unexpected flow id", h.Get(HeaderName))
+	}
+}
+
+func TestTraceContextStartsTrace(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		tp   string
+	}{
+		{"no reuse", []interface{}{TraceContextParameterValue}, testTraceParent},
+		{"missing traceparent", []interface{}{ReuseParameterValue, TraceContextParameterValue}, ""},
+		{"invalid traceparent", []interface{}{ReuseParameterValue, TraceContextParameterValue}, "00-invalid"},
+	} {
+		f := createTraceFilter(t, ti.args...)
+		fc := buildfilterContext(TraceParentHeaderName, ti.tp, TraceStateHeaderName, testTraceState)
+		f.Request(fc)
+
+		h := fc.Request().Header
+		p, ok := tracing.ParseTraceParent(h.Get(TraceParentHeaderName))
+		if !ok {
+			t.Error(ti.msg, "invalid traceparent", h.Get(TraceParentHeaderName))
+			continue
+		}
+
+		if p.TraceID == testTraceID {
+			t.Error(ti.msg, "failed to start a new trace")
+		}
+
+		if h.Get(TraceStateHeaderName) != "" {
+			t.Error(ti.msg, "failed to drop the tracestate")
+		}
+
+		if h.Get(HeaderName) == "" || h.Get(HeaderName) == p.TraceID {
+			t.Error(ti.msg, "unexpected flow id", h.Get(HeaderName))
+		}
+	}
+}
+
+func TestTraceIDAsFlowID(t *testing.T) {
+	f := createTraceFilter(t, ReuseParameterValue, TraceIDFlowIDParameterValue)
+
+	fc := buildfilterContext(TraceParentHeaderName, testTraceParent, HeaderName, testFlowId)
+	f.Request(fc)
+	if fid := fc.Request().Header.Get(HeaderName); fid != testTraceID {
+		t.Error("failed to map the trace id to the flow id", fid)
+	}
+
+	fc = buildfilterContext(HeaderName, testTraceID)
+	f.Request(fc)
+	if p, _ := tracing.ParseTraceParent(fc.Request().Header.Get(TraceParentHeaderName)); p.TraceID != testTraceID {
+		t.Error("failed to map the flow id to the trace id", p)
+	}
+
+	fc = buildfilterContext()
+	f.Request(fc)
+	h := fc.Request().Header
+	p, ok := tracing.ParseTraceParent(h.Get(TraceParentHeaderName))
+	if !ok || h.Get(HeaderName) != p.TraceID {
+		t.Error("failed to use the new trace id as the flow id", h.Get(HeaderName), p)
+	}
+}
+
+func TestTraceIDAsFlowIDKeepsReusedFlowID(t *testing.T) {
+	f := createTraceFilter(t, ReuseParameterValue, TraceIDFlowIDParameterValue)
+
+	fc := buildfilterContext(HeaderName, testFlowId)
+	f.Request(fc)
+	h := fc.Request().Header
+	if h.Get(HeaderName) != testFlowId {
+		t.Error("failed to keep the reused flow id", h.Get(HeaderName))
+	}
+
+	if p, ok := tracing.ParseTraceParent(h.Get(TraceParentHeaderName)); !ok || p.TraceID == testFlowId {
+		t.Error("failed to start a new trace", h.Get(TraceParentHeaderName))
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Package jsonbody implements filters to make small modifications in the JSON
+payload of the requests and the responses.
+
+The filters buffer the body, and apply the modification only when the
+Content-Type is application/json, or any type with the +json suffix, and
+the body is a valid JSON document not larger than the maximum buffered
+size. In any other case, the body is passed through untouched. After the
+modification, the Content-Length is adjusted to the new size of the body.
+
+Fields are addressed with path expressions, where the object keys and the
+array indexes are separated by dots, e.g. "data.items.0.id".
+
+The default maximum buffered size is 1MB. It can be set for every filter
+instance, in bytes, as the last, optional argument.
+
+Setting a field, with a JSON literal as the value. Missing objects on the
+path are created. Number arguments are set as JSON numbers, while string
+arguments need to be valid JSON literals, so strings need to be quoted:
+
+	setRequestJSONField("meta.version", 2)
+	setRequestJSONField("meta.client", "\"legacy\"")
+	setResponseJSONField("meta.deprecated", "true")
+
+Deleting a field, or an array item:
+
+	dropRequestJSONField("user.password")
+	dropResponseJSONField("items.0")
+
+Renaming a field. The second argument is the new key of the field, under
+the same parent object:
+
+	renameRequestJSONField("user.login", "username")
+	renameResponseJSONField("userName", "user_name", 65536)
+
+Wrapping the whole document in an envelope object, and unwrapping a field
+of an envelope object to become the whole document:
+
+	wrapRequestJSON("data")
+	unwrapResponseJSON("data")
+*/
+package jsonbody
@@ -0,0 +1,445 @@
+package jsonbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	SetRequestJSONFieldName     = "setRequestJSONField"
+	SetResponseJSONFieldName    = "setResponseJSONField"
+	DropRequestJSONFieldName    = "dropRequestJSONField"
+	DropResponseJSONFieldName   = "dropResponseJSONField"
+	RenameRequestJSONFieldName  = "renameRequestJSONField"
+	RenameResponseJSONFieldName = "renameResponseJSONField"
+	WrapRequestJSONName         = "wrapRequestJSON"
+	WrapResponseJSONName        = "wrapResponseJSON"
+	UnwrapRequestJSONName       = "unwrapRequestJSON"
+	UnwrapResponseJSONName      = "unwrapResponseJSON"
+
+	// The default maximum size of the buffered body: 1MB.
+	DefaultMaxBufferSize = 1 << 20
+)
+
+type operation int
+
+const (
+	setField operation = iota
+	dropField
+	renameField
+	wrap
+	unwrap
+)
+
+type direction int
+
+const (
+	request direction = iota
+	response
+)
+
+type spec struct {
+	op   operation
+	typ  direction
+	name string
+}
+
+type filter struct {
+	op      operation
+	typ     direction
+	path    []string
+	value   interface{}
+	key     string
+	maxSize int64
+}
+
+// multiReadCloser is used to restore a body when only a part of it was
+// read during the buffering
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newSpec(op operation, typ direction, name string) filters.Spec {
+	return &spec{op: op, typ: typ, name: name}
+}
+
+// Creates a filter spec for setting a field in the JSON request body.
+// Name: setRequestJSONField
+func NewSetRequestJSONField() filters.Spec {
+	return newSpec(setField, request, SetRequestJSONFieldName)
+}
+
+// Creates a filter spec for setting a field in the JSON response body.
+// Name: setResponseJSONField
+func NewSetResponseJSONField() filters.Spec {
+	return newSpec(setField, response, SetResponseJSONFieldName)
+}
+
+// Creates a filter spec for deleting a field from the JSON request body.
+// Name: dropRequestJSONField
+func NewDropRequestJSONField() filters.Spec {
+	return newSpec(dropField, request, DropRequestJSONFieldName)
+}
+
+// Creates a filter spec for deleting a field from the JSON response body.
+// Name: dropResponseJSONField
+func NewDropResponseJSONField() filters.Spec {
+	return newSpec(dropField, response, DropResponseJSONFieldName)
+}
+
+// Creates a filter spec for renaming a field in the JSON request body.
+// Name: renameRequestJSONField
+func NewRenameRequestJSONField() filters.Spec {
+	return newSpec(renameField, request, RenameRequestJSONFieldName)
+}
+
+// Creates a filter spec for renaming a field in the JSON response body.
+// Name: renameResponseJSONField
+func NewRenameResponseJSONField() filters.Spec {
+	return newSpec(renameField, response, RenameResponseJSONFieldName)
+}
+
+// Creates a filter spec for wrapping the JSON request body in an envelope.
+// Name: wrapRequestJSON
+func NewWrapRequestJSON() filters.Spec {
+	return newSpec(wrap, request, WrapRequestJSONName)
+}
+
+// Creates a filter spec for wrapping the JSON response body in an envelope.
+// Name: wrapResponseJSON
+func NewWrapResponseJSON() filters.Spec {
+	return newSpec(wrap, response, WrapResponseJSONName)
+}
+
+// Creates a filter spec for unwrapping the JSON request body from an
+// envelope.
+// Name: unwrapRequestJSON
+func NewUnwrapRequestJSON() filters.Spec {
+	return newSpec(unwrap, request, UnwrapRequestJSONName)
+}
+
+// Creates a filter spec for unwrapping the JSON response body from an
+// envelope.
+// Name: unwrapResponseJSON
+func NewUnwrapResponseJSON() filters.Spec {
+	return newSpec(unwrap, response, UnwrapResponseJSONName)
+}
+
+func (s *spec) Name() string { return s.name }
+
+// the number of the mandatory arguments of the operations
+func (s *spec) argCount() int {
+	switch s.op {
+	case setField, renameField:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func parseValue(a interface{}) (interface{}, error) {
+	switch v := a.(type) {
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case string:
+		d := json.NewDecoder(strings.NewReader(v))
+		d.UseNumber()
+		var value interface{}
+		if err := d.Decode(&value); err != nil {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		if d.More() {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		return value, nil
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	n := s.argCount()
+	if len(args) != n && len(args) != n+1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{op: s.op, typ: s.typ, maxSize: DefaultMaxBufferSize}
+
+	if len(args) == n+1 {
+		m, ok := args[n].(float64)
+		if !ok || m <= 0 || math.Trunc(m) != m {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.maxSize = int64(m)
+	}
+
+	first, ok := args[0].(string)
+	if !ok || first == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	switch s.op {
+	case wrap, unwrap:
+		f.key = first
+		return f, nil
+	}
+
+	f.path = strings.Split(first, ".")
+
+	switch s.op {
+	case setField:
+		v, err := parseValue(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		f.value = v
+	case renameField:
+		key, ok := args[1].(string)
+		if !ok || key == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.key = key
+	}
+
+	return f, nil
+}
+
+func isJSON(h http.Header) bool {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// reads the body up to the max size. When the body is larger, it
+// returns false, and the body that can be used to restore the original
+// content.
+func bufferBody(body io.ReadCloser, maxSize int64) ([]byte, io.ReadCloser, bool, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if int64(len(b)) > maxSize {
+		return nil, &multiReadCloser{io.MultiReader(bytes.NewReader(b), body), body}, false, nil
+	}
+
+	body.Close()
+	return b, nil, true, nil
+}
+
+// returns the parent container of the last path segment, creating the
+// missing objects on the path when create is true
+func lookupParent(doc interface{}, path []string, create bool) (interface{}, bool) {
+	current := doc
+	for _, key := range path[:len(path)-1] {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next, ok := c[key]
+			if !ok || next == nil {
+				if !create {
+					return nil, false
+				}
+
+				next = make(map[string]interface{})
+				c[key] = next
+			}
+
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+
+			current = c[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// applies the operation, and returns the modified document, or false
+// when the document was not changed
+func (f *filter) apply(doc interface{}) (interface{}, bool) {
+	switch f.op {
+	case wrap:
+		return map[string]interface{}{f.key: doc}, true
+	case unwrap:
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc, false
+		}
+
+		v, ok := m[f.key]
+		return v, ok
+	}
+
+	parent, ok := lookupParent(doc, f.path, f.op == setField)
+	if !ok {
+		return doc, false
+	}
+
+	last := f.path[len(f.path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		switch f.op {
+		case setField:
+			p[last] = f.value
+			return doc, true
+		case dropField:
+			if _, ok := p[last]; !ok {
+				return doc, false
+			}
+
+			delete(p, last)
+			return doc, true
+		case renameField:
+			v, ok := p[last]
+			if !ok {
+				return doc, false
+			}
+
+			delete(p, last)
+			p[f.key] = v
+			return doc, true
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(last)
+		if err != nil || i < 0 || i >= len(p) {
+			return doc, false
+		}
+
+		switch f.op {
+		case setField:
+			p[i] = f.value
+			return doc, true
+		case dropField:
+			// the parent array needs to be replaced, because its length
+			// changes
+			return f.replaceParent(doc, append(p[:i:i], p[i+1:]...)), true
+		}
+	}
+
+	return doc, false
+}
+
+// replaces the parent container of the last path segment
+func (f *filter) replaceParent(doc interface{}, parent interface{}) interface{} {
+	if len(f.path) == 1 {
+		return parent
+	}
+
+	grandParent, _ := lookupParent(doc, f.path[:len(f.path)-1], false)
+	key := f.path[len(f.path)-2]
+	switch g := grandParent.(type) {
+	case map[string]interface{}:
+		g[key] = parent
+	case []interface{}:
+		i, _ := strconv.Atoi(key)
+		g[i] = parent
+	}
+
+	return doc
+}
+
+// transforms the body when it is JSON and not too large. Returns the new
+// body, its length, and false when the body was not changed. When reading
+// the body fails, it returns the error, and the body must not be
+// forwarded.
+func (f *filter) transform(h http.Header, body io.ReadCloser) (io.ReadCloser, int64, bool, error) {
+	if body == nil || !isJSON(h) {
+		return body, 0, false, nil
+	}
+
+	b, rest, ok, err := bufferBody(body, f.maxSize)
+	if err != nil {
+		body.Close()
+		return nil, 0, false, err
+	}
+
+	if !ok {
+		return rest, 0, false, nil
+	}
+
+	original := ioutil.NopCloser(bytes.NewReader(b))
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil || d.More() {
+		return original, 0, false, nil
+	}
+
+	doc, changed := f.apply(doc)
+	if !changed {
+		return original, 0, false, nil
+	}
+
+	nb, err := json.Marshal(doc)
+	if err != nil {
+		return original, 0, false, nil
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(nb)), int64(len(nb)), true, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	if f.typ != request {
+		return
+	}
+
+	req := ctx.Request()
+	body, n, changed, err := f.transform(req.Header, req.Body)
+	if err != nil {
+		log.Errorf("jsonbody: failed to read the request body: %v", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadRequest})
+		return
+	}
+
+	req.Body = body
+	if changed {
+		req.ContentLength = n
+		req.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	}
+}
+
+func (f *filter) Response(ctx filters.FilterContext) {
+	if f.typ != response {
+		return
+	}
+
+	rsp := ctx.Response()
+	body, n, changed, err := f.transform(rsp.Header, rsp.Body)
+	if err != nil {
+		log.Errorf("jsonbody: failed to read the response body: %v", err)
+		ctx.Serve(&http.Response{StatusCode: http.StatusBadGateway})
+		return
+	}
+
+	rsp.Body = body
+	if changed {
+		rsp.ContentLength = n
+		rsp.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	}
+}
@@ -0,0 +1,359 @@
+package jsonbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		spec filters.Spec
+		args []interface{}
+		err  bool
+	}{{
+		"set, missing value",
+		NewSetRequestJSONField(),
+		[]interface{}{"foo"},
+		true,
+	}, {
+		"set, invalid JSON literal",
+		NewSetRequestJSONField(),
+		[]interface{}{"foo", "bar"},
+		true,
+	}, {
+		"set, multiple JSON literals",
+		NewSetRequestJSONField(),
+		[]interface{}{"foo", "1 2"},
+		true,
+	}, {
+		"set, number",
+		NewSetResponseJSONField(),
+		[]interface{}{"foo", 42.0},
+		false,
+	}, {
+		"set, string literal",
+		NewSetResponseJSONField(),
+		[]interface{}{"foo", `"bar"`},
+		false,
+	}, {
+		"drop, empty path",
+		NewDropRequestJSONField(),
+		[]interface{}{""},
+		true,
+	}, {
+		"drop, invalid path type",
+		NewDropRequestJSONField(),
+		[]interface{}{42.0},
+		true,
+	}, {
+		"rename, empty key",
+		NewRenameRequestJSONField(),
+		[]interface{}{"foo", ""},
+		true,
+	}, {
+		"wrap, too many arguments",
+		NewWrapRequestJSON(),
+		[]interface{}{"data", 1024.0, "foo"},
+		true,
+	}, {
+		"wrap, invalid max size",
+		NewWrapRequestJSON(),
+		[]interface{}{"data", "1024"},
+		true,
+	}, {
+		"wrap, negative max size",
+		NewWrapRequestJSON(),
+		[]interface{}{"data", -1.0},
+		true,
+	}, {
+		"unwrap, with max size",
+		NewUnwrapResponseJSON(),
+		[]interface{}{"data", 1024.0},
+		false,
+	}} {
+		_, err := ti.spec.CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	for _, ti := range []struct {
+		msg         string
+		spec        filters.Spec
+		args        []interface{}
+		contentType string
+		body        string
+		expected    string
+	}{{
+		"set a field",
+		NewSetRequestJSONField(),
+		[]interface{}{"foo", 42.0},
+		"application/json",
+		`{"bar": 1}`,
+		`{"bar": 1, "foo": 42}`,
+	}, {
+		"set a nested field, creating the objects",
+		NewSetRequestJSONField(),
+		[]interface{}{"meta.client.name", `"legacy"`},
+		"application/json",
+		`{"bar": 1}`,
+		`{"bar": 1, "meta": {"client": {"name": "legacy"}}}`,
+	}, {
+		"set an array item",
+		NewSetResponseJSONField(),
+		[]interface{}{"items.1", `{"id": 3}`},
+		"application/json; charset=utf-8",
+		`{"items": [{"id": 1}, {"id": 2}]}`,
+		`{"items": [{"id": 1}, {"id": 3}]}`,
+	}, {
+		"large numbers are preserved",
+		NewSetResponseJSONField(),
+		[]interface{}{"foo", "true"},
+		"application/json",
+		`{"id": 12345678901234567890}`,
+		`{"id": 12345678901234567890, "foo": true}`,
+	}, {
+		"drop a field",
+		NewDropRequestJSONField(),
+		[]interface{}{"user.password"},
+		"application/json",
+		`{"user": {"name": "foo", "password": "bar"}}`,
+		`{"user": {"name": "foo"}}`,
+	}, {
+		"drop an array item",
+		NewDropResponseJSONField(),
+		[]interface{}{"items.0"},
+		"application/vnd.api+json",
+		`{"items": [1, 2, 3]}`,
+		`{"items": [2, 3]}`,
+	}, {
+		"drop an item of the root array",
+		NewDropResponseJSONField(),
+		[]interface{}{"1"},
+		"application/json",
+		`[1, 2, 3]`,
+		`[1, 3]`,
+	}, {
+		"drop a missing field",
+		NewDropRequestJSONField(),
+		[]interface{}{"user.password"},
+		"application/json",
+		`{"foo": "bar"}`,
+		`{"foo": "bar"}`,
+	}, {
+		"rename a field",
+		NewRenameRequestJSONField(),
+		[]interface{}{"user.login", "username"},
+		"application/json",
+		`{"user": {"login": "foo"}}`,
+		`{"user": {"username": "foo"}}`,
+	}, {
+		"wrap",
+		NewWrapRequestJSON(),
+		[]interface{}{"data"},
+		"application/json",
+		`[1, 2]`,
+		`{"data": [1, 2]}`,
+	}, {
+		"unwrap",
+		NewUnwrapResponseJSON(),
+		[]interface{}{"data"},
+		"application/json",
+		`{"data": {"foo": "bar"}, "meta": {}}`,
+		`{"foo": "bar"}`,
+	}, {
+		"not JSON content type",
+		NewWrapRequestJSON(),
+		[]interface{}{"data"},
+		"text/plain",
+		`{"foo": "bar"}`,
+		`{"foo": "bar"}`,
+	}, {
+		"invalid JSON",
+		NewWrapResponseJSON(),
+		[]interface{}{"data"},
+		"application/json",
+		`{"foo": `,
+		`{"foo": `,
+	}, {
+		"body too large",
+		NewWrapResponseJSON(),
+		[]interface{}{"data", 8.0},
+		"application/json",
+		`{"foo": "bar"}`,
+		`{"foo": "bar"}`,
+	}} {
+		f, err := ti.spec.CreateFilter(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		header := http.Header{"Content-Type": []string{ti.contentType}}
+		body := ioutil.NopCloser(bytes.NewBufferString(ti.body))
+		req := &http.Request{Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{})}
+		rsp := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{})}
+		isResponse := strings.Contains(ti.spec.Name(), "Response")
+		if isResponse {
+			rsp.Header = header
+			rsp.Body = body
+		} else {
+			req.Header = header
+			req.Body = body
+		}
+
+		ctx := &filtertest.Context{FRequest: req, FResponse: rsp}
+		f.Request(ctx)
+		f.Response(ctx)
+
+		var (
+			h  http.Header
+			b  []byte
+			cl int64
+		)
+
+		if isResponse {
+			h, cl = rsp.Header, rsp.ContentLength
+			b, err = ioutil.ReadAll(rsp.Body)
+		} else {
+			h, cl = req.Header, req.ContentLength
+			b, err = ioutil.ReadAll(req.Body)
+		}
+
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if ti.expected == ti.body {
+			if string(b) != ti.body {
+				t.Error(ti.msg, "unexpected modification", string(b))
+			}
+
+			continue
+		}
+
+		var got, expected interface{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&got); err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		d = json.NewDecoder(strings.NewReader(ti.expected))
+		d.UseNumber()
+		if err := d.Decode(&expected); err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Error(ti.msg, "unexpected body", string(b))
+		}
+
+		if cl != int64(len(b)) || h.Get("Content-Length") != strconv.Itoa(len(b)) {
+			t.Error(ti.msg, "invalid content length", cl, h.Get("Content-Length"), len(b))
+		}
+	}
+}
+
+func TestProxyRequestContentLength(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if r.ContentLength != int64(len(b)) || len(r.TransferEncoding) != 0 {
+			t.Error("invalid content length", r.ContentLength, r.TransferEncoding, len(b))
+		}
+
+		w.Write(b)
+	}))
+	defer backend.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewWrapRequestJSON())
+	pr := proxytest.New(fr, &eskip.Route{
+		Filters: []*eskip.Filter{{Name: WrapRequestJSONName, Args: []interface{}{"data"}}},
+		Backend: backend.URL})
+	defer pr.Close()
+
+	rsp, err := http.Post(pr.URL, "application/json", bytes.NewBufferString(`{"foo": "bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != `{"data":{"foo":"bar"}}` {
+		t.Error("unexpected body", string(b))
+	}
+}
+
+type failingBody struct{}
+
+func (failingBody) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+func (failingBody) Close() error             { return nil }
+
+func TestBodyReadFailure(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		spec     filters.Spec
+		expected int
+	}{{
+		"request",
+		NewSetRequestJSONField(),
+		http.StatusBadRequest,
+	}, {
+		"response",
+		NewSetResponseJSONField(),
+		http.StatusBadGateway,
+	}} {
+		f, err := ti.spec.CreateFilter([]interface{}{"foo", 42.0})
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		ctx := &filtertest.Context{
+			FRequest:  &http.Request{Header: header, Body: failingBody{}},
+			FResponse: &http.Response{Header: header, Body: failingBody{}},
+		}
+
+		if strings.Contains(ti.spec.Name(), "Response") {
+			f.Response(ctx)
+		} else {
+			f.Request(ctx)
+		}
+
+		if !ctx.FServed || ctx.FResponse.StatusCode != ti.expected {
+			t.Error(ti.msg, "failed to fail the request", ctx.FServed, ctx.FResponse.StatusCode)
+		}
+	}
+}
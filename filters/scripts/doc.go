@@ -0,0 +1,72 @@
+/*
+Package scripts implements the lua filter, to run custom logic written in
+Lua on the requests and the responses, without rebuilding skipper. The
+scripts are executed by an embedded Lua 5.1 interpreter, written in pure
+Go (https://github.com/yuin/gopher-lua).
+
+The first argument of the filter is the script. When it ends with ".lua",
+it is the path of the file containing the script, otherwise it is the
+source code of the script itself:
+
+	* -> lua("/etc/skipper/scripts/auth.lua") -> "https://www.example.org"
+	* -> lua("function request(ctx, params); ctx.request.header['X-Foo'] = 'bar'; end") -> "https://www.example.org"
+
+The script needs to define at least one of the global functions
+`request(ctx, params)` and `response(ctx, params)`. These are called
+during the request and the response filtering phases respectively.
+
+The rest of the filter arguments are passed to the functions in the
+params table. Arguments in the format of "key=value" are set in the
+params table with the key, while all arguments are available in the
+table by their position, too:
+
+	* -> lua("./test.lua", "myparam=foo", "other=bar", "justkey") -> "https://www.example.org"
+
+Here, params.myparam is "foo", params.other is "bar", and params[3] is
+"justkey".
+
+The ctx object provides the following fields:
+
+	ctx.request.method: the HTTP method, read only
+	ctx.request.url: the full request URL, read only
+	ctx.request.url_path: the path of the request URL, can be set
+	ctx.request.url_raw_query: the raw query of the request URL, can be set
+	ctx.request.url_query.<name>: a query parameter, can be set, and setting it to nil deletes it
+	ctx.request.header.<name>: a request header, can be set, and setting it to nil deletes it
+	ctx.request.cookie.<name>: the value of a request cookie, read only
+	ctx.request.remote_addr: the remote address of the client connection, read only
+	ctx.request.host: the host of the incoming request, read only
+	ctx.request.outgoing_host: the host sent to the backend, can be set
+	ctx.response.status_code: the status code of the response, can be set
+	ctx.response.header.<name>: a response header, can be set, and setting it to nil deletes it
+	ctx.path_param.<name>: the wildcard path parameter with the given name, read only
+	ctx.state_bag.<key>: a value in the state bag, can be set with strings, numbers and booleans
+	ctx.serve(response): serves a response, and terminates the processing of the request
+
+The response fields are available only in the response function. The
+ctx.serve function accepts a table with the fields status_code, header
+(a table of header names and values) and body:
+
+	function request(ctx, params)
+		if ctx.request.header["Authorization"] == nil then
+			ctx.serve({status_code = 401, header = {["WWW-Authenticate"] = "Bearer"}})
+		end
+	end
+
+The scripts run in a sandbox: only the base, string, table and math
+libraries are available, without the functions of the base library that
+load files or modules (dofile, loadfile, module and require). The os, io
+and other libraries are not available. The execution of the request and
+the response functions is stopped after a timeout, one second by default.
+
+The interpreter instances are pooled, every filter instance has its own
+pool. The script is compiled only once, when the filter is created. The
+global variables are reset after every request to the values set when the
+script was loaded, and every request gets its own params table. When a
+request changes a table or a local variable of the script, e.g. to store
+a value, the interpreter instance is dropped instead of being returned to
+the pool, so no data is carried over from one request to another.
+When the script fails with an error, or it times out, the error is logged,
+and the processing of the request continues.
+*/
+package scripts
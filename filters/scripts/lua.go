@@ -0,0 +1,697 @@
+package scripts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"github.com/zalando/skipper/filters"
+)
+
+const (
+	LuaName = "lua"
+
+	// The default maximum number of idle interpreter instances per filter
+	// instance.
+	DefaultPoolSize = 64
+
+	// The default maximum duration of executing a request or a response
+	// function.
+	DefaultTimeout = time.Second
+)
+
+const (
+	ctxType            = "skipper.ctx"
+	requestType        = "skipper.request"
+	responseType       = "skipper.response"
+	requestHeaderType  = "skipper.request_header"
+	responseHeaderType = "skipper.response_header"
+	queryType          = "skipper.url_query"
+	cookieType         = "skipper.cookie"
+	pathParamType      = "skipper.path_param"
+	stateBagType       = "skipper.state_bag"
+)
+
+var errNoHooks = errors.New("lua script defines neither a request nor a response function")
+
+// only the libraries without access to the file system, the process or
+// the network are opened
+var libs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.StringLibName, lua.OpenString},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// functions of the base library that load code from files or modules
+var unsafeBaseFunctions = []string{"dofile", "loadfile", "module", "require"}
+
+type luaSpec struct {
+	poolSize int
+	timeout  time.Duration
+}
+
+// the contents of a table or the upvalues of a function
+type luaValues map[lua.LValue]lua.LValue
+
+// the keys used in luaValues for the metatable of a table and for the
+// environment of a function, that cannot collide with the keys of a table
+var (
+	metatableKey = &lua.LUserData{}
+	envKey       = &lua.LUserData{}
+)
+
+// an interpreter instance, with the snapshot of the globals and of the
+// contents of the reachable tables and functions, taken after the script
+// was loaded
+type luaState struct {
+	*lua.LState
+	globals  map[lua.LValue]lua.LValue
+	contents map[lua.LValue]luaValues
+}
+
+type script struct {
+	source      string
+	proto       *lua.FunctionProto
+	params      []string
+	timeout     time.Duration
+	pool        chan *luaState
+	hasRequest  bool
+	hasResponse bool
+}
+
+// Options to configure the lua filter.
+type LuaOptions struct {
+
+	// The maximum number of idle interpreter instances kept by every
+	// filter instance. When not set, DefaultPoolSize is used.
+	PoolSize int
+
+	// The maximum duration of executing a request or a response
+	// function. When not set, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// Returns a filter specification for the lua filter. See the package
+// documentation for the details.
+//
+// Name: "lua".
+func NewLua() filters.Spec {
+	return NewLuaWithOptions(LuaOptions{})
+}
+
+// Returns a filter specification for the lua filter, with custom options.
+func NewLuaWithOptions(o LuaOptions) filters.Spec {
+	if o.PoolSize <= 0 {
+		o.PoolSize = DefaultPoolSize
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+
+	return &luaSpec{poolSize: o.PoolSize, timeout: o.Timeout}
+}
+
+// "lua"
+func (s *luaSpec) Name() string { return LuaName }
+
+func loadSource(s string) (string, error) {
+	if !strings.HasSuffix(s, ".lua") {
+		return s, nil
+	}
+
+	b, err := ioutil.ReadFile(s)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (s *luaSpec) CreateFilter(config []interface{}) (filters.Filter, error) {
+	if len(config) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	src, ok := config[0].(string)
+	if !ok || src == "" {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	params := make([]string, len(config)-1)
+	for i, c := range config[1:] {
+		p, ok := c.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		params[i] = p
+	}
+
+	code, err := loadSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(code), LuaName)
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := lua.Compile(chunk, LuaName)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &script{
+		source:  src,
+		proto:   proto,
+		params:  params,
+		timeout: s.timeout,
+		pool:    make(chan *luaState, s.poolSize)}
+
+	// creating the first instance validates the script, and tells which
+	// hooks are defined
+	L, err := sc.newState()
+	if err != nil {
+		return nil, err
+	}
+
+	_, sc.hasRequest = L.GetGlobal("request").(*lua.LFunction)
+	_, sc.hasResponse = L.GetGlobal("response").(*lua.LFunction)
+	if !sc.hasRequest && !sc.hasResponse {
+		L.Close()
+		return nil, errNoHooks
+	}
+
+	sc.putState(L)
+	return sc, nil
+}
+
+func (s *script) newState() (*luaState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range libs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range unsafeBaseFunctions {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	registerTypes(L)
+	L.SetGlobal("params", s.newParams(L))
+
+	deadline, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	L.SetContext(deadline)
+	defer L.RemoveContext()
+
+	L.Push(L.NewFunctionFromProto(s.proto))
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		L.Close()
+		return nil, err
+	}
+
+	globals := make(map[lua.LValue]lua.LValue)
+	L.G.Global.ForEach(func(k, v lua.LValue) { globals[k] = v })
+	return &luaState{LState: L, globals: globals, contents: reachableContents(L)}, nil
+}
+
+// every run gets its own params table, so that the changes made by a
+// request are not visible to the next one
+func (s *script) newParams(L *lua.LState) *lua.LTable {
+	params := L.NewTable()
+	for i, p := range s.params {
+		params.RawSetInt(i+1, lua.LString(p))
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params.RawSetString(kv[0], lua.LString(kv[1]))
+		}
+	}
+
+	return params
+}
+
+func collectContents(v lua.LValue, c map[lua.LValue]luaValues) {
+	if _, ok := c[v]; ok {
+		return
+	}
+
+	values := make(luaValues)
+	switch vt := v.(type) {
+	case *lua.LTable:
+		vt.ForEach(func(k, v lua.LValue) { values[k] = v })
+		if vt.Metatable != nil {
+			values[metatableKey] = vt.Metatable
+		}
+	case *lua.LFunction:
+		for i, uv := range vt.Upvalues {
+			values[lua.LNumber(i)] = uv.Value()
+		}
+
+		if vt.Env != nil {
+			values[envKey] = vt.Env
+		}
+	default:
+		return
+	}
+
+	c[v] = values
+	for k, v := range values {
+		collectContents(k, c)
+		collectContents(v, c)
+	}
+}
+
+// collects the contents of the tables and the upvalues of the functions,
+// that are reachable from the globals or the registry
+func reachableContents(L *lua.LState) map[lua.LValue]luaValues {
+	c := make(map[lua.LValue]luaValues)
+	collectContents(L.G.Global, c)
+	collectContents(L.G.Registry, c)
+	return c
+}
+
+// tells whether any of the tables or the functions reachable from the
+// globals were changed since the script was loaded, e.g. when a request
+// stored data in a nested table or in a local variable of the script
+func (L *luaState) changed() bool {
+	current := reachableContents(L.LState)
+	if len(current) != len(L.contents) {
+		return true
+	}
+
+	for v, values := range current {
+		original, ok := L.contents[v]
+		if !ok || len(original) != len(values) {
+			return true
+		}
+
+		for k, vv := range values {
+			if ov, ok := original[k]; !ok || ov != vv {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *script) getState() (*luaState, error) {
+	select {
+	case L := <-s.pool:
+		return L, nil
+	default:
+		return s.newState()
+	}
+}
+
+// restores the globals to the state after the script was loaded
+func (L *luaState) resetGlobals() {
+	g := L.G.Global
+	var changed []lua.LValue
+	g.ForEach(func(k, v lua.LValue) {
+		if original, ok := L.globals[k]; !ok || original != v {
+			changed = append(changed, k)
+		}
+	})
+
+	for _, k := range changed {
+		g.RawSet(k, lua.LNil)
+	}
+
+	for k, v := range L.globals {
+		if g.RawGet(k) != v {
+			g.RawSet(k, v)
+		}
+	}
+}
+
+// the state is reused only when no data can be carried over from one
+// request to another: the globals are restored, and when any of the
+// reachable tables or functions was changed, the state is dropped
+func (s *script) putState(L *luaState) {
+	L.SetTop(0)
+	L.resetGlobals()
+	if L.changed() {
+		L.Close()
+		return
+	}
+
+	select {
+	case s.pool <- L:
+	default:
+		L.Close()
+	}
+}
+
+func (s *script) run(hook string, ctx filters.FilterContext) {
+	L, err := s.getState()
+	if err != nil {
+		log.Errorf("failed to create lua state for %s: %v", s.source, err)
+		return
+	}
+
+	deadline, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	L.SetContext(deadline)
+
+	params := s.newParams(L.LState)
+	L.SetGlobal("params", params)
+	err = L.CallByParam(
+		lua.P{Fn: L.GetGlobal(hook), NRet: 0, Protect: true},
+		newUserData(L.LState, ctx, ctxType),
+		params)
+	if err != nil {
+		// the state may be left in an inconsistent state, so it is not
+		// returned to the pool
+		log.Errorf("error while executing lua %s function of %s: %v", hook, s.source, err)
+		L.Close()
+		return
+	}
+
+	L.RemoveContext()
+	s.putState(L)
+}
+
+func (s *script) Request(ctx filters.FilterContext) {
+	if s.hasRequest {
+		s.run("request", ctx)
+	}
+}
+
+func (s *script) Response(ctx filters.FilterContext) {
+	if s.hasResponse {
+		s.run("response", ctx)
+	}
+}
+
+func registerType(L *lua.LState, typ string, index, newIndex lua.LGFunction) {
+	mt := L.NewTypeMetatable(typ)
+	L.SetField(mt, "__index", L.NewFunction(index))
+	if newIndex != nil {
+		L.SetField(mt, "__newindex", L.NewFunction(newIndex))
+	} else {
+		L.SetField(mt, "__newindex", L.NewFunction(readOnly))
+	}
+}
+
+func registerTypes(L *lua.LState) {
+	registerType(L, ctxType, ctxIndex, nil)
+	registerType(L, requestType, requestIndex, requestNewIndex)
+	registerType(L, responseType, responseIndex, responseNewIndex)
+	registerType(L, requestHeaderType, requestHeaderIndex, requestHeaderNewIndex)
+	registerType(L, responseHeaderType, responseHeaderIndex, responseHeaderNewIndex)
+	registerType(L, queryType, queryIndex, queryNewIndex)
+	registerType(L, cookieType, cookieIndex, nil)
+	registerType(L, pathParamType, pathParamIndex, nil)
+	registerType(L, stateBagType, stateBagIndex, stateBagNewIndex)
+}
+
+// all the skipper objects carry the filter context, and their type is
+// defined by their metatable
+func newUserData(L *lua.LState, ctx filters.FilterContext, typ string) lua.LValue {
+	ud := L.NewUserData()
+	ud.Value = ctx
+	L.SetMetatable(ud, L.GetTypeMetatable(typ))
+	return ud
+}
+
+func checkContext(L *lua.LState) filters.FilterContext {
+	ud := L.CheckUserData(1)
+	ctx, ok := ud.Value.(filters.FilterContext)
+	if !ok {
+		L.ArgError(1, "filter context expected")
+	}
+
+	return ctx
+}
+
+func readOnly(L *lua.LState) int {
+	L.RaiseError("cannot set field %s, the object is read only", L.CheckString(2))
+	return 0
+}
+
+func unsupportedField(L *lua.LState, key string) int {
+	L.RaiseError("unsupported field: %s", key)
+	return 0
+}
+
+func pushString(L *lua.LState, s string) int {
+	if s == "" {
+		L.Push(lua.LNil)
+	} else {
+		L.Push(lua.LString(s))
+	}
+
+	return 1
+}
+
+func ctxIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	switch key {
+	case "request":
+		L.Push(newUserData(L, ctx, requestType))
+	case "response":
+		if ctx.Response() == nil {
+			L.Push(lua.LNil)
+		} else {
+			L.Push(newUserData(L, ctx, responseType))
+		}
+	case "path_param":
+		L.Push(newUserData(L, ctx, pathParamType))
+	case "state_bag":
+		L.Push(newUserData(L, ctx, stateBagType))
+	case "serve":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			serve(L, ctx, L.CheckTable(1))
+			return 0
+		}))
+	default:
+		return unsupportedField(L, key)
+	}
+
+	return 1
+}
+
+func requestIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	r := ctx.Request()
+	switch key {
+	case "method":
+		L.Push(lua.LString(r.Method))
+	case "url":
+		L.Push(lua.LString(r.URL.String()))
+	case "url_path":
+		L.Push(lua.LString(r.URL.Path))
+	case "url_raw_query":
+		L.Push(lua.LString(r.URL.RawQuery))
+	case "url_query":
+		L.Push(newUserData(L, ctx, queryType))
+	case "header":
+		L.Push(newUserData(L, ctx, requestHeaderType))
+	case "cookie":
+		L.Push(newUserData(L, ctx, cookieType))
+	case "remote_addr":
+		L.Push(lua.LString(r.RemoteAddr))
+	case "host":
+		L.Push(lua.LString(r.Host))
+	case "outgoing_host":
+		L.Push(lua.LString(ctx.OutgoingHost()))
+	default:
+		return unsupportedField(L, key)
+	}
+
+	return 1
+}
+
+func requestNewIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	value := L.CheckString(3)
+	r := ctx.Request()
+	switch key {
+	case "url_path":
+		r.URL.Path = value
+	case "url_raw_query":
+		r.URL.RawQuery = value
+	case "outgoing_host":
+		ctx.SetOutgoingHost(value)
+	default:
+		return unsupportedField(L, key)
+	}
+
+	return 0
+}
+
+func responseIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	switch key {
+	case "status_code":
+		L.Push(lua.LNumber(ctx.Response().StatusCode))
+	case "header":
+		L.Push(newUserData(L, ctx, responseHeaderType))
+	default:
+		return unsupportedField(L, key)
+	}
+
+	return 1
+}
+
+func responseNewIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	switch key {
+	case "status_code":
+		ctx.Response().StatusCode = L.CheckInt(3)
+	default:
+		return unsupportedField(L, key)
+	}
+
+	return 0
+}
+
+func headerIndex(L *lua.LState, h http.Header) int {
+	return pushString(L, h.Get(L.CheckString(2)))
+}
+
+func headerNewIndex(L *lua.LState, h http.Header) int {
+	key := L.CheckString(2)
+	if L.Get(3) == lua.LNil {
+		h.Del(key)
+	} else {
+		h.Set(key, L.CheckString(3))
+	}
+
+	return 0
+}
+
+func requestHeaderIndex(L *lua.LState) int {
+	return headerIndex(L, checkContext(L).Request().Header)
+}
+
+func requestHeaderNewIndex(L *lua.LState) int {
+	return headerNewIndex(L, checkContext(L).Request().Header)
+}
+
+func responseHeaderIndex(L *lua.LState) int {
+	return headerIndex(L, checkContext(L).Response().Header)
+}
+
+func responseHeaderNewIndex(L *lua.LState) int {
+	return headerNewIndex(L, checkContext(L).Response().Header)
+}
+
+func queryIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	return pushString(L, ctx.Request().URL.Query().Get(L.CheckString(2)))
+}
+
+func queryNewIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	u := ctx.Request().URL
+	q := u.Query()
+	if L.Get(3) == lua.LNil {
+		q.Del(key)
+	} else {
+		q.Set(key, L.CheckString(3))
+	}
+
+	u.RawQuery = q.Encode()
+	return 0
+}
+
+func cookieIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	c, err := ctx.Request().Cookie(L.CheckString(2))
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	L.Push(lua.LString(c.Value))
+	return 1
+}
+
+func pathParamIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	return pushString(L, ctx.PathParam(L.CheckString(2)))
+}
+
+func stateBagIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	v, ok := ctx.StateBag()[L.CheckString(2)]
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	switch vt := v.(type) {
+	case string:
+		L.Push(lua.LString(vt))
+	case float64:
+		L.Push(lua.LNumber(vt))
+	case int:
+		L.Push(lua.LNumber(vt))
+	case bool:
+		L.Push(lua.LBool(vt))
+	default:
+		L.Push(lua.LString(fmt.Sprint(vt)))
+	}
+
+	return 1
+}
+
+func stateBagNewIndex(L *lua.LState) int {
+	ctx := checkContext(L)
+	key := L.CheckString(2)
+	switch v := L.Get(3).(type) {
+	case *lua.LNilType:
+		delete(ctx.StateBag(), key)
+	case lua.LString:
+		ctx.StateBag()[key] = string(v)
+	case lua.LNumber:
+		ctx.StateBag()[key] = float64(v)
+	case lua.LBool:
+		ctx.StateBag()[key] = bool(v)
+	default:
+		L.ArgError(3, "unsupported state bag value type: "+v.Type().String())
+	}
+
+	return 0
+}
+
+func serve(L *lua.LState, ctx filters.FilterContext, t *lua.LTable) {
+	rsp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+
+	if sc, ok := t.RawGetString("status_code").(lua.LNumber); ok {
+		rsp.StatusCode = int(sc)
+	}
+
+	if h, ok := t.RawGetString("header").(*lua.LTable); ok {
+		h.ForEach(func(k, v lua.LValue) {
+			rsp.Header.Add(k.String(), v.String())
+		})
+	}
+
+	body := lua.LVAsString(t.RawGetString("body"))
+	rsp.Body = ioutil.NopCloser(bytes.NewBufferString(body))
+	ctx.Serve(rsp)
+}
@@ -0,0 +1,365 @@
+package scripts
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestCreateFilter(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no arguments",
+		nil,
+		true,
+	}, {
+		"invalid script type",
+		[]interface{}{42.0},
+		true,
+	}, {
+		"invalid param type",
+		[]interface{}{"function request(ctx, params) end", 42.0},
+		true,
+	}, {
+		"syntax error",
+		[]interface{}{"function request(ctx, params)"},
+		true,
+	}, {
+		"error in the main chunk",
+		[]interface{}{"error('failed'); function request(ctx, params) end"},
+		true,
+	}, {
+		"no hooks",
+		[]interface{}{"local foo = 42"},
+		true,
+	}, {
+		"os library not available",
+		[]interface{}{"os.exit(1); function request(ctx, params) end"},
+		true,
+	}, {
+		"io library not available",
+		[]interface{}{"io.open('/etc/passwd'); function request(ctx, params) end"},
+		true,
+	}, {
+		"loading files not available",
+		[]interface{}{"dofile('testdata/test.lua'); function request(ctx, params) end"},
+		true,
+	}, {
+		"string, table and math available",
+		[]interface{}{"local s = string.upper(table.concat({'a', math.floor(1.5)})); function request(ctx, params) end"},
+		false,
+	}, {
+		"missing file",
+		[]interface{}{"testdata/missing.lua"},
+		true,
+	}, {
+		"main chunk timeout",
+		[]interface{}{"while true do end; function request(ctx, params) end"},
+		true,
+	}, {
+		"inline",
+		[]interface{}{"function response(ctx, params) end", "foo=bar"},
+		false,
+	}, {
+		"file",
+		[]interface{}{"testdata/test.lua"},
+		false,
+	}} {
+		_, err := NewLuaWithOptions(LuaOptions{Timeout: 30 * time.Millisecond}).CreateFilter(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestRequest(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		script string
+		params []interface{}
+		check  func(*filtertest.Context) bool
+	}{{
+		"set request header",
+		`function request(ctx, params) ctx.request.header["X-Foo"] = "bar" end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.Header.Get("X-Foo") == "bar" },
+	}, {
+		"delete request header",
+		`function request(ctx, params) ctx.request.header["Authorization"] = nil end`,
+		nil,
+		func(ctx *filtertest.Context) bool { _, ok := ctx.FRequest.Header["Authorization"]; return !ok },
+	}, {
+		"read request header",
+		`function request(ctx, params) ctx.request.header["X-Copy"] = ctx.request.header["authorization"] end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.Header.Get("X-Copy") == "Bearer foo" },
+	}, {
+		"params",
+		`function request(ctx, params) ctx.request.header["X-Foo"] = params.foo .. params[2] end`,
+		[]interface{}{"foo=bar", "baz"},
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.Header.Get("X-Foo") == "barbaz" },
+	}, {
+		"set path",
+		`function request(ctx, params) ctx.request.url_path = "/v2" .. ctx.request.url_path end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.URL.Path == "/v2/api" },
+	}, {
+		"set and delete query",
+		`function request(ctx, params)
+			ctx.request.url_query.bar = ctx.request.url_query.foo
+			ctx.request.url_query.foo = nil
+		end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.URL.RawQuery == "bar=baz" },
+	}, {
+		"path params and cookies",
+		`function request(ctx, params)
+			ctx.request.header["X-Foo"] = ctx.path_param.id .. ctx.request.cookie.session
+		end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.Header.Get("X-Foo") == "42abc" },
+	}, {
+		"state bag",
+		`function request(ctx, params)
+			ctx.state_bag.number = ctx.state_bag.existing + 1
+			ctx.state_bag.flag = true
+		end`,
+		nil,
+		func(ctx *filtertest.Context) bool {
+			return ctx.FStateBag["number"] == float64(43) && ctx.FStateBag["flag"] == true
+		},
+	}, {
+		"outgoing host",
+		`function request(ctx, params) ctx.request.outgoing_host = "backend.example.org" end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FOutgoingHost == "backend.example.org" },
+	}, {
+		"serve",
+		`function request(ctx, params)
+			ctx.serve({status_code = 401, header = {["WWW-Authenticate"] = "Bearer"}, body = "unauthorized"})
+		end`,
+		nil,
+		func(ctx *filtertest.Context) bool {
+			if !ctx.FServed || ctx.FResponse.StatusCode != http.StatusUnauthorized ||
+				ctx.FResponse.Header.Get("WWW-Authenticate") != "Bearer" {
+				return false
+			}
+
+			b, err := ioutil.ReadAll(ctx.FResponse.Body)
+			return err == nil && string(b) == "unauthorized"
+		},
+	}, {
+		"runtime error",
+		`function request(ctx, params) ctx.request.header["X-Foo"] = "bar"; ctx.request.foo = 42 end`,
+		nil,
+		func(ctx *filtertest.Context) bool { return ctx.FRequest.Header.Get("X-Foo") == "bar" },
+	}} {
+		f, err := NewLua().CreateFilter(append([]interface{}{ti.script}, ti.params...))
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		u, _ := url.Parse("https://www.example.org/api?foo=baz")
+		req := &http.Request{URL: u, Header: http.Header{
+			"Authorization": []string{"Bearer foo"},
+			"Cookie":        []string{"session=abc"}}}
+		ctx := &filtertest.Context{
+			FRequest:  req,
+			FParams:   map[string]string{"id": "42"},
+			FStateBag: map[string]interface{}{"existing": float64(42)}}
+		f.Request(ctx)
+		if !ti.check(ctx) {
+			t.Error(ti.msg, "check failed")
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	f, err := NewLua().CreateFilter([]interface{}{"testdata/test.lua", "myparam=foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://www.example.org")
+	ctx := &filtertest.Context{
+		FRequest:  &http.Request{URL: u, Header: http.Header{}},
+		FResponse: &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}}}
+
+	f.Request(ctx)
+	if ctx.FRequest.Header.Get("X-Param") != "foo" {
+		t.Error("failed to set request header")
+	}
+
+	f.Response(ctx)
+	if ctx.FResponse.Header.Get("X-Status") != "404" {
+		t.Error("failed to set response header")
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	f, err := NewLuaWithOptions(LuaOptions{PoolSize: 2}).CreateFilter([]interface{}{
+		`function request(ctx, params) ctx.request.header["X-Path"] = ctx.request.url_path end`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, _ := url.Parse("https://www.example.org/" + string('a'+rune(i%26)))
+			ctx := &filtertest.Context{FRequest: &http.Request{URL: u, Header: http.Header{}}}
+			f.Request(ctx)
+			if ctx.FRequest.Header.Get("X-Path") != u.Path {
+				t.Error("invalid path header", ctx.FRequest.Header.Get("X-Path"), u.Path)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestGlobalsReset(t *testing.T) {
+	f, err := NewLuaWithOptions(LuaOptions{PoolSize: 1}).CreateFilter([]interface{}{
+		`counter = 0
+		function request(ctx, params)
+			counter = counter + 1
+			ctx.request.header["X-Counter"] = tostring(counter)
+			ctx.request.header["X-Leaked"] = tostring(leaked)
+			leaked = ctx.request.url_path
+			string = nil
+		end`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse("https://www.example.org/secret")
+		ctx := &filtertest.Context{FRequest: &http.Request{URL: u, Header: http.Header{}}}
+		f.Request(ctx)
+		if ctx.FRequest.Header.Get("X-Counter") != "1" || ctx.FRequest.Header.Get("X-Leaked") != "nil" {
+			t.Error("globals were not reset", ctx.FRequest.Header)
+		}
+	}
+}
+
+func TestNoDataSharedBetweenRequests(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		script string
+	}{{
+		"nested table",
+		`cache = {}
+		function request(ctx, params)
+			ctx.request.header["X-Leaked"] = tostring(cache.path)
+			cache.path = ctx.request.url_path
+		end`,
+	}, {
+		"local variable",
+		`local last
+		function request(ctx, params)
+			ctx.request.header["X-Leaked"] = tostring(last)
+			last = ctx.request.url_path
+		end`,
+	}, {
+		"params",
+		`function request(ctx, params)
+			ctx.request.header["X-Leaked"] = tostring(params.path)
+			params.path = ctx.request.url_path
+		end`,
+	}, {
+		"library table",
+		`function request(ctx, params)
+			ctx.request.header["X-Leaked"] = tostring(string.path)
+			string.path = ctx.request.url_path
+		end`,
+	}, {
+		"context userdata",
+		`local requests = {}
+		function request(ctx, params)
+			if requests[1] then
+				ctx.request.header["X-Leaked"] = requests[1].request.url_path
+			else
+				ctx.request.header["X-Leaked"] = "nil"
+			end
+
+			requests[1] = ctx
+		end`,
+	}} {
+		f, err := NewLuaWithOptions(LuaOptions{PoolSize: 1}).CreateFilter([]interface{}{ti.script})
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		for _, p := range []string{"/first", "/second"} {
+			u, _ := url.Parse("https://www.example.org" + p)
+			ctx := &filtertest.Context{FRequest: &http.Request{URL: u, Header: http.Header{}}}
+			f.Request(ctx)
+			if l := ctx.FRequest.Header.Get("X-Leaked"); l != "nil" {
+				t.Error(ti.msg, "data shared between requests", l)
+			}
+		}
+	}
+}
+
+func TestStateReused(t *testing.T) {
+	f, err := NewLuaWithOptions(LuaOptions{PoolSize: 1}).CreateFilter([]interface{}{
+		`local prefix = "/v2"
+		function request(ctx, params)
+			local parts = {prefix, ctx.request.url_path}
+			ctx.request.url_path = table.concat(parts)
+		end`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc := f.(*script)
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse("https://www.example.org/foo")
+		ctx := &filtertest.Context{FRequest: &http.Request{URL: u, Header: http.Header{}}}
+		f.Request(ctx)
+		if ctx.FRequest.URL.Path != "/v2/foo" {
+			t.Error("failed to execute the script", ctx.FRequest.URL.Path)
+		}
+
+		if len(sc.pool) != 1 {
+			t.Error("failed to reuse the state")
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	f, err := NewLuaWithOptions(LuaOptions{Timeout: 30 * time.Millisecond}).CreateFilter([]interface{}{
+		`function request(ctx, params)
+			ctx.request.header["X-Foo"] = "bar"
+			while true do end
+		end`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		u, _ := url.Parse("https://www.example.org")
+		f.Request(&filtertest.Context{FRequest: &http.Request{URL: u, Header: http.Header{}}})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Error("failed to stop the script")
+	}
+}
@@ -0,0 +1,7 @@
+function request(ctx, params)
+	ctx.request.header["X-Param"] = params.myparam
+end
+
+function response(ctx, params)
+	ctx.response.header["X-Status"] = tostring(ctx.response.status_code)
+end
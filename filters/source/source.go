@@ -0,0 +1,275 @@
+/*
+Package source implements filters to allow or deny requests based on the
+IP address of the client.
+
+The allowSource filter rejects the requests from clients outside of the
+configured networks, while the denySource filter rejects the requests
+from clients inside of them. The rejected requests get the response 403
+Forbidden.
+
+The networks can be passed in as arguments, in CIDR notation or as
+single addresses, or they can be loaded from files, with one network or
+address per line. Empty lines and lines starting with # are ignored. The
+files are shared by the routes, and they are reloaded in the background
+when they change. When the reload fails, the previously loaded networks
+are used. The networks are stored in prefix trees, so that large lists
+of networks can be checked efficiently.
+
+By default, the X-Forwarded-For header is ignored, because it can be set
+by the clients, and the remote address of the connection is used. When
+skipper is behind a known number of proxies, the option hops=<n> can be
+used to take the address added by the first of these trusted proxies,
+counted from the end of the X-Forwarded-For header.
+
+It is important to note, that these filters should not be used as the
+only gatekeeper for secure endpoints. Always use proper authorization and
+authentication for access control!
+
+Examples:
+
+	// only accept requests from 10.0.0.0/8 and 192.168.1.1
+	* -> allowSource("10.0.0.0/8", "192.168.1.1") -> "https://internal.example.org"
+
+	// reject requests from the networks listed in a file, with one
+	// trusted load balancer in front of skipper
+	* -> denySource("file=/etc/skipper/blocked.txt", "hops=1") -> "https://www.example.org"
+*/
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	snet "github.com/zalando/skipper/net"
+)
+
+const (
+	AllowSourceName = "allowSource"
+	DenySourceName  = "denySource"
+
+	fileOption = "file="
+	hopsOption = "hops="
+
+	// the files are checked for changes at most once in this period
+	checkInterval = time.Second
+)
+
+type spec struct {
+	deny  bool
+	mx    sync.Mutex
+	files map[string]*cidrFile
+}
+
+type filter struct {
+	deny   bool
+	inline *snet.CIDRSet
+	files  []*cidrFile
+	hops   int
+}
+
+// cidrFile holds the networks loaded from a file. The file is checked for
+// changes by the requests, and reloaded in the background.
+type cidrFile struct {
+	path      string
+	mx        sync.Mutex
+	set       *snet.CIDRSet
+	modTime   time.Time
+	checked   time.Time
+	reloading bool
+}
+
+// Returns a filter specification whose instances reject the requests from
+// clients outside of the networks passed in as arguments.
+//
+// Name: "allowSource".
+func NewAllowSource() filters.Spec {
+	return &spec{files: make(map[string]*cidrFile)}
+}
+
+// Returns a filter specification whose instances reject the requests from
+// clients inside of the networks passed in as arguments.
+//
+// Name: "denySource".
+func NewDenySource() filters.Spec {
+	return &spec{deny: true, files: make(map[string]*cidrFile)}
+}
+
+func (s *spec) Name() string {
+	if s.deny {
+		return DenySourceName
+	}
+
+	return AllowSourceName
+}
+
+func parseFile(path string) (*snet.CIDRSet, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(f); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	set := snet.NewCIDRSet()
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		n, err := snet.ParseCIDR(line)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		set.Add(n)
+	}
+
+	return set, info.ModTime(), scanner.Err()
+}
+
+// the files are shared between the routes
+func (s *spec) file(path string) (*cidrFile, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if f, ok := s.files[path]; ok {
+		return f, nil
+	}
+
+	set, modTime, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &cidrFile{path: path, set: set, modTime: modTime, checked: time.Now()}
+	s.files[path] = f
+	return f, nil
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f := &filter{deny: s.deny}
+	var cidrs []string
+	for _, a := range args {
+		sa, ok := a.(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		switch {
+		case strings.HasPrefix(sa, fileOption):
+			cf, err := s.file(sa[len(fileOption):])
+			if err != nil {
+				return nil, err
+			}
+
+			f.files = append(f.files, cf)
+		case strings.HasPrefix(sa, hopsOption):
+			hops, err := strconv.Atoi(sa[len(hopsOption):])
+			if err != nil || hops < 0 {
+				return nil, filters.ErrInvalidFilterParameters
+			}
+
+			f.hops = hops
+		default:
+			cidrs = append(cidrs, sa)
+		}
+	}
+
+	if len(cidrs) == 0 && len(f.files) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	set, err := snet.ParseCIDRSet(cidrs)
+	if err != nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	f.inline = set
+	return f, nil
+}
+
+func (f *cidrFile) reload() {
+	set, modTime, err := parseFile(f.path)
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.reloading = false
+	if err != nil {
+		log.Errorf("source: failed to reload %s: %v", f.path, err)
+		return
+	}
+
+	f.set, f.modTime = set, modTime
+}
+
+// returns the current networks, and starts reloading the file in the
+// background when it has changed
+func (f *cidrFile) current() *snet.CIDRSet {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	now := time.Now()
+	if !f.reloading && now.Sub(f.checked) >= checkInterval {
+		f.checked = now
+		if info, err := os.Stat(f.path); err != nil {
+			log.Errorf("source: failed to check %s: %v", f.path, err)
+		} else if !info.ModTime().Equal(f.modTime) {
+			f.reloading = true
+			go f.reload()
+		}
+	}
+
+	return f.set
+}
+
+func (f *filter) clientIP(r *http.Request) net.IP {
+	return snet.RemoteHostTrusted(r, f.hops)
+}
+
+func (f *filter) contains(ip net.IP) bool {
+	if f.inline.Contains(ip) {
+		return true
+	}
+
+	for _, cf := range f.files {
+		if cf.current().Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	ip := f.clientIP(ctx.Request())
+	if ip == nil || f.contains(ip) == f.deny {
+		ctx.Serve(&http.Response{StatusCode: http.StatusForbidden})
+	}
+}
+
+func (f *filter) Response(filters.FilterContext) {}
@@ -0,0 +1,258 @@
+package source
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "cidrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	writeFile(t, f.Name(), content, time.Now().Add(-time.Hour))
+	return f.Name()
+}
+
+func served(t *testing.T, f filters.Filter, remoteAddr, xff string) bool {
+	req, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.RemoteAddr = remoteAddr
+	if xff != "" {
+		req.Header.Set("X-Forwarded-For", xff)
+	}
+
+	ctx := &filtertest.Context{FRequest: req}
+	f.Request(ctx)
+	if ctx.FServed && ctx.FResponse.StatusCode != http.StatusForbidden {
+		t.Error("invalid status code", ctx.FResponse.StatusCode)
+	}
+
+	return ctx.FServed
+}
+
+func TestCreateFilter(t *testing.T) {
+	file := tempFile(t, "# internal networks\n10.0.0.0/8\n\n192.168.1.1\n")
+	defer os.Remove(file)
+
+	invalidFile := tempFile(t, "10.0.0.0/8\nfoo\n")
+	defer os.Remove(invalidFile)
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"not a string",
+		[]interface{}{42},
+		true,
+	}, {
+		"invalid network",
+		[]interface{}{"10.0.0.0/33"},
+		true,
+	}, {
+		"missing file",
+		[]interface{}{"file=/no/such/file"},
+		true,
+	}, {
+		"invalid file",
+		[]interface{}{"file=" + invalidFile},
+		true,
+	}, {
+		"invalid hops",
+		[]interface{}{"10.0.0.0/8", "hops=-1"},
+		true,
+	}, {
+		"only hops",
+		[]interface{}{"hops=1"},
+		true,
+	}, {
+		"networks",
+		[]interface{}{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"},
+		false,
+	}, {
+		"networks, file and hops",
+		[]interface{}{"10.0.0.0/8", "file=" + file, "hops=2"},
+		false,
+	}} {
+		for _, s := range []filters.Spec{NewAllowSource(), NewDenySource()} {
+			_, err := s.CreateFilter(ti.args)
+			if ti.err && err == nil {
+				t.Error(ti.msg, s.Name(), "failed to fail")
+			} else if !ti.err && err != nil {
+				t.Error(ti.msg, s.Name(), err)
+			}
+		}
+	}
+}
+
+func TestAllowDeny(t *testing.T) {
+	file := tempFile(t, "172.16.0.0/12\n2001:db8::/32\n")
+	defer os.Remove(file)
+
+	args := []interface{}{"10.0.0.0/8", "192.168.1.1", "file=" + file}
+	allow, err := NewAllowSource().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deny, err := NewDenySource().CreateFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		remoteAddr string
+		xff        string
+		inside     bool
+	}{
+		{"10.1.2.3:9090", "", true},
+		{"192.168.1.1:9090", "", true},
+		{"192.168.1.2:9090", "", false},
+		{"172.20.0.1:9090", "", true},
+		{"[2001:db8::1]:9090", "", true},
+		{"[2001:db9::1]:9090", "", false},
+		{"8.8.8.8:9090", "10.1.2.3", false},
+		{"10.1.2.3:9090", "8.8.8.8, 10.0.0.1", true},
+	} {
+		if s := served(t, allow, ti.remoteAddr, ti.xff); s == ti.inside {
+			t.Error("allowSource: unexpected result", ti.remoteAddr, ti.xff, s)
+		}
+
+		if s := served(t, deny, ti.remoteAddr, ti.xff); s != ti.inside {
+			t.Error("denySource: unexpected result", ti.remoteAddr, ti.xff, s)
+		}
+	}
+}
+
+func TestSpoofedForwardedFor(t *testing.T) {
+	allow, err := NewAllowSource().CreateFilter([]interface{}{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !served(t, allow, "8.8.8.8:9090", "10.0.0.1") {
+		t.Error("failed to reject the spoofed address")
+	}
+}
+
+func TestTrustedHops(t *testing.T) {
+	allow, err := NewAllowSource().CreateFilter([]interface{}{"10.0.0.0/8", "hops=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first address is set by the client
+	if !served(t, allow, "192.168.0.1:9090", "10.0.0.1, 8.8.8.8") {
+		t.Error("failed to reject the spoofed address")
+	}
+
+	if served(t, allow, "192.168.0.1:9090", "8.8.8.8, 10.0.0.1") {
+		t.Error("failed to accept the address set by the trusted proxy")
+	}
+
+	direct, err := NewAllowSource().CreateFilter([]interface{}{"10.0.0.0/8", "hops=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !served(t, direct, "192.168.0.1:9090", "10.0.0.1") {
+		t.Error("failed to ignore the header")
+	}
+
+	if served(t, direct, "10.0.0.1:9090", "8.8.8.8") {
+		t.Error("failed to use the remote address")
+	}
+}
+
+func TestReload(t *testing.T) {
+	file := tempFile(t, "10.0.0.0/8\n")
+	defer os.Remove(file)
+
+	spec := NewDenySource().(*spec)
+	f, err := spec.CreateFilter([]interface{}{"file=" + file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := spec.CreateFilter([]interface{}{"file=" + file, "hops=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.(*filter).files[0] != f2.(*filter).files[0] {
+		t.Error("failed to share the file")
+	}
+
+	if served(t, f, "172.16.0.1:9090", "") {
+		t.Fatal("unexpectedly rejected")
+	}
+
+	writeFile(t, file, "10.0.0.0/8\n172.16.0.0/12\n", time.Now())
+	cf := f.(*filter).files[0]
+
+	// skip waiting for the check interval, and wait for the background
+	// reload
+	reloaded := func() bool {
+		cf.mx.Lock()
+		cf.checked = time.Time{}
+		cf.mx.Unlock()
+
+		for i := 0; i < 100; i++ {
+			cf.current()
+			cf.mx.Lock()
+			reloading := cf.reloading
+			cf.mx.Unlock()
+			if !reloading {
+				return true
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		return false
+	}
+
+	if !reloaded() {
+		t.Fatal("failed to reload the file")
+	}
+
+	if !served(t, f, "172.16.0.1:9090", "") {
+		t.Error("failed to use the reloaded networks")
+	}
+
+	// invalid files are ignored, and the previous networks are kept
+	writeFile(t, file, "foo\n", time.Now().Add(time.Minute))
+	if !reloaded() {
+		t.Fatal("failed to reload the file")
+	}
+
+	if !served(t, f, "172.16.0.1:9090", "") {
+		t.Error("failed to keep the previous networks")
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Package loadbalancer implements the selection of the endpoint for the
+routes whose backend consists of multiple endpoints.
+
+Multiple endpoints can be defined for a route by listing their addresses
+separated by commas in the backend address:
+
+    * -> "http://10.2.0.1:8080,http://10.2.0.2:8080,http://10.2.0.3:8080"
+
+By default, the proxy selects a random endpoint for every request.
+Filters can request a sticky endpoint, by setting its opaque id (see
+EndpointID) in the state bag with the StickyEndpointKey, or a key for
+consistent hashing, with the HashKey. In the first case, the endpoint with
+the given id is used, if it is still one of the endpoints of the route,
+and it is healthy. In the second case, the endpoint is selected by
+rendezvous hashing, so that the same key is mapped to the same endpoint
+as long as it is healthy, and when the endpoints change, only the keys of
+the removed endpoints are mapped to a different one. The proxy stores the
+id of the selected endpoint in the state bag with the SelectedEndpointKey,
+so that the filters can respond with it, e.g. in a cookie. The endpoints of
+the route, and the selected endpoint itself, are stored with the
+EndpointsKey and the EndpointKey, so that the filters don't need to parse
+the backend address.
+
+When the connection to an endpoint fails, the proxy marks it unhealthy for
+a quarantine period. During this period, the endpoint is selected only
+when none of the endpoints of the route are healthy.
+*/
+package loadbalancer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// State bag key used by the filters to request a sticky endpoint by
+	// its id.
+	StickyEndpointKey = "loadbalancer:sticky-endpoint"
+
+	// State bag key used by the filters to set the key for selecting the
+	// endpoint by consistent hashing.
+	HashKey = "loadbalancer:hash-key"
+
+	// State bag key used by the proxy to store the id of the selected
+	// endpoint.
+	SelectedEndpointKey = "loadbalancer:selected-endpoint"
+
+	// State bag key used by the proxy to store the endpoints of a route
+	// with multiple endpoints, as []routing.LBEndpoint, so that the
+	// filters don't need to parse the backend address.
+	EndpointsKey = "loadbalancer:endpoints"
+
+	// State bag key used by the proxy to store the endpoint used for the
+	// backend request, as routing.LBEndpoint. It is set after the request
+	// filters, so it is available only for the response filters.
+	EndpointKey = "loadbalancer:endpoint"
+
+	// The default period during which an endpoint is considered
+	// unhealthy after a failed connection.
+	DefaultQuarantine = 10 * time.Second
+)
+
+// Health tracks the endpoints with failed connections.
+type Health struct {
+	quarantine time.Duration
+	mx         sync.Mutex
+	failed     map[string]time.Time
+}
+
+// EndpointID returns the opaque id of an endpoint, that doesn't reveal
+// its address.
+func EndpointID(e routing.LBEndpoint) string {
+	sum := sha256.Sum256([]byte(e.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewHealth creates an object to track the health of the endpoints. When
+// quarantine is not positive, DefaultQuarantine is used.
+func NewHealth(quarantine time.Duration) *Health {
+	if quarantine <= 0 {
+		quarantine = DefaultQuarantine
+	}
+
+	return &Health{quarantine: quarantine, failed: make(map[string]time.Time)}
+}
+
+// Failed marks an endpoint unhealthy for the quarantine period. The
+// expired entries are removed at the same time, including those of the
+// endpoints that were removed from the routes, and so are never checked
+// again.
+func (h *Health) Failed(e routing.LBEndpoint) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	now := time.Now()
+	for a, until := range h.failed {
+		if !now.Before(until) {
+			delete(h.failed, a)
+		}
+	}
+
+	h.failed[e.String()] = now.Add(h.quarantine)
+}
+
+// Healthy tells whether an endpoint is healthy. A nil object reports
+// every endpoint healthy.
+func (h *Health) Healthy(e routing.LBEndpoint) bool {
+	if h == nil {
+		return true
+	}
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	a := e.String()
+	until, ok := h.failed[a]
+	if !ok {
+		return true
+	}
+
+	if time.Now().Before(until) {
+		return false
+	}
+
+	delete(h.failed, a)
+	return true
+}
+
+func hashScore(key string, e routing.LBEndpoint) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(e.String()))
+	return h.Sum64()
+}
+
+func consistentHash(endpoints []routing.LBEndpoint, key string) routing.LBEndpoint {
+	var (
+		selected routing.LBEndpoint
+		max      uint64
+	)
+
+	for i, e := range endpoints {
+		if s := hashScore(key, e); i == 0 || s > max {
+			selected, max = e, s
+		}
+	}
+
+	return selected
+}
+
+// Select selects one of the endpoints, based on the sticky endpoint id or
+// the hash key in the state bag, and the health of the endpoints, and
+// stores the id of the selected endpoint in the state bag. The list of
+// endpoints must not be empty.
+func Select(endpoints []routing.LBEndpoint, h *Health, stateBag map[string]interface{}) routing.LBEndpoint {
+	var healthy []routing.LBEndpoint
+	for _, e := range endpoints {
+		if h.Healthy(e) {
+			healthy = append(healthy, e)
+		}
+	}
+
+	if len(healthy) == 0 {
+		healthy = endpoints
+	}
+
+	selected := healthy[rand.Intn(len(healthy))]
+	if key, ok := stateBag[HashKey].(string); ok && key != "" {
+		selected = consistentHash(healthy, key)
+	}
+
+	if id, ok := stateBag[StickyEndpointKey].(string); ok && id != "" {
+		for _, e := range healthy {
+			if EndpointID(e) == id {
+				selected = e
+				break
+			}
+		}
+	}
+
+	stateBag[SelectedEndpointKey] = EndpointID(selected)
+	return selected
+}
@@ -0,0 +1,153 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func testEndpoints(n int) []routing.LBEndpoint {
+	e := make([]routing.LBEndpoint, n)
+	for i := range e {
+		e[i] = routing.LBEndpoint{Scheme: "http", Host: fmt.Sprintf("10.0.0.%d:8080", i)}
+	}
+
+	return e
+}
+
+func TestEndpointID(t *testing.T) {
+	e := testEndpoints(2)
+	if EndpointID(e[0]) == EndpointID(e[1]) {
+		t.Error("endpoint ids are not unique")
+	}
+
+	if EndpointID(e[0]) != EndpointID(routing.LBEndpoint{Scheme: "http", Host: "10.0.0.0:8080"}) {
+		t.Error("endpoint id is not stable")
+	}
+}
+
+func TestSelectRandom(t *testing.T) {
+	e := testEndpoints(3)
+	counts := make(map[routing.LBEndpoint]int)
+	for i := 0; i < 300; i++ {
+		sb := make(map[string]interface{})
+		s := Select(e, nil, sb)
+		if sb[SelectedEndpointKey] != EndpointID(s) {
+			t.Error("selected endpoint not stored")
+		}
+
+		counts[s]++
+	}
+
+	if len(counts) != len(e) {
+		t.Error("failed to select all endpoints", counts)
+	}
+}
+
+func TestSelectSticky(t *testing.T) {
+	e := testEndpoints(3)
+	for i := 0; i < 30; i++ {
+		s := Select(e, nil, map[string]interface{}{StickyEndpointKey: EndpointID(e[1])})
+		if s != e[1] {
+			t.Error("failed to select sticky endpoint", s)
+		}
+	}
+}
+
+func TestSelectStickyFallback(t *testing.T) {
+	e := testEndpoints(3)
+	gone := routing.LBEndpoint{Scheme: "http", Host: "10.0.0.9:8080"}
+	sb := map[string]interface{}{StickyEndpointKey: EndpointID(gone)}
+	s := Select(e, nil, sb)
+	if s == gone || sb[SelectedEndpointKey] == EndpointID(gone) {
+		t.Error("failed to fall back")
+	}
+
+	h := NewHealth(time.Hour)
+	h.Failed(e[1])
+	for i := 0; i < 30; i++ {
+		if s := Select(e, h, map[string]interface{}{StickyEndpointKey: EndpointID(e[1])}); s == e[1] {
+			t.Error("unhealthy endpoint selected")
+		}
+	}
+}
+
+func TestSelectConsistentHash(t *testing.T) {
+	e := testEndpoints(5)
+	selected := make(map[string]routing.LBEndpoint)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		selected[key] = Select(e, nil, map[string]interface{}{HashKey: key})
+		if s := Select(e, nil, map[string]interface{}{HashKey: key}); s != selected[key] {
+			t.Error("consistent hash is not stable", key)
+		}
+	}
+
+	// removing an endpoint, only its keys get remapped
+	removed := e[2]
+	reduced := append(append([]routing.LBEndpoint{}, e[:2]...), e[3:]...)
+	for key, previous := range selected {
+		s := Select(reduced, nil, map[string]interface{}{HashKey: key})
+		if previous != removed && s != previous {
+			t.Error("key remapped unnecessarily", key)
+		}
+	}
+
+	// unhealthy endpoints are skipped
+	h := NewHealth(time.Hour)
+	h.Failed(removed)
+	for key, previous := range selected {
+		s := Select(e, h, map[string]interface{}{HashKey: key})
+		if s == removed || previous != removed && s != previous {
+			t.Error("failed to skip unhealthy endpoint", key)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	e := testEndpoints(1)[0]
+	h := NewHealth(30 * time.Millisecond)
+	if !h.Healthy(e) {
+		t.Error("unknown endpoint should be healthy")
+	}
+
+	h.Failed(e)
+	if h.Healthy(e) {
+		t.Error("failed endpoint should be unhealthy")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !h.Healthy(e) {
+		t.Error("endpoint should be healthy after the quarantine")
+	}
+}
+
+func TestHealthRemovesExpired(t *testing.T) {
+	e := testEndpoints(2)
+	h := NewHealth(30 * time.Millisecond)
+
+	// the first endpoint is not checked again, as if it was removed from
+	// the routes
+	h.Failed(e[0])
+	time.Sleep(60 * time.Millisecond)
+	h.Failed(e[1])
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if _, ok := h.failed[e[0].String()]; ok || len(h.failed) != 1 {
+		t.Error("failed to remove the expired entry", h.failed)
+	}
+}
+
+func TestAllUnhealthy(t *testing.T) {
+	e := testEndpoints(2)
+	h := NewHealth(time.Hour)
+	h.Failed(e[0])
+	h.Failed(e[1])
+	s := Select(e, h, make(map[string]interface{}))
+	if s != e[0] && s != e[1] {
+		t.Error("failed to select endpoint")
+	}
+}
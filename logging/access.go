@@ -5,14 +5,15 @@ import (
 	"github.com/Sirupsen/logrus"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
 const (
 	dateFormat      = "02/Jan/2006:15:04:05 -0700"
-	commonLogFormat = `%s - - [%s] "%s %s %s" %d %d`
+	commonLogFormat = `%s - %s [%s] "%s %s %s" %d %d`
 	// format:
-	// remote_host - - [date] "method uri protocol" status response_size "referer" "user_agent"
+	// remote_host - auth_user [date] "method uri protocol" status response_size "referer" "user_agent"
 	combinedLogFormat = commonLogFormat + ` "%s" "%s"`
 	// We add the duration in ms and a requested host
 	accessLogFormat = combinedLogFormat + " %d %s\n"
@@ -39,6 +40,13 @@ type AccessEntry struct {
 
 	// The time that the request was received.
 	RequestTime time.Time
+
+	// The authenticated user, as set by the filters with SetAuthUser.
+	AuthUser string
+
+	// The flow id of the request, as set by the flowId filter with
+	// SetFlowId.
+	FlowId string
 }
 
 var accessLog *logrus.Logger
@@ -75,7 +83,7 @@ func remoteHost(r *http.Request) string {
 
 func (f *accessLogFormatter) Format(e *logrus.Entry) ([]byte, error) {
 	keys := []string{
-		"host", "timestamp", "method", "uri", "proto",
+		"host", "auth-user", "timestamp", "method", "uri", "proto",
 		"status", "response-size", "referer", "user-agent",
 		"duration", "requested-host"}
 
@@ -84,7 +92,15 @@ func (f *accessLogFormatter) Format(e *logrus.Entry) ([]byte, error) {
 		values[i] = e.Data[key]
 	}
 
-	return []byte(fmt.Sprintf(f.format, values...)), nil
+	line := fmt.Sprintf(f.format, values...)
+
+	// the flow id is appended only when set, to keep the format of the
+	// lines unchanged for the routes without the flowId filter
+	if flowId, ok := e.Data["flow-id"].(string); ok && flowId != "" {
+		line = strings.TrimSuffix(line, "\n") + " " + flowId + "\n"
+	}
+
+	return []byte(line), nil
 }
 
 // Logs an access event in Apache combined log format (with a minor customization with the duration).
@@ -96,6 +112,7 @@ func LogAccess(entry *AccessEntry) {
 	ts := entry.RequestTime.Format(dateFormat)
 
 	host := "-"
+	authUser := "-"
 	method := ""
 	uri := ""
 	proto := ""
@@ -107,6 +124,10 @@ func LogAccess(entry *AccessEntry) {
 	responseSize := entry.ResponseSize
 	duration := int64(entry.Duration / time.Millisecond)
 
+	if entry.AuthUser != "" {
+		authUser = entry.AuthUser
+	}
+
 	if entry.Request != nil {
 		host = remoteHost(entry.Request)
 		method = entry.Request.Method
@@ -120,6 +141,7 @@ func LogAccess(entry *AccessEntry) {
 	accessLog.WithFields(logrus.Fields{
 		"timestamp":      ts,
 		"host":           host,
+		"auth-user":      authUser,
 		"method":         method,
 		"uri":            uri,
 		"proto":          proto,
@@ -129,5 +151,6 @@ func LogAccess(entry *AccessEntry) {
 		"response-size":  responseSize,
 		"requested-host": requestedHost,
 		"duration":       duration,
+		"flow-id":        entry.FlowId,
 	}).Infoln()
 }
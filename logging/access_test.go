@@ -83,3 +83,15 @@ func TestMissingHostFallback(t *testing.T) {
 	entry.Request.RemoteAddr = ""
 	testAccessLog(t, entry, `- - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "" "" 42 example.com`)
 }
+
+func TestAuthUser(t *testing.T) {
+	entry := testAccessEntry()
+	entry.AuthUser = "jdoe"
+	testAccessLog(t, entry, `127.0.0.1 - jdoe [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "" "" 42 example.com`)
+}
+
+func TestFlowId(t *testing.T) {
+	entry := testAccessEntry()
+	entry.FlowId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testAccessLog(t, entry, `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "" "" 42 example.com 4bf92f3577b34da6a3ce929d0e0e4736`)
+}
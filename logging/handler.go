@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"net/http"
 	"time"
 )
@@ -10,6 +11,15 @@ type loggingHandler struct {
 	proxy http.Handler
 }
 
+// request specific access log information, that can be set during the
+// request processing
+type accessInfo struct {
+	authUser string
+	flowId   string
+}
+
+type accessInfoKey struct{}
+
 // Creates an http.Handler that provides access log
 // for the underlying handler.
 func NewHandler(next http.Handler) http.Handler {
@@ -19,6 +29,9 @@ func NewHandler(next http.Handler) http.Handler {
 func (lh *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
 
+	info := &accessInfo{}
+	r = r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, info))
+
 	lw := &loggingWriter{writer: w}
 	lh.proxy.ServeHTTP(lw, r)
 
@@ -30,6 +43,26 @@ func (lh *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		StatusCode:   lw.code,
 		RequestTime:  now,
 		Duration:     dur,
+		AuthUser:     info.authUser,
+		FlowId:       info.flowId,
 	}
 	LogAccess(entry)
 }
+
+// SetAuthUser sets the name of the authenticated user, to be logged in the
+// access log entry of the request. It has no effect when the request is
+// not served through the logging handler.
+func SetAuthUser(r *http.Request, user string) {
+	if info, ok := r.Context().Value(accessInfoKey{}).(*accessInfo); ok {
+		info.authUser = user
+	}
+}
+
+// SetFlowId sets the flow id of the request, to be logged in the access
+// log entry of the request. It has no effect when the request is not
+// served through the logging handler.
+func SetFlowId(r *http.Request, flowId string) {
+	if info, ok := r.Context().Value(accessInfoKey{}).(*accessInfo); ok {
+		info.flowId = flowId
+	}
+}
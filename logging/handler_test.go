@@ -48,3 +48,37 @@ func TestLogsAccess(t *testing.T) {
 		t.Error("failed to log access")
 	}
 }
+
+func TestLogsAuthUser(t *testing.T) {
+	var accessLog bytes.Buffer
+	Init(Options{AccessLogOutput: &accessLog})
+
+	innerHandler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		SetAuthUser(r, "jdoe")
+	})
+	h := NewHandler(innerHandler)
+
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+
+	output := accessLog.String()
+	if !strings.HasPrefix(output, "- - jdoe [") {
+		t.Error("failed to log the authenticated user", output)
+	}
+}
+
+func TestLogsFlowId(t *testing.T) {
+	var accessLog bytes.Buffer
+	Init(Options{AccessLogOutput: &accessLog})
+
+	innerHandler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		SetFlowId(r, "flow-id-for-testing")
+	})
+	h := NewHandler(innerHandler)
+
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+
+	output := accessLog.String()
+	if !strings.HasSuffix(output, " flow-id-for-testing\n") {
+		t.Error("failed to log the flow id", output)
+	}
+}
@@ -0,0 +1,47 @@
+package net
+
+import (
+	"crypto/x509"
+	"net/http"
+	"regexp"
+)
+
+// The verified client certificate of a request, or nil, when the client
+// didn't send a certificate, or it was not verified.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// The subject alternative names of a certificate: the DNS names, the email
+// addresses and the IP addresses.
+func SubjectAlternativeNames(c *x509.Certificate) []string {
+	var names []string
+	names = append(names, c.DNSNames...)
+	names = append(names, c.EmailAddresses...)
+	for _, ip := range c.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	return names
+}
+
+// Checks whether a regular expression matches the subject of a
+// certificate, in the RFC 2253 format returned by pkix.Name.String(), or
+// one of its subject alternative names.
+func CertificateMatches(c *x509.Certificate, rx *regexp.Regexp) bool {
+	if rx.MatchString(c.Subject.String()) {
+		return true
+	}
+
+	for _, n := range SubjectAlternativeNames(c) {
+		if rx.MatchString(n) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package net
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func testCertificate() *x509.Certificate {
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "client, primary",
+			Organization:       []string{"Example"},
+			OrganizationalUnit: []string{"Engineering"},
+			Country:            []string{"DE"},
+		},
+		DNSNames:       []string{"client.services.example.org"},
+		EmailAddresses: []string{"client@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	r := &http.Request{}
+	if ClientCertificate(r) != nil {
+		t.Error("unexpected certificate without TLS")
+	}
+
+	r.TLS = &tls.ConnectionState{}
+	if ClientCertificate(r) != nil {
+		t.Error("unexpected certificate without verified chains")
+	}
+
+	c := testCertificate()
+	r.TLS.VerifiedChains = [][]*x509.Certificate{{c, {}}}
+	if ClientCertificate(r) != c {
+		t.Error("failed to return the leaf certificate")
+	}
+}
+
+func TestCertificateMatches(t *testing.T) {
+	c := testCertificate()
+	for _, ti := range []struct {
+		expr  string
+		match bool
+	}{
+		{`^CN=client\\, primary,OU=Engineering,O=Example,C=DE$`, true},
+		{`^CN=(?:[^,\\]|\\.)+,OU=Engineering,O=Example,C=DE$`, true},
+		{`^CN=(?:[^,\\]|\\.)+,OU=Marketing,O=Example,C=DE$`, false},
+		{"[.]services[.]example[.]org$", true},
+		{"^client@example[.]org$", true},
+		{"^10[.]0[.]0[.]1$", true},
+		{"^10[.]0[.]0[.]2$", false},
+	} {
+		if CertificateMatches(c, regexp.MustCompile(ti.expr)) != ti.match {
+			t.Error("unexpected match result", ti.expr, ti.match)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var errInvalidCIDR = errors.New("invalid CIDR")
+
+type cidrNode struct {
+	children [2]*cidrNode
+	leaf     bool
+}
+
+// CIDRSet is a set of IPv4 and IPv6 networks, that can check whether it
+// contains an address in a time independent from the number of the
+// networks. The networks are stored in binary prefix trees.
+type CIDRSet struct {
+	v4, v6 *cidrNode
+	count  int
+}
+
+// ParseCIDR parses a network in CIDR notation, or a single IPv4 or IPv6
+// address.
+func ParseCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errInvalidCIDR
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, errInvalidCIDR
+	}
+
+	return n, nil
+}
+
+// ParseCIDRSet creates a CIDRSet from a list of networks in CIDR notation,
+// or single addresses.
+func ParseCIDRSet(cidrs []string) (*CIDRSet, error) {
+	s := NewCIDRSet()
+	for _, c := range cidrs {
+		n, err := ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Add(n)
+	}
+
+	return s, nil
+}
+
+// NewCIDRSet creates an empty CIDRSet.
+func NewCIDRSet() *CIDRSet {
+	return &CIDRSet{v4: &cidrNode{}, v6: &cidrNode{}}
+}
+
+func bit(ip []byte, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
+
+func (node *cidrNode) add(ip []byte, ones int) {
+	for i := 0; i < ones; i++ {
+		if node.leaf {
+			// already covered by a wider network
+			return
+		}
+
+		b := bit(ip, i)
+		if node.children[b] == nil {
+			node.children[b] = &cidrNode{}
+		}
+
+		node = node.children[b]
+	}
+
+	node.leaf = true
+	node.children = [2]*cidrNode{}
+}
+
+// Add adds a network to the set. The IPv4 networks mapped to IPv6, e.g.
+// ::ffff:10.0.0.0/104, are stored as IPv4 networks, because the IPv4
+// addresses are checked as IPv4 addresses.
+func (s *CIDRSet) Add(n *net.IPNet) {
+	ones, bits := n.Mask.Size()
+	ip4 := n.IP.To4()
+	switch {
+	case ip4 != nil && bits == 8*net.IPv4len:
+		s.v4.add(ip4, ones)
+	case ip4 != nil && bits == 8*net.IPv6len && ones >= 96:
+		s.v4.add(ip4, ones-96)
+	case n.IP.To16() != nil && bits == 8*net.IPv6len:
+		s.v6.add(n.IP.To16(), ones)
+
+		// a wider IPv6 network can contain all the mapped addresses
+		if n.IP.Mask(n.Mask).Equal(net.IPv4zero.Mask(n.Mask)) {
+			s.v4.add(nil, 0)
+		}
+	default:
+		return
+	}
+
+	s.count++
+}
+
+// Contains checks whether an address is in one of the networks of the
+// set. IPv4 addresses mapped to IPv6 are checked as IPv4 addresses.
+func (s *CIDRSet) Contains(ip net.IP) bool {
+	if s == nil || ip == nil {
+		return false
+	}
+
+	node, a := s.v6, ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		node, a = s.v4, ip4
+	}
+
+	if a == nil {
+		return false
+	}
+
+	for i := 0; i < len(a)*8; i++ {
+		if node.leaf {
+			return true
+		}
+
+		node = node.children[bit(a, i)]
+		if node == nil {
+			return false
+		}
+	}
+
+	return node.leaf
+}
+
+// Len returns the number of the networks added to the set.
+func (s *CIDRSet) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	return s.count
+}
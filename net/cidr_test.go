@@ -0,0 +1,110 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestCIDRSet(t *testing.T) {
+	s, err := ParseCIDRSet([]string{
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"192.168.1.1",
+		"172.16.0.0/12",
+		"2001:db8::/32",
+		"::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Len() != 6 {
+		t.Error("invalid number of networks", s.Len())
+	}
+
+	for _, test := range []struct {
+		ip       string
+		contains bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", true},
+		{"10.255.255.255", true},
+		{"11.0.0.1", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"172.31.255.255", true},
+		{"172.32.0.0", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::1", true},
+		{"::2", false},
+	} {
+		if c := s.Contains(net.ParseIP(test.ip)); c != test.contains {
+			t.Error("unexpected result", test.ip, c, test.contains)
+		}
+	}
+
+	if s.Contains(nil) {
+		t.Error("unexpectedly contains nil")
+	}
+}
+
+func TestCIDRSetMappedIPv4(t *testing.T) {
+	s, err := ParseCIDRSet([]string{"::ffff:10.0.0.0/104", "::ffff:192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		ip       string
+		contains bool
+	}{
+		{"10.0.0.1", true},
+		{"::ffff:10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"::1", false},
+	} {
+		if c := s.Contains(net.ParseIP(test.ip)); c != test.contains {
+			t.Error("unexpected result", test.ip, c, test.contains)
+		}
+	}
+
+	all, err := ParseCIDRSet([]string{"::/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !all.Contains(net.ParseIP("10.0.0.1")) || !all.Contains(net.ParseIP("2001:db8::1")) {
+		t.Error("failed to contain all the addresses")
+	}
+}
+
+func TestCIDRSetInvalid(t *testing.T) {
+	for _, c := range []string{"", "foo", "10.0.0.0/33", "10.0.0.256"} {
+		if _, err := ParseCIDRSet([]string{c}); err == nil {
+			t.Error("failed to fail", c)
+		}
+	}
+}
+
+func BenchmarkCIDRSet(b *testing.B) {
+	var cidrs []string
+	for i := 0; i < 1<<16; i++ {
+		cidrs = append(cidrs, fmt.Sprintf("%d.%d.%d.0/24", 10+i>>12, i>>4&0xff, i&0xf<<4))
+	}
+
+	s, err := ParseCIDRSet(cidrs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ip := net.ParseIP("20.1.2.3")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Contains(ip)
+	}
+}
@@ -33,3 +33,33 @@ func RemoteHost(r *http.Request) net.IP {
 
 	return parse(r.RemoteAddr)
 }
+
+// The remote address of the client, when skipper is behind the given
+// number of trusted proxies, that append the address of their clients
+// to the 'X-Forwarded-For' header. The address is taken from the
+// header at the position of the first trusted proxy, counted from the
+// end, while the addresses before it, set by the clients, are ignored.
+// When the header has fewer addresses, the first one is used. With zero
+// trusted proxies, or without the header, the remote address of the
+// connection is used.
+func RemoteHostTrusted(r *http.Request, trustedProxies int) net.IP {
+	var ff []string
+	for _, h := range r.Header["X-Forwarded-For"] {
+		for _, a := range strings.Split(h, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				ff = append(ff, a)
+			}
+		}
+	}
+
+	if trustedProxies <= 0 || len(ff) == 0 {
+		return parse(r.RemoteAddr)
+	}
+
+	i := len(ff) - trustedProxies
+	if i < 0 {
+		i = 0
+	}
+
+	return parse(ff[i])
+}
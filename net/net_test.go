@@ -44,3 +44,52 @@ func BenchmarkRemoteHost(b *testing.B) {
 		RemoteHost(r)
 	}
 }
+
+func TestRemoteHostTrusted(t *testing.T) {
+	for _, test := range []struct {
+		msg     string
+		fwdHdr  []string
+		proxies int
+		want    net.IP
+	}{{
+		"no trusted proxies",
+		[]string{"1.2.3.4"},
+		0,
+		net.IPv4(127, 0, 0, 1),
+	}, {
+		"no header",
+		nil,
+		1,
+		net.IPv4(127, 0, 0, 1),
+	}, {
+		"one trusted proxy",
+		[]string{"1.2.3.4, 5.6.7.8"},
+		1,
+		net.IPv4(5, 6, 7, 8),
+	}, {
+		"two trusted proxies",
+		[]string{"1.2.3.4, 5.6.7.8, 10.0.0.1"},
+		2,
+		net.IPv4(5, 6, 7, 8),
+	}, {
+		"multiple headers",
+		[]string{"1.2.3.4", "5.6.7.8, 10.0.0.1"},
+		2,
+		net.IPv4(5, 6, 7, 8),
+	}, {
+		"fewer addresses than proxies",
+		[]string{"1.2.3.4, 5.6.7.8"},
+		3,
+		net.IPv4(1, 2, 3, 4),
+	}, {
+		"ipv6",
+		[]string{"1.2.3.4, 2001:4860:0:2001::68"},
+		1,
+		net.ParseIP("2001:4860:0:2001::68"),
+	}} {
+		r := &http.Request{RemoteAddr: "127.0.0.1:9090", Header: http.Header{"X-Forwarded-For": test.fwdHdr}}
+		if got := RemoteHostTrusted(r, test.proxies); !got.Equal(test.want) {
+			t.Errorf("%s: unexpected IP address '%v', wanted '%v'", test.msg, got, test.want)
+		}
+	}
+}
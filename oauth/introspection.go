@@ -0,0 +1,276 @@
+// Copyright 2015 Zalando SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The default timeout of the introspection requests.
+	DefaultIntrospectionTimeout = 2 * time.Second
+
+	// The default maximum number of cached introspection results.
+	DefaultIntrospectionCacheSize = 1 << 14
+)
+
+// ErrInactiveToken is returned by the introspection when the token is not
+// active, e.g. it has expired, was revoked or is unknown.
+var ErrInactiveToken = errors.New("inactive token")
+
+// IntrospectionUnavailableError is returned by the introspection when the
+// introspection endpoint cannot be reached, e.g. on connection errors or
+// timeouts.
+type IntrospectionUnavailableError struct {
+	Err error
+}
+
+func (e *IntrospectionUnavailableError) Error() string {
+	return fmt.Sprintf("token introspection unavailable: %v", e.Err)
+}
+
+// TokenInfo contains the response of the introspection endpoint, with the
+// claims of the token.
+type TokenInfo map[string]interface{}
+
+// IntrospectionOptions are used to configure an IntrospectionClient.
+type IntrospectionOptions struct {
+
+	// Optional directory containing the client.json credentials
+	// document. When set, the client authenticates with the
+	// introspection endpoint using HTTP basic auth.
+	CredentialsDir string
+
+	// The timeout of the introspection requests. Defaults to
+	// DefaultIntrospectionTimeout.
+	Timeout time.Duration
+
+	// The maximum number of cached results. Defaults to
+	// DefaultIntrospectionCacheSize.
+	CacheSize int
+}
+
+type cachedTokenInfo struct {
+	info    TokenInfo
+	expires time.Time
+}
+
+// IntrospectionClient implements the client side of the OAuth2 token
+// introspection, as defined in RFC 7662. The results for the active
+// tokens are cached until the tokens expire.
+type IntrospectionClient struct {
+	url        string
+	oauth      *OAuthClient
+	httpClient *http.Client
+	cacheSize  int
+	mx         sync.Mutex
+	cache      map[string]cachedTokenInfo
+}
+
+// Initializes a new IntrospectionClient.
+func NewIntrospectionClient(introspectionUrl string, o IntrospectionOptions) *IntrospectionClient {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultIntrospectionTimeout
+	}
+
+	if o.CacheSize <= 0 {
+		o.CacheSize = DefaultIntrospectionCacheSize
+	}
+
+	var oc *OAuthClient
+	if o.CredentialsDir != "" {
+		oc = New(o.CredentialsDir, introspectionUrl, "")
+	}
+
+	return &IntrospectionClient{
+		url:        introspectionUrl,
+		oauth:      oc,
+		httpClient: &http.Client{Timeout: o.Timeout},
+		cacheSize:  o.CacheSize,
+		cache:      make(map[string]cachedTokenInfo)}
+}
+
+// Scopes returns the scopes of the token. The scope claim is a space
+// separated list according to RFC 7662, but some tokeninfo services
+// respond with a list.
+func (ti TokenInfo) Scopes() []string {
+	switch s := ti["scope"].(type) {
+	case string:
+		return strings.Fields(s)
+	case []interface{}:
+		var scopes []string
+		for _, si := range s {
+			if ss, ok := si.(string); ok {
+				scopes = append(scopes, ss)
+			}
+		}
+
+		return scopes
+	default:
+		return nil
+	}
+}
+
+// the map is copied, so that the callers cannot change the cached
+// results
+func (ti TokenInfo) copy() TokenInfo {
+	c := make(TokenInfo, len(ti))
+	for k, v := range ti {
+		c[k] = v
+	}
+
+	return c
+}
+
+// the expiration of the token, taken from the exp claim or, for the
+// tokeninfo services, from the expires_in field
+func (ti TokenInfo) expiration(now time.Time) (time.Time, bool) {
+	if exp, ok := ti["exp"].(float64); ok {
+		return time.Unix(int64(exp), 0), true
+	}
+
+	if expiresIn, ok := ti["expires_in"].(float64); ok {
+		return now.Add(time.Duration(expiresIn) * time.Second), true
+	}
+
+	return time.Time{}, false
+}
+
+func (ic *IntrospectionClient) fromCache(token string, now time.Time) (TokenInfo, bool) {
+	ic.mx.Lock()
+	defer ic.mx.Unlock()
+
+	c, ok := ic.cache[token]
+	if !ok {
+		return nil, false
+	}
+
+	if !now.Before(c.expires) {
+		delete(ic.cache, token)
+		return nil, false
+	}
+
+	return c.info, true
+}
+
+func (ic *IntrospectionClient) store(token string, info TokenInfo, expires time.Time, now time.Time) {
+	ic.mx.Lock()
+	defer ic.mx.Unlock()
+
+	if len(ic.cache) >= ic.cacheSize {
+		for t, c := range ic.cache {
+			if !now.Before(c.expires) {
+				delete(ic.cache, t)
+			}
+		}
+	}
+
+	// when the cache is still full, an arbitrary entry is dropped
+	if len(ic.cache) >= ic.cacheSize {
+		for t := range ic.cache {
+			delete(ic.cache, t)
+			break
+		}
+	}
+
+	ic.cache[token] = cachedTokenInfo{info: info, expires: expires}
+}
+
+func (ic *IntrospectionClient) request(token string) (TokenInfo, error) {
+	body := url.Values{"token": []string{token}}.Encode()
+	req, err := http.NewRequest("POST", ic.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if ic.oauth != nil {
+		cc, err := ic.oauth.getClientCredentials()
+		if err != nil {
+			return nil, err
+		}
+
+		req.SetBasicAuth(cc.Id, cc.Secret)
+	}
+
+	rsp, err := ic.httpClient.Do(req)
+	if err != nil {
+		return nil, &IntrospectionUnavailableError{Err: err}
+	}
+
+	defer rsp.Body.Close()
+
+	switch {
+	case rsp.StatusCode == http.StatusOK:
+	case ic.oauth == nil && (rsp.StatusCode == http.StatusUnauthorized || rsp.StatusCode == http.StatusNotFound):
+		// tokeninfo services respond with these to unknown tokens, while
+		// with client credentials, as in RFC 7662, these statuses mean
+		// that the credentials of skipper were rejected, or the endpoint
+		// is misconfigured
+		return nil, ErrInactiveToken
+	default:
+		return nil, fmt.Errorf("token introspection failed, unexpected status: %d", rsp.StatusCode)
+	}
+
+	var info TokenInfo
+	if err := json.NewDecoder(rsp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	// tokeninfo services don't set the active field, but they respond
+	// only to active tokens
+	if active, ok := info["active"]; ok && active != true {
+		return nil, ErrInactiveToken
+	}
+
+	return info, nil
+}
+
+// Introspect returns the information about a token. When the token is not
+// active, it returns ErrInactiveToken, and when the introspection endpoint
+// cannot be reached, it returns an *IntrospectionUnavailableError. Every
+// call returns a new copy of the token information.
+func (ic *IntrospectionClient) Introspect(token string) (TokenInfo, error) {
+	now := time.Now()
+	if info, ok := ic.fromCache(token, now); ok {
+		return info.copy(), nil
+	}
+
+	info, err := ic.request(token)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, ok := info.expiration(now)
+	if !ok {
+		// without the expiration the result cannot be cached
+		return info, nil
+	}
+
+	if !now.Before(exp) {
+		return nil, ErrInactiveToken
+	}
+
+	ic.store(token, info, exp, now)
+	return info.copy(), nil
+}
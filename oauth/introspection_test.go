@@ -0,0 +1,231 @@
+// Copyright 2015 Zalando SE
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type introspectionServer struct {
+	mx       sync.Mutex
+	requests int
+	tokens   map[string]TokenInfo
+	auth     bool
+}
+
+func (s *introspectionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	s.requests++
+	s.mx.Unlock()
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.auth {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "theclientid" || secret != "clientsecret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	info, ok := s.tokens[r.FormValue("token")]
+	if !ok {
+		info = TokenInfo{"active": false}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func (s *introspectionServer) count() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.requests
+}
+
+func TestIntrospect(t *testing.T) {
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	s := &introspectionServer{tokens: map[string]TokenInfo{
+		"active-token":  {"active": true, "sub": "jdoe", "scope": "read write", "exp": exp},
+		"expired-token": {"active": true, "sub": "jdoe", "exp": float64(time.Now().Add(-time.Hour).Unix())},
+		"no-exp-token":  {"active": true, "sub": "jdoe"},
+	}}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{})
+
+	info, err := c.Introspect("active-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info["sub"] != "jdoe" {
+		t.Error("failed to receive the token info", info)
+	}
+
+	if !reflect.DeepEqual(info.Scopes(), []string{"read", "write"}) {
+		t.Error("failed to parse the scopes", info.Scopes())
+	}
+
+	info["sub"] = "changed"
+	cached, err := c.Introspect("active-token")
+	if err != nil || s.count() != 1 {
+		t.Error("failed to cache the token info", err, s.count())
+	}
+
+	if cached["sub"] != "jdoe" {
+		t.Error("cached token info changed by the caller", cached)
+	}
+
+	if _, err := c.Introspect("unknown-token"); err != ErrInactiveToken {
+		t.Error("failed to reject inactive token", err)
+	}
+
+	if _, err := c.Introspect("expired-token"); err != ErrInactiveToken {
+		t.Error("failed to reject expired token", err)
+	}
+
+	before := s.count()
+	c.Introspect("no-exp-token")
+	c.Introspect("no-exp-token")
+	if s.count() != before+2 {
+		t.Error("unexpectedly cached token info without expiration")
+	}
+}
+
+func TestIntrospectTokeninfoService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("token") != "valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"uid": "jdoe", "scope": ["uid", "read"], "expires_in": 3600}`))
+	}))
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{})
+
+	info, err := c.Introspect("valid-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(info.Scopes(), []string{"uid", "read"}) {
+		t.Error("failed to parse the scopes", info.Scopes())
+	}
+
+	if _, err := c.Introspect("invalid-token"); err != ErrInactiveToken {
+		t.Error("failed to reject unknown token", err)
+	}
+}
+
+func TestIntrospectFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{})
+	_, err := c.Introspect("some-token")
+	if err == nil || err == ErrInactiveToken {
+		t.Error("failed to report the introspection error", err)
+	}
+
+	if _, ok := err.(*IntrospectionUnavailableError); ok {
+		t.Error("failed response reported as unavailable", err)
+	}
+}
+
+func TestIntrospectUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{})
+	if _, err := c.Introspect("some-token"); err == nil {
+		t.Error("failed to fail")
+	} else if _, ok := err.(*IntrospectionUnavailableError); !ok {
+		t.Error("failed to report the endpoint as unavailable", err)
+	}
+}
+
+func TestIntrospectClientCredentials(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &introspectionServer{
+		auth:   true,
+		tokens: map[string]TokenInfo{"active-token": {"active": true}},
+	}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{CredentialsDir: "."})
+	if _, err := c.Introspect("active-token"); err != nil {
+		t.Error("failed to authenticate with the client credentials", err)
+	}
+}
+
+func TestIntrospectRejectedClientCredentials(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{CredentialsDir: "."})
+	if _, err := c.Introspect("active-token"); err == nil || err == ErrInactiveToken {
+		t.Error("failed to report the rejected client credentials", err)
+	}
+}
+
+func TestIntrospectionCacheSize(t *testing.T) {
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	s := &introspectionServer{tokens: map[string]TokenInfo{
+		"token-1": {"active": true, "exp": exp},
+		"token-2": {"active": true, "exp": exp},
+		"token-3": {"active": true, "exp": exp},
+	}}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewIntrospectionClient(ts.URL, IntrospectionOptions{CacheSize: 2})
+	for _, token := range []string{"token-1", "token-2", "token-3"} {
+		if _, err := c.Introspect(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(c.cache) != 2 {
+		t.Error("failed to limit the cache size", len(c.cache))
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Package clientcert implements a custom predicate to match routes based
+on the verified TLS client certificate of a request.
+
+The predicate matches, when skipper terminates TLS, the client sent a
+certificate, it was verified with the configured client CAs, and the
+regular expression passed in as the argument matches either the subject
+of the certificate, or one of its subject alternative names. The subject
+is matched in the string format defined by RFC 2253, e.g.
+CN=client,OU=Engineering,O=Example,C=DE. The subject alternative names
+are the DNS names, the email addresses and the IP addresses of the
+certificate. The expression should be anchored, because the subject
+contains all the attributes: an expression like OU=Engineering would
+match a certificate whose common name contains the same text, too.
+
+Examples:
+
+    // match requests with a client certificate of the Engineering unit
+    example1: ClientCert("^CN=[^,]+,OU=Engineering,O=Example,C=DE$") -> "https://internal.example.org";
+
+    // match requests with a client certificate for a service domain
+    example2: ClientCert("^[a-z0-9-]+[.]services[.]example[.]org$") -> "https://internal.example.org";
+*/
+package clientcert
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/routing"
+)
+
+const Name = "ClientCert"
+
+var InvalidArgsError = errors.New("invalid arguments")
+
+type spec struct{}
+
+type predicate struct {
+	rx *regexp.Regexp
+}
+
+// Creates a predicate specification, whose instances match the requests
+// with a verified client certificate, with a subject or a subject
+// alternative name matching a regular expression.
+func New() routing.PredicateSpec { return &spec{} }
+
+func (s *spec) Name() string { return Name }
+
+func (s *spec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 1 {
+		return nil, InvalidArgsError
+	}
+
+	expr, ok := args[0].(string)
+	if !ok {
+		return nil, InvalidArgsError
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, InvalidArgsError
+	}
+
+	return &predicate{rx: rx}, nil
+}
+
+func (p *predicate) Match(r *http.Request) bool {
+	c := snet.ClientCertificate(r)
+	return c != nil && snet.CertificateMatches(c, p.rx)
+}
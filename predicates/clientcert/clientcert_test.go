@@ -0,0 +1,83 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"too many args",
+		[]interface{}{"OU=Engineering", "OU=Marketing"},
+		true,
+	}, {
+		"not a string",
+		[]interface{}{42},
+		true,
+	}, {
+		"invalid regexp",
+		[]interface{}{"OU=(Engineering"},
+		true,
+	}, {
+		"valid",
+		[]interface{}{"OU=Engineering"},
+		false,
+	}} {
+		_, err := New().Create(ti.args)
+		if ti.err && err == nil {
+			t.Error(ti.msg, "failed to fail")
+		} else if !ti.err && err != nil {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p, err := New().Create([]interface{}{"OU=Engineering"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := func(unit string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{
+			Subject: pkix.Name{CommonName: "client", OrganizationalUnit: []string{unit}},
+		}}}}
+	}
+
+	for _, ti := range []struct {
+		msg   string
+		tls   *tls.ConnectionState
+		match bool
+	}{{
+		"no TLS",
+		nil,
+		false,
+	}, {
+		"no client certificate",
+		&tls.ConnectionState{},
+		false,
+	}, {
+		"not matching",
+		cert("Marketing"),
+		false,
+	}, {
+		"matching",
+		cert("Engineering"),
+		true,
+	}} {
+		if p.Match(&http.Request{TLS: ti.tls}) != ti.match {
+			t.Error(ti.msg, "unexpected match result")
+		}
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Package conn implements custom predicates to match routes based on the
+properties of the connection of a request: the protocol, the TLS
+version, the server name sent by the client during the TLS handshake
+(SNI), and the address family of the client.
+
+Protocol matches the requests with one of the HTTP protocol versions
+passed in as the arguments, e.g. "HTTP/1.1" or "HTTP/2".
+
+TLS matches the requests received over TLS. With the argument "false",
+it matches the requests received without TLS.
+
+TLSVersion matches the requests received over TLS, when the negotiated
+version satisfies the condition. The condition is a version, 1.0, 1.1,
+1.2 or 1.3, optionally prefixed with a comparison operator: =, >=, >, <=
+or <.
+
+SNI matches the requests received over TLS, when the server name sent by
+the client during the handshake equals, case insensitively, one of the
+arguments. Arguments starting with "*." match any single label in the
+place of the star.
+
+ClientIP matches the requests from clients with an IPv4 or an IPv6
+address, set by the argument "ipv4" or "ipv6". Like the Source
+predicate, it uses the first address in the X-Forwarded-For header when
+the header is present. IPv4 addresses mapped to IPv6 are considered
+IPv4.
+
+Examples:
+
+    // legacy TLS clients
+    legacy: TLSVersion("<1.2") -> "https://legacy.example.org";
+
+    // HTTP/2 only
+    h2: Path("/stream") && Protocol("HTTP/2") -> "https://stream.example.org";
+    h2Required: Path("/stream") -> status(505) -> <shunt>;
+
+    // plain HTTP requests
+    insecure: TLS("false") -> redirectTo(308, "https:") -> <shunt>;
+
+    api: SNI("api.example.org", "*.api.example.org") -> "https://api.internal";
+
+    v6: ClientIP("ipv6") -> "https://v6.example.org";
+*/
+package conn
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	ProtocolName   = "Protocol"
+	TLSName        = "TLS"
+	TLSVersionName = "TLSVersion"
+	SNIName        = "SNI"
+	ClientIPName   = "ClientIP"
+)
+
+var InvalidArgsError = errors.New("invalid arguments")
+
+// tls.VersionTLS13 is not defined in older versions of Go
+const versionTLS13 = 0x0304
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": versionTLS13,
+}
+
+type (
+	protocolSpec   struct{}
+	tlsSpec        struct{}
+	tlsVersionSpec struct{}
+	sniSpec        struct{}
+	clientIPSpec   struct{}
+)
+
+type protocolPredicate []struct{ major, minor int }
+
+type tlsPredicate bool
+
+type tlsVersionPredicate struct {
+	op      string
+	version uint16
+}
+
+type sniPredicate []string
+
+type clientIPPredicate bool // true for IPv6
+
+// Creates Protocol predicate.
+func NewProtocol() routing.PredicateSpec { return &protocolSpec{} }
+
+// Creates TLS predicate.
+func NewTLS() routing.PredicateSpec { return &tlsSpec{} }
+
+// Creates TLSVersion predicate.
+func NewTLSVersion() routing.PredicateSpec { return &tlsVersionSpec{} }
+
+// Creates SNI predicate.
+func NewSNI() routing.PredicateSpec { return &sniSpec{} }
+
+// Creates ClientIP predicate.
+func NewClientIP() routing.PredicateSpec { return &clientIPSpec{} }
+
+func stringArgs(args []interface{}) ([]string, error) {
+	if len(args) == 0 {
+		return nil, InvalidArgsError
+	}
+
+	s := make([]string, len(args))
+	for i, a := range args {
+		var ok bool
+		if s[i], ok = a.(string); !ok || s[i] == "" {
+			return nil, InvalidArgsError
+		}
+	}
+
+	return s, nil
+}
+
+func (s *protocolSpec) Name() string { return ProtocolName }
+
+func (s *protocolSpec) Create(args []interface{}) (routing.Predicate, error) {
+	protocols, err := stringArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var p protocolPredicate
+	for _, proto := range protocols {
+		// accept HTTP/2 for HTTP/2.0
+		if !strings.Contains(proto, ".") {
+			proto += ".0"
+		}
+
+		major, minor, ok := http.ParseHTTPVersion(strings.ToUpper(proto))
+		if !ok {
+			return nil, InvalidArgsError
+		}
+
+		p = append(p, struct{ major, minor int }{major, minor})
+	}
+
+	return p, nil
+}
+
+func (p protocolPredicate) Match(r *http.Request) bool {
+	for _, v := range p {
+		if r.ProtoMajor == v.major && r.ProtoMinor == v.minor {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *tlsSpec) Name() string { return TLSName }
+
+func (s *tlsSpec) Create(args []interface{}) (routing.Predicate, error) {
+	switch {
+	case len(args) == 0:
+		return tlsPredicate(true), nil
+	case len(args) == 1 && args[0] == "true":
+		return tlsPredicate(true), nil
+	case len(args) == 1 && args[0] == "false":
+		return tlsPredicate(false), nil
+	default:
+		return nil, InvalidArgsError
+	}
+}
+
+func (p tlsPredicate) Match(r *http.Request) bool {
+	return (r.TLS != nil) == bool(p)
+}
+
+func (s *tlsVersionSpec) Name() string { return TLSVersionName }
+
+func (s *tlsVersionSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 1 {
+		return nil, InvalidArgsError
+	}
+
+	cond, ok := args[0].(string)
+	if !ok {
+		return nil, InvalidArgsError
+	}
+
+	p := &tlsVersionPredicate{op: "="}
+	for _, op := range []string{">=", "<=", "=", ">", "<"} {
+		if strings.HasPrefix(cond, op) {
+			p.op, cond = op, cond[len(op):]
+			break
+		}
+	}
+
+	if p.version, ok = tlsVersions[strings.TrimSpace(cond)]; !ok {
+		return nil, InvalidArgsError
+	}
+
+	return p, nil
+}
+
+func (p *tlsVersionPredicate) Match(r *http.Request) bool {
+	if r.TLS == nil {
+		return false
+	}
+
+	v := r.TLS.Version
+	switch p.op {
+	case ">=":
+		return v >= p.version
+	case "<=":
+		return v <= p.version
+	case ">":
+		return v > p.version
+	case "<":
+		return v < p.version
+	default:
+		return v == p.version
+	}
+}
+
+func (s *sniSpec) Name() string { return SNIName }
+
+func (s *sniSpec) Create(args []interface{}) (routing.Predicate, error) {
+	names, err := stringArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range names {
+		names[i] = strings.ToLower(names[i])
+	}
+
+	return sniPredicate(names), nil
+}
+
+func matchServerName(pattern, name string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return pattern == name
+	}
+
+	i := strings.Index(name, ".")
+	return i > 0 && name[i:] == pattern[1:]
+}
+
+func (p sniPredicate) Match(r *http.Request) bool {
+	if r.TLS == nil || r.TLS.ServerName == "" {
+		return false
+	}
+
+	name := strings.ToLower(r.TLS.ServerName)
+	for _, pattern := range p {
+		if matchServerName(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *clientIPSpec) Name() string { return ClientIPName }
+
+func (s *clientIPSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 1 {
+		return nil, InvalidArgsError
+	}
+
+	switch args[0] {
+	case "ipv4":
+		return clientIPPredicate(false), nil
+	case "ipv6":
+		return clientIPPredicate(true), nil
+	default:
+		return nil, InvalidArgsError
+	}
+}
+
+func (p clientIPPredicate) Match(r *http.Request) bool {
+	ip := snet.RemoteHost(r)
+	if ip == nil {
+		return false
+	}
+
+	return (ip.To4() == nil) == bool(p)
+}
@@ -0,0 +1,108 @@
+package conn
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func TestCreate(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		spec routing.PredicateSpec
+		args []interface{}
+		err  bool
+	}{
+		{"protocol, no args", NewProtocol(), nil, true},
+		{"protocol, invalid", NewProtocol(), []interface{}{"SPDY/3"}, true},
+		{"protocol, not string", NewProtocol(), []interface{}{2.0}, true},
+		{"protocol", NewProtocol(), []interface{}{"HTTP/1.1", "HTTP/2"}, false},
+		{"tls, no args", NewTLS(), nil, false},
+		{"tls, false", NewTLS(), []interface{}{"false"}, false},
+		{"tls, invalid", NewTLS(), []interface{}{"yes"}, true},
+		{"tls, too many args", NewTLS(), []interface{}{"true", "false"}, true},
+		{"tls version, no args", NewTLSVersion(), nil, true},
+		{"tls version, invalid", NewTLSVersion(), []interface{}{">=1.4"}, true},
+		{"tls version, invalid operator", NewTLSVersion(), []interface{}{"!=1.2"}, true},
+		{"tls version", NewTLSVersion(), []interface{}{">=1.2"}, false},
+		{"tls version, without operator", NewTLSVersion(), []interface{}{"1.3"}, false},
+		{"sni, no args", NewSNI(), nil, true},
+		{"sni, empty", NewSNI(), []interface{}{""}, true},
+		{"sni", NewSNI(), []interface{}{"api.example.org", "*.example.org"}, false},
+		{"client ip, no args", NewClientIP(), nil, true},
+		{"client ip, invalid", NewClientIP(), []interface{}{"ipv5"}, true},
+		{"client ip", NewClientIP(), []interface{}{"ipv6"}, false},
+	} {
+		_, err := ti.spec.Create(ti.args)
+		if (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	h1 := &http.Request{ProtoMajor: 1, ProtoMinor: 1, RemoteAddr: "192.168.0.1:4321"}
+	h2 := &http.Request{
+		ProtoMajor: 2,
+		RemoteAddr: "[2001:db8::1]:4321",
+		TLS:        &tls.ConnectionState{Version: tls.VersionTLS12, ServerName: "API.example.org"}}
+	legacy := &http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		RemoteAddr: "[::ffff:192.168.0.1]:4321",
+		TLS:        &tls.ConnectionState{Version: tls.VersionTLS10}}
+	forwarded := &http.Request{
+		RemoteAddr: "192.168.0.1:4321",
+		Header:     http.Header{"X-Forwarded-For": []string{"2001:db8::1, 192.168.0.2"}}}
+
+	for _, ti := range []struct {
+		msg      string
+		spec     routing.PredicateSpec
+		args     []interface{}
+		req      *http.Request
+		expected bool
+	}{
+		{"protocol, HTTP/1.1", NewProtocol(), []interface{}{"HTTP/1.1"}, h1, true},
+		{"protocol, HTTP/2", NewProtocol(), []interface{}{"HTTP/2"}, h2, true},
+		{"protocol, mismatch", NewProtocol(), []interface{}{"HTTP/2.0"}, h1, false},
+		{"protocol, multiple", NewProtocol(), []interface{}{"HTTP/1.0", "http/1.1"}, h1, true},
+		{"tls", NewTLS(), nil, h2, true},
+		{"tls, plain", NewTLS(), nil, h1, false},
+		{"tls false, plain", NewTLS(), []interface{}{"false"}, h1, true},
+		{"tls false", NewTLS(), []interface{}{"false"}, h2, false},
+		{"tls version, plain", NewTLSVersion(), []interface{}{"<1.2"}, h1, false},
+		{"tls version, legacy", NewTLSVersion(), []interface{}{"<1.2"}, legacy, true},
+		{"tls version, less", NewTLSVersion(), []interface{}{"<1.2"}, h2, false},
+		{"tls version, at least", NewTLSVersion(), []interface{}{">=1.2"}, h2, true},
+		{"tls version, greater", NewTLSVersion(), []interface{}{">1.2"}, h2, false},
+		{"tls version, at most", NewTLSVersion(), []interface{}{"<=1.2"}, h2, true},
+		{"tls version, equal", NewTLSVersion(), []interface{}{"=1.0"}, legacy, true},
+		{"tls version, no operator", NewTLSVersion(), []interface{}{"1.2"}, h2, true},
+		{"sni", NewSNI(), []interface{}{"api.example.org"}, h2, true},
+		{"sni, wildcard", NewSNI(), []interface{}{"*.example.org"}, h2, true},
+		{"sni, wildcard, single label only", NewSNI(), []interface{}{"*.org"}, h2, false},
+		{"sni, mismatch", NewSNI(), []interface{}{"www.example.org"}, h2, false},
+		{"sni, no server name", NewSNI(), []interface{}{"api.example.org"}, legacy, false},
+		{"sni, plain", NewSNI(), []interface{}{"api.example.org"}, h1, false},
+		{"client ip, ipv4", NewClientIP(), []interface{}{"ipv4"}, h1, true},
+		{"client ip, ipv6", NewClientIP(), []interface{}{"ipv6"}, h2, true},
+		{"client ip, ipv4 mismatch", NewClientIP(), []interface{}{"ipv4"}, h2, false},
+		{"client ip, mapped ipv4", NewClientIP(), []interface{}{"ipv4"}, legacy, true},
+		{"client ip, forwarded", NewClientIP(), []interface{}{"ipv6"}, forwarded, true},
+	} {
+		p, err := ti.spec.Create(ti.args)
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		if ti.req.Header == nil {
+			ti.req.Header = make(http.Header)
+		}
+
+		if p.Match(ti.req) != ti.expected {
+			t.Error(ti.msg, "expected match:", ti.expected)
+		}
+	}
+}
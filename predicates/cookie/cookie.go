@@ -1,5 +1,9 @@
 /*
 Package cookie implements prediate to check parsed cookie headers by name and value.
+
+The VerifiedCookie predicate matches the cookies signed or encrypted by
+the cookie filters, verifying or decrypting them with the keys from a
+file.
 */
 package cookie
 
@@ -7,12 +11,19 @@ import (
 	"net/http"
 	"regexp"
 
+	"github.com/zalando/skipper/filters/cookie"
 	"github.com/zalando/skipper/predicates"
 	"github.com/zalando/skipper/routing"
 )
 
-// The predicate can be referenced in eskip by the name "Cookie".
-const Name = "Cookie"
+const (
+	// The predicate can be referenced in eskip by the name "Cookie".
+	Name = "Cookie"
+
+	// The predicate can be referenced in eskip by the name
+	// "VerifiedCookie".
+	VerifiedName = "VerifiedCookie"
+)
 
 type (
 	spec struct{}
@@ -21,6 +32,14 @@ type (
 		name     string
 		valueExp *regexp.Regexp
 	}
+
+	verifiedSpec struct{}
+
+	verifiedPredicate struct {
+		name     string
+		codec    *cookie.Codec
+		valueExp *regexp.Regexp
+	}
 )
 
 // New creates a predicate specification, whose instances can be used to match parsed request cookies.
@@ -67,3 +86,68 @@ func (p *predicate) Match(r *http.Request) bool {
 
 	return p.valueExp.MatchString(c.Value)
 }
+
+// NewVerified creates a predicate specification, whose instances match the
+// requests with a cookie signed or encrypted by the cookie filters.
+//
+// The predicate accepts two or three arguments: the cookie name, the
+// codec in the format of sign=<key file> or encrypt=<key file>, and
+// optionally an expression that the verified or decrypted cookie value
+// needs to match.
+//
+// Eskip example:
+//
+// 	VerifiedCookie("session", "encrypt=/etc/skipper/cookie-keys", /^user-/) -> "https://www.example.org";
+//
+func NewVerified() routing.PredicateSpec { return &verifiedSpec{} }
+
+func (s *verifiedSpec) Name() string { return VerifiedName }
+
+func (s *verifiedSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	arg, ok := args[1].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	codec, err := cookie.ParseCodec(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &verifiedPredicate{name: name, codec: codec}
+	if len(args) == 3 {
+		value, ok := args[2].(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		if p.valueExp, err = regexp.Compile(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *verifiedPredicate) Match(r *http.Request) bool {
+	c, err := r.Cookie(p.name)
+	if err != nil {
+		return false
+	}
+
+	value, err := p.codec.Decode(p.name, c.Value)
+	if err != nil {
+		return false
+	}
+
+	return p.valueExp == nil || p.valueExp.MatchString(value)
+}
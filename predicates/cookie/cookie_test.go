@@ -3,9 +3,13 @@ package cookie
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
+
+	"github.com/zalando/skipper/filters/cookie"
 )
 
 func TestCookieArgs(t *testing.T) {
@@ -95,3 +99,68 @@ func TestCookieMatch(t *testing.T) {
 		}()
 	}
 }
+
+func TestVerifiedCookie(t *testing.T) {
+	f, err := ioutil.TempFile("", "cookie-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("key\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	codecArg := "encrypt=" + f.Name()
+
+	for _, args := range [][]interface{}{
+		{"session"},
+		{"session", "sign"},
+		{"session", codecArg, 42},
+		{"session", codecArg, "(invalid"},
+	} {
+		if _, err := NewVerified().Create(args); err == nil {
+			t.Error("failed to fail", args)
+		}
+	}
+
+	codec, err := cookie.ParseCodec(codecArg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := codec.Encode("session", "user-jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range []struct {
+		msg   string
+		args  []interface{}
+		value string
+		match bool
+	}{
+		{"no cookie", []interface{}{"session", codecArg}, "", false},
+		{"valid", []interface{}{"session", codecArg}, valid, true},
+		{"tampered", []interface{}{"session", codecArg}, "x" + valid, false},
+		{"plain", []interface{}{"session", codecArg}, "user-jdoe", false},
+		{"matching value", []interface{}{"session", codecArg, "^user-"}, valid, true},
+		{"not matching value", []interface{}{"session", codecArg, "^admin-"}, valid, false},
+	} {
+		p, err := NewVerified().Create(ti.args)
+		if err != nil {
+			t.Error(ti.msg, err)
+			continue
+		}
+
+		r := &http.Request{Header: http.Header{}}
+		if ti.value != "" {
+			r.AddCookie(&http.Cookie{Name: "session", Value: ti.value})
+		}
+
+		if p.Match(r) != ti.match {
+			t.Error(ti.msg, "unexpected match result")
+		}
+	}
+}
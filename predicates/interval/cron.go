@@ -0,0 +1,165 @@
+package interval
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidCron = errors.New("invalid cron expression")
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+
+	weekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+	minuteField  = cronField{0, 59, nil}
+	hourField    = cronField{0, 23, nil}
+	domField     = cronField{1, 31, nil}
+	monthField   = cronField{1, 12, monthNames}
+	weekdayField = cronField{0, 7, weekdayNames}
+)
+
+// the fields of a cron expression, as bit sets
+type cronSchedule struct {
+	minute, hour, dom, month, weekday uint64
+	domAny, weekdayAny                bool
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errInvalidCron
+	}
+
+	return v, nil
+}
+
+// parses a field of a cron expression: *, a list of values and ranges,
+// optionally with steps, e.g. 1-5, */15 or 0-30/10,45
+func (f cronField) parse(s string) (bits uint64, any bool, err error) {
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, false, errInvalidCron
+			}
+
+			part = part[:i]
+		}
+
+		var from, to int
+		switch {
+		case part == "*":
+			from, to = f.min, f.max
+			any = any || step == 1
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			if from, err = f.value(r[0]); err != nil {
+				return 0, false, err
+			}
+
+			if to, err = f.value(r[1]); err != nil {
+				return 0, false, err
+			}
+
+			if to < from {
+				return 0, false, errInvalidCron
+			}
+		default:
+			if from, err = f.value(part); err != nil {
+				return 0, false, err
+			}
+
+			to = from
+			if step > 1 {
+				to = f.max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, any, nil
+}
+
+// parses a cron expression in the standard format of five fields: minute,
+// hour, day of month, month and day of week
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errInvalidCron
+	}
+
+	var (
+		s   cronSchedule
+		err error
+	)
+
+	if s.minute, _, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if s.hour, _, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if s.dom, s.domAny, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if s.month, _, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if s.weekday, s.weekdayAny, err = weekdayField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// 7 is Sunday, too
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+
+	return &s, nil
+}
+
+func hasBit(bits uint64, v int) bool { return bits&(1<<uint(v)) != 0 }
+
+// tells whether the schedule fires at the wall clock time of t. Like in
+// cron, when both the day of month and the day of week are restricted,
+// either of them needs to match.
+func (s *cronSchedule) matches(t time.Time) bool {
+	if !hasBit(s.minute, t.Minute()) || !hasBit(s.hour, t.Hour()) || !hasBit(s.month, int(t.Month())) {
+		return false
+	}
+
+	dom := hasBit(s.dom, t.Day())
+	weekday := hasBit(s.weekday, int(t.Weekday()))
+	switch {
+	case s.domAny && s.weekdayAny:
+		return true
+	case s.domAny:
+		return weekday
+	case s.weekdayAny:
+		return dom
+	default:
+		return dom || weekday
+	}
+}
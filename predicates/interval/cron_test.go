@@ -0,0 +1,72 @@
+package interval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, ti := range []struct {
+		expr string
+		err  bool
+	}{
+		{"* * * * *", false},
+		{"0 22 * * *", false},
+		{"*/15 9-17 * * mon-fri", false},
+		{"0,30 0 1,15 jan-jun/2 7", false},
+		{"", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"* * * * foo", true},
+	} {
+		_, err := parseCron(ti.expr)
+		if (err != nil) != ti.err {
+			t.Error(ti.expr, err)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2017-03-06 is a Monday
+	for _, ti := range []struct {
+		expr    string
+		time    string
+		matches bool
+	}{
+		{"* * * * *", "2017-03-06T10:11:00Z", true},
+		{"0 22 * * *", "2017-03-06T22:00:00Z", true},
+		{"0 22 * * *", "2017-03-06T22:01:00Z", false},
+		{"*/15 9-17 * * mon-fri", "2017-03-06T09:45:00Z", true},
+		{"*/15 9-17 * * mon-fri", "2017-03-06T09:50:00Z", false},
+		{"*/15 9-17 * * mon-fri", "2017-03-05T09:45:00Z", false},
+		{"0 0 * * 7", "2017-03-05T00:00:00Z", true},
+		{"0 0 * * 0", "2017-03-05T00:00:00Z", true},
+		{"0 0 1 * *", "2017-03-01T00:00:00Z", true},
+		{"0 0 1 * *", "2017-03-02T00:00:00Z", false},
+		{"0 0 1 * mon", "2017-03-06T00:00:00Z", true},
+		{"0 0 1 * mon", "2017-03-01T00:00:00Z", true},
+		{"0 0 1 * mon", "2017-03-07T00:00:00Z", false},
+		{"0 0 * feb *", "2017-03-01T00:00:00Z", false},
+	} {
+		s, err := parseCron(ti.expr)
+		if err != nil {
+			t.Fatal(ti.expr, err)
+		}
+
+		tm, err := time.Parse(time.RFC3339, ti.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.matches(tm) != ti.matches {
+			t.Error(ti.expr, ti.time, "expected match:", ti.matches)
+		}
+	}
+}
@@ -30,6 +30,35 @@ Examples:
 	example3: Path("/zalando") && After("2016-01-01T12:00:00+02:00") -> "https://www.zalando.de";
 	example4: Path("/zalando") && After(1451642400) -> "https://www.zalando.de";
 
+The package includes three predicates for recurring periods of time,
+too: Cron, Weekday and TimeOfDay. They accept an optional last argument
+with the name of a time zone from the IANA database, e.g. Europe/Berlin.
+When not set, the time is evaluated in UTC.
+
+Cron predicate matches during a time window starting whenever a cron
+expression fires. It expects the expression in the standard format of
+five fields (minute, hour, day of month, month, day of week), and the
+length of the window as a duration string, e.g. "2h", between one minute
+and 31 days. The expression is evaluated in the wall clock time of the
+time zone: on the days of the DST changes, the skipped times don't fire,
+while the repeated times fire twice.
+
+Weekday predicate matches on the days of the week passed in as
+arguments. The days can be set by their English name or its first three
+letters, or as ranges, e.g. "Mon-Fri". Ranges can wrap around the end of
+the week, e.g. "Fri-Mon".
+
+TimeOfDay predicate matches between two wall clock times, in the format
+of 15:04 or 15:04:05, including the first, but excluding the second. When
+the second is before the first, the window spans midnight.
+
+Examples:
+
+	maintenance: Cron("0 22 * * *", "2h", "Europe/Berlin") -> static("/", "/var/www/maintenance") -> <shunt>;
+
+	businessHours: Weekday("Mon-Fri", "Europe/Berlin") && TimeOfDay("09:00", "17:00", "Europe/Berlin") -> "https://www.zalando.de";
+	night: TimeOfDay("22:00", "06:00", "Europe/Berlin") -> "https://cheap.zalando.de";
+
 */
 package interval
 
@@ -0,0 +1,318 @@
+package interval
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	CronName      = "Cron"
+	WeekdayName   = "Weekday"
+	TimeOfDayName = "TimeOfDay"
+
+	// The longest window accepted by the Cron predicate.
+	MaxCronDuration = 31 * 24 * time.Hour
+
+	// how far the Cron predicate looks for the next firing of the
+	// schedule, when it is not in a window
+	cronLookAhead = time.Hour
+)
+
+type cronSpec struct{}
+
+type cronPredicate struct {
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+	getTime  func() time.Time
+
+	// the mutex only serializes the evaluation of the schedule, the
+	// current window is read without locking
+	mx     sync.Mutex
+	window atomic.Value
+}
+
+// the period during which the result of the Cron predicate doesn't change
+type cronWindow struct {
+	from, to time.Time
+	matches  bool
+}
+
+type weekdaySpec struct{}
+
+type weekdayPredicate struct {
+	days     [7]bool
+	location *time.Location
+	getTime  func() time.Time
+}
+
+type timeOfDaySpec struct{}
+
+type timeOfDayPredicate struct {
+	from, to time.Duration
+	location *time.Location
+	getTime  func() time.Time
+}
+
+// Creates Cron predicate.
+func NewCron() routing.PredicateSpec { return &cronSpec{} }
+
+// Creates Weekday predicate.
+func NewWeekday() routing.PredicateSpec { return &weekdaySpec{} }
+
+// Creates TimeOfDay predicate.
+func NewTimeOfDay() routing.PredicateSpec { return &timeOfDaySpec{} }
+
+// parses the optional time zone argument, UTC when not set
+func parseLocation(args []interface{}, i int) (*time.Location, bool) {
+	if len(args) <= i {
+		return time.UTC, true
+	}
+
+	name, ok := args[i].(string)
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+
+	return loc, true
+}
+
+func (s *cronSpec) Name() string { return CronName }
+
+func (s *cronSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	expr, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	schedule, err := parseCron(expr)
+	if err != nil {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	ds, ok := args[1].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	d, err := time.ParseDuration(ds)
+	if err != nil || d < time.Minute || d > MaxCronDuration {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	loc, ok := parseLocation(args, 2)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &cronPredicate{
+		schedule: schedule,
+		duration: d,
+		location: loc,
+		getTime:  time.Now}, nil
+}
+
+// Checks whether the current time is within the duration after the last
+// time the schedule fired. The schedule is evaluated for every absolute
+// minute in the time zone, so on DST changes, the wall clock times that
+// are skipped don't fire, while the ones that are repeated fire twice.
+//
+// The result is cached until the current window ends, or until the next
+// time the schedule fires, so the schedule is evaluated only when one of
+// these boundaries has passed.
+func (p *cronPredicate) Match(r *http.Request) bool {
+	now := p.getTime()
+	if w, ok := p.currentWindow(now); ok {
+		return w.matches
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	// another request may have evaluated the schedule in the meantime
+	if w, ok := p.currentWindow(now); ok {
+		return w.matches
+	}
+
+	w := p.evaluate(now)
+	p.window.Store(w)
+	return w.matches
+}
+
+func (p *cronPredicate) currentWindow(now time.Time) (cronWindow, bool) {
+	w, ok := p.window.Load().(cronWindow)
+	return w, ok && !now.Before(w.from) && now.Before(w.to)
+}
+
+func (p *cronPredicate) evaluate(now time.Time) cronWindow {
+	minute := now.Truncate(time.Minute)
+
+	// the latest firing within the duration, when the current time is in
+	// its window, the result doesn't change until the window ends
+	start := minute.Add(-p.duration)
+	for t := minute; t.After(start); t = t.Add(-time.Minute) {
+		if p.schedule.matches(t.In(p.location)) {
+			if end := t.Add(p.duration); now.Before(end) {
+				return cronWindow{from: minute, to: end, matches: true}
+			}
+
+			break
+		}
+	}
+
+	// otherwise the result doesn't change until the next firing
+	next := minute.Add(cronLookAhead)
+	for t := minute.Add(time.Minute); t.Before(next); t = t.Add(time.Minute) {
+		if p.schedule.matches(t.In(p.location)) {
+			next = t
+			break
+		}
+	}
+
+	return cronWindow{from: minute, to: next}
+}
+
+func (s *weekdaySpec) Name() string { return WeekdayName }
+
+func parseWeekday(s string) (int, bool) {
+	s = strings.ToLower(s)
+	if len(s) < 3 {
+		return 0, false
+	}
+
+	d, ok := weekdayNames[s[:3]]
+	if !ok || len(s) > 3 && s != strings.ToLower(time.Weekday(d).String()) {
+		return 0, false
+	}
+
+	return d, true
+}
+
+func (s *weekdaySpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &weekdayPredicate{location: time.UTC, getTime: time.Now}
+	var hasDays bool
+	for i, a := range args {
+		arg, ok := a.(string)
+		if !ok {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		r := strings.SplitN(arg, "-", 2)
+		from, ok := parseWeekday(r[0])
+		if !ok {
+			// the last argument can be the time zone
+			if i == len(args)-1 && hasDays {
+				if p.location, ok = parseLocation(args, i); ok {
+					continue
+				}
+			}
+
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		to := from
+		if len(r) == 2 {
+			if to, ok = parseWeekday(r[1]); !ok {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+		}
+
+		// ranges can wrap around the end of the week, e.g. Fri-Mon
+		for d := from; ; d = (d + 1) % 7 {
+			p.days[d] = true
+			if d == to {
+				break
+			}
+		}
+
+		hasDays = true
+	}
+
+	return p, nil
+}
+
+func (p *weekdayPredicate) Match(r *http.Request) bool {
+	return p.days[p.getTime().In(p.location).Weekday()]
+}
+
+func (s *timeOfDaySpec) Name() string { return TimeOfDayName }
+
+// parses a wall clock time as 15:04 or 15:04:05, and returns it as the
+// time since midnight
+func parseTimeOfDay(arg interface{}) (time.Duration, bool) {
+	s, ok := arg.(string)
+	if !ok {
+		return 0, false
+	}
+
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return wallClock(t), true
+		}
+	}
+
+	return 0, false
+}
+
+func wallClock(t time.Time) time.Duration {
+	h, m, s := t.Clock()
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+}
+
+func (s *timeOfDaySpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	from, ok := parseTimeOfDay(args[0])
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	to, ok := parseTimeOfDay(args[1])
+	if !ok || to == from {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	loc, ok := parseLocation(args, 2)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &timeOfDayPredicate{
+		from:     from,
+		to:       to,
+		location: loc,
+		getTime:  time.Now}, nil
+}
+
+// The wall clock time in the time zone is compared, so the window
+// follows the DST changes. When the end is before the start, the window
+// spans midnight.
+func (p *timeOfDayPredicate) Match(r *http.Request) bool {
+	c := wallClock(p.getTime().In(p.location))
+	if p.from < p.to {
+		return p.from <= c && c < p.to
+	}
+
+	return p.from <= c || c < p.to
+}
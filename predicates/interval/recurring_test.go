@@ -0,0 +1,231 @@
+package interval
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tm
+}
+
+func TestCreateRecurring(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		spec routing.PredicateSpec
+		args []interface{}
+		err  bool
+	}{
+		{"cron, no args", NewCron(), nil, true},
+		{"cron, no duration", NewCron(), []interface{}{"0 22 * * *"}, true},
+		{"cron, invalid expression", NewCron(), []interface{}{"0 22 * *", "2h"}, true},
+		{"cron, invalid duration", NewCron(), []interface{}{"0 22 * * *", "2 hours"}, true},
+		{"cron, too short duration", NewCron(), []interface{}{"0 22 * * *", "30s"}, true},
+		{"cron, too long duration", NewCron(), []interface{}{"0 22 * * *", "745h"}, true},
+		{"cron, invalid time zone", NewCron(), []interface{}{"0 22 * * *", "2h", "Europe/Nowhere"}, true},
+		{"cron", NewCron(), []interface{}{"0 22 * * *", "2h"}, false},
+		{"cron, time zone", NewCron(), []interface{}{"0 22 * * *", "2h", "Europe/Berlin"}, false},
+		{"weekday, no args", NewWeekday(), nil, true},
+		{"weekday, invalid day", NewWeekday(), []interface{}{"Mo"}, true},
+		{"weekday, invalid range", NewWeekday(), []interface{}{"Mon-Foo"}, true},
+		{"weekday, time zone only", NewWeekday(), []interface{}{"Europe/Berlin"}, true},
+		{"weekday, time zone not last", NewWeekday(), []interface{}{"Mon", "Europe/Berlin", "Tue"}, true},
+		{"weekday, number", NewWeekday(), []interface{}{1.0}, true},
+		{"weekday", NewWeekday(), []interface{}{"Mon", "wednesday", "Fri-Sun"}, false},
+		{"weekday, time zone", NewWeekday(), []interface{}{"Mon-Fri", "Europe/Berlin"}, false},
+		{"time of day, no args", NewTimeOfDay(), nil, true},
+		{"time of day, invalid time", NewTimeOfDay(), []interface{}{"9am", "17:00"}, true},
+		{"time of day, empty window", NewTimeOfDay(), []interface{}{"09:00", "09:00"}, true},
+		{"time of day, invalid time zone", NewTimeOfDay(), []interface{}{"09:00", "17:00", "CEST"}, true},
+		{"time of day", NewTimeOfDay(), []interface{}{"09:00", "17:30:30"}, false},
+		{"time of day, time zone", NewTimeOfDay(), []interface{}{"22:00", "06:00", "Europe/Berlin"}, false},
+	} {
+		_, err := ti.spec.Create(ti.args)
+		if (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		args     []interface{}
+		times    []string
+		expected []bool
+	}{{
+		"nightly window, sequential",
+		[]interface{}{"0 22 * * *", "2h"},
+		[]string{
+			"2017-03-06T21:59:59Z",
+			"2017-03-06T22:00:00Z",
+			"2017-03-06T23:30:00Z",
+			"2017-03-06T23:59:59Z",
+			"2017-03-07T00:00:00Z",
+			"2017-03-07T21:00:00Z",
+			"2017-03-07T22:10:00Z",
+		},
+		[]bool{false, true, true, true, false, false, true},
+	}, {
+		"time zone",
+		[]interface{}{"0 22 * * *", "2h", "Europe/Berlin"},
+		[]string{"2017-03-06T20:59:00Z", "2017-03-06T21:00:00Z", "2017-03-06T22:30:00Z", "2017-03-06T23:00:00Z"},
+		[]bool{false, true, true, false},
+	}, {
+		"time going backwards",
+		[]interface{}{"0 22 * * *", "2h"},
+		[]string{"2017-03-06T23:00:00Z", "2017-03-06T21:00:00Z", "2017-03-06T22:00:00Z"},
+		[]bool{true, false, true},
+	}, {
+		"spring forward, skipped time doesn't fire",
+		[]interface{}{"30 2 * * *", "30m", "Europe/Berlin"},
+		[]string{"2017-03-25T01:40:00Z", "2017-03-26T00:40:00Z", "2017-03-26T01:10:00Z", "2017-03-26T01:40:00Z"},
+		[]bool{true, false, false, false},
+	}, {
+		"fall back, repeated time fires twice",
+		[]interface{}{"30 2 * * *", "30m", "Europe/Berlin"},
+		[]string{"2017-10-29T00:40:00Z", "2017-10-29T01:10:00Z", "2017-10-29T01:40:00Z", "2017-10-29T02:10:00Z"},
+		[]bool{true, false, true, false},
+	}} {
+		p, err := NewCron().Create(ti.args)
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		cp := p.(*cronPredicate)
+		for i, s := range ti.times {
+			now := mustParseTime(t, s)
+			cp.getTime = func() time.Time { return now }
+			if cp.Match(&http.Request{}) != ti.expected[i] {
+				t.Error(ti.msg, s, "expected match:", ti.expected[i])
+			}
+		}
+	}
+}
+
+func TestCronWindow(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		args     []interface{}
+		now      string
+		from, to string
+		matches  bool
+	}{{
+		"in the window, until the window ends",
+		[]interface{}{"0 22 * * *", "2h"},
+		"2017-03-06T22:30:10Z",
+		"2017-03-06T22:30:00Z",
+		"2017-03-07T00:00:00Z",
+		true,
+	}, {
+		"out of the window, until the next firing",
+		[]interface{}{"0 22 * * *", "2h"},
+		"2017-03-06T21:30:10Z",
+		"2017-03-06T21:30:00Z",
+		"2017-03-06T22:00:00Z",
+		false,
+	}, {
+		"out of the window, next firing not in sight",
+		[]interface{}{"0 22 * * *", "2h"},
+		"2017-03-06T12:30:10Z",
+		"2017-03-06T12:30:00Z",
+		"2017-03-06T13:30:00Z",
+		false,
+	}} {
+		p, err := NewCron().Create(ti.args)
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		cp := p.(*cronPredicate)
+		now := mustParseTime(t, ti.now)
+		cp.getTime = func() time.Time { return now }
+		if cp.Match(&http.Request{}) != ti.matches {
+			t.Error(ti.msg, "expected match:", ti.matches)
+		}
+
+		w, ok := cp.currentWindow(now)
+		if !ok {
+			t.Error(ti.msg, "window not cached")
+			continue
+		}
+
+		if !w.from.Equal(mustParseTime(t, ti.from)) || !w.to.Equal(mustParseTime(t, ti.to)) {
+			t.Error(ti.msg, "invalid window", w.from, w.to)
+		}
+	}
+}
+
+func TestWeekdayMatch(t *testing.T) {
+	p, err := NewWeekday().Create([]interface{}{"Mon-Fri", "Europe/Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wp := p.(*weekdayPredicate)
+	for _, ti := range []struct {
+		time     string
+		expected bool
+	}{
+		// Friday 23:30 UTC is Saturday in Berlin
+		{"2017-03-10T22:30:00Z", true},
+		{"2017-03-10T23:30:00Z", false},
+		// Sunday 23:30 UTC is Monday in Berlin
+		{"2017-03-12T22:30:00Z", false},
+		{"2017-03-12T23:30:00Z", true},
+	} {
+		now := mustParseTime(t, ti.time)
+		wp.getTime = func() time.Time { return now }
+		if wp.Match(&http.Request{}) != ti.expected {
+			t.Error(ti.time, "expected match:", ti.expected)
+		}
+	}
+
+	p, err = NewWeekday().Create([]interface{}{"Sat-Mon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wp = p.(*weekdayPredicate)
+	if !wp.days[time.Saturday] || !wp.days[time.Sunday] || !wp.days[time.Monday] || wp.days[time.Tuesday] {
+		t.Error("failed to wrap around the end of the week", wp.days)
+	}
+}
+
+func TestTimeOfDayMatch(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		args     []interface{}
+		time     string
+		expected bool
+	}{
+		{"in window", []interface{}{"09:00", "17:00"}, "2017-03-06T09:00:00Z", true},
+		{"before window", []interface{}{"09:00", "17:00"}, "2017-03-06T08:59:59Z", false},
+		{"end excluded", []interface{}{"09:00", "17:00"}, "2017-03-06T17:00:00Z", false},
+		{"over midnight, before", []interface{}{"22:00", "06:00"}, "2017-03-06T23:00:00Z", true},
+		{"over midnight, after", []interface{}{"22:00", "06:00"}, "2017-03-06T05:00:00Z", true},
+		{"over midnight, outside", []interface{}{"22:00", "06:00"}, "2017-03-06T12:00:00Z", false},
+		{"winter time", []interface{}{"09:00", "17:00", "Europe/Berlin"}, "2017-01-02T07:30:00Z", false},
+		{"summer time", []interface{}{"09:00", "17:00", "Europe/Berlin"}, "2017-07-03T07:30:00Z", true},
+	} {
+		p, err := NewTimeOfDay().Create(ti.args)
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		tp := p.(*timeOfDayPredicate)
+		now := mustParseTime(t, ti.time)
+		tp.getTime = func() time.Time { return now }
+		if tp.Match(&http.Request{}) != ti.expected {
+			t.Error(ti.msg, "expected match:", ti.expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package traffic
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// The eskip name of the consistent hashing variant of the predicate.
+	HashPredicateName = "TrafficHash"
+)
+
+type hashSpec struct{}
+
+type hashPredicate struct {
+	min, max float64
+	key      func(*http.Request) string
+}
+
+// Creates a new traffic control predicate specification, that selects
+// the requests by consistent hashing of a request property instead of
+// by chance.
+func NewHash() routing.PredicateSpec { return &hashSpec{} }
+
+func (s *hashSpec) Name() string { return HashPredicateName }
+
+// The client address is taken from the X-Forwarded-For header only when
+// the number of trusted proxies is set, the same way as in the source
+// filters, because otherwise the clients could choose their segment by
+// setting the header.
+func clientIPKey(hops int) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if ip := snet.RemoteHostTrusted(r, hops); ip != nil {
+			return ip.String()
+		}
+
+		return ""
+	}
+}
+
+func parseHashKey(arg string) (func(*http.Request) string, bool) {
+	if arg == "ip" {
+		return clientIPKey(0), true
+	}
+
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, false
+	}
+
+	name := kv[1]
+	switch kv[0] {
+	case "ip":
+		hops, err := strconv.Atoi(name)
+		if err != nil || hops < 0 {
+			return nil, false
+		}
+
+		return clientIPKey(hops), true
+	case "header":
+		return func(r *http.Request) string { return r.Header.Get(name) }, true
+	case "cookie":
+		return func(r *http.Request) string {
+			if c, err := r.Cookie(name); err == nil {
+				return c.Value
+			}
+
+			return ""
+		}, true
+	case "query":
+		return func(r *http.Request) string { return r.URL.Query().Get(name) }, true
+	default:
+		return nil, false
+	}
+}
+
+func (s *hashSpec) Create(args []interface{}) (routing.Predicate, error) {
+	var bounds []float64
+	for len(args) > 0 {
+		b, ok := args[0].(float64)
+		if !ok {
+			break
+		}
+
+		bounds = append(bounds, b)
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &hashPredicate{}
+	switch len(bounds) {
+	case 1:
+		p.max = bounds[0]
+	case 2:
+		p.min, p.max = bounds[0], bounds[1]
+	default:
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	if p.min < 0 || p.max > 1 || p.min >= p.max {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	if p.key, ok = parseHashKey(arg); !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return p, nil
+}
+
+// maps a key to the [0, 1) interval
+func hashPosition(key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+func (p *hashPredicate) Match(r *http.Request) bool {
+	key := p.key(r)
+	if key == "" {
+		return false
+	}
+
+	pos := hashPosition(key)
+	return p.min <= pos && pos < p.max
+}
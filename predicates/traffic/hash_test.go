@@ -0,0 +1,200 @@
+package traffic
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHashCreate(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"no key",
+		[]interface{}{.1, .2},
+		true,
+	}, {
+		"no bounds",
+		[]interface{}{"ip"},
+		true,
+	}, {
+		"upper bound only",
+		[]interface{}{.1, "header=X-User-Id"},
+		false,
+	}, {
+		"segment",
+		[]interface{}{.1, .3, "cookie=user"},
+		false,
+	}, {
+		"full range",
+		[]interface{}{0.0, 1.0, "query=user"},
+		false,
+	}, {
+		"too many bounds",
+		[]interface{}{.1, .2, .3, "ip"},
+		true,
+	}, {
+		"empty segment",
+		[]interface{}{.3, .3, "ip"},
+		true,
+	}, {
+		"out of range",
+		[]interface{}{.3, 1.3, "ip"},
+		true,
+	}, {
+		"negative",
+		[]interface{}{-.3, "ip"},
+		true,
+	}, {
+		"unknown key",
+		[]interface{}{.3, "path=user"},
+		true,
+	}, {
+		"missing name",
+		[]interface{}{.3, "header="},
+		true,
+	}, {
+		"trusted proxies",
+		[]interface{}{.3, "ip=1"},
+		false,
+	}, {
+		"invalid trusted proxies",
+		[]interface{}{.3, "ip=-1"},
+		true,
+	}} {
+		_, err := NewHash().Create(ti.args)
+		if (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	for _, ti := range []struct {
+		msg string
+		key string
+		req func(*http.Request)
+	}{{
+		"header",
+		"header=X-User-Id",
+		func(r *http.Request) { r.Header.Set("X-User-Id", "user") },
+	}, {
+		"cookie",
+		"cookie=user",
+		func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "user", Value: "user"}) },
+	}, {
+		"query",
+		"query=user",
+		func(r *http.Request) { r.URL.RawQuery = "user=user" },
+	}, {
+		"ip",
+		"ip",
+		func(r *http.Request) { r.RemoteAddr = "10.0.0.1:4321" },
+	}, {
+		"ip with trusted proxies",
+		"ip=1",
+		func(r *http.Request) { r.Header.Set("X-Forwarded-For", "10.0.0.1") },
+	}} {
+		p, err := NewHash().Create([]interface{}{0.0, 1.0, ti.key})
+		if err != nil {
+			t.Fatal(ti.msg, err)
+		}
+
+		r, err := http.NewRequest("GET", "https://www.example.org", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Match(r) {
+			t.Error(ti.msg, "matched without key")
+		}
+
+		ti.req(r)
+		if !p.Match(r) {
+			t.Error(ti.msg, "failed to match")
+		}
+	}
+}
+
+func TestHashSegments(t *testing.T) {
+	var segments []*hashPredicate
+	for _, b := range [][]float64{{0, .1}, {.1, .4}, {.4, 1}} {
+		p, err := NewHash().Create([]interface{}{b[0], b[1], "header=X-User-Id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		segments = append(segments, p.(*hashPredicate))
+	}
+
+	const n = 10000
+	counts := make([]int, len(segments))
+	for i := 0; i < n; i++ {
+		r := &http.Request{Header: http.Header{"X-User-Id": []string{fmt.Sprintf("user-%d", i)}}}
+		matched := -1
+		for j, s := range segments {
+			if s.Match(r) {
+				if matched >= 0 {
+					t.Fatal("overlapping segments", i)
+				}
+
+				matched = j
+				counts[j]++
+			}
+		}
+
+		if matched < 0 {
+			t.Fatal("no segment matched", i)
+		}
+
+		// the same key matches the same segment
+		if !segments[matched].Match(r) {
+			t.Fatal("inconsistent match", i)
+		}
+	}
+
+	for i, expected := range []float64{.1, .3, .6} {
+		ratio := float64(counts[i]) / n
+		if ratio < expected-.03 || ratio > expected+.03 {
+			t.Error("unexpected distribution", i, ratio, expected)
+		}
+	}
+}
+
+func TestHashIPIgnoresForwardedForWithoutTrustedProxies(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		key      string
+		expected string
+	}{{
+		"no trusted proxies",
+		"ip",
+		"10.0.0.1",
+	}, {
+		"one trusted proxy",
+		"ip=1",
+		"192.168.0.2",
+	}, {
+		"more trusted proxies than addresses",
+		"ip=3",
+		"192.168.0.1",
+	}} {
+		key, ok := parseHashKey(ti.key)
+		if !ok {
+			t.Fatal(ti.msg, "failed to parse key")
+		}
+
+		r := &http.Request{
+			RemoteAddr: "10.0.0.1:4321",
+			Header:     http.Header{"X-Forwarded-For": []string{"192.168.0.1, 192.168.0.2"}}}
+		if k := key(r); k != ti.expected {
+			t.Error(ti.msg, "invalid key", k, ti.expected)
+		}
+	}
+}
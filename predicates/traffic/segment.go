@@ -0,0 +1,63 @@
+package traffic
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	// The eskip name of the traffic segment predicate.
+	SegmentPredicateName = "TrafficSegment"
+)
+
+type segmentSpec struct{}
+
+type segmentPredicate struct {
+	min, max float64
+}
+
+// Creates a new traffic segment predicate specification. The predicates
+// match the requests whose random value, drawn once per request and
+// shared by all the TrafficSegment predicates, falls in the segment.
+func NewSegment() routing.PredicateSpec { return &segmentSpec{} }
+
+func (s *segmentSpec) Name() string { return SegmentPredicateName }
+
+func (s *segmentSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	min, ok := args[0].(float64)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	max, ok := args[1].(float64)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	if min < 0 || max > 1 || min >= max {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &segmentPredicate{min: min, max: max}, nil
+}
+
+func (p *segmentPredicate) matchValue(v float64) bool {
+	return p.min <= v && v < p.max
+}
+
+// Match is used only when the predicate is evaluated outside of the
+// routing, and it draws its own random value.
+func (p *segmentPredicate) Match(*http.Request) bool {
+	return p.matchValue(rand.Float64())
+}
+
+func (p *segmentPredicate) MatchContext(ctx *routing.MatchContext, _ *http.Request) bool {
+	return p.matchValue(ctx.RandomValue())
+}
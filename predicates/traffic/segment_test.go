@@ -0,0 +1,90 @@
+package traffic
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func TestSegmentCreate(t *testing.T) {
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		"no args",
+		nil,
+		true,
+	}, {
+		"one arg",
+		[]interface{}{.1},
+		true,
+	}, {
+		"not number",
+		[]interface{}{.1, "foo"},
+		true,
+	}, {
+		"empty segment",
+		[]interface{}{.3, .3},
+		true,
+	}, {
+		"reversed",
+		[]interface{}{.3, .1},
+		true,
+	}, {
+		"out of range",
+		[]interface{}{.3, 1.1},
+		true,
+	}, {
+		"valid",
+		[]interface{}{.1, .3},
+		false,
+	}, {
+		"full range",
+		[]interface{}{0.0, 1.0},
+		false,
+	}} {
+		_, err := NewSegment().Create(ti.args)
+		if (err != nil) != ti.err {
+			t.Error(ti.msg, err)
+		}
+	}
+}
+
+func TestSegmentSplit(t *testing.T) {
+	var segments []routing.ContextPredicate
+	for _, b := range [][]float64{{0, .1}, {.1, .4}, {.4, 1}} {
+		p, err := NewSegment().Create([]interface{}{b[0], b[1]})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		segments = append(segments, p.(routing.ContextPredicate))
+	}
+
+	const n = 10000
+	counts := make([]int, len(segments))
+	r := &http.Request{}
+	for i := 0; i < n; i++ {
+		ctx := &routing.MatchContext{}
+		matched := 0
+		for j, s := range segments {
+			if s.MatchContext(ctx, r) {
+				matched++
+				counts[j]++
+			}
+		}
+
+		if matched != 1 {
+			t.Fatal("the segments should match exactly once", matched)
+		}
+	}
+
+	for i, expected := range []float64{.1, .3, .6} {
+		ratio := float64(counts[i]) / n
+		if ratio < expected-.03 || ratio > expected+.03 {
+			t.Error("unexpected distribution", i, ratio, expected)
+		}
+	}
+}
@@ -66,6 +66,66 @@ independent traffic controlled route sets, which uses session stickiness:
         responseCookie("catalog-test", "default") ->
         "https://catalog";
 
+The TrafficHash variant of the predicate doesn't take a chance, but
+selects the requests by hashing a request property, so the same client
+consistently matches the same route, without setting a cookie. The last
+argument specifies the property:
+
+    header=<name>: the value of a request header
+    cookie=<name>: the value of a request cookie
+    query=<name>: the value of a query parameter
+    ip: the remote address of the connection
+    ip=<hops>: the address added to the X-Forwarded-For header by the
+        first of the given number of trusted proxies in front of
+        skipper, counted from the end of the header
+
+The hash of the property is mapped to the [0, 1) interval, and the
+predicate matches when it falls in the segment set by the first two
+arguments, the lower bound inclusive, the upper bound exclusive. When
+only one number is passed in, the segment starts at 0. This way, several
+routes can split the same key space into non-overlapping segments.
+Requests without the property don't match. The X-Forwarded-For header is
+used only with ip=<hops>, because otherwise the clients could select
+their segment by setting the header.
+
+    // 10% of the users
+    v3:
+        TrafficHash(0, .1, "header=X-User-Id") ->
+        "https://api-v3";
+
+    // another 20% of the users
+    v2:
+        TrafficHash(.1, .3, "header=X-User-Id") ->
+        "https://api-v2";
+
+    // the remaining users, and the requests without a user id
+    v1:
+        "https://api-v1";
+
+Each Traffic predicate draws its own random number, so with multiple
+Traffic routes, the chance of a route depends on the routes matched
+before it. The TrafficSegment predicate takes the segment of the [0, 1)
+interval as two arguments, the lower bound inclusive, the upper bound
+exclusive. The random value is drawn once per request, and shared by all
+the TrafficSegment predicates while matching the request, so a set of
+routes with non-overlapping segments splits the traffic exactly in the
+configured proportions:
+
+    // 10%
+    v3:
+        TrafficSegment(0, .1) ->
+        "https://api-v3";
+
+    // 20%
+    v2:
+        TrafficSegment(.1, .3) ->
+        "https://api-v2";
+
+    // 70%
+    v1:
+        TrafficSegment(.3, 1) ->
+        "https://api-v1";
+
 */
 package traffic
 
@@ -28,6 +28,7 @@ type (
 	debugDocument struct {
 		RouteId         string             `json:"route_id,omitempty"`
 		Route           string             `json:"route,omitempty"`
+		RouteWeight     int                `json:"route_weight,omitempty"`
 		Incoming        *debugRequest      `json:"incoming,omitempty"`
 		Outgoing        *debugRequest      `json:"outgoing,omitempty"`
 		ResponseMod     *debugResponseMod  `json:"response_mod,omitempty"`
@@ -44,6 +45,7 @@ type (
 
 type debugInfo struct {
 	route        *eskip.Route
+	weight       int
 	incoming     *http.Request
 	outgoing     *http.Request
 	response     *http.Response
@@ -80,6 +82,7 @@ func convertDebugInfo(d *debugInfo) debugDocument {
 	if d.route != nil {
 		doc.RouteId = d.route.Id
 		doc.Route = d.route.String()
+		doc.RouteWeight = d.weight
 		doc.Filters = d.route.Filters
 		doc.Predicates = d.route.Predicates
 	}
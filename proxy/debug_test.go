@@ -79,6 +79,21 @@ func TestDebug(t *testing.T) {
 				Header: http.Header{"X-Test-Response-Header": []string{"test-response-header-value"}}},
 			RequestBody:     "outgoing body content",
 			ResponseModBody: "response body"},
+	}, {
+		"route with weight",
+		debugInfo{
+			route: &eskip.Route{
+				Id:         "testRoute",
+				Backend:    "https://www.example.org",
+				Predicates: []*eskip.Predicate{{Name: "Weight", Args: []interface{}{float64(3)}}}},
+			weight: 3},
+		debugDocument{
+			RouteId: "testRoute",
+			Route: (&eskip.Route{
+				Backend:    "https://www.example.org",
+				Predicates: []*eskip.Predicate{{Name: "Weight", Args: []interface{}{float64(3)}}}}).String(),
+			RouteWeight: 3,
+			Predicates:  []*eskip.Predicate{{Name: "Weight", Args: []interface{}{float64(3)}}}},
 	}, {
 		"route not found",
 		debugInfo{
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testBackendHeader = "X-Test-Backend"
+
+func startEndpoints(n int) []*httptest.Server {
+	s := make([]*httptest.Server, n)
+	for i := range s {
+		index := strconv.Itoa(i)
+		s[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(testBackendHeader, index)
+			w.Header().Set("X-Test-Host", r.Host)
+			addr := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+			w.Header().Set("Location", "http://"+addr.String()+"/login")
+		}))
+	}
+
+	return s
+}
+
+func endpointList(s []*httptest.Server) string {
+	var u []string
+	for _, si := range s {
+		u = append(u, si.URL)
+	}
+
+	return strings.Join(u, ",")
+}
+
+func testLBRequest(t *testing.T, url string, header http.Header) *http.Response {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header = header
+	rsp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	return rsp
+}
+
+func TestLoadBalancedRandom(t *testing.T) {
+	s := startEndpoints(3)
+	for _, si := range s {
+		defer si.Close()
+	}
+
+	p, err := newTestProxy(fmt.Sprintf(`* -> "%s"`, endpointList(s)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.close()
+
+	ps := httptest.NewServer(p.proxy)
+	defer ps.Close()
+
+	selected := make(map[string]bool)
+	for i := 0; i < 60; i++ {
+		rsp := testLBRequest(t, ps.URL, http.Header{})
+		b := rsp.Header.Get(testBackendHeader)
+		selected[b] = true
+
+		index, _ := strconv.Atoi(b)
+		if rsp.Header.Get("X-Test-Host") != strings.TrimPrefix(s[index].URL, "http://") {
+			t.Error("invalid outgoing host", rsp.Header.Get("X-Test-Host"))
+		}
+	}
+
+	if len(selected) != len(s) {
+		t.Error("failed to balance the requests", selected)
+	}
+}
+
+func TestStickyCookie(t *testing.T) {
+	s := startEndpoints(3)
+	for _, si := range s {
+		defer si.Close()
+	}
+
+	p, err := newTestProxy(fmt.Sprintf(`* -> stickyCookie("sticky", "secure=false") -> "%s"`, endpointList(s)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.close()
+
+	ps := httptest.NewServer(p.proxy)
+	defer ps.Close()
+
+	rsp := testLBRequest(t, ps.URL, http.Header{})
+	backend := rsp.Header.Get(testBackendHeader)
+	setCookie := rsp.Header.Get("Set-Cookie")
+	if !strings.HasPrefix(setCookie, "sticky=") {
+		t.Fatal("failed to set the sticky cookie", setCookie)
+	}
+
+	cookie := strings.Split(setCookie, ";")[0]
+	if strings.Contains(cookie, "127.0.0.1") {
+		t.Error("the cookie is not opaque", cookie)
+	}
+
+	for i := 0; i < 30; i++ {
+		rsp := testLBRequest(t, ps.URL, http.Header{"Cookie": []string{cookie}})
+		if rsp.Header.Get(testBackendHeader) != backend {
+			t.Fatal("failed to stick to the endpoint", backend, rsp.Header.Get(testBackendHeader))
+		}
+
+		if rsp.Header.Get("Set-Cookie") != "" {
+			t.Error("unexpected cookie update")
+		}
+	}
+
+	// the failed connection marks the endpoint unhealthy
+	index, _ := strconv.Atoi(backend)
+	s[index].Close()
+	rsp = testLBRequest(t, ps.URL, http.Header{"Cookie": []string{cookie}})
+	if rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Error("failed to fail", rsp.StatusCode)
+	}
+
+	rsp = testLBRequest(t, ps.URL, http.Header{"Cookie": []string{cookie}})
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get(testBackendHeader) == backend {
+		t.Error("failed to fall back to another endpoint", rsp.StatusCode, rsp.Header.Get(testBackendHeader))
+	}
+
+	if newCookie := rsp.Header.Get("Set-Cookie"); newCookie == "" || strings.HasPrefix(newCookie, cookie+";") {
+		t.Error("failed to update the sticky cookie", newCookie)
+	}
+}
+
+func TestStickyHeader(t *testing.T) {
+	s := startEndpoints(5)
+	for _, si := range s {
+		defer si.Close()
+	}
+
+	p, err := newTestProxy(fmt.Sprintf(`* -> stickyHeader("X-Session-Id") -> "%s"`, endpointList(s)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.close()
+
+	ps := httptest.NewServer(p.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 10; i++ {
+		h := http.Header{"X-Session-Id": []string{fmt.Sprintf("session-%d", i)}}
+		backend := testLBRequest(t, ps.URL, h).Header.Get(testBackendHeader)
+		for j := 0; j < 10; j++ {
+			if b := testLBRequest(t, ps.URL, h).Header.Get(testBackendHeader); b != backend {
+				t.Error("failed to select the same endpoint", i, backend, b)
+			}
+		}
+	}
+}
+
+func TestLoadBalancedEndpointFilters(t *testing.T) {
+	s := startEndpoints(3)
+	for _, si := range s {
+		defer si.Close()
+	}
+
+	p, err := newTestProxy(fmt.Sprintf(
+		`* -> preserveHost("false") -> proxyPassReverse() -> "%s"`,
+		endpointList(s),
+	), PreserveHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.close()
+
+	ps := httptest.NewServer(p.proxy)
+	defer ps.Close()
+
+	for i := 0; i < 30; i++ {
+		rsp := testLBRequest(t, ps.URL, http.Header{})
+		index, _ := strconv.Atoi(rsp.Header.Get(testBackendHeader))
+		if rsp.Header.Get("X-Test-Host") != strings.TrimPrefix(s[index].URL, "http://") {
+			t.Error("invalid outgoing host", rsp.Header.Get("X-Test-Host"))
+		}
+
+		if rsp.Header.Get("Location") != ps.URL+"/login" {
+			t.Error("failed to rewrite the location", rsp.Header.Get("Location"))
+		}
+	}
+}
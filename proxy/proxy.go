@@ -28,8 +28,10 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tracing"
 )
 
 const (
@@ -115,6 +117,15 @@ type Params struct {
 
 	// Enable the expiremental upgrade protocol feature
 	ExperimentalUpgrade bool
+
+	// Tracer used to create the spans of the requests. When not set,
+	// the spans are not recorded.
+	Tracer tracing.Tracer
+
+	// The period during which an endpoint of a backend with multiple
+	// endpoints is not selected after a failed connection, unless all
+	// the endpoints are unhealthy. Defaults to 10 seconds.
+	EndpointQuarantine time.Duration
 }
 
 // When set, the proxy will skip the TLS verification on outgoing requests.
@@ -162,6 +173,8 @@ type Proxy struct {
 	quit                chan struct{}
 	flushInterval       time.Duration
 	experimentalUpgrade bool
+	tracer              tracing.Tracer
+	endpointHealth      *loadbalancer.Health
 }
 
 type filterContext struct {
@@ -222,10 +235,10 @@ func copyStream(to flusherWriter, from io.Reader) error {
 
 // creates an outgoing http request to be forwarded to the route endpoint
 // based on the augmented incoming request
-func mapRequest(r *http.Request, rt *routing.Route, host string) (*http.Request, error) {
+func mapRequest(r *http.Request, endpoint routing.LBEndpoint, host string) (*http.Request, error) {
 	u := r.URL
-	u.Scheme = rt.Scheme
-	u.Host = rt.Host
+	u.Scheme = endpoint.Scheme
+	u.Host = endpoint.Host
 
 	body := r.Body
 	if r.ContentLength == 0 {
@@ -237,6 +250,10 @@ func mapRequest(r *http.Request, rt *routing.Route, host string) (*http.Request,
 		return nil, err
 	}
 
+	if body != nil {
+		rr.ContentLength = r.ContentLength
+	}
+
 	rr.Header = cloneHeader(r.Header)
 	rr.Host = host
 
@@ -293,6 +310,10 @@ func WithParams(o Params) *Proxy {
 		m = metrics.Void
 	}
 
+	if o.Tracer == nil || o.Flags.Debug() {
+		o.Tracer = tracing.Noop
+	}
+
 	return &Proxy{
 		routing:             o.Routing,
 		roundTripper:        tr,
@@ -301,7 +322,9 @@ func WithParams(o Params) *Proxy {
 		metrics:             m,
 		quit:                quit,
 		flushInterval:       o.FlushInterval,
-		experimentalUpgrade: o.ExperimentalUpgrade}
+		experimentalUpgrade: o.ExperimentalUpgrade,
+		tracer:              o.Tracer,
+		endpointHealth:      loadbalancer.NewHealth(o.EndpointQuarantine)}
 }
 
 // calls a function with recovering from panics and logging them
@@ -332,6 +355,10 @@ func (p *Proxy) newFilterContext(
 		c.originalRequest = cloneRequestMetadata(r)
 	}
 
+	if len(route.LBEndpoints) > 0 {
+		c.stateBag[loadbalancer.EndpointsKey] = route.LBEndpoints
+	}
+
 	if p.flags.PreserveHost() {
 		c.outgoingHost = r.Host
 	} else {
@@ -417,13 +444,31 @@ func shunt(r *http.Request) *http.Response {
 		Request:    r}
 }
 
+// calls a filter with a span, that is the parent span of the spans created
+// by the filter
+func applyFilterWithSpan(ctx *filterContext, parent tracing.Span, name string, apply func(filters.FilterContext), onErr func(err interface{})) {
+	span := parent.StartChild(name)
+	span.SetTag(tracing.FilterNameTag, name)
+	ctx.stateBag[tracing.FilterSpanKey] = span
+	tryCatch(func() { apply(ctx) }, func(err interface{}) {
+		span.SetTag(tracing.ErrorTag, true)
+		onErr(err)
+	})
+
+	delete(ctx.stateBag, tracing.FilterSpanKey)
+	span.Finish()
+}
+
 // applies all filters to a request
-func (p *Proxy) applyFiltersToRequest(f []*routing.RouteFilter, ctx *filterContext, onErr func(err interface{})) []*routing.RouteFilter {
+func (p *Proxy) applyFiltersToRequest(f []*routing.RouteFilter, ctx *filterContext, parent tracing.Span, onErr func(err interface{})) []*routing.RouteFilter {
+	span := parent.StartChild("request_filters")
+	defer span.Finish()
+
 	var start time.Time
 	var filters = make([]*routing.RouteFilter, 0, len(f))
 	for _, fi := range f {
 		start = time.Now()
-		tryCatch(func() { fi.Request(ctx) }, onErr)
+		applyFilterWithSpan(ctx, span, fi.Name, fi.Request, onErr)
 		p.metrics.MeasureFilterRequest(fi.Name, start)
 		filters = append(filters, fi)
 		if ctx.served || ctx.servedWithResponse {
@@ -434,17 +479,29 @@ func (p *Proxy) applyFiltersToRequest(f []*routing.RouteFilter, ctx *filterConte
 }
 
 // applies filters to a response in reverse order
-func (p *Proxy) applyFiltersToResponse(filters []*routing.RouteFilter, ctx filters.FilterContext, onErr func(err interface{})) {
+func (p *Proxy) applyFiltersToResponse(filters []*routing.RouteFilter, ctx *filterContext, parent tracing.Span, onErr func(err interface{})) {
+	span := parent.StartChild("response_filters")
+	defer span.Finish()
+
 	count := len(filters)
 	var start time.Time
 	for i, _ := range filters {
 		fi := filters[count-1-i]
 		start = time.Now()
-		tryCatch(func() { fi.Response(ctx) }, onErr)
+		applyFilterWithSpan(ctx, span, fi.Name, fi.Response, onErr)
 		p.metrics.MeasureFilterResponse(fi.Name, start)
 	}
 }
 
+// sets the status code of the response on a span, and flags the server
+// errors
+func setStatusTags(span tracing.Span, code int) {
+	span.SetTag(tracing.HTTPStatusCodeTag, code)
+	if code >= http.StatusInternalServerError {
+		span.SetTag(tracing.ErrorTag, true)
+	}
+}
+
 // addBranding overwrites any existing `X-Powered-By` or `Server` header from headerMap
 func addBranding(headerMap http.Header) {
 	headerMap.Set("X-Powered-By", "Skipper")
@@ -462,6 +519,50 @@ func (p *Proxy) lookupRoute(r *http.Request) (rt *routing.Route, params map[stri
 	return p.routing.Route(r)
 }
 
+// selects the endpoint of the backend, and, when it is not the first
+// endpoint of a route with multiple endpoints, sets the outgoing host to
+// its host, unless a filter or the PreserveHost flag set it to a
+// different value.
+func (p *Proxy) selectEndpoint(rt *routing.Route, c *filterContext) routing.LBEndpoint {
+	if len(rt.LBEndpoints) == 0 {
+		e := routing.LBEndpoint{Scheme: rt.Scheme, Host: rt.Host}
+		c.stateBag[loadbalancer.EndpointKey] = e
+		return e
+	}
+
+	e := loadbalancer.Select(rt.LBEndpoints, p.endpointHealth, c.stateBag)
+	if c.outgoingHost == rt.Host && (!p.flags.PreserveHost() || c.req.Host != rt.Host) {
+		c.outgoingHost = e.Host
+	}
+
+	c.stateBag[loadbalancer.EndpointKey] = e
+	return e
+}
+
+// starts the span of the backend request. When a filter set the trace
+// context of the outgoing request to a different trace than the one of the
+// proxy span, e.g. the flowId filter with flowid=traceid, the span
+// continues the trace of the filter, with the sampling decision of the
+// proxy span, so that the injected trace context keeps the trace id set
+// by the filter.
+func (p *Proxy) startBackendSpan(parent tracing.Span, h http.Header) tracing.Span {
+	ph := make(http.Header)
+	parent.Inject(ph)
+	pp, ok := tracing.ParseTraceParent(ph.Get(tracing.TraceParentHeaderName))
+	if !ok {
+		return parent.StartChild("backend")
+	}
+
+	fp, ok := tracing.ParseTraceParent(h.Get(tracing.TraceParentHeaderName))
+	if !ok || fp.TraceID == pp.TraceID {
+		return parent.StartChild("backend")
+	}
+
+	fp.Flags = pp.Flags
+	h.Set(tracing.TraceParentHeaderName, fp.String())
+	return p.tracer.StartSpan("backend", h)
+}
+
 // send a premature error response
 func sendError(w http.ResponseWriter, error string, code int) {
 	http.Error(w, error, code)
@@ -472,8 +573,18 @@ func sendError(w http.ResponseWriter, error string, code int) {
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startServe := time.Now()
 
+	span := p.tracer.StartSpan("proxy", r.Header)
+	defer span.Finish()
+	span.SetTag(tracing.SpanKindTag, tracing.SpanKindServer)
+	span.SetTag(tracing.ComponentTag, "skipper")
+	span.SetTag(tracing.HTTPMethodTag, r.Method)
+	span.SetTag(tracing.HTTPHostTag, r.Host)
+	span.SetTag(tracing.HTTPPathTag, r.URL.Path)
+
 	start := startServe
+	lookupSpan := span.StartChild("lookup_route")
 	rt, params := p.lookupRoute(r)
+	lookupSpan.Finish()
 	if rt == nil {
 		if p.flags.Debug() {
 			dbgResponse(w, &debugInfo{
@@ -483,6 +594,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		p.metrics.IncRoutingFailures()
+		setStatusTags(span, http.StatusNotFound)
 		sendError(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		p.metrics.MeasureServe(unknownRouteId, r.Host, r.Method, http.StatusNotFound, startServe)
 		log.Debugf("Could not find a route for %v", r.URL)
@@ -490,6 +602,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p.metrics.MeasureRouteLookup(start)
+	span.SetTag(tracing.RouteIDTag, rt.Id)
 
 	start = time.Now()
 	routeFilters := rt.Filters
@@ -509,24 +622,27 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	processedFilters := p.applyFiltersToRequest(routeFilters, c, onErr)
+	processedFilters := p.applyFiltersToRequest(routeFilters, c, span, onErr)
 	p.metrics.MeasureAllFiltersRequest(rt.Id, start)
 
 	var debugReq *http.Request
 	if !c.served && !c.servedWithResponse {
 		var (
-			rs  *http.Response
-			err error
+			rs       *http.Response
+			err      error
+			endpoint routing.LBEndpoint
 		)
 
 		start = time.Now()
 		if rt.Shunt {
 			rs = shunt(r)
 		} else if p.flags.Debug() {
-			debugReq, err = mapRequest(r, rt, c.outgoingHost)
+			endpoint = p.selectEndpoint(rt, c)
+			debugReq, err = mapRequest(r, endpoint, c.outgoingHost)
 			if err != nil {
 				dbgResponse(w, &debugInfo{
 					route:        &rt.Route,
+					weight:       rt.Weight,
 					incoming:     c.OriginalRequest(),
 					response:     &http.Response{StatusCode: http.StatusInternalServerError},
 					err:          err,
@@ -536,10 +652,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 			rs = &http.Response{Header: make(http.Header)}
 		} else {
-
-			rr, err := mapRequest(r, rt, c.outgoingHost)
+			endpoint = p.selectEndpoint(rt, c)
+			rr, err := mapRequest(r, endpoint, c.outgoingHost)
 			if err != nil {
 				log.Errorf("Could not mapRequest, caused by: %v", err)
+				setStatusTags(span, http.StatusInternalServerError)
 				sendError(w,
 					http.StatusText(http.StatusInternalServerError),
 					http.StatusInternalServerError)
@@ -552,10 +669,15 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				backendURL, err := url.Parse(rt.Backend)
 				if err != nil {
 					log.Errorf("Can not parse backend %s, caused by: %s", rt.Backend, err)
+					setStatusTags(span, http.StatusBadGateway)
 					sendError(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
 					return
 				}
 
+				if len(rt.LBEndpoints) > 0 {
+					backendURL = &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host}
+				}
+
 				reverseProxy := httputil.NewSingleHostReverseProxy(backendURL)
 				reverseProxy.FlushInterval = p.flushInterval
 				upgradeProxy := upgradeProxy{
@@ -570,15 +692,30 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			backendSpan := p.startBackendSpan(span, rr.Header)
+			backendSpan.SetTag(tracing.SpanKindTag, tracing.SpanKindClient)
+			backendSpan.SetTag(tracing.BackendHostTag, endpoint.Host)
+			backendSpan.Inject(rr.Header)
 			rs, err = p.roundTripper.RoundTrip(rr)
+			if err != nil {
+				backendSpan.SetTag(tracing.ErrorTag, true)
+			} else {
+				setStatusTags(backendSpan, rs.StatusCode)
+			}
+
+			backendSpan.Finish()
 
 			if err != nil {
 				p.metrics.IncErrorsBackend(rt.Id)
 				code := http.StatusInternalServerError
 				if _, ok := err.(net.Error); ok {
 					code = http.StatusServiceUnavailable
+					if len(rt.LBEndpoints) > 0 {
+						p.endpointHealth.Failed(endpoint)
+					}
 				}
 
+				setStatusTags(span, code)
 				sendError(w, http.StatusText(code), code)
 				p.metrics.MeasureServe(rt.Id, r.Host, r.Method, code, startServe)
 				log.Error("error during backend roundtrip: ", err)
@@ -587,7 +724,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		p.metrics.MeasureBackend(rt.Id, start)
-		p.metrics.MeasureBackendHost(rt.Host, start)
+		p.metrics.MeasureBackendHost(endpoint.Host, start)
 		c.res = rs
 	}
 
@@ -595,7 +732,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !c.served && p.flags.PreserveOriginal() {
 		c.originalResponse = cloneResponseMetadata(c.Response())
 	}
-	p.applyFiltersToResponse(processedFilters, c, onErr)
+	p.applyFiltersToResponse(processedFilters, c, span, onErr)
 	p.metrics.MeasureAllFiltersResponse(rt.Id, start)
 
 	if c.res.Body != nil {
@@ -611,6 +748,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if p.flags.Debug() {
 			dbgResponse(w, &debugInfo{
 				route:        &rt.Route,
+				weight:       rt.Weight,
 				incoming:     c.OriginalRequest(),
 				outgoing:     debugReq,
 				response:     c.Response(),
@@ -632,6 +770,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	setStatusTags(span, c.Response().StatusCode)
 	p.metrics.MeasureServe(rt.Id, r.Host, r.Method, c.Response().StatusCode, startServe)
 }
 
@@ -983,3 +983,38 @@ func TestRoundtripperRetry(t *testing.T) {
 		t.Error("failed to retry failing connection")
 	}
 }
+
+func TestForwardsContentLength(t *testing.T) {
+	payload := []byte("Hello, world!")
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != int64(len(payload)) || len(r.TransferEncoding) != 0 {
+			t.Error("invalid request framing", r.ContentLength, r.TransferEncoding)
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil || !bytes.Equal(b, payload) {
+			t.Error("invalid request body", string(b), err)
+		}
+	}))
+	defer backend.Close()
+
+	p, err := newTestProxy(fmt.Sprintf(`* -> "%s"`, backend.URL), FlagsNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer p.close()
+
+	ps := httptest.NewServer(p.proxy)
+	defer ps.Close()
+
+	rsp, err := http.Post(ps.URL, "text/plain", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Error("failed to forward the request", rsp.StatusCode)
+	}
+}
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/tracing"
+)
+
+type recordingExporter struct {
+	mx    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+type childSpanFilter struct{}
+
+func (e *recordingExporter) Export(s *tracing.SpanData) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) find(operation string) *tracing.SpanData {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	for _, s := range e.spans {
+		if s.Operation == operation {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (e *recordingExporter) hasParent(operation, parent string) bool {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	for _, s := range e.spans {
+		if s.Operation != operation {
+			continue
+		}
+
+		for _, ps := range e.spans {
+			if ps.Operation == parent && ps.SpanID == s.ParentID && ps.TraceID == s.TraceID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *childSpanFilter) Name() string                                       { return "childSpan" }
+func (f *childSpanFilter) CreateFilter([]interface{}) (filters.Filter, error) { return f, nil }
+func (f *childSpanFilter) Response(filters.FilterContext)                     {}
+
+func (f *childSpanFilter) Request(ctx filters.FilterContext) {
+	span := tracing.SpanFromContext(ctx).StartChild("custom")
+	span.SetTag("custom", true)
+	span.Finish()
+}
+
+func TestTracingSpans(t *testing.T) {
+	var backendTraceParent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendTraceParent = r.Header.Get(tracing.TraceParentHeaderName)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+
+	dc, err := testdataclient.NewDoc(`route1: * -> childSpan() -> setResponseHeader("X-Test", "foo") -> "` + backend.URL + `"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr := builtin.MakeRegistry()
+	fr.Register(&childSpanFilter{})
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: fr,
+		PollTimeout:    sourcePollTimeout,
+		DataClients:    []routing.DataClient{dc},
+		Log:            tl})
+	defer rt.Close()
+
+	exporter := &recordingExporter{}
+	p := WithParams(Params{Routing: rt, Tracer: tracing.New(tracing.Options{Exporter: exporter, SampleRatio: 1})})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	r, err := http.NewRequest("GET", "https://www.example.org/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Header.Set(tracing.TraceParentHeaderName, "00-"+traceID+"-"+parentID+"-01")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot {
+		t.Fatal("unexpected status code", w.Code)
+	}
+
+	root := exporter.find("proxy")
+	if root == nil {
+		t.Fatal("missing root span")
+	}
+
+	if root.TraceID != traceID || root.ParentID != parentID {
+		t.Error("failed to continue the incoming trace", root.TraceID, root.ParentID)
+	}
+
+	if root.Tags[tracing.RouteIDTag] != "route1" || root.Tags[tracing.HTTPStatusCodeTag] != http.StatusTeapot {
+		t.Error("invalid root span tags", root.Tags)
+	}
+
+	for _, ti := range []struct {
+		operation string
+		parent    string
+	}{
+		{"lookup_route", "proxy"},
+		{"request_filters", "proxy"},
+		{"childSpan", "request_filters"},
+		{"custom", "childSpan"},
+		{"backend", "proxy"},
+		{"response_filters", "proxy"},
+		{"setResponseHeader", "response_filters"},
+	} {
+		// the filters have spans both in the request and the response
+		// phase, so any span of the operation can have the parent
+		if !exporter.hasParent(ti.operation, ti.parent) {
+			t.Error("missing span or invalid parent", ti.operation, ti.parent)
+		}
+	}
+
+	b := exporter.find("backend")
+	if b == nil {
+		t.FailNow()
+	}
+
+	if b.Tags[tracing.BackendHostTag] != backend.Listener.Addr().String() {
+		t.Error("invalid backend host tag", b.Tags[tracing.BackendHostTag])
+	}
+
+	if backendTraceParent != "00-"+traceID+"-"+b.SpanID+"-01" {
+		t.Error("failed to propagate the trace context", backendTraceParent)
+	}
+}
+
+func TestTracingFlagsBackendErrors(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`* -> "http://127.0.0.1:1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		PollTimeout:    sourcePollTimeout,
+		DataClients:    []routing.DataClient{dc},
+		Log:            tl})
+	defer rt.Close()
+
+	exporter := &recordingExporter{}
+	p := WithParams(Params{Routing: rt, Tracer: tracing.New(tracing.Options{Exporter: exporter, SampleRatio: 1})})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), r)
+
+	if b := exporter.find("backend"); b == nil || b.Tags[tracing.ErrorTag] != true {
+		t.Error("failed to flag the backend error")
+	}
+
+	if root := exporter.find("proxy"); root == nil || root.Tags[tracing.ErrorTag] != true {
+		t.Error("failed to flag the error of the request")
+	}
+}
+
+func TestTracingKeepsFlowIDTraceContext(t *testing.T) {
+	var backendTraceParent, backendFlowID string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendTraceParent = r.Header.Get(tracing.TraceParentHeaderName)
+		backendFlowID = r.Header.Get("X-Flow-Id")
+	}))
+	defer backend.Close()
+
+	dc, err := testdataclient.NewDoc(`* -> flowId("flowid=traceid") -> "` + backend.URL + `"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl := loggingtest.New()
+	defer tl.Close()
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		PollTimeout:    sourcePollTimeout,
+		DataClients:    []routing.DataClient{dc},
+		Log:            tl})
+	defer rt.Close()
+
+	exporter := &recordingExporter{}
+	p := WithParams(Params{Routing: rt, Tracer: tracing.New(tracing.Options{Exporter: exporter, SampleRatio: 1})})
+	defer p.Close()
+
+	if err := tl.WaitFor("route settings applied", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest("GET", "https://www.example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.ServeHTTP(httptest.NewRecorder(), r)
+
+	tp, ok := tracing.ParseTraceParent(backendTraceParent)
+	if !ok || tp.TraceID != backendFlowID {
+		t.Fatal("failed to keep the trace id of the flow id", backendTraceParent, backendFlowID)
+	}
+
+	b := exporter.find("backend")
+	if b == nil || b.TraceID != backendFlowID || tp.ParentID != b.SpanID || tp.Flags != tracing.FlagSampled {
+		t.Error("failed to continue the trace of the flow id in the backend span", b, backendTraceParent)
+	}
+}
@@ -3,6 +3,7 @@ package routing
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/zalando/skipper/eskip"
@@ -182,18 +183,30 @@ func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan []*eskip.Route {
 }
 
 // splits the backend address of a route definition into separate
-// scheme and host variables.
-func splitBackend(r *eskip.Route) (string, string, error) {
+// scheme and host variables. When the backend contains a comma
+// separated list of addresses, the scheme and the host of the first one
+// are returned, and the list of all endpoints.
+func splitBackend(r *eskip.Route) (string, string, []LBEndpoint, error) {
 	if r.Shunt {
-		return "", "", nil
+		return "", "", nil, nil
 	}
 
-	bu, err := url.ParseRequestURI(r.Backend)
-	if err != nil {
-		return "", "", err
+	addresses := strings.Split(r.Backend, ",")
+	endpoints := make([]LBEndpoint, len(addresses))
+	for i, a := range addresses {
+		bu, err := url.ParseRequestURI(strings.TrimSpace(a))
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		endpoints[i] = LBEndpoint{Scheme: bu.Scheme, Host: bu.Host}
+	}
+
+	if len(endpoints) == 1 {
+		return endpoints[0].Scheme, endpoints[0].Host, nil, nil
 	}
 
-	return bu.Scheme, bu.Host, nil
+	return endpoints[0].Scheme, endpoints[0].Host, endpoints, nil
 }
 
 // creates a filter instance based on its definition and its
@@ -239,7 +252,7 @@ func isTreePredicate(name string) bool {
 func processPredicates(cpm map[string]PredicateSpec, defs []*eskip.Predicate) ([]Predicate, error) {
 	cps := make([]Predicate, 0, len(defs))
 	for _, def := range defs {
-		if isTreePredicate(def.Name) {
+		if isTreePredicate(def.Name) || def.Name == WeightName {
 			continue
 		}
 
@@ -259,6 +272,29 @@ func processPredicates(cpm map[string]PredicateSpec, defs []*eskip.Predicate) ([
 	return cps, nil
 }
 
+// returns the sum of the Weight predicates of a route
+func processWeight(defs []*eskip.Predicate) (int, error) {
+	var w int
+	for _, def := range defs {
+		if def.Name != WeightName {
+			continue
+		}
+
+		if len(def.Args) != 1 {
+			return 0, predicates.ErrInvalidPredicateParameters
+		}
+
+		v, ok := def.Args[0].(float64)
+		if !ok || v != float64(int(v)) {
+			return 0, predicates.ErrInvalidPredicateParameters
+		}
+
+		w += int(v)
+	}
+
+	return w, nil
+}
+
 // returns the subtree path if it is a valid definition
 func processPathOrSubTree(p *eskip.Predicate) (string, error) {
 	if len(p.Args) != 1 {
@@ -319,7 +355,7 @@ func processTreePredicates(r *Route, predicates []*eskip.Predicate) error {
 
 // processes a route definition for the routing table
 func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *eskip.Route) (*Route, error) {
-	scheme, host, err := splitBackend(def)
+	scheme, host, endpoints, err := splitBackend(def)
 	if err != nil {
 		return nil, err
 	}
@@ -334,7 +370,19 @@ func processRouteDef(cpm map[string]PredicateSpec, fr filters.Registry, def *esk
 		return nil, err
 	}
 
-	r := &Route{Route: *def, Scheme: scheme, Host: host, Predicates: cps, Filters: fs}
+	weight, err := processWeight(def.Predicates)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Route{
+		Route:       *def,
+		Scheme:      scheme,
+		Host:        host,
+		LBEndpoints: endpoints,
+		Weight:      weight,
+		Predicates:  cps,
+		Filters:     fs}
 	if err := processTreePredicates(r, def.Predicates); err != nil {
 		return nil, err
 	}
@@ -1,8 +1,10 @@
 package routing
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/routing/testdataclient"
 )
@@ -62,3 +64,117 @@ func TestNoMultipleTreePredicates(t *testing.T) {
 		}()
 	}
 }
+
+func TestBackendEndpoints(t *testing.T) {
+	for _, ti := range []struct {
+		msg       string
+		backend   string
+		endpoints []LBEndpoint
+		err       bool
+	}{{
+		"single backend",
+		"https://www.example.org",
+		nil,
+		false,
+	}, {
+		"multiple endpoints",
+		"http://10.0.0.1:8080, http://10.0.0.2:8080,https://10.0.0.3",
+		[]LBEndpoint{
+			{"http", "10.0.0.1:8080"},
+			{"http", "10.0.0.2:8080"},
+			{"https", "10.0.0.3"}},
+		false,
+	}, {
+		"invalid endpoint",
+		"http://10.0.0.1:8080,10.0.0.2",
+		nil,
+		true,
+	}} {
+		r, err := processRouteDef(
+			make(map[string]PredicateSpec),
+			make(filters.Registry),
+			&eskip.Route{Backend: ti.backend})
+		if err != nil {
+			if !ti.err {
+				t.Error(ti.msg, err)
+			}
+
+			continue
+		}
+
+		if ti.err {
+			t.Error(ti.msg, "failed to fail")
+			continue
+		}
+
+		if !reflect.DeepEqual(r.LBEndpoints, ti.endpoints) {
+			t.Error(ti.msg, "invalid endpoints", r.LBEndpoints, ti.endpoints)
+		}
+
+		if len(ti.endpoints) > 0 && (r.Scheme != ti.endpoints[0].Scheme || r.Host != ti.endpoints[0].Host) {
+			t.Error(ti.msg, "invalid scheme or host", r.Scheme, r.Host)
+		}
+	}
+}
+
+func TestWeightArgs(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		args   []interface{}
+		weight int
+		err    bool
+	}{{
+		"no args",
+		nil,
+		0,
+		true,
+	}, {
+		"not a number",
+		[]interface{}{"3"},
+		0,
+		true,
+	}, {
+		"not an integer",
+		[]interface{}{1.5},
+		0,
+		true,
+	}, {
+		"too many args",
+		[]interface{}{1.0, 2.0},
+		0,
+		true,
+	}, {
+		"positive",
+		[]interface{}{3.0},
+		3,
+		false,
+	}, {
+		"negative",
+		[]interface{}{-2.0},
+		-2,
+		false,
+	}} {
+		r, err := processRouteDef(
+			make(map[string]PredicateSpec),
+			make(filters.Registry),
+			&eskip.Route{
+				Predicates: []*eskip.Predicate{{Name: WeightName, Args: ti.args}},
+				Shunt:      true})
+		if err != nil {
+			if !ti.err {
+				t.Error(ti.msg, err)
+			}
+
+			continue
+		}
+
+		if ti.err {
+			t.Error(ti.msg, "failed to fail")
+			continue
+		}
+
+		if r.Weight != ti.weight {
+			t.Error(ti.msg, "invalid weight", r.Weight, ti.weight)
+		}
+	}
+}
@@ -47,6 +47,14 @@ HeaderRegexp("Key", "^Value$").
 must be present in the request and one of the associated values must
 match the expression.
 
+- Weight: an integer added to the weight of the route. The routes found
+in the lookup tree for the same path are evaluated in the order of their
+weight, that is, by default, the number of their conditions. The Weight
+predicate allows ordering conflicting routes explicitly, e.g. a route
+with Weight(10) is evaluated before the routes with fewer than ten more
+conditions. The weight can be negative, too. When a route has multiple
+Weight predicates, their values are added.
+
 
 Wildcards
 
@@ -76,6 +84,11 @@ predicate is matched based on the path tree, the predicate receives the
 request object, and it returns true or false meaning that the request is
 a match or not.
 
+Predicates that implement the ContextPredicate interface receive a
+MatchContext too, that is shared by all the predicates evaluated while
+matching the same request. It provides a random value drawn once per
+request, that allows multiple routes to split the traffic exactly.
+
 
 Data Clients
 
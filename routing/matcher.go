@@ -13,6 +13,7 @@ import (
 type leafRequestMatcher struct {
 	r    *http.Request
 	path string
+	ctx  *MatchContext
 }
 
 func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
@@ -21,7 +22,7 @@ func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
 		return false, nil
 	}
 
-	l := matchLeaves(v.leaves, m.r, m.path)
+	l := matchLeaves(v.leaves, m.ctx, m.r, m.path)
 
 	return l != nil, l
 }
@@ -33,6 +34,7 @@ type leafMatcher struct {
 	headersExact  map[string]string
 	headersRegexp map[string][]*regexp.Regexp
 	predicates    []Predicate
+	weight        int
 	route         *Route
 }
 
@@ -50,6 +52,7 @@ func leafWeight(l *leafMatcher) int {
 	w += len(l.headersExact)
 	w += len(l.headersRegexp)
 	w += len(l.predicates)
+	w += l.weight
 
 	return w
 }
@@ -156,6 +159,7 @@ func newLeaf(r *Route) (*leafMatcher, error) {
 		headersExact:  canonicalizeHeaders(r.Headers),
 		headersRegexp: canonicalizeHeaderRegexps(allHeaderRxs),
 		predicates:    r.Predicates,
+		weight:        r.Weight,
 		route:         r}, nil
 }
 
@@ -377,9 +381,13 @@ func matchHeaders(exact map[string]string, hrxs map[string][]*regexp.Regexp, h h
 }
 
 // check if all defined custom predicates are matched
-func matchPredicates(cps []Predicate, req *http.Request) bool {
+func matchPredicates(cps []Predicate, ctx *MatchContext, req *http.Request) bool {
 	for _, cp := range cps {
-		if !cp.Match(req) {
+		if ccp, ok := cp.(ContextPredicate); ok {
+			if !ccp.MatchContext(ctx, req) {
+				return false
+			}
+		} else if !cp.Match(req) {
 			return false
 		}
 	}
@@ -388,7 +396,7 @@ func matchPredicates(cps []Predicate, req *http.Request) bool {
 }
 
 // matches a request to the conditions in a leaf matcher
-func matchLeaf(l *leafMatcher, req *http.Request, path string) bool {
+func matchLeaf(l *leafMatcher, ctx *MatchContext, req *http.Request, path string) bool {
 	if l.method != "" && l.method != req.Method {
 		return false
 	}
@@ -405,7 +413,7 @@ func matchLeaf(l *leafMatcher, req *http.Request, path string) bool {
 		return false
 	}
 
-	if !matchPredicates(l.predicates, req) {
+	if !matchPredicates(l.predicates, ctx, req) {
 		return false
 	}
 
@@ -413,9 +421,9 @@ func matchLeaf(l *leafMatcher, req *http.Request, path string) bool {
 }
 
 // matches a request to a set of leaf matchers
-func matchLeaves(leaves leafMatchers, req *http.Request, path string) *leafMatcher {
+func matchLeaves(leaves leafMatchers, ctx *MatchContext, req *http.Request, path string) *leafMatcher {
 	for _, l := range leaves {
-		if matchLeaf(l, req, path) {
+		if matchLeaf(l, ctx, req, path) {
 			return l
 		}
 	}
@@ -430,7 +438,8 @@ func (m *matcher) match(r *http.Request) (*Route, map[string]string) {
 	// normalize path before matching
 	// in case ignoring trailing slashes, match without the trailing slash
 	path := cleanPath(r.URL.Path, m.matchingOptions)
-	lrm := &leafRequestMatcher{r, path}
+	ctx := &MatchContext{}
+	lrm := &leafRequestMatcher{r, path, ctx}
 
 	// first match fixed and wildcard paths
 	params, l := matchPathTree(m.paths, path, lrm)
@@ -440,7 +449,7 @@ func (m *matcher) match(r *http.Request) (*Route, map[string]string) {
 	}
 
 	// if no path match, match root leaves for other conditions
-	l = matchLeaves(m.rootLeaves, r, path)
+	l = matchLeaves(m.rootLeaves, ctx, r, path)
 	if l != nil {
 		return l.route, nil
 	}
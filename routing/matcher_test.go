@@ -499,7 +499,7 @@ func TestMatchLeafWrongMethod(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if matchLeaf(l, req, "/some/path") {
+	if matchLeaf(l, &MatchContext{}, req, "/some/path") {
 		t.Error("failed not to match leaf method")
 	}
 }
@@ -520,7 +520,7 @@ func TestMatchLeafWrongHost(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if matchLeaf(l, req, "/some/path") {
+	if matchLeaf(l, &MatchContext{}, req, "/some/path") {
 		t.Error("failed not to match leaf host")
 	}
 }
@@ -541,7 +541,7 @@ func TestMatchLeafWrongPath(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if matchLeaf(l, req, "/some/other/path") {
+	if matchLeaf(l, &MatchContext{}, req, "/some/other/path") {
 		t.Error("failed not to match leaf path")
 	}
 }
@@ -562,7 +562,7 @@ func TestMatchLeafWrongExactHeader(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if matchLeaf(l, req, "/some/path") {
+	if matchLeaf(l, &MatchContext{}, req, "/some/path") {
 		t.Error("failed not to match leaf exact header")
 	}
 }
@@ -583,7 +583,7 @@ func TestMatchLeafWrongRegexpHeader(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if matchLeaf(l, req, "/some/path") {
+	if matchLeaf(l, &MatchContext{}, req, "/some/path") {
 		t.Error("failed not to match leaf regexp header")
 	}
 }
@@ -604,7 +604,7 @@ func TestMatchLeaf(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": []*regexp.Regexp{rxhd}}}
-	if !matchLeaf(l, req, "/some/path") {
+	if !matchLeaf(l, &MatchContext{}, req, "/some/path") {
 		t.Error("failed to match leaf")
 	}
 }
@@ -613,7 +613,7 @@ func TestMatchLeavesFalse(t *testing.T) {
 	l0 := &leafMatcher{method: "PUT"}
 	l1 := &leafMatcher{method: "POST"}
 	req := &http.Request{Method: "GET"}
-	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path") != nil {
+	if matchLeaves([]*leafMatcher{l0, l1}, &MatchContext{}, req, "/some/path") != nil {
 		t.Error("failed not to match leaves")
 	}
 }
@@ -622,7 +622,7 @@ func TestMatchLeavesTrue(t *testing.T) {
 	l0 := &leafMatcher{method: "PUT"}
 	l1 := &leafMatcher{method: "POST"}
 	req := &http.Request{Method: "PUT"}
-	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path") != l0 {
+	if matchLeaves([]*leafMatcher{l0, l1}, &MatchContext{}, req, "/some/path") != l0 {
 		t.Error("failed not to match leaves")
 	}
 }
@@ -659,7 +659,7 @@ func TestMatchPathTree(t *testing.T) {
 		t.Error(err)
 	}
 
-	p, v := matchPathTree(tree, "/some/path", &leafRequestMatcher{&http.Request{}, ""})
+	p, v := matchPathTree(tree, "/some/path", &leafRequestMatcher{&http.Request{}, "", &MatchContext{}})
 
 	if len(p) != 0 || v.route.Route.Id != "1" {
 		t.Error("failed to match path", len(p))
@@ -678,7 +678,7 @@ func TestMatchPathTreeWithWildcards(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	p, v := matchPathTree(tree, "/some/path/and/params", &leafRequestMatcher{&http.Request{}, ""})
+	p, v := matchPathTree(tree, "/some/path/and/params", &leafRequestMatcher{&http.Request{}, "", &MatchContext{}})
 	if len(p) != 2 || p["param0"] != "and" || p["param1"] != "params" || v.route.Route.Id != "1" {
 		t.Error("failed to match path", len(p))
 	}
@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"math/rand"
 	"net/http"
 	"sync/atomic"
 	"time"
@@ -20,6 +21,10 @@ const (
 	// (See more details about the Path and PathSubtree predicates
 	// at https://godoc.org/github.com/zalando/skipper/eskip)
 	PathSubtreeName = "PathSubtree"
+
+	// Name of the builtin weight predicate, that sets the explicit
+	// weight of a route.
+	WeightName = "Weight"
 )
 
 // Control flags for route matching.
@@ -52,6 +57,37 @@ type Predicate interface {
 	Match(*http.Request) bool
 }
 
+// MatchContext holds the state of matching a single request, shared by
+// all the predicates evaluated for the request.
+type MatchContext struct {
+	random    float64
+	hasRandom bool
+}
+
+// ContextPredicate is implemented by the custom predicates that depend
+// on the state shared by the predicates while matching a request. When
+// a predicate implements it, the routing calls MatchContext instead of
+// Match.
+type ContextPredicate interface {
+	Predicate
+
+	// Returns true if the request matches the predicate.
+	MatchContext(*MatchContext, *http.Request) bool
+}
+
+// RandomValue returns a random number in the [0, 1) interval. The value
+// is drawn once per request, and it is the same for every predicate
+// evaluated while matching the request, so that multiple routes can
+// split the requests in exact proportions.
+func (c *MatchContext) RandomValue() float64 {
+	if !c.hasRandom {
+		c.random = rand.Float64()
+		c.hasRandom = true
+	}
+
+	return c.random
+}
+
 // PredicateSpec instances are used to create custom predicates
 // (of type Predicate) with concrete arguments during the
 // construction of the routing tree.
@@ -115,6 +151,15 @@ type RouteFilter struct {
 	Index int
 }
 
+// LBEndpoint represents one of the endpoints of a backend with multiple
+// addresses.
+type LBEndpoint struct {
+	Scheme, Host string
+}
+
+// Returns the address of the endpoint in the form of scheme://host.
+func (e LBEndpoint) String() string { return e.Scheme + "://" + e.Host }
+
 // Route object with preprocessed filter instances.
 type Route struct {
 
@@ -130,6 +175,16 @@ type Route struct {
 	// The backend scheme and host.
 	Scheme, Host string
 
+	// The endpoints of the backend, when the backend address contains
+	// a comma separated list of addresses. The Scheme and the Host
+	// fields contain the first endpoint.
+	LBEndpoints []LBEndpoint
+
+	// The explicit weight of the route set with the Weight predicates,
+	// added to the number of its conditions when ordering the routes
+	// matching the same path.
+	Weight int
+
 	// The preprocessed custom predicate instances.
 	Predicates []Predicate
 
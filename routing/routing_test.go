@@ -438,3 +438,100 @@ func TestNonMatchedStaticRoute(t *testing.T) {
 		}
 	}
 }
+
+type contextPredicate struct {
+	values *[]float64
+}
+
+func (cp *contextPredicate) Name() string { return "ContextPredicate" }
+
+func (cp *contextPredicate) Create([]interface{}) (routing.Predicate, error) {
+	return cp, nil
+}
+
+func (cp *contextPredicate) Match(*http.Request) bool { return false }
+
+func (cp *contextPredicate) MatchContext(ctx *routing.MatchContext, _ *http.Request) bool {
+	*cp.values = append(*cp.values, ctx.RandomValue())
+	return false
+}
+
+func TestSharedMatchContext(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+        route1: Path("/foo") && ContextPredicate() -> "https://route1.example.org";
+        route2: ContextPredicate() -> "https://route2.example.org";
+        catchAll: * -> "https://route.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []float64
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{&contextPredicate{&values}}, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	var previous float64
+	for i := 0; i < 3; i++ {
+		values = nil
+		if r, err := tr.checkGetRequest("https://www.example.org/foo"); err != nil || r.Backend != "https://route.example.org" {
+			t.Fatal("failed to match the catch-all route", err)
+		}
+
+		if len(values) != 2 || values[0] != values[1] {
+			t.Fatal("the random value is not shared", values)
+		}
+
+		if i > 0 && values[0] == previous {
+			t.Error("the random value is not drawn per request")
+		}
+
+		previous = values[0]
+	}
+}
+
+func TestWeight(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+        route1: Path("/foo") && Method("GET") && Header("X-Test", "test") -> "https://route1.example.org";
+        route2: Path("/foo") && Weight(3) -> "https://route2.example.org";
+        route3: Path("/bar") && Method("GET") -> "https://route3.example.org";
+        route4: Path("/bar") && Method("GET") && Weight(-1) && Weight(-1) -> "https://route4.example.org";
+        route5: Path("/bar") -> "https://route5.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := newTestRouting(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tr.close()
+
+	req, err := http.NewRequest("GET", "https://www.example.org/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("X-Test", "test")
+	if r, err := tr.checkRequest(req); err != nil || r.Backend != "https://route2.example.org" {
+		t.Error("failed to match the route with the higher weight", err)
+	} else if r.Weight != 3 {
+		t.Error("invalid weight", r.Weight)
+	}
+
+	if r, err := tr.checkGetRequest("https://www.example.org/bar"); err != nil || r.Backend != "https://route3.example.org" {
+		t.Error("failed to match the route with the higher weight", err)
+	}
+
+	req, err = http.NewRequest("POST", "https://www.example.org/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := tr.checkRequest(req); err != nil || r.Backend != "https://route5.example.org" {
+		t.Error("failed to match the route with the lower weight", err)
+	}
+}
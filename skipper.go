@@ -1,10 +1,16 @@
 package skipper
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -12,10 +18,14 @@ import (
 	"github.com/zalando/skipper/eskipfile"
 	"github.com/zalando/skipper/etcd"
 	"github.com/zalando/skipper/filters"
+	authfilters "github.com/zalando/skipper/filters/auth"
 	"github.com/zalando/skipper/filters/builtin"
 	"github.com/zalando/skipper/innkeeper"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/oauth"
+	"github.com/zalando/skipper/predicates/clientcert"
+	"github.com/zalando/skipper/predicates/conn"
 	"github.com/zalando/skipper/predicates/cookie"
 	"github.com/zalando/skipper/predicates/interval"
 	"github.com/zalando/skipper/predicates/query"
@@ -23,6 +33,7 @@ import (
 	"github.com/zalando/skipper/predicates/traffic"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/tracing"
 )
 
 const (
@@ -106,6 +117,15 @@ type Options struct {
 	// The whitespace separated list of OAuth2 scopes.
 	OAuthScope string
 
+	// URL of the OAuth2 token introspection (tokeninfo) endpoint, as
+	// defined in RFC 7662. When set, the oauthTokeninfo* filters are
+	// registered. When OAuthCredentialsDir is set, the client.json
+	// credentials are used to authenticate with the endpoint.
+	OAuthTokeninfoURL string
+
+	// Timeout of the requests to the token introspection endpoint.
+	OAuthTokeninfoTimeout time.Duration
+
 	// File containing static route definitions.
 	RoutesFile string
 
@@ -210,11 +230,48 @@ type Options struct {
 	//Path of key when using TLS
 	KeyPathTLS string
 
+	// Path of a PEM bundle of CA certificates used to verify the client
+	// certificates when using TLS. When set, the clients may send a
+	// certificate, and when they do, it is verified. The verified
+	// certificate can be checked by the ClientCert predicate and the
+	// requireClientCert filter.
+	ClientCAPathTLS string
+
+	// Host names mapped to the paths of PEM bundles of CA certificates.
+	// When a client connects with one of these host names as the server
+	// name (SNI), it is required to send a certificate that can be
+	// verified with the CAs of the host. The requests to these hosts are
+	// rejected, when they were sent on a connection established for a
+	// different server name.
+	ClientCertHostsTLS map[string]string
+
 	// Flush interval for upgraded Proxy connections
 	BackendFlushInterval time.Duration
 
 	// Experimental feature to handle protocol Upgrades for Websockets, SPDY, etc.
 	ExperimentalUpgrade bool
+
+	// Custom tracer used to create the spans of the requests. When set,
+	// the OTLP options are ignored.
+	Tracer tracing.Tracer
+
+	// Address of the OTLP/HTTP receiver, e.g. an OpenTelemetry collector
+	// or Jaeger, where the spans of the requests are sent. When neither
+	// this nor the Tracer is set, the spans are not recorded.
+	OTLPEndpoint string
+
+	// Service name used in the exported spans. Defaults to skipper.
+	TracingServiceName string
+
+	// The ratio of the new traces that are sampled, between 0 and 1.
+	// With 0, which is the default, only the traces continued from the
+	// sampled incoming requests are recorded.
+	TracingSampleRatio float64
+
+	// The period during which an endpoint of a backend with multiple
+	// endpoints is not selected after a failed connection. Defaults to
+	// 10 seconds.
+	EndpointQuarantine time.Duration
 }
 
 func createDataClients(o Options, auth innkeeper.Authentication) ([]routing.DataClient, error) {
@@ -326,15 +383,159 @@ func (o *Options) isHTTPS() bool {
 	return o.CertPathTLS != "" && o.KeyPathTLS != ""
 }
 
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// creates the TLS configuration with the client certificate
+// verification settings
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(o.CertPathTLS, o.KeyPathTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// the protocols are set explicitly, because the http.Server sets them
+	// only on the default configuration, and not on the ones returned by
+	// GetConfigForClient
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"}}
+	if o.ClientCAPathTLS != "" {
+		pool, err := loadCertPool(o.ClientCAPathTLS)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if len(o.ClientCertHostsTLS) == 0 {
+		return config, nil
+	}
+
+	hosts := make(map[string]*tls.Config)
+	for host, caPath := range o.ClientCertHostsTLS {
+		pool, err := loadCertPool(caPath)
+		if err != nil {
+			return nil, err
+		}
+
+		hostConfig := config.Clone()
+		hostConfig.ClientCAs = pool
+		hostConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		hosts[strings.ToLower(host)] = hostConfig
+	}
+
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		// returning nil falls back to the default configuration
+		return hosts[strings.ToLower(hello.ServerName)], nil
+	}
+
+	return config, nil
+}
+
+// rejects the requests to the hosts that require a client certificate,
+// when the TLS handshake was made for a different server name, or without
+// a verified certificate. The server name (SNI) is set by the client
+// independently from the Host header, so the TLS configuration alone
+// cannot enforce the certificate requirement of a host.
+type clientCertHostsHandler struct {
+	hosts   map[string]bool
+	handler http.Handler
+}
+
+func newClientCertHostsHandler(h http.Handler, hosts map[string]string) http.Handler {
+	hh := &clientCertHostsHandler{hosts: make(map[string]bool), handler: h}
+	for host := range hosts {
+		hh.hosts[strings.ToLower(host)] = true
+	}
+
+	return hh
+}
+
+func (h *clientCertHostsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.Host)
+	if hostOnly, _, err := net.SplitHostPort(host); err == nil {
+		host = hostOnly
+	}
+
+	if !h.hosts[host] {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if r.TLS == nil || !strings.EqualFold(r.TLS.ServerName, host) {
+		http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+		return
+	}
+
+	if len(r.TLS.VerifiedChains) == 0 {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
 func listenAndServe(proxy http.Handler, o *Options) error {
-	// create the access log handler
-	loggingHandler := logging.NewHandler(proxy)
 	log.Infof("proxy listener on %v", o.Address)
 	if o.isHTTPS() {
-		return http.ListenAndServeTLS(o.Address, o.CertPathTLS, o.KeyPathTLS, loggingHandler)
+		config, err := o.tlsConfig()
+		if err != nil {
+			return err
+		}
+
+		if len(o.ClientCertHostsTLS) > 0 {
+			proxy = newClientCertHostsHandler(proxy, o.ClientCertHostsTLS)
+		}
+
+		// create the access log handler
+		server := &http.Server{Addr: o.Address, Handler: logging.NewHandler(proxy), TLSConfig: config}
+		return server.ListenAndServeTLS("", "")
 	}
 	log.Infof("certPathTLS or keyPathTLS not found, defaulting to HTTP")
-	return http.ListenAndServe(o.Address, loggingHandler)
+
+	// create the access log handler
+	return http.ListenAndServe(o.Address, logging.NewHandler(proxy))
+}
+
+// BundledPredicates returns the predicates that skipper registers in
+// addition to the custom predicates set in the options. They take
+// precedence over the custom predicates with the same name.
+func BundledPredicates() []routing.PredicateSpec {
+	return []routing.PredicateSpec{
+		source.New(),
+		clientcert.New(),
+		conn.NewProtocol(),
+		conn.NewTLS(),
+		conn.NewTLSVersion(),
+		conn.NewSNI(),
+		conn.NewClientIP(),
+		interval.NewBetween(),
+		interval.NewBefore(),
+		interval.NewAfter(),
+		interval.NewCron(),
+		interval.NewWeekday(),
+		interval.NewTimeOfDay(),
+		cookie.New(),
+		cookie.NewVerified(),
+		query.New(),
+		traffic.New(),
+		traffic.NewHash(),
+		traffic.NewSegment(),
+	}
 }
 
 // Run skipper.
@@ -380,6 +581,16 @@ func Run(o Options) error {
 	// create a filter registry with the available filter specs registered,
 	// and register the custom filters
 	registry := builtin.MakeRegistry()
+	if o.OAuthTokeninfoURL != "" {
+		tic := oauth.NewIntrospectionClient(o.OAuthTokeninfoURL, oauth.IntrospectionOptions{
+			CredentialsDir: o.OAuthCredentialsDir,
+			Timeout:        o.OAuthTokeninfoTimeout})
+		registry.Register(authfilters.NewOAuthTokeninfoAnyScope(tic))
+		registry.Register(authfilters.NewOAuthTokeninfoAllScope(tic))
+		registry.Register(authfilters.NewOAuthTokeninfoAnyKV(tic))
+		registry.Register(authfilters.NewOAuthTokeninfoAllKV(tic))
+	}
+
 	for _, f := range o.CustomFilters {
 		registry.Register(f)
 	}
@@ -403,14 +614,7 @@ func Run(o Options) error {
 	}
 
 	// include bundeled custom predicates
-	o.CustomPredicates = append(o.CustomPredicates,
-		source.New(),
-		interval.NewBetween(),
-		interval.NewBefore(),
-		interval.NewAfter(),
-		cookie.New(),
-		query.New(),
-		traffic.New())
+	o.CustomPredicates = append(o.CustomPredicates, BundledPredicates()...)
 
 	// create a routing engine
 	routing := routing.New(routing.Options{
@@ -430,7 +634,22 @@ func Run(o Options) error {
 		IdleConnectionsPerHost: o.IdleConnectionsPerHost,
 		CloseIdleConnsPeriod:   o.CloseIdleConnsPeriod,
 		FlushInterval:          o.BackendFlushInterval,
-		ExperimentalUpgrade:    o.ExperimentalUpgrade}
+		ExperimentalUpgrade:    o.ExperimentalUpgrade,
+		Tracer:                 o.Tracer,
+		EndpointQuarantine:     o.EndpointQuarantine}
+
+	if proxyParams.Tracer == nil && o.OTLPEndpoint != "" {
+		exporter := tracing.NewOTLPExporter(tracing.OTLPOptions{
+			Endpoint:    o.OTLPEndpoint,
+			ServiceName: o.TracingServiceName,
+		})
+		defer exporter.Close()
+
+		proxyParams.Tracer = tracing.New(tracing.Options{
+			Exporter:    exporter,
+			SampleRatio: o.TracingSampleRatio,
+		})
+	}
 
 	if o.DebugListener != "" {
 		do := proxyParams
@@ -2,9 +2,11 @@ package skipper
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -208,3 +210,147 @@ func TestHTTPServer(t *testing.T) {
 		t.Fatalf("Failed to stream response body: %v", err)
 	}
 }
+
+func TestTLSConfigClientCerts(t *testing.T) {
+	o := Options{
+		CertPathTLS:        "fixtures/test.crt",
+		KeyPathTLS:         "fixtures/test.key",
+		ClientCAPathTLS:    "fixtures/test.crt",
+		ClientCertHostsTLS: map[string]string{"Internal.Example.org": "fixtures/test.crt"},
+	}
+
+	config, err := o.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.ClientAuth != tls.VerifyClientCertIfGiven || config.ClientCAs == nil {
+		t.Error("failed to set the optional client certificate verification")
+	}
+
+	hostConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "internal.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hostConfig == nil || hostConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Error("failed to require client certificates for the host")
+	}
+
+	if hostConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "www.example.org"}); err != nil || hostConfig != nil {
+		t.Error("unexpected configuration for other hosts", err)
+	}
+}
+
+func TestClientCertHostsHTTP2(t *testing.T) {
+	o := Options{
+		CertPathTLS:        "fixtures/test.crt",
+		KeyPathTLS:         "fixtures/test.key",
+		ClientCertHostsTLS: map[string]string{"internal.example.org": "fixtures/test.crt"},
+	}
+
+	config, err := o.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: config}
+	go server.ServeTLS(l, "", "")
+	defer server.Close()
+
+	cert, err := tls.LoadX509KeyPair("fixtures/test.crt", "fixtures/test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"www.example.org", "internal.example.org"} {
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{cert},
+			NextProtos:         []string{"h2", "http/1.1"}})
+		if err != nil {
+			t.Fatal(host, err)
+		}
+
+		if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+			t.Error(host, "failed to negotiate HTTP/2", p)
+		}
+
+		conn.Close()
+	}
+}
+
+func TestTLSConfigInvalidClientCA(t *testing.T) {
+	for _, o := range []Options{{
+		CertPathTLS:     "fixtures/test.crt",
+		KeyPathTLS:      "fixtures/test.key",
+		ClientCAPathTLS: "fixtures/notFound.crt",
+	}, {
+		CertPathTLS:        "fixtures/test.crt",
+		KeyPathTLS:         "fixtures/test.key",
+		ClientCertHostsTLS: map[string]string{"internal.example.org": "fixtures/test.key"},
+	}} {
+		if _, err := o.tlsConfig(); err == nil {
+			t.Error("failed to fail", o.ClientCAPathTLS, o.ClientCertHostsTLS)
+		}
+	}
+}
+
+func TestClientCertHostsHandler(t *testing.T) {
+	h := newClientCertHostsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		map[string]string{"Internal.Example.org": "fixtures/test.crt"})
+
+	verified := [][]*x509.Certificate{{{}}}
+	for _, ti := range []struct {
+		msg    string
+		host   string
+		tls    *tls.ConnectionState
+		status int
+	}{{
+		"unprotected host",
+		"www.example.org",
+		&tls.ConnectionState{ServerName: "www.example.org"},
+		http.StatusOK,
+	}, {
+		"verified",
+		"internal.example.org:9090",
+		&tls.ConnectionState{ServerName: "internal.example.org", VerifiedChains: verified},
+		http.StatusOK,
+	}, {
+		"different server name",
+		"internal.example.org",
+		&tls.ConnectionState{ServerName: "www.example.org"},
+		http.StatusMisdirectedRequest,
+	}, {
+		"different server name with a certificate verified by the default CAs",
+		"internal.example.org",
+		&tls.ConnectionState{ServerName: "www.example.org", VerifiedChains: verified},
+		http.StatusMisdirectedRequest,
+	}, {
+		"no server name",
+		"Internal.Example.org",
+		&tls.ConnectionState{},
+		http.StatusMisdirectedRequest,
+	}, {
+		"no verified certificate",
+		"internal.example.org",
+		&tls.ConnectionState{ServerName: "internal.example.org"},
+		http.StatusForbidden,
+	}} {
+		r := httptest.NewRequest("GET", "https://"+ti.host, nil)
+		r.TLS = ti.tls
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != ti.status {
+			t.Error(ti.msg, "unexpected status", w.Code, ti.status)
+		}
+	}
+}
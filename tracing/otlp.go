@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	DefaultOTLPServiceName   = "skipper"
+	DefaultOTLPBatchSize     = 512
+	DefaultOTLPQueueSize     = 1 << 12
+	DefaultOTLPFlushInterval = 5 * time.Second
+	DefaultOTLPTimeout       = 10 * time.Second
+
+	otlpTracesPath = "/v1/traces"
+
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+// Options of the OTLP exporter.
+type OTLPOptions struct {
+
+	// The address of the OTLP/HTTP receiver, e.g. http://localhost:4318.
+	// The spans are sent to the /v1/traces path.
+	Endpoint string
+
+	// The name of the service set in the resource of the spans. Defaults
+	// to skipper.
+	ServiceName string
+
+	// The maximum number of spans sent in one request.
+	BatchSize int
+
+	// The maximum number of spans waiting to be sent. When the queue
+	// is full, the new spans are dropped.
+	QueueSize int
+
+	// The maximum time the spans wait in the queue before sent.
+	FlushInterval time.Duration
+
+	// The timeout of the requests to the receiver.
+	Timeout time.Duration
+}
+
+// OTLPExporter sends the spans in batches to a receiver supporting the
+// OTLP/HTTP protocol with the JSON encoding, like the OpenTelemetry
+// collector or Jaeger.
+type OTLPExporter struct {
+	url           string
+	serviceName   string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	queue         chan *SpanData
+	quit          chan struct{}
+	done          chan struct{}
+	once          sync.Once
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// NewOTLPExporter creates an exporter, and starts sending the spans in the
+// background.
+func NewOTLPExporter(o OTLPOptions) *OTLPExporter {
+	if o.ServiceName == "" {
+		o.ServiceName = DefaultOTLPServiceName
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultOTLPBatchSize
+	}
+
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultOTLPQueueSize
+	}
+
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultOTLPFlushInterval
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultOTLPTimeout
+	}
+
+	e := &OTLPExporter{
+		url:           strings.TrimSuffix(o.Endpoint, "/") + otlpTracesPath,
+		serviceName:   o.ServiceName,
+		batchSize:     o.BatchSize,
+		flushInterval: o.FlushInterval,
+		client:        &http.Client{Timeout: o.Timeout},
+		queue:         make(chan *SpanData, o.QueueSize),
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go e.run()
+	return e
+}
+
+// Export queues a span to be sent. When the queue is full, the span is
+// dropped.
+func (e *OTLPExporter) Export(s *SpanData) {
+	select {
+	case e.queue <- s:
+	default:
+		log.Debug("tracing: queue full, dropping span")
+	}
+}
+
+// Close sends the queued spans, and stops the exporter.
+func (e *OTLPExporter) Close() {
+	e.once.Do(func() {
+		close(e.quit)
+		<-e.done
+	})
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	var batch []*SpanData
+	flush := func() {
+		if len(batch) > 0 {
+			if err := e.send(batch); err != nil {
+				log.Errorf("tracing: failed to export %d spans: %v", len(batch), err)
+			}
+
+			batch = nil
+		}
+	}
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.quit:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) >= e.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func toAttribute(key string, value interface{}) otlpAttribute {
+	switch v := value.(type) {
+	case string:
+		return stringAttribute(key, v)
+	case bool:
+		return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &v}}
+	case int:
+		return otlpAttribute{Key: key, Value: otlpValue{IntValue: strconv.Itoa(v)}}
+	case int64:
+		return otlpAttribute{Key: key, Value: otlpValue{IntValue: strconv.FormatInt(v, 10)}}
+	case float64:
+		return otlpAttribute{Key: key, Value: otlpValue{DoubleValue: &v}}
+	default:
+		return stringAttribute(key, fmt.Sprint(v))
+	}
+}
+
+func toOTLPSpan(s *SpanData) otlpSpan {
+	out := otlpSpan{
+		TraceID:           s.TraceID,
+		SpanID:            s.SpanID,
+		ParentSpanID:      s.ParentID,
+		Name:              s.Operation,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+
+	for k, v := range s.Tags {
+		switch k {
+		case SpanKindTag:
+			switch v {
+			case SpanKindServer:
+				out.Kind = otlpSpanKindServer
+			case SpanKindClient:
+				out.Kind = otlpSpanKindClient
+			}
+		case ErrorTag:
+			if v == true {
+				out.Status.Code = otlpStatusError
+			}
+		default:
+			out.Attributes = append(out.Attributes, toAttribute(k, v))
+		}
+	}
+
+	return out
+}
+
+func (e *OTLPExporter) send(batch []*SpanData) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = toOTLPSpan(s)
+	}
+
+	b, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			stringAttribute("service.name", e.serviceName),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: DefaultOTLPServiceName},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	rsp, err := e.client.Post(e.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code: %d", rsp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		requests []otlpRequest
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Error("invalid request", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		mx.Lock()
+		requests = append(requests, req)
+		mx.Unlock()
+	}))
+	defer receiver.Close()
+
+	e := NewOTLPExporter(OTLPOptions{
+		Endpoint:      receiver.URL + "/",
+		ServiceName:   "test-service",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+
+	tr := New(Options{Exporter: e, SampleRatio: 1})
+	s := tr.StartSpan("proxy", http.Header{})
+	s.SetTag(SpanKindTag, SpanKindServer)
+	s.SetTag(HTTPStatusCodeTag, 502)
+	s.SetTag(ErrorTag, true)
+
+	c := s.StartChild("backend")
+	c.SetTag(SpanKindTag, SpanKindClient)
+	c.SetTag(BackendHostTag, "10.0.0.1:8080")
+	c.Finish()
+	s.Finish()
+
+	tr.StartSpan("flushed on close", http.Header{}).Finish()
+	e.Close()
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(requests) != 2 {
+		t.Fatal("unexpected number of requests", len(requests))
+	}
+
+	rs := requests[0].ResourceSpans[0]
+	if len(rs.Resource.Attributes) != 1 || *rs.Resource.Attributes[0].Value.StringValue != "test-service" {
+		t.Error("invalid resource", rs.Resource)
+	}
+
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatal("unexpected number of spans", len(spans))
+	}
+
+	backend, proxy := spans[0], spans[1]
+	if backend.Kind != otlpSpanKindClient || backend.ParentSpanID != proxy.SpanID || backend.TraceID != proxy.TraceID {
+		t.Error("invalid backend span", backend)
+	}
+
+	if len(backend.Attributes) != 1 || backend.Attributes[0].Key != BackendHostTag {
+		t.Error("invalid backend span attributes", backend.Attributes)
+	}
+
+	if proxy.Kind != otlpSpanKindServer || proxy.Status.Code != otlpStatusError {
+		t.Error("invalid proxy span", proxy)
+	}
+
+	if len(proxy.Attributes) != 1 || proxy.Attributes[0].Value.IntValue != "502" {
+		t.Error("invalid proxy span attributes", proxy.Attributes)
+	}
+
+	if spans := requests[1].ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 1 || spans[0].Name != "flushed on close" {
+		t.Error("failed to flush the spans on close", spans)
+	}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TraceParentHeaderName = "traceparent"
+	TraceStateHeaderName  = "tracestate"
+
+	// FlagSampled is the trace flag signaling that the caller may have
+	// recorded the trace.
+	FlagSampled byte = 0x01
+
+	traceParentVersion = "00"
+	traceParentLength  = 55
+	traceIDLength      = 32
+	spanIDLength       = 16
+)
+
+// TraceParent holds the fields of a W3C traceparent header.
+type TraceParent struct {
+	TraceID  string
+	ParentID string
+	Flags    byte
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isHexID(s string, length int) bool {
+	return len(s) == length && isLowerHex(s) && strings.Trim(s, "0") != ""
+}
+
+// IsTraceID checks whether a string is a valid trace id: 32 lower case
+// hex digits, not all zero.
+func IsTraceID(s string) bool {
+	return isHexID(s, traceIDLength)
+}
+
+// ParseTraceParent parses a traceparent header. Headers with a future
+// version are accepted, as long as their known fields are valid.
+func ParseTraceParent(h string) (TraceParent, bool) {
+	if len(h) < traceParentLength {
+		return TraceParent{}, false
+	}
+
+	version := h[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return TraceParent{}, false
+	}
+
+	if version == traceParentVersion && len(h) != traceParentLength ||
+		len(h) > traceParentLength && h[traceParentLength] != '-' {
+		return TraceParent{}, false
+	}
+
+	if h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return TraceParent{}, false
+	}
+
+	traceID, parentID, flags := h[3:35], h[36:52], h[53:55]
+	if !isHexID(traceID, traceIDLength) || !isHexID(parentID, spanIDLength) || !isLowerHex(flags) {
+		return TraceParent{}, false
+	}
+
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return TraceParent{}, false
+	}
+
+	return TraceParent{TraceID: traceID, ParentID: parentID, Flags: byte(f)}, true
+}
+
+// String returns the traceparent header value, always with the version
+// 00.
+func (p TraceParent) String() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, p.TraceID, p.ParentID, p.Flags)
+}
+
+func newHexID(length int) string {
+	b := make([]byte, length/2)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+
+		// the all zero ids are invalid
+		if id := hex.EncodeToString(b); strings.Trim(id, "0") != "" {
+			return id
+		}
+	}
+}
+
+// NewTraceID generates a random trace id.
+func NewTraceID() string { return newHexID(traceIDLength) }
+
+// NewSpanID generates a random span id.
+func NewSpanID() string { return newHexID(spanIDLength) }
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceParent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	for _, ti := range []struct {
+		msg    string
+		header string
+		valid  bool
+	}{
+		{"empty", "", false},
+		{"valid", testTraceParent, true},
+		{"not sampled", "00-" + testTraceID + "-" + testParentID + "-00", true},
+		{"future version", "01-" + testTraceID + "-" + testParentID + "-01-future", true},
+		{"future version without separator", "01-" + testTraceID + "-" + testParentID + "-01future", false},
+		{"invalid version", "ff-" + testTraceID + "-" + testParentID + "-01", false},
+		{"version 00 too long", testTraceParent + "-future", false},
+		{"upper case", "00-" + strings.ToUpper(testTraceID) + "-" + testParentID + "-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + testParentID + "-01", false},
+		{"zero parent id", "00-" + testTraceID + "-0000000000000000-01", false},
+		{"invalid separator", "00_" + testTraceID + "-" + testParentID + "-01", false},
+		{"invalid flags", "00-" + testTraceID + "-" + testParentID + "-0x", false},
+	} {
+		p, ok := ParseTraceParent(ti.header)
+		if ok != ti.valid {
+			t.Error(ti.msg, "unexpected validation result", ok)
+			continue
+		}
+
+		if ok && (p.TraceID != testTraceID || p.ParentID != testParentID) {
+			t.Error(ti.msg, "failed to parse the ids", p)
+		}
+	}
+}
+
+func TestTraceParentString(t *testing.T) {
+	p := TraceParent{TraceID: testTraceID, ParentID: testParentID, Flags: FlagSampled}
+	if p.String() != testTraceParent {
+		t.Error("invalid traceparent", p.String())
+	}
+
+	if p, ok := ParseTraceParent("01-" + testTraceID + "-" + testParentID + "-01-future"); !ok || p.String() != testTraceParent {
+		t.Error("failed to downgrade the version", p.String())
+	}
+}
+
+func TestNewIDs(t *testing.T) {
+	if id := NewTraceID(); !IsTraceID(id) {
+		t.Error("invalid trace id", id)
+	}
+
+	if id := NewSpanID(); !isHexID(id, spanIDLength) {
+		t.Error("invalid span id", id)
+	}
+}
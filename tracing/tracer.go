@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SpanData contains the recorded data of a finished span.
+type SpanData struct {
+	TraceID   string
+	SpanID    string
+	ParentID  string
+	Operation string
+	Start     time.Time
+	End       time.Time
+	Tags      map[string]interface{}
+}
+
+// Exporter receives the finished spans of the sampled traces. Export is
+// called on the request path, so it should not block.
+type Exporter interface {
+	Export(*SpanData)
+}
+
+// Options to create a tracer.
+type Options struct {
+
+	// Receives the finished spans.
+	Exporter Exporter
+
+	// The ratio of the new traces that are sampled, between 0 and 1.
+	// With 0, which is the default, no new traces are sampled, and with
+	// 1, all of them are. The traces continued from the incoming
+	// requests follow the sampling decision of the caller.
+	SampleRatio float64
+}
+
+type tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+}
+
+type span struct {
+	tracer  *tracer
+	sampled bool
+	mx      sync.Mutex
+	data    SpanData
+}
+
+// New creates a tracer that records the spans, and propagates the trace
+// context in the W3C traceparent header.
+func New(o Options) Tracer {
+	if o.SampleRatio < 0 {
+		o.SampleRatio = 0
+	} else if o.SampleRatio > 1 {
+		o.SampleRatio = 1
+	}
+
+	return &tracer{exporter: o.Exporter, sampleRatio: o.SampleRatio}
+}
+
+func (t *tracer) newSpan(operation, traceID, parentID string, sampled bool) *span {
+	return &span{
+		tracer:  t,
+		sampled: sampled && t.exporter != nil,
+		data: SpanData{
+			TraceID:   traceID,
+			SpanID:    NewSpanID(),
+			ParentID:  parentID,
+			Operation: operation,
+			Start:     time.Now(),
+			Tags:      make(map[string]interface{}),
+		},
+	}
+}
+
+func (t *tracer) StartSpan(operation string, h http.Header) Span {
+	if p, ok := ParseTraceParent(h.Get(TraceParentHeaderName)); ok {
+		return t.newSpan(operation, p.TraceID, p.ParentID, p.Flags&FlagSampled != 0)
+	}
+
+	sampled := t.sampleRatio >= 1 || t.sampleRatio > 0 && rand.Float64() < t.sampleRatio
+	return t.newSpan(operation, NewTraceID(), "", sampled)
+}
+
+func (s *span) SetTag(key string, value interface{}) {
+	s.mx.Lock()
+	s.data.Tags[key] = value
+	s.mx.Unlock()
+}
+
+func (s *span) StartChild(operation string) Span {
+	return s.tracer.newSpan(operation, s.data.TraceID, s.data.SpanID, s.sampled)
+}
+
+func (s *span) Inject(h http.Header) {
+	var flags byte
+	if s.sampled {
+		flags = FlagSampled
+	}
+
+	h.Set(TraceParentHeaderName, TraceParent{
+		TraceID:  s.data.TraceID,
+		ParentID: s.data.SpanID,
+		Flags:    flags,
+	}.String())
+}
+
+func (s *span) Finish() {
+	if !s.sampled {
+		return
+	}
+
+	s.mx.Lock()
+	s.data.End = time.Now()
+	d := s.data
+	d.Tags = make(map[string]interface{}, len(s.data.Tags))
+	for k, v := range s.data.Tags {
+		d.Tags[k] = v
+	}
+
+	s.mx.Unlock()
+	s.tracer.exporter.Export(&d)
+}
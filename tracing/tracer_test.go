@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+type testExporter struct {
+	mx    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *testExporter) Export(s *SpanData) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func TestNoop(t *testing.T) {
+	s := Noop.StartSpan("test", http.Header{})
+	c := s.StartChild("child")
+	c.SetTag("foo", "bar")
+
+	h := http.Header{}
+	c.Inject(h)
+	if len(h) != 0 {
+		t.Error("unexpected headers", h)
+	}
+
+	c.Finish()
+	s.Finish()
+}
+
+func TestSpanFromContext(t *testing.T) {
+	ctx := &filtertest.Context{FStateBag: make(map[string]interface{})}
+	if SpanFromContext(ctx) != NoopSpan {
+		t.Error("failed to return the noop span")
+	}
+
+	s := New(Options{SampleRatio: 1}).StartSpan("test", http.Header{})
+	ctx.FStateBag[FilterSpanKey] = s
+	if SpanFromContext(ctx) != s {
+		t.Error("failed to return the span of the filter")
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	e := &testExporter{}
+	tr := New(Options{Exporter: e, SampleRatio: 1})
+
+	s := tr.StartSpan("root", http.Header{})
+	s.SetTag("foo", "bar")
+	c := s.StartChild("child")
+	c.Finish()
+	s.Finish()
+
+	if len(e.spans) != 2 {
+		t.Fatal("unexpected number of spans", len(e.spans))
+	}
+
+	child, root := e.spans[0], e.spans[1]
+	if !IsTraceID(root.TraceID) || root.ParentID != "" {
+		t.Error("invalid root span", root.TraceID, root.ParentID)
+	}
+
+	if child.TraceID != root.TraceID || child.ParentID != root.SpanID {
+		t.Error("invalid child span", child.TraceID, child.ParentID)
+	}
+
+	if root.Tags["foo"] != "bar" || root.End.Before(root.Start) {
+		t.Error("invalid span data", root.Tags, root.Start, root.End)
+	}
+
+	h := http.Header{}
+	c.Inject(h)
+	if p, ok := ParseTraceParent(h.Get(TraceParentHeaderName)); !ok ||
+		p.TraceID != root.TraceID || p.ParentID != child.SpanID || p.Flags != FlagSampled {
+		t.Error("invalid injected trace context", h.Get(TraceParentHeaderName))
+	}
+}
+
+func TestContinuesTrace(t *testing.T) {
+	for _, ti := range []struct {
+		msg     string
+		flags   string
+		sampled bool
+	}{
+		{"sampled", "01", true},
+		{"not sampled", "00", false},
+	} {
+		e := &testExporter{}
+		tr := New(Options{Exporter: e, SampleRatio: 1})
+
+		h := http.Header{}
+		h.Set(TraceParentHeaderName, "00-"+testTraceID+"-"+testParentID+"-"+ti.flags)
+		s := tr.StartSpan("root", h)
+
+		out := http.Header{}
+		s.Inject(out)
+		s.Finish()
+
+		p, ok := ParseTraceParent(out.Get(TraceParentHeaderName))
+		if !ok || p.TraceID != testTraceID || p.ParentID == testParentID || (p.Flags&FlagSampled != 0) != ti.sampled {
+			t.Error(ti.msg, "invalid injected trace context", out.Get(TraceParentHeaderName))
+		}
+
+		if (len(e.spans) == 1) != ti.sampled {
+			t.Error(ti.msg, "unexpected export", len(e.spans))
+			continue
+		}
+
+		if ti.sampled && e.spans[0].ParentID != testParentID {
+			t.Error(ti.msg, "invalid parent id", e.spans[0].ParentID)
+		}
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	e := &testExporter{}
+	tr := New(Options{Exporter: e, SampleRatio: 0.5})
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		tr.StartSpan("root", http.Header{}).Finish()
+	}
+
+	if len(e.spans) < n/4 || len(e.spans) > 3*n/4 {
+		t.Error("unexpected number of sampled spans", len(e.spans))
+	}
+}
+
+func TestSampleRatioZero(t *testing.T) {
+	e := &testExporter{}
+	tr := New(Options{Exporter: e})
+	for i := 0; i < 100; i++ {
+		tr.StartSpan("root", http.Header{}).Finish()
+	}
+
+	if len(e.spans) != 0 {
+		t.Error("unexpected sampled spans", len(e.spans))
+	}
+
+	h := http.Header{}
+	h.Set(TraceParentHeaderName, "00-"+testTraceID+"-"+testParentID+"-01")
+	tr.StartSpan("root", h).Finish()
+	if len(e.spans) != 1 {
+		t.Error("failed to follow the sampling decision of the caller")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Package tracing provides the interfaces used by skipper to trace the
+processing of the requests, and a tracer implementation propagating the
+trace context as defined by the W3C Trace Context specification
+(https://www.w3.org/TR/trace-context/).
+
+The proxy creates a span for every request, with child spans for the
+route lookup, for the request and the response filters, with one span
+for each filter, and for the backend round trip. The spans are tagged
+with the route id, the backend host, the status code and the error flag.
+
+Filters can create their own spans as the children of the span of the
+filter, that the proxy provides through the filter context:
+
+	span := tracing.SpanFromContext(ctx).StartChild("fetch_token")
+	defer span.Finish()
+
+The default tracer is a no-op implementation. The tracer created with
+New records the spans and passes them to an Exporter, e.g. to the OTLP
+exporter, that sends them to an OpenTelemetry collector, or to any other
+backend supporting the OTLP/HTTP protocol, like Jaeger:
+
+	exporter := tracing.NewOTLPExporter(tracing.OTLPOptions{Endpoint: "http://localhost:4318"})
+	tracer := tracing.New(tracing.Options{Exporter: exporter, SampleRatio: 0.1})
+
+Custom tracers can be used by implementing the Tracer and the Span
+interfaces.
+*/
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// FilterSpanKey is the key in the state bag of the filter context, where
+// the proxy stores the span of the currently executed filter. The filters
+// should use SpanFromContext to access it.
+const FilterSpanKey = "tracing:filterSpan"
+
+// Span tags set by the proxy.
+const (
+	SpanKindTag       = "span.kind"
+	ComponentTag      = "component"
+	HTTPMethodTag     = "http.method"
+	HTTPHostTag       = "http.host"
+	HTTPPathTag       = "http.path"
+	HTTPStatusCodeTag = "http.status_code"
+	ErrorTag          = "error"
+	RouteIDTag        = "skipper.route_id"
+	BackendHostTag    = "skipper.backend_host"
+	FilterNameTag     = "skipper.filter"
+
+	SpanKindServer = "server"
+	SpanKindClient = "client"
+)
+
+// Span is a timed operation, part of a trace.
+type Span interface {
+
+	// SetTag sets a tag of the span.
+	SetTag(key string, value interface{})
+
+	// StartChild starts a new span as the child of the span.
+	StartChild(operation string) Span
+
+	// Inject sets the trace context headers of the span, so that
+	// the called services can continue the trace.
+	Inject(http.Header)
+
+	// Finish ends the span.
+	Finish()
+}
+
+// Tracer creates the root spans of the requests.
+type Tracer interface {
+
+	// StartSpan starts a new span, continuing the trace of the
+	// incoming request when its headers contain a trace context.
+	StartSpan(operation string, h http.Header) Span
+}
+
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+// Noop is a tracer that doesn't record the spans. It is used by default.
+var Noop Tracer = noopTracer{}
+
+// NoopSpan is a span that doesn't record anything.
+var NoopSpan Span = noopSpan{}
+
+// SpanFromContext returns the span of the currently executed filter, or
+// NoopSpan, when the filter context doesn't provide one, e.g. in tests.
+func SpanFromContext(ctx filters.FilterContext) Span {
+	if s, ok := ctx.StateBag()[FilterSpanKey].(Span); ok {
+		return s
+	}
+
+	return NoopSpan
+}
+
+func (noopTracer) StartSpan(string, http.Header) Span { return NoopSpan }
+
+func (noopSpan) SetTag(string, interface{}) {}
+func (noopSpan) StartChild(string) Span     { return NoopSpan }
+func (noopSpan) Inject(http.Header)         {}
+func (noopSpan) Finish()                    {}